@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -28,7 +29,10 @@ Example:
 The threshold controls duplicate sensitivity:
   - 0.01: Very strict (only near-identical vectors)
   - 0.05: Balanced (recommended default)
-  - 0.10: Loose (more aggressive deduplication)`,
+  - 0.10: Loose (more aggressive deduplication)
+
+Results are reproducible: the default seed is fixed. Use --stream for files
+too large to load into memory.`,
 	RunE: runAnalyze,
 }
 
@@ -39,7 +43,9 @@ func init() {
 	analyzeCmd.Flags().Float64P("threshold", "t", 0.05, "cosine distance threshold for duplicates")
 	analyzeCmd.Flags().IntP("clusters", "k", 0, "number of clusters (0 = auto: sqrt(N/2))")
 	analyzeCmd.Flags().IntP("workers", "w", 0, "number of parallel workers (0 = NumCPU)")
-	analyzeCmd.Flags().Int64("seed", 0, "random seed for reproducibility (0 = random)")
+	analyzeCmd.Flags().Int64("seed", dedup.DefaultSeed, "random seed for clustering")
+	analyzeCmd.Flags().Bool("stream", false, "process the file in mini-batches with bounded memory")
+	analyzeCmd.Flags().Int("batch-size", 10000, "vectors per mini-batch (with --stream)")
 
 	_ = analyzeCmd.MarkFlagRequired("file")
 
@@ -53,6 +59,8 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 	clusters, _ := cmd.Flags().GetInt("clusters")
 	workers, _ := cmd.Flags().GetInt("workers")
 	seed, _ := cmd.Flags().GetInt64("seed")
+	stream, _ := cmd.Flags().GetBool("stream")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
 	verbose := viper.GetBool("verbose")
 
 	// Setup context with cancellation
@@ -68,6 +76,28 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	cfg := dedup.Config{
+		Threshold:     threshold,
+		K:             clusters,
+		MaxIterations: 10,
+		Workers:       workers,
+		Seed:          seed,
+		BatchSize:     batchSize,
+	}
+	engine := dedup.NewEngine(cfg)
+
+	if stream {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Streaming vectors from %s...\n", filePath)
+		}
+		result, err := streamDedupFile(ctx, engine, filePath, func(types.Vector) error { return nil })
+		if err != nil {
+			return fmt.Errorf("deduplication failed: %w", err)
+		}
+		printAnalysisReport(result, verbose)
+		return nil
+	}
+
 	// Load vectors from file
 	if verbose {
 		fmt.Fprintf(os.Stderr, "Loading vectors from %s...\n", filePath)
@@ -90,17 +120,6 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "Vector dimension: %d\n", vectors[0].Dimension())
 	}
 
-	// Configure deduplication engine
-	cfg := dedup.Config{
-		Threshold:     threshold,
-		K:             clusters,
-		MaxIterations: 10,
-		Workers:       workers,
-		Seed:          seed,
-	}
-
-	engine := dedup.NewEngine(cfg)
-
 	// Run deduplication
 	if verbose {
 		fmt.Fprintln(os.Stderr, "Running semantic deduplication...")
@@ -118,13 +137,54 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 }
 
 func loadVectorsFromFile(filePath string) ([]types.Vector, error) {
-	file, err := os.Open(filePath)
+	var vectors []types.Vector
+	err := readVectorsFromFile(filePath, func(v types.Vector) error {
+		vectors = append(vectors, v)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return vectors, nil
+}
+
+// streamDedupFile runs the engine's streaming dedup over a JSONL file,
+// calling emit for each unique vector.
+func streamDedupFile(ctx context.Context, engine *dedup.Engine, filePath string, emit func(types.Vector) error) (*types.DeduplicationResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan types.Vector, 1024)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		readErr <- readVectorsFromFile(filePath, func(v types.Vector) error {
+			select {
+			case in <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	result, err := engine.DeduplicateStream(ctx, in, emit)
+	cancel()
+	if rerr := <-readErr; rerr != nil && err == nil && !errors.Is(rerr, context.Canceled) {
+		return nil, rerr
+	}
+	return result, err
+}
+
+// readVectorsFromFile calls fn for each vector in a JSONL file, skipping
+// malformed lines and lines without an id or values.
+func readVectorsFromFile(filePath string, fn func(types.Vector) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
 	defer func() { _ = file.Close() }()
 
-	var vectors []types.Vector
 	scanner := bufio.NewScanner(file)
 
 	// Increase buffer for large lines
@@ -155,18 +215,16 @@ func loadVectorsFromFile(filePath string) ([]types.Vector, error) {
 			continue
 		}
 
-		vectors = append(vectors, types.Vector{
+		if err := fn(types.Vector{
 			ID:       v.ID,
 			Values:   v.Values,
 			Metadata: v.Metadata,
-		})
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		}); err != nil {
+			return err
+		}
 	}
 
-	return vectors, nil
+	return scanner.Err()
 }
 
 func printAnalysisReport(result *types.DeduplicationResult, verbose bool) {
@@ -174,7 +232,7 @@ func printAnalysisReport(result *types.DeduplicationResult, verbose bool) {
 	fmt.Println("=== Semantic Deduplication Analysis ===")
 	fmt.Println()
 	fmt.Printf("Total vectors analyzed:  %d\n", result.TotalProcessed)
-	fmt.Printf("Unique vectors:          %d\n", len(result.UniqueVectors))
+	fmt.Printf("Unique vectors:          %d\n", result.TotalProcessed-result.DuplicateCount)
 	fmt.Printf("Duplicates found:        %d\n", result.DuplicateCount)
 	fmt.Printf("Potential savings:       %.1f%%\n", result.SavingsPercent())
 	fmt.Println()
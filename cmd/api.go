@@ -18,7 +18,9 @@ import (
 	_ "github.com/Siddhant-K-code/distill/pkg/embedding/cohere"
 	_ "github.com/Siddhant-K-code/distill/pkg/embedding/ollama"
 	_ "github.com/Siddhant-K-code/distill/pkg/embedding/openai"
+	"github.com/Siddhant-K-code/distill/pkg/memory"
 	"github.com/Siddhant-K-code/distill/pkg/metrics"
+	"github.com/Siddhant-K-code/distill/pkg/pipeline"
 	"github.com/Siddhant-K-code/distill/pkg/sse"
 	"github.com/Siddhant-K-code/distill/pkg/telemetry"
 	"github.com/Siddhant-K-code/distill/pkg/types"
@@ -82,6 +84,24 @@ type DedupeOptions struct {
 	// so the dedup pipeline cannot reorder or remove them. This prevents
 	// Distill from silently invalidating Anthropic prompt cache prefixes.
 	PreserveCachePrefix bool `json:"preserve_cache_prefix,omitempty"`
+
+	// Method selects how duplicates are found: "embedding" clusters by
+	// embedding distance, "lexical" groups near-identical text without
+	// embeddings, and "hybrid" drops lexical near-duplicates before
+	// embedding the rest. Empty uses embedding when embeddings are supplied
+	// or a provider is configured, and lexical otherwise.
+	Method string `json:"method,omitempty"`
+
+	// AutoThreshold picks the clustering threshold from the gap in the
+	// nearest-neighbour distance histogram; threshold is the fallback when
+	// there is no clear gap. The value used is reported in stats.
+	AutoThreshold bool `json:"auto_threshold,omitempty"`
+
+	// LexicalThreshold is the minimum estimated Jaccard similarity for the
+	// lexical and hybrid methods to treat two chunks as near-duplicates.
+	// threshold is an embedding distance and does not apply to them.
+	// Default: 0.8.
+	LexicalThreshold float64 `json:"lexical_threshold,omitempty"`
 }
 
 // DedupeChunk represents a chunk in the request.
@@ -94,6 +114,15 @@ type DedupeChunk struct {
 	// this chunk is treated as a cache boundary marker. Used with
 	// options.preserve_cache_prefix to freeze the prefix during dedup.
 	CacheControl string    `json:"cache_control,omitempty"`
+
+	// Role, Timestamp (RFC 3339), and Importance (0–1) describe the chunk
+	// as a conversation turn for the pipeline's summarize stage.
+	Role       string  `json:"role,omitempty"`
+	Timestamp  string  `json:"timestamp,omitempty"`
+	Importance float64 `json:"importance,omitempty"`
+
+	// Metadata is passed through the pipeline and echoed in its response.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // DedupeResponse is the JSON response for /v1/dedupe.
@@ -124,6 +153,16 @@ type DedupeStats struct {
 	CachePrefixHash   string `json:"cache_prefix_hash,omitempty"`
 	SuffixInputCount  int    `json:"suffix_input_count,omitempty"`
 	SuffixOutputCount int    `json:"suffix_output_count,omitempty"`
+
+	// Method is the dedup method used; LexicalDuplicates counts chunks the
+	// hybrid method removed before embedding.
+	Method            string `json:"method,omitempty"`
+	LexicalDuplicates int    `json:"lexical_duplicates,omitempty"`
+
+	// Threshold is the clustering distance threshold used; ThresholdAuto
+	// reports whether options.auto_threshold picked it.
+	Threshold     float64 `json:"threshold,omitempty"`
+	ThresholdAuto bool    `json:"threshold_auto,omitempty"`
 }
 
 // APIServer holds the API server state.
@@ -233,6 +272,7 @@ func runAPI(cmd *cobra.Command, args []string) error {
 	mux.HandleFunc("/v1/dedupe/stream", m.Middleware("/v1/dedupe/stream", server.handleDedupeStream))
 
 	// Setup memory store (opt-in)
+	var memStore *memory.SQLiteStore
 	enableMemory, _ := cmd.Flags().GetBool("memory")
 	if enableMemory {
 		memDBPath := viper.GetString("memory.db_path")
@@ -243,7 +283,8 @@ func runAPI(cmd *cobra.Command, args []string) error {
 		if memThreshold == 0 {
 			memThreshold = 0.15
 		}
-		memStore, err := memoryStoreFromConfig(memDBPath, memThreshold)
+		var err error
+		memStore, err = memoryStoreFromConfig(memDBPath, memThreshold)
 		if err != nil {
 			return fmt.Errorf("failed to create memory store: %w", err)
 		}
@@ -266,12 +307,18 @@ func runAPI(cmd *cobra.Command, args []string) error {
 		}
 		defer func() { _ = sessStore.Close() }()
 
+		if memStore != nil {
+			configureSessionPromotion(sessStore, memStore)
+		}
+		stopExpiry := startSessionExpiry(sessStore)
+		defer stopExpiry()
+
 		sessAPI := &SessionAPI{store: sessStore}
 		sessAPI.RegisterSessionRoutes(mux, m.Middleware)
 	}
 
 	// Pipeline and batch routes.
-	pipelineAPI := NewPipelineAPI()
+	pipelineAPI := NewPipelineAPI(embedder)
 	pipelineAPI.RegisterPipelineRoutes(mux, m.Middleware)
 
 	mux.HandleFunc("/health", server.handleHealth)
@@ -466,6 +513,16 @@ func (s *APIServer) handleDedupe(w http.ResponseWriter, r *http.Request) {
 		dedupChunks = partition.Suffix
 	}
 
+	method, err := resolveDedupMethod(req.Options.Method, needsEmbedding, s.embedder != nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dedupChunks, lexicalDuplicates := lexicalPrefilter(method, dedupChunks, req.Options.LexicalThreshold)
+	if method == pipeline.DedupLexical || method == pipeline.DedupHybrid {
+		needsEmbedding = method == pipeline.DedupHybrid && missingEmbeddings(dedupChunks)
+	}
+
 	// Generate embeddings if needed (only for the dedup-eligible suffix).
 	if needsEmbedding {
 		if s.embedder == nil {
@@ -509,11 +566,7 @@ func (s *APIServer) handleDedupe(w http.ResponseWriter, r *http.Request) {
 
 	// Cluster the dedup-eligible suffix only.
 	_, clusterSpan := s.tracing.StartClustering(ctx, len(dedupChunks), threshold)
-	clusterer := contextlab.NewClusterer(contextlab.ClusterConfig{
-		Threshold: threshold,
-		Linkage:   "average",
-	})
-	clusterResult := clusterer.Cluster(dedupChunks)
+	clusterResult := clusterForDedup(method, dedupChunks, threshold, req.Options)
 	clusterSpan.End()
 
 	// Select representatives
@@ -566,6 +619,12 @@ func (s *APIServer) handleDedupe(w http.ResponseWriter, r *http.Request) {
 		ClusterCount: clusterResult.ClusterCount,
 		ReductionPct: reductionPct,
 		LatencyMs:    latency.Milliseconds(),
+
+		Method:            method,
+		LexicalDuplicates: lexicalDuplicates,
+
+		Threshold:     clusterResult.Threshold,
+		ThresholdAuto: clusterResult.ThresholdAuto,
 	}
 	if req.Options.PreserveCachePrefix && partition.MarkerCount > 0 {
 		stats.CachePrefixFrozen = true
@@ -618,6 +677,11 @@ func (s *APIServer) handleDedupeStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := pipeline.ValidateDedupMethod(req.Options.Method); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Initialize SSE writer
 	sw := sse.NewWriter(w)
 	if sw == nil {
@@ -657,6 +721,13 @@ func (s *APIServer) handleDedupeStream(w http.ResponseWriter, r *http.Request) {
 		dedupChunks = partition.Suffix
 	}
 
+	// The method was validated before the stream started.
+	method, _ := resolveDedupMethod(req.Options.Method, needsEmbedding, s.embedder != nil)
+	dedupChunks, lexicalDuplicates := lexicalPrefilter(method, dedupChunks, req.Options.LexicalThreshold)
+	if method == pipeline.DedupLexical || method == pipeline.DedupHybrid {
+		needsEmbedding = method == pipeline.DedupHybrid && missingEmbeddings(dedupChunks)
+	}
+
 	// Stage 1: Embedding (suffix only).
 	if needsEmbedding {
 		if s.embedder == nil {
@@ -706,11 +777,7 @@ func (s *APIServer) handleDedupeStream(w http.ResponseWriter, r *http.Request) {
 	_ = sw.SendProgress(sse.StageClustering, 0)
 
 	_, clusterSpan := s.tracing.StartClustering(ctx, len(dedupChunks), threshold)
-	clusterer := contextlab.NewClusterer(contextlab.ClusterConfig{
-		Threshold: threshold,
-		Linkage:   "average",
-	})
-	clusterResult := clusterer.Cluster(dedupChunks)
+	clusterResult := clusterForDedup(method, dedupChunks, threshold, req.Options)
 	clusterSpan.End()
 
 	_ = sw.SendProgressWithStats(sse.StageClustering, 1.0, map[string]interface{}{
@@ -779,6 +846,12 @@ func (s *APIServer) handleDedupeStream(w http.ResponseWriter, r *http.Request) {
 		ClusterCount: clusterResult.ClusterCount,
 		ReductionPct: reductionPct,
 		LatencyMs:    latency.Milliseconds(),
+
+		Method:            method,
+		LexicalDuplicates: lexicalDuplicates,
+
+		Threshold:     clusterResult.Threshold,
+		ThresholdAuto: clusterResult.ThresholdAuto,
 	}
 	if req.Options.PreserveCachePrefix && partition.MarkerCount > 0 {
 		stats.CachePrefixFrozen = true
@@ -794,6 +867,67 @@ func (s *APIServer) handleDedupeStream(w http.ResponseWriter, r *http.Request) {
 	_ = sw.SendComplete(outputChunks, stats)
 }
 
+// resolveDedupMethod validates the requested dedup method and resolves
+// the default: embedding when embeddings are supplied or can be fetched,
+// lexical otherwise.
+func resolveDedupMethod(method string, needsEmbedding, hasEmbedder bool) (string, error) {
+	if err := pipeline.ValidateDedupMethod(method); err != nil {
+		return "", err
+	}
+	if method == pipeline.DedupAuto {
+		if needsEmbedding && !hasEmbedder {
+			return pipeline.DedupLexical, nil
+		}
+		return pipeline.DedupEmbedding, nil
+	}
+	return method, nil
+}
+
+// lexicalPrefilter drops lexical near-duplicates for the hybrid method so
+// they are never embedded, returning the remaining chunks and how many
+// were dropped.
+func lexicalPrefilter(method string, chunks []types.Chunk, lexicalThreshold float64) ([]types.Chunk, int) {
+	if method != pipeline.DedupHybrid {
+		return chunks, 0
+	}
+	kept := nearDupDetector(lexicalThreshold).Filter(chunks)
+	return kept, len(chunks) - len(kept)
+}
+
+// clusterForDedup clusters chunks by embedding distance, or by lexical
+// similarity for the lexical method.
+func clusterForDedup(method string, chunks []types.Chunk, threshold float64, opts DedupeOptions) *types.ClusterResult {
+	if method == pipeline.DedupLexical {
+		return nearDupDetector(opts.LexicalThreshold).Cluster(chunks)
+	}
+	clusterer := contextlab.NewClusterer(contextlab.ClusterConfig{
+		Threshold:     threshold,
+		AutoThreshold: opts.AutoThreshold,
+		Linkage:       "average",
+	})
+	return clusterer.Cluster(chunks)
+}
+
+// nearDupDetector returns the lexical detector for a request's
+// lexical_threshold; zero keeps the default.
+func nearDupDetector(lexicalThreshold float64) *contextlab.NearDupDetector {
+	cfg := contextlab.DefaultNearDupConfig()
+	if lexicalThreshold > 0 {
+		cfg.Threshold = lexicalThreshold
+	}
+	return contextlab.NewNearDupDetector(cfg)
+}
+
+// missingEmbeddings reports whether any chunk lacks an embedding.
+func missingEmbeddings(chunks []types.Chunk) bool {
+	for _, c := range chunks {
+		if len(c.Embedding) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -6,7 +6,11 @@ import (
 	"strings"
 
 	"github.com/Siddhant-K-code/distill/pkg/batch"
+	"github.com/Siddhant-K-code/distill/pkg/compress"
+	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	"github.com/Siddhant-K-code/distill/pkg/embedding"
 	"github.com/Siddhant-K-code/distill/pkg/pipeline"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -21,6 +25,22 @@ type PipelineOptions struct {
 	Dedup     PipelineDedupOptions     `json:"dedup,omitempty"`
 	Compress  PipelineCompressOptions  `json:"compress,omitempty"`
 	Summarize PipelineSummarizeOptions `json:"summarize,omitempty"`
+
+	// Tokenizer names the token counter for this request (e.g.
+	// "cl100k_base"). Empty uses the server default.
+	Tokenizer string `json:"tokenizer,omitempty"`
+
+	// MaxTokens is an overall token budget. The pipeline allocates it
+	// across chunks by score and drops the lowest-utility ones last.
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// Query is the question the chunks are retrieved for. Extractive
+	// compression keeps the sentences most relevant to it.
+	Query string `json:"query,omitempty"`
+
+	// QueryEmbedding is the precomputed query embedding. When omitted the
+	// server embeds Query with its configured provider, if any.
+	QueryEmbedding []float32 `json:"query_embedding,omitempty"`
 }
 
 type PipelineDedupOptions struct {
@@ -28,11 +48,30 @@ type PipelineDedupOptions struct {
 	Threshold float64 `json:"threshold,omitempty"`
 	Lambda    float64 `json:"lambda,omitempty"`
 	TargetK   int     `json:"target_k,omitempty"`
+
+	// Method is embedding, lexical, or hybrid; empty picks embedding when
+	// every chunk has one and lexical otherwise.
+	Method string `json:"method,omitempty"`
+
+	// AutoThreshold picks the threshold from the gap in the chunks'
+	// nearest-neighbour distance histogram; Threshold is the fallback.
+	AutoThreshold bool `json:"auto_threshold,omitempty"`
+
+	// Metadata constraints on the kept chunks: source_field,
+	// max_per_source, cover_field, freshness_field, freshness_half_life,
+	// and freshness_weight.
+	pipeline.SelectionParams
 }
 
 type PipelineCompressOptions struct {
 	Enabled         bool    `json:"enabled"`
 	TargetReduction float64 `json:"target_reduction,omitempty"`
+
+	// Mode is one of extractive (default), placeholder, hybrid, pruner, auto, code, log.
+	Mode string `json:"mode,omitempty"`
+
+	// Compressors lists modes to apply in order; overrides Mode.
+	Compressors []string `json:"compressors,omitempty"`
 }
 
 type PipelineSummarizeOptions struct {
@@ -43,8 +82,22 @@ type PipelineSummarizeOptions struct {
 
 // PipelineResponse is the JSON response for POST /v1/pipeline.
 type PipelineResponse struct {
-	Chunks []DedupeChunk        `json:"chunks"`
-	Stats  PipelineStatsPayload `json:"stats"`
+	Chunks  []DedupeChunk        `json:"chunks"`
+	Stats   PipelineStatsPayload `json:"stats"`
+	Explain []ChunkExplanation   `json:"explain,omitempty"`
+}
+
+// ChunkExplanation is the serialisable form of pipeline.ChunkReport,
+// returned per input chunk when /v1/pipeline is called with ?explain=true.
+type ChunkExplanation struct {
+	ID             string          `json:"id"`
+	Status         string          `json:"status"`
+	OriginalHash   string          `json:"original_hash,omitempty"`
+	OriginalTokens int             `json:"original_tokens"`
+	FinalTokens    int             `json:"final_tokens"`
+	Compressors    []string        `json:"compressors,omitempty"`
+	Kept           []compress.Span `json:"kept,omitempty"`
+	Diff           string          `json:"diff,omitempty"`
 }
 
 // PipelineStatsPayload is the serialisable form of pipeline.Stats.
@@ -54,6 +107,50 @@ type PipelineStatsPayload struct {
 	TotalReduction float64                   `json:"total_reduction"`
 	LatencyMs      float64                   `json:"latency_ms"`
 	Stages         map[string]StageStatsPL   `json:"stages"`
+	StageOrder     []string                  `json:"stage_order,omitempty"`
+	Budget         *BudgetStatsPL            `json:"budget,omitempty"`
+	Summarize      *SummarizeStatsPL         `json:"summarize,omitempty"`
+	Dedup          *DedupStatsPL             `json:"dedup,omitempty"`
+}
+
+// DedupStatsPL is the serialisable form of pipeline.DedupStats.
+type DedupStatsPL struct {
+	Method        string  `json:"method"`
+	Clusters      int     `json:"clusters"`
+	Threshold     float64 `json:"threshold,omitempty"`
+	ThresholdAuto bool    `json:"threshold_auto,omitempty"`
+}
+
+// SummarizeStatsPL is the serialisable form of summarize.SummarizeStats.
+type SummarizeStatsPL struct {
+	InputTurns      int     `json:"input_turns"`
+	OutputTurns     int     `json:"output_turns"`
+	InputTokens     int     `json:"input_tokens"`
+	OutputTokens    int     `json:"output_tokens"`
+	CompressedTurns int     `json:"compressed_turns"`
+	PreservedTurns  int     `json:"preserved_turns"`
+	ReductionPct    float64 `json:"reduction_pct"`
+	LatencyMs       float64 `json:"latency_ms"`
+}
+
+// BudgetStatsPL is the serialisable form of pipeline.BudgetStats.
+type BudgetStatsPL struct {
+	MaxTokens     int                 `json:"max_tokens"`
+	InputTokens   int                 `json:"input_tokens"`
+	UsedTokens    int                 `json:"used_tokens"`
+	DroppedChunks int                 `json:"dropped_chunks"`
+	Allocations   []ChunkAllocationPL `json:"allocations"`
+}
+
+// ChunkAllocationPL is the serialisable form of pipeline.ChunkAllocation.
+type ChunkAllocationPL struct {
+	ID           string  `json:"id"`
+	Utility      float64 `json:"utility"`
+	InputTokens  int     `json:"input_tokens"`
+	Allocated    int     `json:"allocated"`
+	KeepRatio    float64 `json:"keep_ratio"`
+	OutputTokens int     `json:"output_tokens"`
+	Dropped      bool    `json:"dropped"`
 }
 
 // StageStatsPL is the serialisable form of pipeline.StageStats.
@@ -99,12 +196,15 @@ type BatchResultsResponse struct {
 // PipelineAPI holds the pipeline runner and batch processor.
 type PipelineAPI struct {
 	processor *batch.Processor
+	embedder  embedding.Provider // optional; used for query-conditioned compression
 }
 
 // NewPipelineAPI creates a PipelineAPI with a default batch processor.
-func NewPipelineAPI() *PipelineAPI {
+// embedder may be nil.
+func NewPipelineAPI(embedder embedding.Provider) *PipelineAPI {
 	return &PipelineAPI{
 		processor: batch.NewProcessor(batch.DefaultConfig()),
+		embedder:  embedder,
 	}
 }
 
@@ -129,7 +229,13 @@ func (a *PipelineAPI) handlePipeline(w http.ResponseWriter, r *http.Request) {
 	}
 
 	chunks := dedupeChunksToTypes(req.Chunks)
-	opts := pipelineOptsFromRequest(req.Options)
+	opts, err := a.requestOptions(r, req.Options)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	explain := r.URL.Query().Get("explain") == "true"
+	opts.Explain = explain
 
 	runner := pipeline.New()
 	result, stats, err := runner.Run(r.Context(), chunks, opts)
@@ -142,10 +248,30 @@ func (a *PipelineAPI) handlePipeline(w http.ResponseWriter, r *http.Request) {
 		Chunks: typesToDedupeChunks(result),
 		Stats:  marshalStats(stats),
 	}
+	if explain {
+		resp.Explain = marshalExplain(pipeline.Explain(chunks, result, opts.Tokenizer))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// requestOptions builds pipeline options from a request body and the
+// ?profile= query parameter, which replaces the fixed stages with a
+// profile from distill.yaml.
+func (a *PipelineAPI) requestOptions(r *http.Request, o PipelineOptions) (pipeline.Options, error) {
+	opts, err := pipelineOptsFromRequest(o)
+	if err != nil {
+		return opts, err
+	}
+	opts.Embedder = a.embedder
+	if profile := r.URL.Query().Get("profile"); profile != "" {
+		if opts.Stages, err = pipelineProfile(profile); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
 // handleBatchSubmit accepts a new batch job.
 func (a *PipelineAPI) handleBatchSubmit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -159,9 +285,15 @@ func (a *PipelineAPI) handleBatchSubmit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	opts, err := a.requestOptions(r, req.Options)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	job, err := a.processor.Submit(batch.SubmitRequest{
 		Chunks:  dedupeChunksToTypes(req.Chunks),
-		Options: pipelineOptsFromRequest(req.Options),
+		Options: opts,
 	})
 	if err != nil {
 		http.Error(w, "submit error: "+err.Error(), http.StatusServiceUnavailable)
@@ -246,44 +378,153 @@ func (a *PipelineAPI) handleBatchResults(w http.ResponseWriter, _ *http.Request,
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
+// dedupeChunksToTypes converts request chunks, folding the turn fields into
+// metadata under the keys the pipeline reads.
 func dedupeChunksToTypes(in []DedupeChunk) []types.Chunk {
 	out := make([]types.Chunk, len(in))
 	for i, c := range in {
+		var meta map[string]interface{}
+		if len(c.Metadata) > 0 || c.Role != "" || c.Timestamp != "" || c.Importance > 0 {
+			meta = make(map[string]interface{}, len(c.Metadata)+3)
+			for k, v := range c.Metadata {
+				meta[k] = v
+			}
+		}
+		if c.Role != "" {
+			meta[pipeline.RoleMetadataKey] = c.Role
+		}
+		if c.Timestamp != "" {
+			meta[pipeline.TimestampMetadataKey] = c.Timestamp
+		}
+		if c.Importance > 0 {
+			meta[pipeline.ImportanceMetadataKey] = c.Importance
+		}
 		out[i] = types.Chunk{
 			ID:        c.ID,
 			Text:      c.Text,
 			Embedding: c.Embedding,
 			Score:     c.Score,
+			Metadata:  meta,
 		}
 	}
 	return out
 }
 
+// typesToDedupeChunks converts result chunks for a response. Turn fields
+// are lifted back out of metadata; provenance is reported via ?explain.
 func typesToDedupeChunks(in []types.Chunk) []DedupeChunk {
 	out := make([]DedupeChunk, len(in))
 	for i, c := range in {
-		out[i] = DedupeChunk{
+		dc := DedupeChunk{
 			ID:        c.ID,
 			Text:      c.Text,
 			Embedding: c.Embedding,
 			Score:     c.Score,
 		}
+		for k, v := range c.Metadata {
+			switch k {
+			case compress.ProvenanceMetadataKey:
+				continue
+			case pipeline.RoleMetadataKey:
+				if s, ok := v.(string); ok {
+					dc.Role = s
+					continue
+				}
+			case pipeline.TimestampMetadataKey:
+				if s, ok := v.(string); ok {
+					dc.Timestamp = s
+					continue
+				}
+			case pipeline.ImportanceMetadataKey:
+				if f, ok := v.(float64); ok {
+					dc.Importance = f
+					continue
+				}
+			}
+			if dc.Metadata == nil {
+				dc.Metadata = make(map[string]interface{})
+			}
+			dc.Metadata[k] = v
+		}
+		out[i] = dc
 	}
 	return out
 }
 
-func pipelineOptsFromRequest(o PipelineOptions) pipeline.Options {
+func marshalExplain(reports []pipeline.ChunkReport) []ChunkExplanation {
+	out := make([]ChunkExplanation, len(reports))
+	for i, r := range reports {
+		out[i] = ChunkExplanation{
+			ID:             r.ID,
+			Status:         r.Status,
+			OriginalHash:   r.OriginalHash,
+			OriginalTokens: r.OriginalTokens,
+			FinalTokens:    r.FinalTokens,
+			Compressors:    r.Compressors,
+			Kept:           r.Kept,
+			Diff:           r.Diff,
+		}
+	}
+	return out
+}
+
+func pipelineOptsFromRequest(o PipelineOptions) (pipeline.Options, error) {
+	counter, err := tokenizer.Get(o.Tokenizer)
+	if err != nil {
+		return pipeline.Options{}, err
+	}
+	mode, chain, err := compressModesFromRequest(o.Compress)
+	if err != nil {
+		return pipeline.Options{}, err
+	}
+	if err := pipeline.ValidateDedupMethod(o.Dedup.Method); err != nil {
+		return pipeline.Options{}, err
+	}
+	constraints, err := o.Dedup.Apply(contextlab.SelectionConstraints{})
+	if err != nil {
+		return pipeline.Options{}, err
+	}
 	return pipeline.Options{
 		DedupEnabled:            o.Dedup.Enabled,
 		DedupThreshold:          o.Dedup.Threshold,
 		DedupLambda:             o.Dedup.Lambda,
 		DedupTargetK:            o.Dedup.TargetK,
+		DedupMethod:             o.Dedup.Method,
+		DedupAutoThreshold:      o.Dedup.AutoThreshold,
+		DedupConstraints:        constraints,
 		CompressEnabled:         o.Compress.Enabled,
 		CompressTargetReduction: o.Compress.TargetReduction,
+		CompressMode:            mode,
+		CompressChain:           chain,
 		SummarizeEnabled:        o.Summarize.Enabled,
 		SummarizeMaxTokens:      o.Summarize.MaxTokens,
 		SummarizeRecent:         o.Summarize.KeepRecent,
+		Summarizer:              configuredSummarizer(),
+		MaxTokens:               o.MaxTokens,
+		Query:                   o.Query,
+		QueryEmbedding:          o.QueryEmbedding,
+		Tokenizer:               counter,
+	}, nil
+}
+
+// compressModesFromRequest validates the requested compression mode and
+// compressor chain.
+func compressModesFromRequest(o PipelineCompressOptions) (compress.Mode, []compress.Mode, error) {
+	mode := compress.Mode(o.Mode)
+	if mode != "" {
+		if _, err := compress.ForMode(mode); err != nil {
+			return "", nil, err
+		}
+	}
+	var chain []compress.Mode
+	for _, name := range o.Compressors {
+		m := compress.Mode(name)
+		if _, err := compress.ForMode(m); err != nil {
+			return "", nil, err
+		}
+		chain = append(chain, m)
 	}
+	return mode, chain, nil
 }
 
 func marshalStats(s pipeline.Stats) PipelineStatsPayload {
@@ -297,11 +538,54 @@ func marshalStats(s pipeline.Stats) PipelineStatsPayload {
 			LatencyMs:    float64(v.Latency.Microseconds()) / 1000.0,
 		}
 	}
-	return PipelineStatsPayload{
+	payload := PipelineStatsPayload{
 		OriginalTokens: s.OriginalTokens,
 		FinalTokens:    s.FinalTokens,
 		TotalReduction: s.TotalReduction,
 		LatencyMs:      float64(s.TotalLatency.Microseconds()) / 1000.0,
 		Stages:         stages,
+		StageOrder:     s.StageOrder,
+	}
+	if b := s.Budget; b != nil {
+		allocs := make([]ChunkAllocationPL, len(b.Allocations))
+		for i, a := range b.Allocations {
+			allocs[i] = ChunkAllocationPL{
+				ID:           a.ID,
+				Utility:      a.Utility,
+				InputTokens:  a.InputTokens,
+				Allocated:    a.Allocated,
+				KeepRatio:    a.KeepRatio,
+				OutputTokens: a.OutputTokens,
+				Dropped:      a.Dropped,
+			}
+		}
+		payload.Budget = &BudgetStatsPL{
+			MaxTokens:     b.MaxTokens,
+			InputTokens:   b.InputTokens,
+			UsedTokens:    b.UsedTokens,
+			DroppedChunks: b.DroppedChunks,
+			Allocations:   allocs,
+		}
+	}
+	if ss := s.Summarize; ss != nil {
+		payload.Summarize = &SummarizeStatsPL{
+			InputTurns:      ss.InputTurns,
+			OutputTurns:     ss.OutputTurns,
+			InputTokens:     ss.InputTokens,
+			OutputTokens:    ss.OutputTokens,
+			CompressedTurns: ss.CompressedTurns,
+			PreservedTurns:  ss.PreservedTurns,
+			ReductionPct:    ss.ReductionPct,
+			LatencyMs:       float64(ss.Latency.Microseconds()) / 1000.0,
+		}
+	}
+	if d := s.Dedup; d != nil {
+		payload.Dedup = &DedupStatsPL{
+			Method:        d.Method,
+			Clusters:      d.Clusters,
+			Threshold:     d.Threshold,
+			ThresholdAuto: d.ThresholdAuto,
+		}
 	}
+	return payload
 }
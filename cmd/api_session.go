@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/Siddhant-K-code/distill/pkg/session"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 )
 
 // SessionAPI handles session-related HTTP endpoints.
@@ -39,6 +41,10 @@ func (s *SessionAPI) handleCreate(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
+		if errors.Is(err, tokenizer.ErrUnknownTokenizer) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -74,6 +80,14 @@ func (s *SessionAPI) handlePush(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
 			return
 		}
+		if err == session.ErrUnknownFormat {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == session.ErrVersionConflict {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -98,6 +112,7 @@ func (s *SessionAPI) handleContext(w http.ResponseWriter, r *http.Request) {
 	} else {
 		req.SessionID = r.URL.Query().Get("session_id")
 		req.Role = r.URL.Query().Get("role")
+		req.Format = r.URL.Query().Get("format")
 	}
 
 	if req.SessionID == "" {
@@ -111,6 +126,10 @@ func (s *SessionAPI) handleContext(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if err == session.ErrUnknownFormat {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
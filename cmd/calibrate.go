@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Recommend dedup thresholds for a corpus and embedding model",
+	Long: `Samples a corpus, shows the distribution of each chunk's distance to its
+nearest neighbour, and recommends a dedup threshold.
+
+The recommendation is the middle of the gap between duplicates and distinct
+chunks, the same value --auto-threshold picks per request. With --label,
+you judge pairs near that threshold as duplicates or not and the threshold
+is fitted to your answers.
+
+Input is a JSON array of chunks (--input or stdin, as for distill pipeline)
+or a JSONL vector file (--file, as for distill analyze). Chunks are
+embedded with each --model; without one their own embeddings are used.
+
+Example:
+  distill calibrate --input chunks.json
+  distill calibrate --input chunks.json --model text-embedding-3-small --model text-embedding-3-large
+  distill calibrate --file vectors.jsonl --label 20`,
+	RunE: runCalibrate,
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.Flags().String("input", "", "Input JSON file of chunks (default: stdin)")
+	calibrateCmd.Flags().StringP("file", "f", "", "JSONL vector file to read instead of --input")
+	calibrateCmd.Flags().Int("sample", 2000, "Chunks to sample from the corpus (0 = all)")
+	calibrateCmd.Flags().Int64("seed", 1, "Random seed for sampling")
+	calibrateCmd.Flags().StringSlice("model", nil, "Embedding model to calibrate; repeat to compare models (default: the input's embeddings)")
+	calibrateCmd.Flags().String("embedding-provider", "", "Embedding provider for --model (openai, ollama, cohere)")
+	calibrateCmd.Flags().String("openai-key", "", "API key for --model (or OPENAI_API_KEY / COHERE_API_KEY)")
+	calibrateCmd.Flags().Int("label", 0, "Number of pairs near the threshold to label interactively")
+	calibrateCmd.Flags().Float64("threshold", 0.15, "Threshold to label around when there is no clear gap")
+	calibrateCmd.Flags().Bool("json", false, "Print the reports as JSON")
+}
+
+// CalibrationReport is the result of calibrating one embedding model.
+type CalibrationReport struct {
+	Model       string             `json:"model"`
+	Chunks      int                `json:"chunks"`
+	Percentiles map[string]float64 `json:"percentiles"`
+	Histogram   []int              `json:"histogram"`
+	BinWidth    float64            `json:"bin_width"`
+
+	// AutoThreshold is the gap threshold; zero when there is no clear gap.
+	AutoThreshold float64 `json:"auto_threshold,omitempty"`
+
+	// Labeled and LabelAccuracy describe the fit to labelled pairs.
+	Labeled        int     `json:"labeled,omitempty"`
+	LabelAccuracy  float64 `json:"label_accuracy,omitempty"`
+	LabelThreshold float64 `json:"label_threshold,omitempty"`
+
+	// Recommended is the labelled fit if any, else the gap threshold;
+	// zero when neither is available.
+	Recommended float64 `json:"recommended,omitempty"`
+}
+
+// calibrationBinWidth and calibrationMax shape the printed histogram.
+const (
+	calibrationBinWidth = 0.02
+	calibrationMax      = 0.6
+)
+
+func runCalibrate(cmd *cobra.Command, _ []string) error {
+	inputFile, _ := cmd.Flags().GetString("input")
+	vectorFile, _ := cmd.Flags().GetString("file")
+	sampleSize, _ := cmd.Flags().GetInt("sample")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	models, _ := cmd.Flags().GetStringSlice("model")
+	labelCount, _ := cmd.Flags().GetInt("label")
+	fallback, _ := cmd.Flags().GetFloat64("threshold")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	chunks, err := loadCalibrationChunks(inputFile, vectorFile)
+	if err != nil {
+		return err
+	}
+	if labelCount > 0 && inputFile == "" && vectorFile == "" {
+		return fmt.Errorf("--label reads answers from stdin; pass the corpus with --input or --file")
+	}
+	chunks = sampleChunks(chunks, sampleSize, seed)
+	if len(chunks) < 2 {
+		return fmt.Errorf("need at least 2 chunks to calibrate, got %d", len(chunks))
+	}
+
+	ctx := context.Background()
+	if len(models) == 0 {
+		models = []string{""}
+	}
+	answers := bufio.NewReader(os.Stdin)
+	var reports []CalibrationReport
+	for _, model := range models {
+		embeddings, name, err := calibrationEmbeddings(ctx, cmd, chunks, model)
+		if err != nil {
+			return err
+		}
+
+		neighbors := contextlab.NearestNeighbors(embeddings)
+		if len(neighbors) == 0 {
+			return fmt.Errorf("%s: need at least 2 chunks with embeddings", name)
+		}
+		report := calibrationReport(name, neighbors)
+
+		if labelCount > 0 {
+			around := report.AutoThreshold
+			if around == 0 {
+				around = fallback
+			}
+			fmt.Fprintf(os.Stderr, "\nLabelling pairs for %s near %.3f. Duplicates? [y]es, [n]o, [s]kip, [q]uit\n", name, around)
+			labels, err := labelPairs(chunks, neighbors, around, labelCount, answers, os.Stderr)
+			if err != nil {
+				return err
+			}
+			if len(labels) > 0 {
+				threshold, correct := contextlab.FitThreshold(labels)
+				report.Labeled = len(labels)
+				report.LabelAccuracy = float64(correct) / float64(len(labels))
+				report.LabelThreshold = threshold
+				report.Recommended = threshold
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, r := range reports {
+		printCalibrationReport(r)
+	}
+	return nil
+}
+
+// loadCalibrationChunks reads chunks from a JSONL vector file, a JSON
+// chunk file, or stdin.
+func loadCalibrationChunks(inputFile, vectorFile string) ([]types.Chunk, error) {
+	if vectorFile != "" {
+		var chunks []types.Chunk
+		err := readVectorsFromFile(vectorFile, func(v types.Vector) error {
+			chunks = append(chunks, types.Chunk{
+				ID:        v.ID,
+				Text:      vectorText(v),
+				Embedding: v.Values,
+				Metadata:  v.Metadata,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading vectors: %w", err)
+		}
+		return chunks, nil
+	}
+
+	var raw []byte
+	var err error
+	if inputFile != "" {
+		raw, err = os.ReadFile(inputFile)
+	} else {
+		raw, err = readStdin()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	var chunks []types.Chunk
+	if err := json.Unmarshal(raw, &chunks); err != nil {
+		return nil, fmt.Errorf("parsing input JSON: %w", err)
+	}
+	return chunks, nil
+}
+
+// vectorText returns a vector's text from its metadata, if stored there.
+func vectorText(v types.Vector) string {
+	for _, key := range []string{"text", "content"} {
+		if s, ok := v.Metadata[key].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// sampleChunks returns n chunks chosen at random, in their input order.
+func sampleChunks(chunks []types.Chunk, n int, seed int64) []types.Chunk {
+	if n <= 0 || len(chunks) <= n {
+		return chunks
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(chunks))[:n]
+	sort.Ints(picked)
+	out := make([]types.Chunk, n)
+	for i, idx := range picked {
+		out[i] = chunks[idx]
+	}
+	return out
+}
+
+// calibrationEmbeddings embeds chunks with model, or returns their own
+// embeddings when model is empty.
+func calibrationEmbeddings(ctx context.Context, cmd *cobra.Command, chunks []types.Chunk, model string) ([][]float32, string, error) {
+	if model == "" {
+		embeddings := make([][]float32, len(chunks))
+		for i, c := range chunks {
+			embeddings[i] = c.Embedding
+		}
+		return embeddings, "input embeddings", nil
+	}
+
+	embedder, err := createEmbedderForModel(cmd, model)
+	if err != nil {
+		return nil, model, fmt.Errorf("creating embedder for %s: %w", model, err)
+	}
+	if embedder == nil {
+		return nil, model, fmt.Errorf("no API key for %s (--openai-key, OPENAI_API_KEY, or COHERE_API_KEY)", model)
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		if c.Text == "" {
+			return nil, model, fmt.Errorf("chunk %s has no text to embed", c.ID)
+		}
+		texts[i] = c.Text
+	}
+	embeddings, err := embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, model, fmt.Errorf("embedding with %s: %w", model, err)
+	}
+	return embeddings, model, nil
+}
+
+// calibrationReport summarises the nearest-neighbour distances.
+func calibrationReport(model string, neighbors []contextlab.Neighbor) CalibrationReport {
+	distances := contextlab.NeighborDistances(neighbors)
+	sorted := append([]float64(nil), distances...)
+	sort.Float64s(sorted)
+
+	percentiles := make(map[string]float64)
+	for _, p := range []int{5, 25, 50, 75, 95} {
+		percentiles[fmt.Sprintf("p%d", p)] = sorted[(len(sorted)-1)*p/100]
+	}
+	hist := contextlab.NewDistanceHistogram(distances, calibrationBinWidth, calibrationMax)
+
+	report := CalibrationReport{
+		Model:       model,
+		Chunks:      len(neighbors),
+		Percentiles: percentiles,
+		Histogram:   hist.Counts,
+		BinWidth:    hist.BinWidth,
+	}
+	if t, ok := contextlab.AutoThreshold(distances); ok {
+		report.AutoThreshold = t
+		report.Recommended = t
+	}
+	return report
+}
+
+// labelPairs asks about up to n nearest-neighbour pairs, alternating
+// between the closest pairs below and above the threshold so the answers
+// bracket it, and returns the answers.
+func labelPairs(chunks []types.Chunk, neighbors []contextlab.Neighbor, around float64, n int, in *bufio.Reader, out io.Writer) ([]contextlab.LabeledDistance, error) {
+	var below, above []contextlab.Neighbor
+	for _, nb := range neighbors {
+		if nb.Distance <= around {
+			below = append(below, nb)
+		} else {
+			above = append(above, nb)
+		}
+	}
+	sort.SliceStable(below, func(i, j int) bool { return below[i].Distance > below[j].Distance })
+	sort.SliceStable(above, func(i, j int) bool { return above[i].Distance < above[j].Distance })
+	candidates := make([]contextlab.Neighbor, 0, len(neighbors))
+	for i := 0; i < len(below) || i < len(above); i++ {
+		if i < len(below) {
+			candidates = append(candidates, below[i])
+		}
+		if i < len(above) {
+			candidates = append(candidates, above[i])
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	var labels []contextlab.LabeledDistance
+	for _, nb := range candidates {
+		if len(labels) >= n {
+			break
+		}
+		pair := [2]int{nb.Index, nb.Neighbor}
+		if pair[0] > pair[1] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+
+		a, b := chunks[nb.Index], chunks[nb.Neighbor]
+		fmt.Fprintf(out, "\n[%d/%d] distance %.3f\n  A (%s): %s\n  B (%s): %s\n> ",
+			len(labels)+1, n, nb.Distance, a.ID, previewText(a.Text), b.ID, previewText(b.Text))
+
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("reading answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			labels = append(labels, contextlab.LabeledDistance{Distance: nb.Distance, Duplicate: true})
+		case "n", "no":
+			labels = append(labels, contextlab.LabeledDistance{Distance: nb.Distance})
+		case "q", "quit":
+			return labels, nil
+		}
+	}
+	return labels, nil
+}
+
+// previewText flattens text to one line of at most 160 characters.
+func previewText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return "(no text)"
+	}
+	if r := []rune(text); len(r) > 160 {
+		return string(r[:160]) + "..."
+	}
+	return text
+}
+
+func printCalibrationReport(r CalibrationReport) {
+	fmt.Printf("=== %s (%d chunks) ===\n\n", r.Model, r.Chunks)
+	fmt.Println("Nearest-neighbour cosine distance:")
+
+	peak := 0
+	for _, c := range r.Histogram {
+		if c > peak {
+			peak = c
+		}
+	}
+	for i, c := range r.Histogram {
+		label := fmt.Sprintf("%.2f-%.2f", float64(i)*r.BinWidth, float64(i+1)*r.BinWidth)
+		if i == len(r.Histogram)-1 {
+			label = fmt.Sprintf("%-9s", fmt.Sprintf("%.2f+", float64(i)*r.BinWidth))
+		}
+		bar := 0
+		if peak > 0 {
+			bar = (c*40 + peak - 1) / peak
+		}
+		fmt.Printf("  %s %6d %s\n", label, c, strings.Repeat("#", bar))
+	}
+
+	fmt.Printf("\nPercentiles:  p5=%.3f p25=%.3f p50=%.3f p75=%.3f p95=%.3f\n",
+		r.Percentiles["p5"], r.Percentiles["p25"], r.Percentiles["p50"], r.Percentiles["p75"], r.Percentiles["p95"])
+	if r.AutoThreshold > 0 {
+		fmt.Printf("Gap:          %.3f\n", r.AutoThreshold)
+	} else {
+		fmt.Println("Gap:          none below", contextlab.MaxAutoThreshold)
+	}
+	if r.Labeled > 0 {
+		fmt.Printf("Labelled:     %.3f (%d pairs, %.0f%% agree)\n", r.LabelThreshold, r.Labeled, r.LabelAccuracy*100)
+	}
+
+	if r.Recommended == 0 {
+		fmt.Println("\nNo clear threshold; label pairs with --label to fit one.")
+		fmt.Println()
+		return
+	}
+	fmt.Printf("\nRecommended threshold: %.3f\n\n", r.Recommended)
+	fmt.Println("  # distill.yaml")
+	fmt.Println("  dedup:")
+	fmt.Printf("    threshold: %.3f\n", r.Recommended)
+	fmt.Println("  memory:")
+	fmt.Printf("    dedup_threshold: %.3f\n", r.Recommended)
+	fmt.Println("  session:")
+	fmt.Printf("    dedup_threshold: %.3f\n", r.Recommended)
+	fmt.Printf("\n  # or per command: distill sync -t %.3f\n\n", r.Recommended)
+}
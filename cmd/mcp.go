@@ -10,6 +10,8 @@ import (
 	"github.com/Siddhant-K-code/distill/pkg/contextlab"
 	"github.com/Siddhant-K-code/distill/pkg/embedding/openai"
 	"github.com/Siddhant-K-code/distill/pkg/memory"
+	"github.com/Siddhant-K-code/distill/pkg/pipeline"
+	"github.com/Siddhant-K-code/distill/pkg/rerank"
 	"github.com/Siddhant-K-code/distill/pkg/retriever"
 	"github.com/Siddhant-K-code/distill/pkg/session"
 	pcretriever "github.com/Siddhant-K-code/distill/pkg/retriever/pinecone"
@@ -33,7 +35,7 @@ Transports:
   http            - For remote/cloud deployments (hosted MCP server)
 
 Tools exposed:
-  deduplicate_chunks    - Deduplicate chunks with embeddings
+  deduplicate_chunks    - Deduplicate chunks by embedding or near-identical text
   retrieve_deduplicated - Query vector DB with deduplication
   analyze_redundancy    - Analyze chunks for redundancy stats
 
@@ -101,12 +103,14 @@ func init() {
 	mcpCmd.Flags().Int("target-k", 8, "Default target chunk count")
 	mcpCmd.Flags().Float64("threshold", 0.15, "Default clustering threshold")
 	mcpCmd.Flags().Float64("lambda", 0.5, "Default MMR lambda")
+	addRerankFlags(mcpCmd)
 }
 
 // MCPServer wraps the MCP server with Distill capabilities
 type MCPServer struct {
 	broker    *contextlab.Broker
 	embedder  retriever.EmbeddingProvider
+	reranker  rerank.Reranker
 	cfg       contextlab.BrokerConfig
 	memStore  *memory.SQLiteStore
 	sessStore *session.SQLiteStore
@@ -139,6 +143,11 @@ func runMCP(cmd *cobra.Command, args []string) error {
 
 	ctx := context.Background()
 
+	reranker, rerankWeight, err := configuredReranker(cmd)
+	if err != nil {
+		return err
+	}
+
 	// Create broker config
 	brokerCfg := contextlab.BrokerConfig{
 		OverFetchK:        overFetchK,
@@ -148,12 +157,15 @@ func runMCP(cmd *cobra.Command, args []string) error {
 		SelectionStrategy: contextlab.SelectByScore,
 		EnableMMR:         true,
 		MMRLambda:         lambda,
+		Reranker:          reranker,
+		RerankWeight:      rerankWeight,
 		IncludeMetadata:   true,
 	}
 
 	// Create MCP server wrapper
 	mcpSrv := &MCPServer{
-		cfg: brokerCfg,
+		reranker: reranker,
+		cfg:      brokerCfg,
 	}
 
 	// Create memory store (opt-in)
@@ -162,6 +174,11 @@ func runMCP(cmd *cobra.Command, args []string) error {
 		memDBPath, _ := cmd.Flags().GetString("memory-db")
 		memCfg := memory.DefaultConfig()
 		memCfg.DedupThreshold = threshold
+		memCfg.Summarizer = configuredSummarizer()
+		var err error
+		if memCfg.Quantization, memCfg.Rescore, err = storageQuantization("memory"); err != nil {
+			return err
+		}
 		memStore, err := memory.NewSQLiteStore(memDBPath, memCfg)
 		if err != nil {
 			return fmt.Errorf("failed to create memory store: %w", err)
@@ -176,11 +193,19 @@ func runMCP(cmd *cobra.Command, args []string) error {
 		sessDBPath, _ := cmd.Flags().GetString("session-db")
 		sessCfg := session.DefaultConfig()
 		sessCfg.DefaultDedupThreshold = threshold
+		sessCfg.Summarizer = configuredSummarizer()
+		var err error
+		if sessCfg.Quantization, sessCfg.Rescore, err = storageQuantization("session"); err != nil {
+			return err
+		}
 		sessStore, err := session.NewSQLiteStore(sessDBPath, sessCfg)
 		if err != nil {
 			return fmt.Errorf("failed to create session store: %w", err)
 		}
 		defer func() { _ = sessStore.Close() }()
+		if mcpSrv.memStore != nil {
+			configureSessionPromotion(sessStore, mcpSrv.memStore)
+		}
 		mcpSrv.sessStore = sessStore
 	}
 
@@ -317,11 +342,12 @@ BENEFITS:
 - Improves response quality by providing diverse perspectives
 - Prevents LLM confusion from repetitive content
 
-INPUT: Array of chunks with text and embeddings (from your RAG pipeline)
+INPUT: Array of chunks with text and, ideally, embeddings (from your RAG pipeline).
+Chunks without embeddings are deduplicated by near-identical text instead.
 OUTPUT: Deduplicated chunks with diversity optimization`),
 		mcp.WithArray("chunks",
 			mcp.Required(),
-			mcp.Description("Array of chunk objects. Each chunk must have 'text' (string) and 'embedding' (array of floats). Optional: 'id' (string), 'score' (float), 'metadata' (object)."),
+			mcp.Description("Array of chunk objects. Each chunk must have 'text' (string). Optional: 'embedding' (array of floats), 'id' (string), 'score' (float), 'metadata' (object)."),
 		),
 		mcp.WithNumber("target_k",
 			mcp.Description("Target number of chunks to return (default: 8)"),
@@ -332,6 +358,9 @@ OUTPUT: Deduplicated chunks with diversity optimization`),
 		mcp.WithNumber("lambda",
 			mcp.Description("MMR lambda - 1.0 for pure relevance, 0.0 for pure diversity (default: 0.5)"),
 		),
+		mcp.WithString("method",
+			mcp.Description("embedding, lexical (near-identical text, no embeddings needed), or hybrid (lexical first, then embedding). Default: embedding when every chunk has one, else lexical."),
+		),
 	)
 
 	s.AddTool(deduplicateTool, m.handleDeduplicateChunks)
@@ -364,6 +393,9 @@ USE THIS instead of raw vector DB queries when you need diverse, non-redundant c
 			mcp.WithNumber("lambda",
 				mcp.Description("MMR lambda for relevance vs diversity (default: 0.5)"),
 			),
+			mcp.WithBoolean("rerank",
+				mcp.Description("Rerank representatives against the query before MMR (default: on when the server has a reranker; true without one uses BM25)"),
+			),
 		)
 
 		s.AddTool(retrieveTool, m.handleRetrieveDeduplicated)
@@ -482,6 +514,9 @@ Use this at the start of a task to track context incrementally.`),
 			mcp.WithNumber("max_tokens",
 				mcp.Description("Token budget for the session (default: 128000)"),
 			),
+			mcp.WithString("tokenizer",
+				mcp.Description("Token counter for the budget: heuristic, cl100k_base, or o200k_base (default: server setting)"),
+			),
 		)
 		s.AddTool(createSessionTool, m.handleCreateSession)
 
@@ -493,8 +528,13 @@ and the token budget is enforced via compression and eviction.`),
 				mcp.Required(),
 			),
 			mcp.WithString("content",
-				mcp.Description("Entry content"),
-				mcp.Required(),
+				mcp.Description("Entry content (required unless messages is set)"),
+			),
+			mcp.WithString("messages",
+				mcp.Description("JSON array of provider-native messages, pushed in order"),
+			),
+			mcp.WithString("format",
+				mcp.Description("Format of messages: openai or anthropic"),
 			),
 			mcp.WithString("role",
 				mcp.Description("Entry role: user, assistant, tool, system (default: tool)"),
@@ -505,6 +545,9 @@ and the token budget is enforced via compression and eviction.`),
 			mcp.WithNumber("importance",
 				mcp.Description("Entry importance 0-1 (default: 0.5, higher = harder to evict)"),
 			),
+			mcp.WithNumber("expected_version",
+				mcp.Description("Only push if the session is still at this version (from a previous push result)"),
+			),
 		)
 		s.AddTool(pushSessionTool, m.handlePushSession)
 
@@ -521,6 +564,9 @@ Returns entries in push order with compression levels and token counts.`),
 			mcp.WithString("role",
 				mcp.Description("Filter by role"),
 			),
+			mcp.WithString("format",
+				mcp.Description("Also return a ready-to-send messages array: openai or anthropic"),
+			),
 		)
 		s.AddTool(sessionContextTool, m.handleSessionContext)
 
@@ -666,13 +712,6 @@ func (m *MCPServer) handleDeduplicateChunks(ctx context.Context, request mcp.Cal
 		return mcp.NewToolResultError("chunks array is empty"), nil
 	}
 
-	// Validate chunks have embeddings
-	for i, c := range inputChunks {
-		if len(c.Embedding) == 0 {
-			return mcp.NewToolResultError(fmt.Sprintf("chunk %d missing embedding", i)), nil
-		}
-	}
-
 	// Convert to internal types
 	chunks := make([]types.Chunk, len(inputChunks))
 	for i, c := range inputChunks {
@@ -708,6 +747,16 @@ func (m *MCPServer) handleDeduplicateChunks(ctx context.Context, request mcp.Cal
 		cfg.MMRLambda = lambda
 	}
 
+	// Chunks without embeddings are deduplicated lexically.
+	method, err := resolveDedupMethod(request.GetString("method", ""), missingEmbeddings(chunks), false)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if method != pipeline.DedupLexical && missingEmbeddings(chunks) {
+		return mcp.NewToolResultError(fmt.Sprintf("method %q requires an embedding on every chunk", method)), nil
+	}
+	chunks, _ = lexicalPrefilter(method, chunks, 0)
+
 	// Create a temporary broker for processing
 	clusterer := contextlab.NewClusterer(contextlab.ClusterConfig{
 		Threshold: cfg.ClusterThreshold,
@@ -722,7 +771,12 @@ func (m *MCPServer) handleDeduplicateChunks(ctx context.Context, request mcp.Cal
 	})
 
 	// Process chunks
-	clusterResult := clusterer.Cluster(chunks)
+	var clusterResult *types.ClusterResult
+	if method == pipeline.DedupLexical {
+		clusterResult = nearDupDetector(0).Cluster(chunks)
+	} else {
+		clusterResult = clusterer.Cluster(chunks)
+	}
 	representatives := selector.Select(clusterResult)
 
 	var finalChunks []types.Chunk
@@ -775,6 +829,15 @@ func (m *MCPServer) handleRetrieveDeduplicated(ctx context.Context, request mcp.
 	if lambda := request.GetFloat("lambda", -1); lambda >= 0 && lambda <= 1 {
 		cfg.MMRLambda = lambda
 	}
+	cfg.Reranker = m.reranker
+	if _, ok := request.GetArguments()["rerank"]; ok {
+		switch {
+		case !request.GetBool("rerank", false):
+			cfg.Reranker = nil
+		case cfg.Reranker == nil:
+			cfg.Reranker = rerank.NewBM25(rerank.BM25Config{})
+		}
+	}
 	m.broker.SetConfig(cfg)
 
 	// Execute retrieval
@@ -789,9 +852,12 @@ func (m *MCPServer) handleRetrieveDeduplicated(ctx context.Context, request mcp.
 		"stats": map[string]interface{}{
 			"retrieved":             brokerResult.Stats.Retrieved,
 			"clustered":             brokerResult.Stats.Clustered,
+			"reranked":              brokerResult.Stats.Reranked,
 			"returned":              brokerResult.Stats.Returned,
 			"retrieval_latency_ms":  brokerResult.Stats.RetrievalLatency.Milliseconds(),
 			"clustering_latency_ms": brokerResult.Stats.ClusteringLatency.Milliseconds(),
+			"rerank_latency_ms":     brokerResult.Stats.RerankLatency.Milliseconds(),
+			"rerank_fallback":       brokerResult.Stats.RerankFallback,
 			"total_latency_ms":      brokerResult.Stats.TotalLatency.Milliseconds(),
 		},
 	}
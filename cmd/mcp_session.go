@@ -18,9 +18,12 @@ func (m *MCPServer) handleCreateSession(ctx context.Context, request mcp.CallToo
 		maxTokens = int(v)
 	}
 
+	tokenizerName, _ := args["tokenizer"].(string)
+
 	sess, err := m.sessStore.Create(ctx, session.CreateRequest{
 		SessionID: sessionID,
 		MaxTokens: maxTokens,
+		Tokenizer: tokenizerName,
 	})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("create session: %v", err)), nil
@@ -44,8 +47,9 @@ func (m *MCPServer) handlePushSession(ctx context.Context, request mcp.CallToolR
 	}
 
 	content, _ := args["content"].(string)
-	if content == "" {
-		return mcp.NewToolResultError("content is required"), nil
+	messages, _ := args["messages"].(string)
+	if content == "" && messages == "" {
+		return mcp.NewToolResultError("content or messages is required"), nil
 	}
 
 	source, _ := args["source"].(string)
@@ -55,17 +59,29 @@ func (m *MCPServer) handlePushSession(ctx context.Context, request mcp.CallToolR
 		importance = v
 	}
 
-	result, err := m.sessStore.Push(ctx, session.PushRequest{
-		SessionID: sessionID,
-		Entries: []session.PushEntry{
+	format, _ := args["format"].(string)
+	req := session.PushRequest{SessionID: sessionID, Format: format}
+	if v, ok := args["expected_version"].(float64); ok {
+		expected := int(v)
+		req.ExpectedVersion = &expected
+	}
+	if content != "" {
+		req.Entries = []session.PushEntry{
 			{
 				Role:       role,
 				Content:    content,
 				Source:     source,
 				Importance: importance,
 			},
-		},
-	})
+		}
+	}
+	if messages != "" {
+		if err := json.Unmarshal([]byte(messages), &req.Messages); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid messages: %v", err)), nil
+		}
+	}
+
+	result, err := m.sessStore.Push(ctx, req)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("push: %v", err)), nil
 	}
@@ -88,11 +104,13 @@ func (m *MCPServer) handleSessionContext(ctx context.Context, request mcp.CallTo
 	}
 
 	role, _ := args["role"].(string)
+	format, _ := args["format"].(string)
 
 	result, err := m.sessStore.Context(ctx, session.ContextRequest{
 		SessionID: sessionID,
 		MaxTokens: maxTokens,
 		Role:      role,
+		Format:    format,
 	})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("context: %v", err)), nil
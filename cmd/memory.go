@@ -93,12 +93,23 @@ func openMemoryStore(cmd *cobra.Command) (*memory.SQLiteStore, error) {
 
 	cfg := memory.DefaultConfig()
 	cfg.DedupThreshold = threshold
+	cfg.Summarizer = configuredSummarizer()
+	var err error
+	if cfg.Quantization, cfg.Rescore, err = storageQuantization("memory"); err != nil {
+		return nil, err
+	}
 
 	return memory.NewSQLiteStore(dbPath, cfg)
 }
 
 // createEmbedder builds an embedding.Provider from CLI flags and config.
 func createEmbedder(cmd *cobra.Command) (embedding.Provider, error) {
+	return createEmbedderForModel(cmd, "")
+}
+
+// createEmbedderForModel is createEmbedder with the model overridden;
+// an empty model uses embedding.model from the config.
+func createEmbedderForModel(cmd *cobra.Command, model string) (embedding.Provider, error) {
 	apiKey, _ := cmd.Flags().GetString("openai-key")
 	if apiKey == "" {
 		apiKey = os.Getenv("OPENAI_API_KEY")
@@ -123,7 +134,9 @@ func createEmbedder(cmd *cobra.Command) (embedding.Provider, error) {
 		}
 	}
 
-	model := viper.GetString("embedding.model")
+	if model == "" {
+		model = viper.GetString("embedding.model")
+	}
 	if model == "" {
 		model = "text-embedding-3-small"
 	}
@@ -286,5 +299,10 @@ func memoryStoreFromConfig(dbPath string, threshold float64) (*memory.SQLiteStor
 	}
 	cfg := memory.DefaultConfig()
 	cfg.DedupThreshold = threshold
+	cfg.Summarizer = configuredSummarizer()
+	var err error
+	if cfg.Quantization, cfg.Rescore, err = storageQuantization("memory"); err != nil {
+		return nil, err
+	}
 	return memory.NewSQLiteStore(dbPath, cfg)
 }
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/Siddhant-K-code/distill/pkg/config"
 	"github.com/Siddhant-K-code/distill/pkg/pipeline"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var pipelineCmd = &cobra.Command{
@@ -24,7 +27,22 @@ Example (file):
   distill pipeline --input chunks.json --output optimised.json
 
 Example (disable compress):
-  distill pipeline --no-compress --dedup-threshold 0.2`,
+  distill pipeline --no-compress --dedup-threshold 0.2
+
+Example (route tool output to placeholders, prose to pruner+extractive):
+  distill pipeline --compress-mode auto
+
+Example (fit everything into 8000 tokens, compressing low-score chunks hardest):
+  distill pipeline --input chunks.json --max-tokens 8000 --stats
+
+Example (show what was kept, dropped, and inserted per chunk):
+  distill pipeline --input chunks.json --explain
+
+Example (run the "rag" pipeline declared under pipelines: in distill.yaml):
+  distill pipeline --profile rag --stats
+
+Example (keep the sentences relevant to a question):
+  distill pipeline --query "How are sessions expired?"`,
 	RunE: runPipeline,
 }
 
@@ -33,16 +51,27 @@ func init() {
 
 	pipelineCmd.Flags().String("input", "", "Input JSON file (default: stdin)")
 	pipelineCmd.Flags().String("output", "", "Output JSON file (default: stdout)")
+	pipelineCmd.Flags().String("profile", "", "Named pipeline from the pipelines section of distill.yaml; replaces the stage toggles")
 
 	// Dedup flags.
 	pipelineCmd.Flags().Bool("no-dedup", false, "Disable deduplication stage")
 	pipelineCmd.Flags().Float64("dedup-threshold", 0.15, "Cosine distance threshold for dedup clustering")
+	pipelineCmd.Flags().Bool("dedup-auto-threshold", false, "Pick the dedup threshold from the distance histogram (--dedup-threshold is the fallback)")
 	pipelineCmd.Flags().Float64("dedup-lambda", 0.7, "MMR diversity weight")
 	pipelineCmd.Flags().Int("dedup-target-k", 0, "Maximum chunks to keep after dedup (0 = no limit)")
+	pipelineCmd.Flags().String("dedup-method", "", "Dedup method: embedding, lexical (no embeddings needed), or hybrid (default: embedding when every chunk has one, else lexical)")
 
 	// Compress flags.
 	pipelineCmd.Flags().Bool("no-compress", false, "Disable compression stage")
 	pipelineCmd.Flags().Float64("compress-ratio", 0.5, "Target compression ratio (0.5 = reduce to 50% of tokens)")
+	pipelineCmd.Flags().String("compress-mode", "extractive", "Compression mode: extractive, placeholder, hybrid, pruner, auto, code, log")
+	pipelineCmd.Flags().StringSlice("compressors", nil, "Ordered compressors to chain (e.g. pruner,extractive); overrides --compress-mode")
+	pipelineCmd.Flags().String("query", "", "Question to condition extractive compression on")
+	pipelineCmd.Flags().String("openai-key", "", "API key for query embeddings (or OPENAI_API_KEY / COHERE_API_KEY)")
+	pipelineCmd.Flags().String("embedding-provider", "", "Embedding provider for --query (openai, ollama, cohere)")
+
+	// Budget flags.
+	pipelineCmd.Flags().Int("max-tokens", 0, "Overall token budget; allocated across chunks by score (0 = no budget)")
 
 	// Summarize flags.
 	pipelineCmd.Flags().Bool("summarize", false, "Enable summarization stage")
@@ -51,6 +80,7 @@ func init() {
 
 	// Output flags.
 	pipelineCmd.Flags().Bool("stats", false, "Print pipeline statistics to stderr")
+	pipelineCmd.Flags().Bool("explain", false, "Print a per-chunk diff of kept, dropped, and inserted text to stderr")
 }
 
 func runPipeline(cmd *cobra.Command, _ []string) error {
@@ -78,22 +108,62 @@ func runPipeline(cmd *cobra.Command, _ []string) error {
 	doSummarize, _ := cmd.Flags().GetBool("summarize")
 
 	threshold, _ := cmd.Flags().GetFloat64("dedup-threshold")
+	autoThreshold, _ := cmd.Flags().GetBool("dedup-auto-threshold")
 	lambda, _ := cmd.Flags().GetFloat64("dedup-lambda")
 	targetK, _ := cmd.Flags().GetInt("dedup-target-k")
+	dedupMethod, _ := cmd.Flags().GetString("dedup-method")
 	compressRatio, _ := cmd.Flags().GetFloat64("compress-ratio")
+	compressMode, _ := cmd.Flags().GetString("compress-mode")
+	compressors, _ := cmd.Flags().GetStringSlice("compressors")
+	query, _ := cmd.Flags().GetString("query")
+	budget, _ := cmd.Flags().GetInt("max-tokens")
 	maxTokens, _ := cmd.Flags().GetInt("summarize-max-tokens")
 	keepRecent, _ := cmd.Flags().GetInt("summarize-recent")
+	explain, _ := cmd.Flags().GetBool("explain")
+
+	mode, chain, err := compressModesFromRequest(PipelineCompressOptions{
+		Mode:        compressMode,
+		Compressors: compressors,
+	})
+	if err != nil {
+		return err
+	}
+	if err := pipeline.ValidateDedupMethod(dedupMethod); err != nil {
+		return err
+	}
 
 	opts := pipeline.Options{
 		DedupEnabled:            !noDedup,
 		DedupThreshold:          threshold,
 		DedupLambda:             lambda,
 		DedupTargetK:            targetK,
+		DedupMethod:             dedupMethod,
+		DedupAutoThreshold:      autoThreshold,
 		CompressEnabled:         !noCompress,
 		CompressTargetReduction: compressRatio,
+		CompressMode:            mode,
+		CompressChain:           chain,
 		SummarizeEnabled:        doSummarize,
 		SummarizeMaxTokens:      maxTokens,
 		SummarizeRecent:         keepRecent,
+		Summarizer:              configuredSummarizer(),
+		MaxTokens:               budget,
+		Query:                   query,
+		Explain:                 explain,
+	}
+	if query != "" {
+		// Without an embedder, relevance falls back to lexical overlap.
+		embedder, err := createEmbedder(cmd)
+		if err != nil {
+			return fmt.Errorf("creating embedder: %w", err)
+		}
+		opts.Embedder = embedder
+	}
+
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		if opts.Stages, err = pipelineProfile(profile); err != nil {
+			return err
+		}
 	}
 
 	// Run.
@@ -126,17 +196,82 @@ func runPipeline(cmd *cobra.Command, _ []string) error {
 		fmt.Fprintf(os.Stderr, "  final_tokens:    %d\n", stats.FinalTokens)
 		fmt.Fprintf(os.Stderr, "  total_reduction: %.1f%%\n", stats.TotalReduction*100)
 		fmt.Fprintf(os.Stderr, "  latency:         %s\n", stats.TotalLatency)
-		for name, s := range stats.Stages {
-			if s.Enabled {
+		for _, name := range stats.StageOrder {
+			if s := stats.Stages[name]; s.Enabled {
 				fmt.Fprintf(os.Stderr, "  stage[%s]: reduction=%.1f%% latency=%s\n",
 					name, s.Reduction*100, s.Latency)
 			}
 		}
+		if d := stats.Dedup; d != nil {
+			fmt.Fprintf(os.Stderr, "  dedup: %s, %d clusters", d.Method, d.Clusters)
+			if d.ThresholdAuto {
+				fmt.Fprintf(os.Stderr, ", threshold %.3f (auto)", d.Threshold)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		if b := stats.Budget; b != nil {
+			fmt.Fprintf(os.Stderr, "  budget: used %d of %d tokens, dropped %d chunks\n",
+				b.UsedTokens, b.MaxTokens, b.DroppedChunks)
+			for _, a := range b.Allocations {
+				if a.Dropped {
+					fmt.Fprintf(os.Stderr, "    %s: utility=%.2f %d tokens -> dropped\n", a.ID, a.Utility, a.InputTokens)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "    %s: utility=%.2f %d -> %d tokens (keep %.0f%%)\n",
+					a.ID, a.Utility, a.InputTokens, a.OutputTokens, a.KeepRatio*100)
+			}
+		}
+		if ss := stats.Summarize; ss != nil {
+			fmt.Fprintf(os.Stderr, "  summarize: %d turns, %d compressed, %d preserved, %d -> %d tokens\n",
+				ss.InputTurns, ss.CompressedTurns, ss.PreservedTurns, ss.InputTokens, ss.OutputTokens)
+		}
+	}
+
+	if explain {
+		for _, r := range pipeline.Explain(chunks, result, nil) {
+			fmt.Fprintf(os.Stderr, "== %s: %s", r.ID, r.Status)
+			if len(r.Compressors) > 0 {
+				fmt.Fprintf(os.Stderr, " by %s", strings.Join(r.Compressors, ", "))
+			}
+			fmt.Fprintf(os.Stderr, " (%d -> %d tokens)\n%s", r.OriginalTokens, r.FinalTokens, r.Diff)
+		}
 	}
 
 	return nil
 }
 
+// pipelineProfile builds the stages of the named pipeline declared under
+// "pipelines" in the config file.
+func pipelineProfile(name string) ([]pipeline.Stage, error) {
+	// Viper lower-cases map keys when reading config files.
+	key := "pipelines." + strings.ToLower(name)
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("unknown pipeline profile %q", name)
+	}
+	var pc config.PipelineConfig
+	if err := viper.UnmarshalKey(key, &pc); err != nil {
+		return nil, fmt.Errorf("pipeline profile %q: %w", name, err)
+	}
+	if len(pc.Stages) == 0 {
+		return nil, fmt.Errorf("pipeline profile %q declares no stages", name)
+	}
+
+	specs := make([]pipeline.StageSpec, len(pc.Stages))
+	for i, st := range pc.Stages {
+		specs[i] = pipeline.StageSpec{
+			Name:   st.Name,
+			Type:   st.Type,
+			Params: st.Params,
+			When:   st.When,
+		}
+	}
+	stages, err := pipeline.BuildStages(specs)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline profile %q: %w", name, err)
+	}
+	return stages, nil
+}
+
 // readStdin reads all of stdin.
 func readStdin() ([]byte, error) {
 	var buf []byte
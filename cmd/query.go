@@ -50,9 +50,14 @@ func init() {
 	queryCmd.Flags().Int("over-fetch-k", 50, "Number of chunks to over-fetch")
 	queryCmd.Flags().Int("target-k", 8, "Target number of chunks")
 	queryCmd.Flags().Float64("threshold", 0.15, "Clustering threshold")
+	queryCmd.Flags().Bool("auto-threshold", false, "Pick the clustering threshold from the distance histogram (--threshold is the fallback)")
 	queryCmd.Flags().Float64("lambda", 0.5, "MMR lambda")
 	queryCmd.Flags().Bool("enable-mmr", true, "Enable MMR re-ranking")
 	queryCmd.Flags().Bool("no-dedup", false, "Disable deduplication (raw retrieval)")
+	addRerankFlags(queryCmd)
+	addExpandFlags(queryCmd)
+	addMultiQueryFlags(queryCmd)
+	queryCmd.Flags().StringArray("variant", nil, "Also retrieve with this rephrasing of the query, fused with the results (repeatable)")
 
 	// Output settings
 	queryCmd.Flags().Bool("show-text", true, "Show chunk text")
@@ -75,6 +80,7 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	overFetchK, _ := cmd.Flags().GetInt("over-fetch-k")
 	targetK, _ := cmd.Flags().GetInt("target-k")
 	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	autoThreshold, _ := cmd.Flags().GetBool("auto-threshold")
 	lambda, _ := cmd.Flags().GetFloat64("lambda")
 	enableMMR, _ := cmd.Flags().GetBool("enable-mmr")
 	noDedup, _ := cmd.Flags().GetBool("no-dedup")
@@ -200,14 +206,29 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		// Use ContextLab broker
 		fmt.Fprintf(os.Stderr, "Retrieving with deduplication...\n")
 
+		reranker, rerankWeight, err := configuredReranker(cmd)
+		if err != nil {
+			return err
+		}
+		expand, err := configuredExpansion(cmd)
+		if err != nil {
+			return err
+		}
+		variants, _ := cmd.Flags().GetStringArray("variant")
+
 		brokerCfg := contextlab.BrokerConfig{
 			OverFetchK:        overFetchK,
 			TargetK:           targetK,
 			ClusterThreshold:  threshold,
+			AutoThreshold:     autoThreshold,
 			ClusterLinkage:    "average",
 			SelectionStrategy: contextlab.SelectByScore,
 			EnableMMR:         enableMMR,
 			MMRLambda:         lambda,
+			Reranker:          reranker,
+			RerankWeight:      rerankWeight,
+			Expand:            expand,
+			MultiQuery:        configuredMultiQuery(cmd),
 			IncludeMetadata:   true,
 		}
 
@@ -215,6 +236,8 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		defer func() { _ = broker.Close() }()
 
 		req := &types.RetrievalRequest{
+			Query:          query,
+			Queries:        variants,
 			QueryEmbedding: embedding,
 			Namespace:      namespace,
 		}
@@ -246,6 +269,10 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Println()
 
+		if hits, ok := chunk.Metadata[contextlab.QueryHitsKey].([]map[string]interface{}); ok && stats.Queries > 1 {
+			fmt.Printf("    Found by: %d of %d queries\n", len(hits), stats.Queries)
+		}
+
 		if showText && chunk.Text != "" {
 			text := chunk.Text
 			if textLimit > 0 && len(text) > textLimit {
@@ -267,10 +294,16 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	// Display stats
 	if showStats {
 		fmt.Println("=== Statistics ===")
+		if stats.Queries > 1 {
+			fmt.Printf("Queries:      %d variants\n", stats.Queries)
+		}
 		fmt.Printf("Retrieved:    %d chunks\n", stats.Retrieved)
 		if stats.Clustered > 0 {
 			fmt.Printf("Clusters:     %d\n", stats.Clustered)
 		}
+		if stats.ThresholdAuto {
+			fmt.Printf("Threshold:    %.3f (auto)\n", stats.Threshold)
+		}
 		fmt.Printf("Returned:     %d chunks\n", stats.Returned)
 		if stats.Retrieved > 0 && stats.Returned > 0 {
 			reduction := float64(stats.Retrieved-stats.Returned) / float64(stats.Retrieved) * 100
@@ -280,6 +313,15 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		if stats.ClusteringLatency > 0 {
 			fmt.Printf("Clustering:   %dms\n", stats.ClusteringLatency.Milliseconds())
 		}
+		if stats.Reranked > 0 {
+			fmt.Printf("Reranking:    %dms (%d chunks)\n", stats.RerankLatency.Milliseconds(), stats.Reranked)
+		}
+		if stats.RerankFallback != "" {
+			fmt.Printf("Rerank fallback: %s\n", stats.RerankFallback)
+		}
+		if stats.Expanded > 0 {
+			fmt.Printf("Expansion:    %dms (%d chunks added)\n", stats.ExpandLatency.Milliseconds(), stats.Expanded)
+		}
 		fmt.Printf("Total:        %dms\n", stats.TotalLatency.Milliseconds())
 	}
 
@@ -4,7 +4,13 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
+	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/rerank"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -44,9 +50,11 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.distill.yaml)")
 	rootCmd.PersistentFlags().Bool("verbose", false, "enable verbose output")
+	rootCmd.PersistentFlags().String("tokenizer", "", "token counter: heuristic, cl100k_base, o200k_base (default heuristic)")
 
 	// Bind to viper
 	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	_ = viper.BindPFlag("tokenizer.name", rootCmd.PersistentFlags().Lookup("tokenizer"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -79,4 +87,166 @@ func initConfig() {
 			fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 		}
 	}
+
+	configureTokenizer()
+}
+
+// configureTokenizer installs the process-wide token counter from the
+// tokenizer.name and tokenizer.vocab_dir settings. Every package that
+// budgets tokens counts with it unless a request picks another one.
+func configureTokenizer() {
+	tokenizer.SetVocabDir(viper.GetString("tokenizer.vocab_dir"))
+
+	name := viper.GetString("tokenizer.name")
+	if name == "" {
+		return
+	}
+	counter, err := tokenizer.Get(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; falling back to the heuristic token counter\n", err)
+		return
+	}
+	tokenizer.SetDefault(counter)
+}
+
+var (
+	summarizerOnce sync.Once
+	textSummarizer summarize.TextSummarizer
+)
+
+// configuredSummarizer returns the summarizer selected by the summarizer.*
+// settings, or nil for the default extractive summaries. It is built once
+// so every store and request shares its cache.
+func configuredSummarizer() summarize.TextSummarizer {
+	summarizerOnce.Do(func() {
+		switch provider := viper.GetString("summarizer.provider"); provider {
+		case "", "extractive":
+		case "openai":
+			s, err := summarize.NewAbstractiveSummarizer(summarize.AbstractiveConfig{
+				BaseURL:       viper.GetString("summarizer.base_url"),
+				Model:         viper.GetString("summarizer.model"),
+				APIKey:        viper.GetString("summarizer.api_key"),
+				Timeout:       viper.GetDuration("summarizer.timeout"),
+				Deterministic: viper.GetBool("summarizer.deterministic"),
+				CacheSize:     viper.GetInt("summarizer.cache_size"),
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: summarizer: %v; falling back to extractive summaries\n", err)
+				return
+			}
+			textSummarizer = s
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: unknown summarizer provider %q; falling back to extractive summaries\n", provider)
+		}
+	})
+	return textSummarizer
+}
+
+// storageQuantization reads the embedding quantization and rescore settings
+// of a store's config section ("memory" or "session").
+func storageQuantization(section string) (distillmath.Quantization, bool, error) {
+	quant, err := distillmath.ParseQuantization(viper.GetString(section + ".quantization"))
+	if err != nil {
+		return "", false, fmt.Errorf("%s.quantization: %w", section, err)
+	}
+	return quant, viper.GetBool(section + ".rescore"), nil
+}
+
+// addRerankFlags registers the reranker flags shared by serve, query, and
+// mcp.
+func addRerankFlags(cmd *cobra.Command) {
+	cmd.Flags().String("reranker", "", "Rerank representatives before MMR (bm25, cohere; empty = off)")
+	cmd.Flags().Float64("rerank-weight", 1.0, "Weight of reranker scores vs retrieval scores (0-1)")
+}
+
+// configuredReranker builds the reranker selected by the --reranker flag or
+// the rerank.* settings, and returns it with the blend weight. It returns a
+// nil reranker when reranking is off.
+func configuredReranker(cmd *cobra.Command) (rerank.Reranker, float64, error) {
+	provider := viper.GetString("rerank.provider")
+	if f := cmd.Flags().Lookup("reranker"); f != nil && (f.Changed || provider == "") {
+		provider = f.Value.String()
+	}
+	weight := 1.0
+	if viper.IsSet("rerank.weight") {
+		weight = viper.GetFloat64("rerank.weight")
+	}
+	if f := cmd.Flags().Lookup("rerank-weight"); f != nil && f.Changed {
+		weight, _ = cmd.Flags().GetFloat64("rerank-weight")
+	}
+
+	apiKey := viper.GetString("rerank.api_key")
+	if apiKey == "" {
+		apiKey = os.Getenv("COHERE_API_KEY")
+	}
+	r, err := rerank.New(rerank.Config{
+		Provider: rerank.Provider(provider),
+		Model:    viper.GetString("rerank.model"),
+		BaseURL:  viper.GetString("rerank.base_url"),
+		APIKey:   apiKey,
+		Timeout:  viper.GetDuration("rerank.timeout"),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("reranker: %w", err)
+	}
+	return r, weight, nil
+}
+
+// addExpandFlags registers the result expansion flags on cmd.
+func addExpandFlags(cmd *cobra.Command) {
+	cmd.Flags().String("expand", "", "Expand results with surrounding chunks (neighbors, parent; empty = off)")
+	cmd.Flags().Int("expand-window", 1, "Chunks added on each side with --expand neighbors")
+	cmd.Flags().Int("expand-max-tokens", 0, "Token cap on all returned chunks when expanding (0 = none)")
+}
+
+// configuredExpansion returns the result expansion selected by the
+// --expand flags or the expand.* settings.
+func configuredExpansion(cmd *cobra.Command) (contextlab.ExpandConfig, error) {
+	cfg := contextlab.ExpandConfig{
+		Mode:       contextlab.ExpandMode(viper.GetString("expand.mode")),
+		Window:     viper.GetInt("expand.window"),
+		DocField:   viper.GetString("expand.doc_field"),
+		IndexField: viper.GetString("expand.index_field"),
+		MaxTokens:  viper.GetInt("expand.max_tokens"),
+	}
+	if f := cmd.Flags().Lookup("expand"); f != nil && f.Changed {
+		cfg.Mode = contextlab.ExpandMode(f.Value.String())
+	}
+	if f := cmd.Flags().Lookup("expand-window"); f != nil && (f.Changed || cfg.Window == 0) {
+		cfg.Window, _ = cmd.Flags().GetInt("expand-window")
+	}
+	if f := cmd.Flags().Lookup("expand-max-tokens"); f != nil && f.Changed {
+		cfg.MaxTokens, _ = cmd.Flags().GetInt("expand-max-tokens")
+	}
+	if cfg.Mode == "none" {
+		cfg.Mode = contextlab.ExpandNone
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("expand: %w", err)
+	}
+	return cfg, nil
+}
+
+// addMultiQueryFlags registers the multi-query retrieval flags on cmd.
+func addMultiQueryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("query-template", nil, "Also retrieve with this variant of the query, e.g. \"how do I {query}\" (repeatable)")
+	cmd.Flags().Bool("decompose-query", false, "Retrieve each question of a multi-part query separately")
+}
+
+// configuredMultiQuery returns the query variant settings selected by the
+// --query-template and --decompose-query flags or the multi_query.*
+// settings.
+func configuredMultiQuery(cmd *cobra.Command) contextlab.MultiQueryConfig {
+	cfg := contextlab.MultiQueryConfig{
+		Templates: viper.GetStringSlice("multi_query.templates"),
+		Decompose: viper.GetBool("multi_query.decompose"),
+		RRFK:      viper.GetInt("multi_query.rrf_k"),
+	}
+	if f := cmd.Flags().Lookup("query-template"); f != nil && f.Changed {
+		cfg.Templates, _ = cmd.Flags().GetStringArray("query-template")
+	}
+	if f := cmd.Flags().Lookup("decompose-query"); f != nil && f.Changed {
+		cfg.Decompose, _ = cmd.Flags().GetBool("decompose-query")
+	}
+	return cfg
 }
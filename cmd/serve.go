@@ -16,6 +16,7 @@ import (
 	_ "github.com/Siddhant-K-code/distill/pkg/embedding/ollama"
 	_ "github.com/Siddhant-K-code/distill/pkg/embedding/openai"
 	"github.com/Siddhant-K-code/distill/pkg/metrics"
+	"github.com/Siddhant-K-code/distill/pkg/pipeline"
 	"github.com/Siddhant-K-code/distill/pkg/retriever"
 	"github.com/Siddhant-K-code/distill/pkg/telemetry"
 	pcretriever "github.com/Siddhant-K-code/distill/pkg/retriever/pinecone"
@@ -65,8 +66,12 @@ func init() {
 	serveCmd.Flags().Int("over-fetch-k", 50, "Number of chunks to over-fetch")
 	serveCmd.Flags().Int("target-k", 8, "Target number of chunks to return")
 	serveCmd.Flags().Float64("threshold", 0.15, "Clustering threshold")
+	serveCmd.Flags().Bool("auto-threshold", false, "Pick the clustering threshold per query from the distance histogram (--threshold is the fallback)")
 	serveCmd.Flags().Float64("lambda", 0.5, "MMR lambda (relevance vs diversity)")
 	serveCmd.Flags().Bool("enable-mmr", true, "Enable MMR re-ranking")
+	addRerankFlags(serveCmd)
+	addExpandFlags(serveCmd)
+	addMultiQueryFlags(serveCmd)
 
 	// Bind to viper for config file support
 	_ = viper.BindPFlag("server.port", serveCmd.Flags().Lookup("port"))
@@ -80,6 +85,7 @@ func init() {
 	_ = viper.BindPFlag("retriever.top_k", serveCmd.Flags().Lookup("over-fetch-k"))
 	_ = viper.BindPFlag("retriever.target_k", serveCmd.Flags().Lookup("target-k"))
 	_ = viper.BindPFlag("dedup.threshold", serveCmd.Flags().Lookup("threshold"))
+	_ = viper.BindPFlag("dedup.auto_threshold", serveCmd.Flags().Lookup("auto-threshold"))
 	_ = viper.BindPFlag("dedup.lambda", serveCmd.Flags().Lookup("lambda"))
 	_ = viper.BindPFlag("dedup.enable_mmr", serveCmd.Flags().Lookup("enable-mmr"))
 }
@@ -101,6 +107,7 @@ type ServerConfig struct {
 // RetrieveRequest is the JSON request body for /v1/retrieve.
 type RetrieveRequest struct {
 	Query          string                 `json:"query,omitempty"`
+	Queries        []string               `json:"queries,omitempty"`
 	QueryEmbedding []float32              `json:"query_embedding,omitempty"`
 	Index          string                 `json:"index,omitempty"`
 	Namespace      string                 `json:"namespace,omitempty"`
@@ -109,6 +116,17 @@ type RetrieveRequest struct {
 	Threshold      float64                `json:"threshold,omitempty"`
 	Lambda         float64                `json:"lambda,omitempty"`
 	Filter         map[string]interface{} `json:"filter,omitempty"`
+
+	// Metadata constraints on the returned chunks: source_field,
+	// max_per_source, cover_field, freshness_field, freshness_half_life,
+	// and freshness_weight.
+	pipeline.SelectionParams
+
+	// Expansion of each result with the chunks around it: expand
+	// (neighbors, parent, or none), expand_window, and expand_max_tokens.
+	Expand          string `json:"expand,omitempty"`
+	ExpandWindow    int    `json:"expand_window,omitempty"`
+	ExpandMaxTokens int    `json:"expand_max_tokens,omitempty"`
 }
 
 // RetrieveResponse is the JSON response for /v1/retrieve.
@@ -128,12 +146,20 @@ type ChunkResponse struct {
 
 // StatsResponse contains processing statistics.
 type StatsResponse struct {
-	Retrieved           int   `json:"retrieved"`
-	Clustered           int   `json:"clustered"`
-	Returned            int   `json:"returned"`
-	RetrievalLatencyMs  int64 `json:"retrieval_latency_ms"`
-	ClusteringLatencyMs int64 `json:"clustering_latency_ms"`
-	TotalLatencyMs      int64 `json:"total_latency_ms"`
+	Queries             int     `json:"queries,omitempty"`
+	Retrieved           int     `json:"retrieved"`
+	Clustered           int     `json:"clustered"`
+	Threshold           float64 `json:"threshold"`
+	ThresholdAuto       bool    `json:"threshold_auto,omitempty"`
+	Reranked            int     `json:"reranked,omitempty"`
+	Expanded            int     `json:"expanded,omitempty"`
+	Returned            int     `json:"returned"`
+	RetrievalLatencyMs  int64   `json:"retrieval_latency_ms"`
+	ClusteringLatencyMs int64   `json:"clustering_latency_ms"`
+	RerankLatencyMs     int64   `json:"rerank_latency_ms,omitempty"`
+	RerankFallback      string  `json:"rerank_fallback,omitempty"`
+	ExpandLatencyMs     int64   `json:"expand_latency_ms,omitempty"`
+	TotalLatencyMs      int64   `json:"total_latency_ms"`
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -153,6 +179,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 	overFetchK := viper.GetInt("retriever.top_k")
 	targetK := viper.GetInt("retriever.target_k")
 	threshold := viper.GetFloat64("dedup.threshold")
+	autoThreshold := viper.GetBool("dedup.auto_threshold")
 	lambda := viper.GetFloat64("dedup.lambda")
 	enableMMR := viper.GetBool("dedup.enable_mmr")
 
@@ -242,15 +269,30 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	reranker, rerankWeight, err := configuredReranker(cmd)
+	if err != nil {
+		return err
+	}
+	expand, err := configuredExpansion(cmd)
+	if err != nil {
+		return err
+	}
+	multiQuery := configuredMultiQuery(cmd)
+
 	// Create broker
 	brokerCfg := contextlab.BrokerConfig{
 		OverFetchK:        overFetchK,
 		TargetK:           targetK,
 		ClusterThreshold:  threshold,
+		AutoThreshold:     autoThreshold,
 		ClusterLinkage:    "average",
 		SelectionStrategy: contextlab.SelectByScore,
 		EnableMMR:         enableMMR,
 		MMRLambda:         lambda,
+		Reranker:          reranker,
+		RerankWeight:      rerankWeight,
+		Expand:            expand,
+		MultiQuery:        multiQuery,
 		IncludeMetadata:   true,
 	}
 
@@ -339,6 +381,15 @@ func runServe(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Backend: %s\n", backend)
 	fmt.Printf("  Index: %s\n", index)
 	fmt.Printf("  Embeddings: %v\n", embedder != nil)
+	if reranker != nil {
+		fmt.Printf("  Reranker: %s\n", reranker.Name())
+	}
+	if expand.Enabled() {
+		fmt.Printf("  Expand: %s\n", expand.Mode)
+	}
+	if len(multiQuery.Templates) > 0 || multiQuery.Decompose {
+		fmt.Printf("  Multi-query: %d templates, decompose %v\n", len(multiQuery.Templates), multiQuery.Decompose)
+	}
 	fmt.Println()
 	fmt.Println("Endpoints:")
 	fmt.Printf("  POST http://%s/v1/retrieve\n", addr)
@@ -367,21 +418,23 @@ func (s *Server) handleRetrieve(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if req.Query == "" && len(req.QueryEmbedding) == 0 {
-		http.Error(w, "Either 'query' or 'query_embedding' is required", http.StatusBadRequest)
+	if req.Query == "" && len(req.QueryEmbedding) == 0 && len(req.Queries) == 0 {
+		http.Error(w, "One of 'query', 'queries', or 'query_embedding' is required", http.StatusBadRequest)
 		return
 	}
 
 	// Build retrieval request
 	retrievalReq := &types.RetrievalRequest{
 		Query:          req.Query,
+		Queries:        req.Queries,
 		QueryEmbedding: req.QueryEmbedding,
 		Namespace:      req.Namespace,
 		Filter:         req.Filter,
 	}
 
 	// Override broker config if specified in request
-	if req.OverFetchK > 0 || req.TargetK > 0 || req.Threshold > 0 || req.Lambda > 0 {
+	if req.OverFetchK > 0 || req.TargetK > 0 || req.Threshold > 0 || req.Lambda > 0 || req.SelectionParams != (pipeline.SelectionParams{}) ||
+		req.Expand != "" || req.ExpandWindow > 0 || req.ExpandMaxTokens > 0 {
 		cfg := s.broker.GetConfig()
 		if req.OverFetchK > 0 {
 			cfg.OverFetchK = req.OverFetchK
@@ -395,6 +448,28 @@ func (s *Server) handleRetrieve(w http.ResponseWriter, r *http.Request) {
 		if req.Lambda > 0 {
 			cfg.MMRLambda = req.Lambda
 		}
+		constraints, err := req.Apply(cfg.Constraints)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfg.Constraints = constraints
+		if req.Expand != "" {
+			cfg.Expand.Mode = contextlab.ExpandMode(req.Expand)
+			if req.Expand == "none" {
+				cfg.Expand.Mode = contextlab.ExpandNone
+			}
+		}
+		if req.ExpandWindow > 0 {
+			cfg.Expand.Window = req.ExpandWindow
+		}
+		if req.ExpandMaxTokens > 0 {
+			cfg.Expand.MaxTokens = req.ExpandMaxTokens
+		}
+		if err := cfg.Expand.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		s.broker.SetConfig(cfg)
 	}
 
@@ -428,11 +503,19 @@ func (s *Server) handleRetrieve(w http.ResponseWriter, r *http.Request) {
 	resp := RetrieveResponse{
 		Chunks: chunks,
 		Stats: StatsResponse{
+			Queries:             result.Stats.Queries,
 			Retrieved:           result.Stats.Retrieved,
 			Clustered:           result.Stats.Clustered,
+			Threshold:           result.Stats.Threshold,
+			ThresholdAuto:       result.Stats.ThresholdAuto,
+			Reranked:            result.Stats.Reranked,
+			Expanded:            result.Stats.Expanded,
 			Returned:            result.Stats.Returned,
 			RetrievalLatencyMs:  result.Stats.RetrievalLatency.Milliseconds(),
 			ClusteringLatencyMs: result.Stats.ClusteringLatency.Milliseconds(),
+			RerankLatencyMs:     result.Stats.RerankLatency.Milliseconds(),
+			RerankFallback:      result.Stats.RerankFallback,
+			ExpandLatencyMs:     result.Stats.ExpandLatency.Milliseconds(),
 			TotalLatencyMs:      result.Stats.TotalLatency.Milliseconds(),
 		},
 	}
@@ -3,8 +3,12 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/memory"
 	"github.com/Siddhant-K-code/distill/pkg/session"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -21,6 +25,7 @@ when the token budget is exceeded.
 Examples:
   distill session create --max-tokens 128000
   distill session push --session-id abc --role user --content "Fix the bug"
+  distill session push --session-id abc --format openai --messages-file transcript.json
   distill session context --session-id abc
   distill session delete --session-id abc`,
 }
@@ -68,13 +73,16 @@ func init() {
 	sessionPushCmd.Flags().String("content", "", "Entry content")
 	sessionPushCmd.Flags().String("source", "", "Entry source (e.g. file_read, search)")
 	sessionPushCmd.Flags().Float64("importance", 0.5, "Entry importance (0-1)")
+	sessionPushCmd.Flags().String("format", "", "Message format of --messages-file: openai or anthropic")
+	sessionPushCmd.Flags().String("messages-file", "", "JSON file with a provider-native messages array (- for stdin)")
+	sessionPushCmd.Flags().Int("expected-version", -1, "Only push if the session is at this version (-1 = unconditional)")
 	_ = sessionPushCmd.MarkFlagRequired("session-id")
-	_ = sessionPushCmd.MarkFlagRequired("content")
 
 	// Context flags
 	sessionContextCmd.Flags().String("session-id", "", "Session ID")
 	sessionContextCmd.Flags().Int("max-tokens", 0, "Max tokens to return (0 = all)")
 	sessionContextCmd.Flags().String("role", "", "Filter by role")
+	sessionContextCmd.Flags().String("format", "", "Also emit a messages array: openai or anthropic")
 	_ = sessionContextCmd.MarkFlagRequired("session-id")
 
 	// Delete flags
@@ -93,12 +101,16 @@ func runSessionCreate(cmd *cobra.Command, _ []string) error {
 	maxTokens, _ := cmd.Flags().GetInt("max-tokens")
 	threshold, _ := cmd.Flags().GetFloat64("dedup-threshold")
 	preserveRecent, _ := cmd.Flags().GetInt("preserve-recent")
+	// Pin the session to the tokenizer selected with --tokenizer or
+	// tokenizer.name so later invocations keep counting the same way.
+	tokenizerName := viper.GetString("tokenizer.name")
 
 	sess, err := store.Create(context.Background(), session.CreateRequest{
 		SessionID:      sessionID,
 		MaxTokens:      maxTokens,
 		DedupThreshold: threshold,
 		PreserveRecent: preserveRecent,
+		Tokenizer:      tokenizerName,
 	})
 	if err != nil {
 		return err
@@ -119,18 +131,43 @@ func runSessionPush(cmd *cobra.Command, _ []string) error {
 	content, _ := cmd.Flags().GetString("content")
 	source, _ := cmd.Flags().GetString("source")
 	importance, _ := cmd.Flags().GetFloat64("importance")
+	format, _ := cmd.Flags().GetString("format")
+	messagesFile, _ := cmd.Flags().GetString("messages-file")
+	expectedVersion, _ := cmd.Flags().GetInt("expected-version")
 
-	result, err := store.Push(context.Background(), session.PushRequest{
-		SessionID: sessionID,
-		Entries: []session.PushEntry{
+	req := session.PushRequest{SessionID: sessionID, Format: format}
+	if expectedVersion >= 0 {
+		req.ExpectedVersion = &expectedVersion
+	}
+	if content != "" {
+		req.Entries = []session.PushEntry{
 			{
 				Role:       role,
 				Content:    content,
 				Source:     source,
 				Importance: importance,
 			},
-		},
-	})
+		}
+	}
+	if messagesFile != "" {
+		var data []byte
+		if messagesFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(messagesFile)
+		}
+		if err != nil {
+			return fmt.Errorf("read messages: %w", err)
+		}
+		if err := json.Unmarshal(data, &req.Messages); err != nil {
+			return fmt.Errorf("parse messages: %w", err)
+		}
+	}
+	if len(req.Entries) == 0 && len(req.Messages) == 0 {
+		return fmt.Errorf("one of --content or --messages-file is required")
+	}
+
+	result, err := store.Push(context.Background(), req)
 	if err != nil {
 		return err
 	}
@@ -148,11 +185,13 @@ func runSessionContext(cmd *cobra.Command, _ []string) error {
 	sessionID, _ := cmd.Flags().GetString("session-id")
 	maxTokens, _ := cmd.Flags().GetInt("max-tokens")
 	role, _ := cmd.Flags().GetString("role")
+	format, _ := cmd.Flags().GetString("format")
 
 	result, err := store.Context(context.Background(), session.ContextRequest{
 		SessionID: sessionID,
 		MaxTokens: maxTokens,
 		Role:      role,
+		Format:    format,
 	})
 	if err != nil {
 		return err
@@ -205,5 +244,69 @@ func newSessionStore(dbPath string) (*session.SQLiteStore, error) {
 		cfg.DefaultMaxTokens = maxTokens
 	}
 
+	if viper.GetBool("session.rolling_summary") {
+		cfg.RollingSummary.Enabled = true
+	}
+	if v := viper.GetInt("session.rolling_summary_max_tokens"); v > 0 {
+		cfg.RollingSummary.MaxTokens = v
+	}
+	cfg.Summarizer = configuredSummarizer()
+	var err error
+	if cfg.Quantization, cfg.Rescore, err = storageQuantization("session"); err != nil {
+		return nil, err
+	}
+
 	return session.NewSQLiteStore(dbPath, cfg)
 }
+
+// configureSessionPromotion attaches memStore to sessStore when
+// session.promote.enabled is set, building the policy from viper config.
+// Used by API and MCP when both stores are enabled.
+func configureSessionPromotion(sessStore *session.SQLiteStore, memStore memory.Store) {
+	if !viper.GetBool("session.promote.enabled") {
+		return
+	}
+	policy := session.DefaultPromotionPolicy()
+	if viper.IsSet("session.promote.on_delete") {
+		policy.OnDelete = viper.GetBool("session.promote.on_delete")
+	}
+	if n := viper.GetInt("session.promote.every_n_pushes"); n > 0 {
+		policy.EveryNPushes = n
+	}
+	if v := viper.GetFloat64("session.promote.min_importance"); v > 0 {
+		policy.MinImportance = v
+	}
+	policy.Sources = viper.GetStringSlice("session.promote.sources")
+	policy.Tags = viper.GetStringSlice("session.promote.tags")
+	sessStore.SetPromotion(memStore, policy)
+}
+
+// startSessionExpiry deletes sessions idle for longer than session.ttl on a
+// fixed interval. Returns a stop function; it is a no-op when no TTL is set.
+func startSessionExpiry(sessStore *session.SQLiteStore) func() {
+	ttl := viper.GetDuration("session.ttl")
+	if ttl <= 0 {
+		return func() {}
+	}
+	interval := ttl / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	stopCh := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				_, _ = sessStore.ExpireIdle(ctx, ttl)
+				cancel()
+			}
+		}
+	}()
+	return func() { close(stopCh) }
+}
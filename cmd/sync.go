@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,6 +13,7 @@ import (
 	"github.com/Siddhant-K-code/distill/pkg/dedup"
 	"github.com/Siddhant-K-code/distill/pkg/ingest"
 	pc "github.com/Siddhant-K-code/distill/pkg/pinecone"
+	"github.com/Siddhant-K-code/distill/pkg/types"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,6 +28,8 @@ and uploads to a Pinecone index using parallel workers.
 Example:
   distill sync --file data.jsonl --index my-index --dedup=true
 
+Use --stream to deduplicate and upload files too large to load into memory.
+
 Environment Variables:
   PINECONE_API_KEY    Your Pinecone API key (required)`,
 	RunE: runSync,
@@ -46,6 +51,7 @@ func init() {
 	syncCmd.Flags().Bool("dedup", true, "enable semantic deduplication before upload")
 	syncCmd.Flags().Float64P("threshold", "t", 0.05, "cosine distance threshold for duplicates")
 	syncCmd.Flags().IntP("clusters", "k", 0, "number of clusters (0 = auto)")
+	syncCmd.Flags().Bool("stream", false, "deduplicate and upload in mini-batches with bounded memory")
 
 	// Performance settings
 	syncCmd.Flags().IntP("workers", "w", 0, "number of upload workers (0 = NumCPU*2)")
@@ -68,6 +74,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 	clusters, _ := cmd.Flags().GetInt("clusters")
 	workers, _ := cmd.Flags().GetInt("workers")
 	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	stream, _ := cmd.Flags().GetBool("stream")
 	verbose := viper.GetBool("verbose")
 
 	// Resolve API key from env if not provided
@@ -102,6 +109,17 @@ func runSync(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if stream {
+		return runSyncStream(ctx, cmd, filePath, pc.Config{
+			APIKey:    apiKey,
+			IndexName: indexName,
+			Namespace: namespace,
+		}, ingest.Config{
+			BatchSize: batchSize,
+			Workers:   workers,
+		})
+	}
+
 	// Load vectors
 	fmt.Fprintf(os.Stderr, "Loading vectors from %s...\n", filePath)
 	loadStart := time.Now()
@@ -221,3 +239,82 @@ func printSyncSummary(stats *ingest.Stats, verbose bool) {
 	fmt.Printf("Throughput:          %.0f vectors/sec\n", stats.VectorsPerSecond())
 	fmt.Println()
 }
+
+// runSyncStream deduplicates the file in mini-batches and uploads unique
+// vectors as they are found, without loading the file into memory.
+func runSyncStream(ctx context.Context, cmd *cobra.Command, filePath string, pcCfg pc.Config, ingestCfg ingest.Config) error {
+	dedupEnabled, _ := cmd.Flags().GetBool("dedup")
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	clusters, _ := cmd.Flags().GetInt("clusters")
+	workers, _ := cmd.Flags().GetInt("workers")
+	verbose := viper.GetBool("verbose")
+
+	fmt.Fprintf(os.Stderr, "Connecting to Pinecone index %q...\n", pcCfg.IndexName)
+	client, err := pc.NewClient(ctx, pcCfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Pinecone: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// Unique vectors are re-encoded as JSONL into the ingestion pipeline.
+	pr, pw := io.Pipe()
+	dedupDone := make(chan *types.DeduplicationResult, 1)
+	go func() {
+		enc := json.NewEncoder(pw)
+		emit := func(v types.Vector) error {
+			return enc.Encode(map[string]interface{}{"id": v.ID, "values": v.Values, "metadata": v.Metadata})
+		}
+		if !dedupEnabled {
+			_ = pw.CloseWithError(readVectorsFromFile(filePath, emit))
+			dedupDone <- nil
+			return
+		}
+		engine := dedup.NewEngine(dedup.Config{
+			Threshold:     threshold,
+			K:             clusters,
+			MaxIterations: 10,
+			Workers:       workers,
+		})
+		result, err := streamDedupFile(ctx, engine, filePath, emit)
+		_ = pw.CloseWithError(err)
+		dedupDone <- result
+	}()
+
+	fmt.Fprintln(os.Stderr, "Streaming upload...")
+	bar := progressbar.NewOptions64(-1,
+		progressbar.OptionSetDescription("Uploading"),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetItsString("vectors"),
+		progressbar.OptionThrottle(100*time.Millisecond),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+	var lastUploaded int64
+	stats, err := ingest.NewPipeline(client, ingestCfg).IngestReader(ctx, pr, func(stats ingest.Stats) {
+		current := stats.UploadedVectors + stats.FailedVectors
+		if delta := current - lastUploaded; delta > 0 {
+			_ = bar.Add64(delta)
+			lastUploaded = current
+		}
+	})
+	_ = pr.Close()
+	result := <-dedupDone
+	_ = bar.Finish()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("ingestion failed: %w", err)
+	}
+
+	if result != nil {
+		fmt.Fprintf(os.Stderr, "Deduplication complete: %d unique vectors (removed %d duplicates, %.1f%% savings)\n",
+			result.TotalProcessed-result.DuplicateCount, result.DuplicateCount, result.SavingsPercent())
+	}
+	printSyncSummary(stats, verbose)
+
+	if stats.FailedVectors > 0 {
+		return fmt.Errorf("%d vectors failed to upload", stats.FailedVectors)
+	}
+	return nil
+}
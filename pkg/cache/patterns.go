@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"strings"
 
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -254,10 +255,9 @@ func (d *PatternDetector) annotate(pt PatternType, tokens int, text string) *Cac
 	}
 }
 
-// estimateTokens approximates the token count of text using the common
-// 4-chars-per-token heuristic.
+// estimateTokens counts tokens with the default tokenizer.
 func estimateTokens(text string) int {
-	return (len(text) + 3) / 4
+	return tokenizer.Count(text)
 }
 
 // classifyPattern determines the pattern type.
@@ -99,12 +99,12 @@ func hashPrefix(chunks []types.Chunk) string {
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
-// estimatePrefixTokens approximates the token count of the prefix using the
-// 4-chars-per-token heuristic.
+// estimatePrefixTokens counts the tokens of the prefix with the default
+// tokenizer.
 func estimatePrefixTokens(chunks []types.Chunk) int {
 	total := 0
 	for _, c := range chunks {
-		total += (len(c.Text) + 3) / 4
+		total += estimateTokens(c.Text)
 	}
 	return total
 }
@@ -0,0 +1,80 @@
+package compress
+
+import (
+	"context"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// AutoCompressor routes each chunk to the strategy that suits its content:
+// structured tool output (JSON, XML, tables) goes to the placeholder
+// compressor, logs and stack traces to the log compressor, source code and
+// diffs to the code compressor, everything else
+// through the pruner and then extractive selection.
+type AutoCompressor struct {
+	// Structured compresses chunks detected as structured content.
+	Structured *PlaceholderCompressor
+
+	// Log compresses chunks detected as logs, test output, or stack traces.
+	Log *LogCompressor
+
+	// Code compresses chunks detected as source code or unified diffs.
+	Code *CodeCompressor
+
+	// Prose compresses all other chunks.
+	Prose Compressor
+}
+
+// NewAutoCompressor creates an auto-selecting compressor with defaults.
+func NewAutoCompressor() *AutoCompressor {
+	return &AutoCompressor{
+		Structured: NewPlaceholderCompressor(),
+		Log:        NewLogCompressor(),
+		Code:       NewCodeCompressor(),
+		Prose:      NewPipeline(NewPruner(), NewExtractiveCompressor()),
+	}
+}
+
+// Compress compresses each chunk with the strategy chosen for it, keeping
+// the input order.
+func (a *AutoCompressor) Compress(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, Stats, error) {
+	start := time.Now()
+	stats := Stats{}
+
+	// Chunks are compressed one at a time; embed the query only once.
+	opts, err := PrepareQuery(ctx, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	result := make([]types.Chunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		var c Compressor = a.Prose
+		switch {
+		case a.Structured.IsStructured(chunk.Text):
+			c = a.Structured
+		case LooksLikeLog(chunk.Text):
+			c = a.Log
+		case LooksLikeCode(chunk.Text):
+			c = a.Code
+		}
+
+		out, s, err := c.Compress(ctx, []types.Chunk{chunk}, opts)
+		if err != nil {
+			return nil, Stats{}, err
+		}
+		result = append(result, out...)
+		stats.InputTokens += s.InputTokens
+		stats.OutputTokens += s.OutputTokens
+		stats.ChunksProcessed += s.ChunksProcessed
+		stats.ChunksSkipped += s.ChunksSkipped
+	}
+
+	stats.Latency = time.Since(start)
+	if stats.InputTokens > 0 {
+		stats.ReductionPercent = float64(stats.InputTokens-stats.OutputTokens) / float64(stats.InputTokens) * 100
+	}
+
+	return result, stats, nil
+}
@@ -0,0 +1,426 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// Languages reported by DetectLanguage.
+const (
+	LangGo         = "go"
+	LangPython     = "python"
+	LangJavaScript = "javascript"
+	LangJava       = "java"
+	LangRust       = "rust"
+	LangC          = "c"
+	LangDiff       = "diff"
+)
+
+// CodeCompressor shrinks source code and unified diffs without breaking
+// their structure. For code it keeps imports, type declarations and
+// function signatures and collapses function bodies to "{ ... }" with the
+// line range they covered. For diffs it keeps file and hunk headers and
+// changed lines and trims unchanged context.
+type CodeCompressor struct {
+	// DiffContextLines is how many unchanged lines to keep next to each
+	// change in a diff hunk.
+	DiffContextLines int
+
+	// MinBodyLines is the smallest function body that gets collapsed;
+	// shorter bodies are kept verbatim.
+	MinBodyLines int
+}
+
+// NewCodeCompressor creates a code compressor with default settings.
+func NewCodeCompressor() *CodeCompressor {
+	return &CodeCompressor{
+		DiffContextLines: 1,
+		MinBodyLines:     2,
+	}
+}
+
+// Compress collapses function bodies and diff context in chunks that look
+// like code. Other chunks pass through unchanged.
+func (c *CodeCompressor) Compress(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, Stats, error) {
+	start := time.Now()
+	stats := Stats{}
+
+	counter := tokenizer.OrDefault(opts.Tokenizer)
+	result := make([]types.Chunk, 0, len(chunks))
+
+	for _, chunk := range chunks {
+		inputTokens := counter.Count(chunk.Text)
+		stats.InputTokens += inputTokens
+
+		lang := DetectLanguage(chunk.Text)
+		if len(chunk.Text) < opts.MinChunkLength || lang == "" {
+			stats.ChunksSkipped++
+			stats.OutputTokens += inputTokens
+			result = append(result, chunk)
+			continue
+		}
+
+		compressed := c.compressCode(chunk.Text, lang)
+		stats.ChunksProcessed++
+		stats.OutputTokens += counter.Count(compressed)
+
+		newChunk := chunk.Clone()
+		newChunk.Text = compressed
+		recordProvenance(chunk, newChunk, string(ModeCode), opts)
+		result = append(result, *newChunk)
+	}
+
+	stats.Latency = time.Since(start)
+	if stats.InputTokens > 0 {
+		stats.ReductionPercent = float64(stats.InputTokens-stats.OutputTokens) / float64(stats.InputTokens) * 100
+	}
+
+	return result, stats, nil
+}
+
+// compressCode dispatches on the detected language.
+func (c *CodeCompressor) compressCode(text, lang string) string {
+	lines := strings.Split(text, "\n")
+	switch lang {
+	case LangDiff:
+		return strings.Join(c.compressDiff(lines), "\n")
+	case LangPython:
+		return strings.Join(c.collapseIndented(lines), "\n")
+	default:
+		return strings.Join(c.collapseBraces(lines, lang), "\n")
+	}
+}
+
+// containerPattern matches block openers whose contents are declarations
+// (types, classes, interfaces) rather than executable bodies.
+var containerPattern = regexp.MustCompile(
+	`\b(struct|interface|class|enum|trait|impl|namespace|union|record|object|module)\b`)
+
+// collapseBraces collapses brace-delimited bodies, descending into type and
+// class declarations so their method bodies collapse too.
+func (c *CodeCompressor) collapseBraces(lines []string, lang string) []string {
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		delta := braceDelta(line, lang)
+		if delta <= 0 || containerPattern.MatchString(stripCode(line, lang)) {
+			out = append(out, line)
+			continue
+		}
+
+		// Find the line closing this block.
+		depth, j := delta, i
+		for depth > 0 && j+1 < len(lines) {
+			j++
+			depth += braceDelta(lines[j], lang)
+		}
+		if depth > 0 || j-i-1 < c.MinBodyLines {
+			out = append(out, line)
+			continue
+		}
+
+		open := strings.LastIndex(line, "{")
+		closing := strings.TrimSpace(lines[j])
+		suffix := ""
+		if strings.HasPrefix(closing, "}") {
+			suffix = closing[1:]
+		}
+		out = append(out, fmt.Sprintf("%s { ... }%s // lines %d-%d",
+			strings.TrimRight(line[:open], " \t"), suffix, i+2, j))
+		i = j
+	}
+	return out
+}
+
+// pyBlockPattern matches Python function definitions.
+var pyBlockPattern = regexp.MustCompile(`^(\s*)(async\s+)?def\s`)
+
+// collapseIndented collapses Python function bodies, keeping decorators,
+// signatures, class statements and module-level code.
+func (c *CodeCompressor) collapseIndented(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := pyBlockPattern.FindStringSubmatch(line)
+		if m == nil {
+			out = append(out, line)
+			continue
+		}
+		indent := len(m[1])
+
+		// Signatures may span lines. A body on the signature's own line,
+		// as in "def f(): return x", leaves nothing to collapse.
+		sigEnd, inline := pySignatureEnd(lines, i)
+		out = append(out, lines[i:sigEnd+1]...)
+		if inline {
+			i = sigEnd
+			continue
+		}
+
+		// The body is every following line indented deeper than the def,
+		// ignoring blank lines.
+		end := sigEnd
+		for k := sigEnd + 1; k < len(lines); k++ {
+			if strings.TrimSpace(lines[k]) == "" {
+				continue
+			}
+			if indentOf(lines[k]) <= indent {
+				break
+			}
+			end = k
+		}
+		if end-sigEnd < c.MinBodyLines {
+			out = append(out, lines[sigEnd+1:end+1]...)
+			i = end
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s    ...  # lines %d-%d", m[1], sigEnd+2, end+1))
+		i = end
+	}
+	return out
+}
+
+// pySignatureEnd returns the line holding the colon that closes the header
+// of the def at lines[i], and whether a body follows that colon on the same
+// line. Colons inside brackets or strings (annotations, lambdas, slices)
+// don't close the header.
+func pySignatureEnd(lines []string, i int) (end int, inline bool) {
+	depth := 0
+	for k := i; k < len(lines); k++ {
+		code := stripPyComment(lines[k])
+		var quote rune
+		escaped := false
+		for j, r := range code {
+			switch {
+			case quote != 0:
+				if escaped {
+					escaped = false
+				} else if r == '\\' {
+					escaped = true
+				} else if r == quote {
+					quote = 0
+				}
+			case r == '"' || r == '\'':
+				quote = r
+			case r == '(' || r == '[' || r == '{':
+				depth++
+			case r == ')' || r == ']' || r == '}':
+				depth--
+			case r == ':' && depth == 0:
+				return k, strings.TrimSpace(code[j+1:]) != ""
+			}
+		}
+	}
+	return len(lines) - 1, false
+}
+
+// compressDiff keeps headers and changed lines of a unified diff and
+// replaces runs of unchanged context with a marker.
+func (c *CodeCompressor) compressDiff(lines []string) []string {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if !isDiffContext(line) {
+			keep[i] = true
+			continue
+		}
+		for d := 1; d <= c.DiffContextLines; d++ {
+			if (i-d >= 0 && isDiffChange(lines[i-d])) || (i+d < len(lines) && isDiffChange(lines[i+d])) {
+				keep[i] = true
+				break
+			}
+		}
+	}
+
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if keep[i] {
+			out = append(out, lines[i])
+			continue
+		}
+		j := i
+		for j+1 < len(lines) && !keep[j+1] {
+			j++
+		}
+		out = append(out, fmt.Sprintf(" ... (%d unchanged lines)", j-i+1))
+		i = j
+	}
+	return out
+}
+
+func isDiffChange(line string) bool {
+	return (strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++")) ||
+		(strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---"))
+}
+
+func isDiffContext(line string) bool {
+	return strings.HasPrefix(line, " ")
+}
+
+// braceDelta returns opening minus closing braces on a line, ignoring
+// braces inside string literals and line comments.
+func braceDelta(line, lang string) int {
+	delta := 0
+	for _, r := range stripCode(line, lang) {
+		switch r {
+		case '{':
+			delta++
+		case '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+// maxCharLiteral bounds how far a char literal's closing quote may follow
+// its opening one; '\u{1F600}' is the longest escape.
+const maxCharLiteral = 10
+
+// stripCode removes string/char literals and // comments from a line.
+// Only JavaScript quotes strings with '; elsewhere a ' opens a char literal
+// only when its closing quote follows within a few runes, so Rust
+// lifetimes such as &'a str don't swallow the rest of the line.
+func stripCode(line, lang string) string {
+	runes := []rune(line)
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for i, r := range runes {
+		switch {
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '`':
+			quote = r
+		case r == '\'' && (lang == LangJavaScript || isCharLiteral(runes[i:])):
+			quote = r
+		case r == '/' && i > 0 && runes[i-1] == '/':
+			s := b.String()
+			return s[:len(s)-1]
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isCharLiteral reports whether the quote at runes[0] opens a char
+// literal: one rune, or an escape sequence, then a closing quote.
+func isCharLiteral(runes []rune) bool {
+	if len(runes) < 3 {
+		return false
+	}
+	if runes[1] != '\\' {
+		return runes[2] == '\''
+	}
+	for k := 3; k < len(runes) && k <= maxCharLiteral; k++ {
+		if runes[k] == '\'' {
+			return true
+		}
+	}
+	return false
+}
+
+func stripPyComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func indentOf(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// languageSignals are line patterns that vote for a language.
+var languageSignals = []struct {
+	lang    string
+	pattern *regexp.Regexp
+}{
+	{LangGo, regexp.MustCompile(`^package \w+$`)},
+	{LangGo, regexp.MustCompile(`^func (\([^)]*\) )?\w+\(`)},
+	{LangGo, regexp.MustCompile(`^type \w+ (struct|interface) \{`)},
+	{LangGo, regexp.MustCompile(`\w+ := `)},
+	{LangPython, regexp.MustCompile(`^\s*(async\s+)?def \w+\(.*`)},
+	{LangPython, regexp.MustCompile(`^\s*class \w+(\(.*\))?:\s*$`)},
+	{LangPython, regexp.MustCompile(`^from [\w.]+ import `)},
+	{LangPython, regexp.MustCompile(`^import \w+(\.\w+)*$`)},
+	{LangJavaScript, regexp.MustCompile(`\bfunction\s*\w*\s*\(`)},
+	{LangJavaScript, regexp.MustCompile(`=>\s*[{(]`)},
+	{LangJavaScript, regexp.MustCompile(`^import .* from ['"]`)},
+	{LangJavaScript, regexp.MustCompile(`^(export )?(const|let) \w+ = `)},
+	{LangJava, regexp.MustCompile(`^import [\w.]+(\.\*)?;$`)},
+	{LangJava, regexp.MustCompile(`\b(public|private|protected)( static)? [\w<>\[\]]+ \w+\(`)},
+	{LangJava, regexp.MustCompile(`^(public )?(final )?(class|interface) \w+`)},
+	{LangRust, regexp.MustCompile(`^\s*(pub )?fn \w+`)},
+	{LangRust, regexp.MustCompile(`^use [\w:]+`)},
+	{LangRust, regexp.MustCompile(`^\s*impl\b`)},
+	{LangC, regexp.MustCompile(`^#include [<"]`)},
+	{LangC, regexp.MustCompile(`^(static )?(int|void|char|size_t|bool)\s+\**\w+\(`)},
+}
+
+// DetectLanguage guesses the programming language of text, returning
+// LangDiff for unified diffs and "" when the text does not look like code.
+func DetectLanguage(text string) string {
+	lines := strings.Split(text, "\n")
+
+	hunks, headers := 0, 0
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			hunks++
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			headers++
+		}
+	}
+	if hunks > 0 && headers > 0 {
+		return LangDiff
+	}
+
+	votes := make(map[string]int)
+	for _, line := range lines {
+		for _, sig := range languageSignals {
+			if sig.pattern.MatchString(line) {
+				votes[sig.lang]++
+			}
+		}
+	}
+
+	best, bestVotes := "", 0
+	for _, lang := range []string{LangGo, LangPython, LangJavaScript, LangJava, LangRust, LangC} {
+		if votes[lang] > bestVotes {
+			best, bestVotes = lang, votes[lang]
+		}
+	}
+	// One stray match is not enough to call prose code.
+	if bestVotes < 2 {
+		return ""
+	}
+	return best
+}
+
+// LooksLikeCode reports whether text is source code or a unified diff.
+func LooksLikeCode(text string) bool {
+	return DetectLanguage(text) != ""
+}
@@ -4,8 +4,13 @@ package compress
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/embedding"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -17,16 +22,78 @@ const (
 	ModeExtractive Mode = "extractive"
 	// ModePlaceholder replaces verbose outputs with compact summaries.
 	ModePlaceholder Mode = "placeholder"
-	// ModeHybrid combines extractive and placeholder strategies.
+	// ModeHybrid combines extractive and placeholder strategies. Logs and
+	// stack traces are detected and sent to the log compressor.
 	ModeHybrid Mode = "hybrid"
+	// ModePruner removes filler phrases and redundant patterns.
+	ModePruner Mode = "pruner"
+	// ModeAuto picks a strategy per chunk: placeholder for structured tool
+	// output (JSON, XML, tables), log for build/test output and stack
+	// traces, code for source files and diffs, pruner followed by
+	// extractive for prose.
+	ModeAuto Mode = "auto"
+	// ModeCode collapses function bodies in source code and trims context
+	// lines in unified diffs.
+	ModeCode Mode = "code"
+	// ModeLog collapses repeated and templated log lines and trims stack
+	// traces, keeping every error and warning.
+	ModeLog Mode = "log"
 )
 
+// ErrUnknownMode is returned by ForMode and NewChain for unsupported modes.
+var ErrUnknownMode = errors.New("unknown compression mode")
+
+// Modes returns the supported compression modes.
+func Modes() []Mode {
+	return []Mode{ModeExtractive, ModePlaceholder, ModeHybrid, ModePruner, ModeAuto, ModeCode, ModeLog}
+}
+
+// ForMode returns the compressor implementing mode.
+func ForMode(mode Mode) (Compressor, error) {
+	switch Mode(strings.ToLower(string(mode))) {
+	case ModeExtractive:
+		return NewExtractiveCompressor(), nil
+	case ModePlaceholder:
+		return NewPlaceholderCompressor(), nil
+	case ModeHybrid:
+		return NewPipeline(NewPlaceholderCompressor(), NewExtractiveCompressor()), nil
+	case ModePruner:
+		return NewPruner(), nil
+	case ModeAuto:
+		return NewAutoCompressor(), nil
+	case ModeCode:
+		return NewCodeCompressor(), nil
+	case ModeLog:
+		return NewLogCompressor(), nil
+	default:
+		return nil, fmt.Errorf("%w %q (supported: extractive, placeholder, hybrid, pruner, auto, code, log)", ErrUnknownMode, mode)
+	}
+}
+
+// NewChain builds a Pipeline that applies the given modes in order, e.g.
+// [pruner, extractive].
+func NewChain(modes ...Mode) (*Pipeline, error) {
+	compressors := make([]Compressor, 0, len(modes))
+	for _, m := range modes {
+		c, err := ForMode(m)
+		if err != nil {
+			return nil, err
+		}
+		compressors = append(compressors, c)
+	}
+	return NewPipeline(compressors...), nil
+}
+
 // Options configures compression behavior.
 type Options struct {
 	// TargetReduction is the desired reduction ratio (e.g., 0.3 = reduce to 30% of original).
+	// The code and log compressors shrink by structure and ignore it.
 	TargetReduction float64
 
 	// PreserveStructure keeps JSON/code structure intact when possible.
+	// Chunks that look like source code or diffs are compressed by the
+	// CodeCompressor, and logs by the LogCompressor, instead of sentence
+	// extraction.
 	PreserveStructure bool
 
 	// Mode selects the compression strategy.
@@ -37,6 +104,32 @@ type Options struct {
 
 	// MaxOutputTokens caps the total output tokens (0 = no limit).
 	MaxOutputTokens int
+
+	// Tokenizer counts tokens for stats and targets. Nil uses the
+	// package-wide default from pkg/tokenizer.
+	Tokenizer tokenizer.Counter
+
+	// Query conditions extractive compression on what the agent is asking:
+	// sentences that overlap with the query score higher and are kept.
+	Query string
+
+	// QueryEmbedding is the query's embedding. When empty and Embedder is
+	// set, the Query text is embedded instead; callers compressing chunks
+	// in several calls should embed it once with PrepareQuery.
+	QueryEmbedding []float32
+
+	// Embedder embeds sentences (in one batch per chunk) so they can be
+	// compared with the query embedding. Nil scores by lexical overlap only.
+	Embedder embedding.Provider
+
+	// QueryWeight is the share of a sentence's score taken from its query
+	// relevance, in (0, 1]. Zero uses DefaultQueryWeight.
+	QueryWeight float64
+
+	// Explain records a Provenance under ProvenanceMetadataKey on every
+	// chunk a compressor rewrites. Aligning each output with its input
+	// costs time, so it is off unless the caller reports on the result.
+	Explain bool
 }
 
 // DefaultOptions returns sensible defaults for compression.
@@ -102,13 +195,20 @@ func (p *Pipeline) Compress(ctx context.Context, chunks []types.Chunk, opts Opti
 	result := chunks
 	var totalStats Stats
 
-	for _, c := range p.compressors {
+	opts, err := PrepareQuery(ctx, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	for i, c := range p.compressors {
 		compressed, stats, err := c.Compress(ctx, result, opts)
 		if err != nil {
 			return nil, Stats{}, err
 		}
 		result = compressed
-		totalStats.InputTokens = stats.InputTokens
+		if i == 0 {
+			totalStats.InputTokens = stats.InputTokens
+		}
 		totalStats.OutputTokens = stats.OutputTokens
 		totalStats.ChunksProcessed += stats.ChunksProcessed
 		totalStats.ChunksSkipped += stats.ChunksSkipped
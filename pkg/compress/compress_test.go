@@ -2,6 +2,9 @@ package compress
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Siddhant-K-code/distill/pkg/types"
@@ -209,6 +212,49 @@ func TestPipeline(t *testing.T) {
 	}
 }
 
+func TestForMode(t *testing.T) {
+	for _, m := range Modes() {
+		if _, err := ForMode(m); err != nil {
+			t.Errorf("ForMode(%q) error = %v", m, err)
+		}
+	}
+	if _, err := ForMode("abstractive"); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("expected ErrUnknownMode, got %v", err)
+	}
+	if _, err := NewChain(ModePruner, "bogus"); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("expected ErrUnknownMode from NewChain, got %v", err)
+	}
+}
+
+func TestAutoCompressor(t *testing.T) {
+	ctx := context.Background()
+	jsonOutput := `[{"id": 1, "status": "ok", "payload": "aaaaaaaaaa"}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}]`
+	prose := "As mentioned earlier, this is the first important sentence. " +
+		"Basically, this is the second sentence. " +
+		"It is important to note that this is the third sentence. " +
+		"This is the fourth sentence with key information."
+
+	chunks := []types.Chunk{{ID: "json", Text: jsonOutput}, {ID: "prose", Text: prose}}
+	opts := Options{TargetReduction: 0.5, PreserveStructure: true, MinChunkLength: 10}
+
+	result, stats, err := NewAutoCompressor().Compress(ctx, chunks, opts)
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if len(result) != 2 || result[0].ID != "json" || result[1].ID != "prose" {
+		t.Fatalf("expected chunk order preserved, got %+v", result)
+	}
+	if !contains(result[0].Text, "more") {
+		t.Errorf("expected JSON to be placeholder-compressed, got %q", result[0].Text)
+	}
+	if containsLower(result[1].Text, "basically") || len(result[1].Text) >= len(prose) {
+		t.Errorf("expected prose to be pruned and shortened, got %q", result[1].Text)
+	}
+	if stats.OutputTokens >= stats.InputTokens {
+		t.Errorf("expected reduction, got %d -> %d", stats.InputTokens, stats.OutputTokens)
+	}
+}
+
 func TestEstimateTokens(t *testing.T) {
 	tests := []struct {
 		input string
@@ -274,3 +320,546 @@ func toLower(s string) string {
 	}
 	return string(result)
 }
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"go", "package main\n\nfunc main() {\n\tx := 1\n}", LangGo},
+		{"python", "import os\n\ndef main():\n    pass", LangPython},
+		{"diff", "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n-a\n+b", LangDiff},
+		{"prose", "The function of this team is to import goods. We package them.", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.input); got != tt.want {
+			t.Errorf("%s: DetectLanguage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCodeCompressor_Go(t *testing.T) {
+	src := `package store
+
+import "fmt"
+
+type Store struct {
+	name string
+}
+
+func (s *Store) Get(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	v := s.name + key
+	return v, nil
+}
+
+func New(name string) *Store {
+	return &Store{name: name}
+}`
+
+	result, stats, err := NewCodeCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: src}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	for _, want := range []string{
+		`import "fmt"`,
+		"type Store struct {",
+		"\tname string",
+		"func (s *Store) Get(key string) (string, error) { ... } // lines 10-14",
+		"return &Store{name: name}", // one-line body kept
+	} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if contains(out, "empty key") {
+		t.Errorf("expected Get body to be collapsed, got:\n%s", out)
+	}
+	if stats.ChunksProcessed != 1 || stats.OutputTokens >= stats.InputTokens {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCodeCompressor_Python(t *testing.T) {
+	src := `import os
+
+class Loader:
+    def load(self, path):
+        with open(path) as f:
+            data = f.read()
+        return data.strip()
+
+def main():
+    print(Loader().load(os.environ["P"]))`
+
+	result, _, err := NewCodeCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: src}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	for _, want := range []string{"class Loader:", "    def load(self, path):", "        ...  # lines 5-7", "def main():"} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if contains(out, "f.read()") {
+		t.Errorf("expected load body to be collapsed, got:\n%s", out)
+	}
+}
+
+func TestCodeCompressor_RustLifetimes(t *testing.T) {
+	src := `use std::fmt;
+
+pub fn first<'a>(items: &'a [String], sep: &str) -> &'a str {
+    let sep = '{';
+    let quote = '\'';
+    items[0]
+}
+
+pub fn last(items: &[String]) -> String {
+    items[items.len() - 1].clone()
+}`
+
+	result, _, err := NewCodeCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: src}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	want := "pub fn first<'a>(items: &'a [String], sep: &str) -> &'a str { ... } // lines 4-6"
+	if !contains(out, want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out)
+	}
+	if !contains(out, "pub fn last(items: &[String]) -> String {") {
+		t.Errorf("expected the next function kept, got:\n%s", out)
+	}
+}
+
+func TestCodeCompressor_PythonOneLineDef(t *testing.T) {
+	src := `import os
+
+def name(): return os.name
+
+if os.name == "nt":
+    print(name())
+    print("windows")
+
+def load(path: str) -> str:
+    with open(path) as f:
+        data = f.read()
+    return data.strip()`
+
+	result, _, err := NewCodeCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: src}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	for _, want := range []string{
+		"def name(): return os.name",
+		"def load(path: str) -> str:",
+		"    print(name())",
+		`    print("windows")`,
+		"    ...  # lines 10-12",
+	} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestCodeCompressor_Diff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,9 +1,9 @@
+ package main
+ 
+ import "fmt"
+ 
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("world")
+ 	x := 1
+ 	y := 2
+ 	fmt.Println(x + y)
+ }`
+
+	result, _, err := NewCodeCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: diff}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	for _, want := range []string{
+		"+++ b/main.go",
+		"@@ -1,9 +1,9 @@",
+		" ... (4 unchanged lines)",
+		" func main() {",
+		`-	fmt.Println("hello")`,
+		`+	fmt.Println("world")`,
+		" 	x := 1",
+		" ... (3 unchanged lines)",
+	} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestExtractive_PreserveStructureCode(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\ta := 1\n\tb := 2\n\tprintln(a + b)\n}"
+	chunks := []types.Chunk{{ID: "1", Text: src}}
+
+	result, _, err := NewExtractiveCompressor().Compress(context.Background(), chunks,
+		Options{TargetReduction: 0.5, PreserveStructure: true})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if want := "func main() { ... } // lines 4-6"; !contains(result[0].Text, want) {
+		t.Errorf("expected code compression with PreserveStructure, got:\n%s", result[0].Text)
+	}
+
+	// Pruning must leave code layout alone when structure is preserved.
+	pruned, _, _ := NewPruner().Compress(context.Background(), chunks, Options{PreserveStructure: true})
+	if pruned[0].Text != src {
+		t.Errorf("expected pruner to skip code, got:\n%s", pruned[0].Text)
+	}
+}
+
+// topicEmbedder embeds text as counts of a few topic words and records how
+// many single and batch calls it receives.
+type topicEmbedder struct {
+	topics     []string
+	embedCalls int
+	batchCalls int
+}
+
+func (e *topicEmbedder) vector(text string) []float32 {
+	v := make([]float32, len(e.topics))
+	for i, t := range e.topics {
+		v[i] = float32(strings.Count(strings.ToLower(text), t))
+	}
+	return v
+}
+
+func (e *topicEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.embedCalls++
+	return e.vector(text), nil
+}
+
+func (e *topicEmbedder) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	e.batchCalls++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = e.vector(t)
+	}
+	return out, nil
+}
+
+func (e *topicEmbedder) Dimension() int    { return len(e.topics) }
+func (e *topicEmbedder) ModelName() string { return "topic" }
+
+func TestExtractive_QueryConditioned(t *testing.T) {
+	ctx := context.Background()
+	text := "This important overview must be read first. " +
+		"The cache layer stores rendered pages for ten minutes. " +
+		"Database migrations run automatically on deploy. " +
+		"Operators should review the release notes key points."
+	chunks := []types.Chunk{{ID: "1", Text: text}}
+	compressor := NewExtractiveCompressor()
+
+	// Without a query the generic signals win.
+	plain, _, err := compressor.Compress(ctx, chunks, Options{TargetReduction: 0.3})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if contains(plain[0].Text, "migrations") {
+		t.Fatalf("expected unconditioned compression to drop the migration sentence, got %q", plain[0].Text)
+	}
+
+	// Lexical overlap alone pulls the matching sentence in.
+	lexical, _, err := compressor.Compress(ctx, chunks, Options{
+		TargetReduction: 0.3,
+		Query:           "When do database migrations run?",
+	})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if !contains(lexical[0].Text, "migrations run automatically") {
+		t.Errorf("expected query-relevant sentence to be kept, got %q", lexical[0].Text)
+	}
+
+	// Embedding similarity finds the sentence without shared words.
+	embedder := &topicEmbedder{topics: []string{"cache", "database", "release"}}
+	semantic, _, err := compressor.Compress(ctx, chunks, Options{
+		TargetReduction: 0.3,
+		QueryEmbedding:  []float32{1, 0, 0},
+		Embedder:        embedder,
+	})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if !contains(semantic[0].Text, "cache layer") {
+		t.Errorf("expected embedding-relevant sentence to be kept, got %q", semantic[0].Text)
+	}
+	if embedder.batchCalls != 1 {
+		t.Errorf("expected sentences embedded in 1 batch, got %d calls", embedder.batchCalls)
+	}
+}
+
+func TestPrepareQuery_EmbedsOnce(t *testing.T) {
+	ctx := context.Background()
+	text := "The cache layer stores rendered pages for ten minutes. " +
+		"Database migrations run automatically on deploy. " +
+		"Operators should review the release notes key points."
+	chunks := []types.Chunk{{ID: "1", Text: text}, {ID: "2", Text: text}, {ID: "3", Text: text}}
+
+	embedder := &topicEmbedder{topics: []string{"cache", "database", "release"}}
+	opts := Options{TargetReduction: 0.3, Query: "How long are pages cached?", Embedder: embedder}
+	if _, _, err := NewAutoCompressor().Compress(ctx, chunks, opts); err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if embedder.embedCalls != 1 {
+		t.Errorf("expected the query embedded once, got %d calls", embedder.embedCalls)
+	}
+
+	prepared, err := PrepareQuery(ctx, opts)
+	if err != nil {
+		t.Fatalf("PrepareQuery() error = %v", err)
+	}
+	embedder.embedCalls = 0
+	if _, _, err := NewExtractiveCompressor().Compress(ctx, chunks, prepared); err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if embedder.embedCalls != 0 {
+		t.Errorf("expected a prepared query not embedded again, got %d calls", embedder.embedCalls)
+	}
+}
+
+func TestLogCompressor(t *testing.T) {
+	logText := `2024-05-01T10:00:01Z INFO fetching shard 1 of 40 from 10.0.0.11
+2024-05-01T10:00:02Z INFO fetching shard 2 of 40 from 10.0.0.12
+2024-05-01T10:00:03Z INFO fetching shard 3 of 40 from 10.0.0.13
+2024-05-01T10:00:04Z INFO fetching shard 4 of 40 from 10.0.0.14
+2024-05-01T10:00:05Z WARN shard 5 slow response
+2024-05-01T10:00:06Z INFO fetching shard 6 of 40 from 10.0.0.16
+2024-05-01T10:00:07Z ERROR connection reset by peer
+2024-05-01T10:00:07Z ERROR connection reset by peer
+2024-05-01T10:00:09Z INFO done`
+
+	chunks := []types.Chunk{{ID: "log", Text: logText}}
+	result, stats, err := NewLogCompressor().Compress(context.Background(), chunks, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	out := result[0].Text
+
+	for _, want := range []string{
+		"<*> INFO fetching shard <*> of <*> from <*>  [x4]\n2024-05-01T10:00:05Z WARN shard 5 slow response\n" +
+			"2024-05-01T10:00:06Z INFO fetching shard 6 of 40 from 10.0.0.16",
+		"2024-05-01T10:00:07Z ERROR connection reset by peer  [x2]",
+		"INFO done",
+	} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if contains(out, "10.0.0.12") {
+		t.Errorf("expected templated lines to be collapsed, got:\n%s", out)
+	}
+
+	summary, ok := result[0].Metadata[LogMetadataKey].(LogSummary)
+	if !ok {
+		t.Fatalf("expected LogSummary in metadata, got %#v", result[0].Metadata)
+	}
+	if summary.LinesIn != 9 || summary.LinesOut != 5 || summary.CollapsedLines != 4 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if len(summary.Templates) != 1 || summary.Templates[0].Count != 4 {
+		t.Errorf("expected one template of 4 lines, got %+v", summary.Templates)
+	}
+	if stats.ChunksProcessed != 1 || stats.OutputTokens >= stats.InputTokens {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLogCompressor_StackTraces(t *testing.T) {
+	javaTrace := `Exception in thread "main" java.lang.IllegalStateException: boom
+	at com.acme.Service.handle(Service.java:42)
+	at com.acme.Router.route(Router.java:17)
+	at com.acme.Router.dispatch(Router.java:9)
+	at com.acme.Server.serve(Server.java:88)
+	at com.acme.Main.main(Main.java:5)`
+
+	goPanic := `panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.lookup(...)
+	/src/app/main.go:12
+main.process(0xc000010000)
+	/src/app/main.go:20 +0x1d
+main.run()
+	/src/app/main.go:31 +0x25
+main.main()
+	/src/app/main.go:40 +0x17
+exit status 2`
+
+	c := NewLogCompressor()
+	result, _, err := c.Compress(context.Background(),
+		[]types.Chunk{{ID: "java", Text: javaTrace}, {ID: "go", Text: goPanic}}, Options{})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+
+	java := result[0].Text
+	for _, want := range []string{"IllegalStateException: boom", "Service.handle", "... 3 frames omitted", "Main.main"} {
+		if !contains(java, want) {
+			t.Errorf("expected Java trace to contain %q, got:\n%s", want, java)
+		}
+	}
+	if contains(java, "Router") {
+		t.Errorf("expected middle frames dropped, got:\n%s", java)
+	}
+
+	goOut := result[1].Text
+	for _, want := range []string{"panic: runtime error", "main.lookup(...)", "... 2 frames omitted", "main.main()", "/src/app/main.go:40", "exit status 2"} {
+		if !contains(goOut, want) {
+			t.Errorf("expected Go trace to contain %q, got:\n%s", want, goOut)
+		}
+	}
+	if summary := result[1].Metadata[LogMetadataKey].(LogSummary); summary.FramesDropped != 2 {
+		t.Errorf("expected 2 frames dropped, got %+v", summary)
+	}
+}
+
+func TestHybrid_DetectsLogs(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "2024-05-01 10:00:%02d INFO request id=%d served in %dms\n", i, 1000+i, i*3)
+	}
+	b.WriteString("2024-05-01 10:00:21 ERROR upstream timeout")
+
+	c, err := ForMode(ModeHybrid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _, err := c.Compress(context.Background(), []types.Chunk{{ID: "1", Text: b.String()}}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if !contains(result[0].Text, "[x20]") || !contains(result[0].Text, "ERROR upstream timeout") {
+		t.Errorf("expected hybrid mode to log-compress, got:\n%s", result[0].Text)
+	}
+	if _, ok := result[0].Metadata[LogMetadataKey]; !ok {
+		t.Error("expected log summary in metadata")
+	}
+	if LooksLikeLog("Plain prose. Nothing to see here.\nAnother line of prose.\nAnd a third.") {
+		t.Error("expected prose not to look like a log")
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	ctx := context.Background()
+	text := "Basically, the first sentence is important. " +
+		"The second sentence is filler. " +
+		"The third sentence has key details about 42 servers."
+	chunks := []types.Chunk{{ID: "1", Text: text}, {ID: "2", Text: "short"}}
+
+	chain := NewPipeline(NewPruner(), NewExtractiveCompressor())
+	result, _, err := chain.Compress(ctx, chunks, Options{TargetReduction: 0.8, Explain: true})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+
+	p, ok := ProvenanceOf(result[0])
+	if !ok {
+		t.Fatalf("expected provenance on compressed chunk, got %#v", result[0].Metadata)
+	}
+	if p.OriginalHash != HashText(text) || p.OriginalLength != len(text) {
+		t.Errorf("expected hash and length of the original, got %+v", p)
+	}
+	if len(p.Compressors) != 2 || p.Compressors[0] != "pruner" || p.Compressors[1] != "extractive" {
+		t.Errorf("expected [pruner extractive], got %v", p.Compressors)
+	}
+	if len(p.Kept) == 0 {
+		t.Fatal("expected kept spans")
+	}
+	// Every kept span must be verbatim text of the original at the
+	// recorded output offset, even after two compressors.
+	for _, s := range p.Kept {
+		kept := text[s.Start:s.End]
+		if got := result[0].Text[s.OutStart : s.OutStart+len(kept)]; got != kept {
+			t.Errorf("span %+v: original %q, output %q", s, kept, got)
+		}
+	}
+
+	if _, ok := ProvenanceOf(result[1]); ok {
+		t.Error("expected no provenance on an untouched chunk")
+	}
+
+	report := Explain(text, result[0])
+	for _, want := range []string{"- Basically,", "- The second sentence is filler.", "  The third sentence has key details about 42 servers."} {
+		if !contains(report, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, report)
+		}
+	}
+
+	// Without Explain no alignment is done and nothing is recorded.
+	result, _, err = chain.Compress(ctx, chunks, Options{TargetReduction: 0.8})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if _, ok := ProvenanceOf(result[0]); ok {
+		t.Error("expected no provenance without Explain")
+	}
+}
+
+func TestExplain_Placeholder(t *testing.T) {
+	orig := `[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}, {"id": 6}]`
+	result, _, err := NewPlaceholderCompressor().Compress(context.Background(),
+		[]types.Chunk{{ID: "1", Text: orig}}, Options{Explain: true})
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	p, ok := ProvenanceOf(result[0])
+	if !ok || p.Compressors[0] != "placeholder" {
+		t.Fatalf("expected placeholder provenance, got %+v", p)
+	}
+	report := Explain(orig, result[0])
+	if !contains(report, "- ") || !contains(report, "+ ") {
+		t.Errorf("expected dropped and inserted lines, got:\n%s", report)
+	}
+}
+
+func TestExtractive_CJK(t *testing.T) {
+	text := "会话存储在空闲三十分钟后使会话过期。清理程序每分钟运行一次。" +
+		"过期的记录会被分批删除。删除操作不会阻塞写入。所有操作都会记录到审计日志中。"
+	chunks := []types.Chunk{{ID: "zh", Text: text}}
+
+	result, _, err := NewExtractiveCompressor().Compress(context.Background(), chunks, Options{TargetReduction: 0.5})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	out := result[0].Text
+	if out == "" || len(out) >= len(text) {
+		t.Fatalf("expected shorter non-empty output, got %q", out)
+	}
+	// Every kept sentence is whole, ending in a CJK full stop.
+	for _, s := range strings.SplitAfter(strings.ReplaceAll(out, " ", ""), "。") {
+		if s != "" && !strings.Contains(text, s) {
+			t.Errorf("kept text %q is not a whole sentence of the input", s)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -13,7 +15,9 @@ import (
 // It uses sentence-level extraction based on embedding similarity to preserve
 // the most semantically relevant portions.
 type ExtractiveCompressor struct {
-	// SentenceDelimiters defines characters that end sentences.
+	// SentenceDelimiters lists extra characters that end sentences, on top
+	// of the language-aware terminators of pkg/nlp (including CJK full
+	// stops).
 	SentenceDelimiters string
 }
 
@@ -29,10 +33,15 @@ func (e *ExtractiveCompressor) Compress(ctx context.Context, chunks []types.Chun
 	start := time.Now()
 	stats := Stats{}
 
+	counter := tokenizer.OrDefault(opts.Tokenizer)
+	query, err := newQueryContext(ctx, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
 	result := make([]types.Chunk, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		inputTokens := estimateTokens(chunk.Text)
+		inputTokens := counter.Count(chunk.Text)
 		stats.InputTokens += inputTokens
 
 		if len(chunk.Text) < opts.MinChunkLength {
@@ -42,12 +51,34 @@ func (e *ExtractiveCompressor) Compress(ctx context.Context, chunks []types.Chun
 			continue
 		}
 
-		compressed := e.extractSalientSpans(chunk.Text, opts.TargetReduction)
+		if opts.PreserveStructure && LooksLikeLog(chunk.Text) {
+			// Logs and stack traces go to the log compressor, which keeps
+			// every error line and records what it dropped.
+			newChunk := NewLogCompressor().compressChunk(chunk, opts)
+			stats.ChunksProcessed++
+			stats.OutputTokens += counter.Count(newChunk.Text)
+			result = append(result, *newChunk)
+			continue
+		}
+
+		var compressed string
+		name := string(ModeExtractive)
+		if lang := DetectLanguage(chunk.Text); opts.PreserveStructure && lang != "" {
+			// Sentence splitting would shred code; collapse bodies instead.
+			compressed = NewCodeCompressor().compressCode(chunk.Text, lang)
+			name = string(ModeCode)
+		} else {
+			compressed, err = e.extractSalientSpans(ctx, chunk.Text, opts.TargetReduction, counter, query)
+			if err != nil {
+				return nil, Stats{}, err
+			}
+		}
 		stats.ChunksProcessed++
-		stats.OutputTokens += estimateTokens(compressed)
+		stats.OutputTokens += counter.Count(compressed)
 
 		newChunk := chunk.Clone()
 		newChunk.Text = compressed
+		recordProvenance(chunk, newChunk, name, opts)
 		result = append(result, *newChunk)
 	}
 
@@ -59,33 +90,54 @@ func (e *ExtractiveCompressor) Compress(ctx context.Context, chunks []types.Chun
 	return result, stats, nil
 }
 
-// extractSalientSpans selects the most important sentences to meet target reduction.
-func (e *ExtractiveCompressor) extractSalientSpans(text string, targetReduction float64) string {
+// extractSalientSpans selects the most important sentences to meet target
+// reduction. With a query, scores blend in each sentence's relevance to it.
+func (e *ExtractiveCompressor) extractSalientSpans(ctx context.Context, text string, targetReduction float64, counter tokenizer.Counter, query *queryContext) (string, error) {
 	sentences := e.splitSentences(text)
 	if len(sentences) <= 1 {
-		return text
+		return text, nil
 	}
 
 	// Score sentences by position and content signals
 	scored := make([]scoredSentence, len(sentences))
+	maxScore := 0.0
 	for i, s := range sentences {
 		scored[i] = scoredSentence{
 			text:  s,
 			index: i,
 			score: e.scoreSentence(s, i, len(sentences)),
 		}
+		if scored[i].score > maxScore {
+			maxScore = scored[i].score
+		}
+	}
+
+	// Blend in query relevance, normalising the generic score to [0, 1]
+	// so the two are on the same scale.
+	if query != nil {
+		relevance, err := query.relevance(ctx, sentences)
+		if err != nil {
+			return "", err
+		}
+		for i := range scored {
+			base := 0.0
+			if maxScore > 0 {
+				base = scored[i].score / maxScore
+			}
+			scored[i].score = (1-query.weight)*base + query.weight*relevance[i]
+		}
 	}
 
 	// Sort by score descending
 	sortByScore(scored)
 
 	// Select top sentences until we hit target
-	targetTokens := int(float64(estimateTokens(text)) * targetReduction)
+	targetTokens := int(float64(counter.Count(text)) * targetReduction)
 	var selected []scoredSentence
 	currentTokens := 0
 
 	for _, s := range scored {
-		tokens := estimateTokens(s.text)
+		tokens := counter.Count(s.text)
 		if currentTokens+tokens > targetTokens && len(selected) > 0 {
 			break
 		}
@@ -105,31 +157,12 @@ func (e *ExtractiveCompressor) extractSalientSpans(text string, targetReduction
 		result.WriteString(strings.TrimSpace(s.text))
 	}
 
-	return result.String()
+	return result.String(), nil
 }
 
 // splitSentences breaks text into sentences.
 func (e *ExtractiveCompressor) splitSentences(text string) []string {
-	var sentences []string
-	var current strings.Builder
-
-	for _, r := range text {
-		current.WriteRune(r)
-		if strings.ContainsRune(e.SentenceDelimiters, r) {
-			s := strings.TrimSpace(current.String())
-			if len(s) > 0 {
-				sentences = append(sentences, s)
-			}
-			current.Reset()
-		}
-	}
-
-	// Handle remaining text without delimiter
-	if remaining := strings.TrimSpace(current.String()); len(remaining) > 0 {
-		sentences = append(sentences, remaining)
-	}
-
-	return sentences
+	return nlp.SplitSentencesWith(text, e.SentenceDelimiters)
 }
 
 // scoreSentence assigns importance based on position and content.
@@ -144,8 +177,9 @@ func (e *ExtractiveCompressor) scoreSentence(sentence string, index, total int)
 		score += 1.0
 	}
 
-	// Length: prefer medium-length sentences
-	words := len(strings.Fields(sentence))
+	// Length: prefer medium-length sentences (words segmented per script,
+	// so CJK sentences are not counted as one word)
+	words := len(nlp.Words(sentence))
 	if words >= 5 && words <= 25 {
 		score += 1.0
 	}
@@ -194,10 +228,7 @@ func sortByIndex(sentences []scoredSentence) {
 	}
 }
 
-// estimateTokens provides a rough token count (avg 4 chars per token).
+// estimateTokens counts tokens with the default tokenizer.
 func estimateTokens(text string) int {
-	if len(text) == 0 {
-		return 0
-	}
-	return (len(text) + 3) / 4
+	return tokenizer.Count(text)
 }
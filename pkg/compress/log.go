@@ -0,0 +1,368 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// LogMetadataKey is the chunk metadata key under which LogCompressor
+// records a LogSummary of what it dropped.
+const LogMetadataKey = "log_compression"
+
+// LogCompressor shrinks build logs, test output, and stack traces. It keeps
+// every error and warning line, collapses runs of consecutive lines that
+// differ only by timestamps, IDs, or numbers into one template with a count
+// (drain-style clustering), and keeps only the first and last frames of
+// stack traces. Lines keep their original order.
+type LogCompressor struct {
+	// SimilarityThreshold is the fraction of equal tokens two lines need to
+	// share the same template.
+	SimilarityThreshold float64
+
+	// MinClusterSize is the smallest number of similar lines that get
+	// collapsed into a template; smaller groups are kept verbatim.
+	MinClusterSize int
+
+	// KeepFrames is how many frames to keep at each end of a stack trace.
+	KeepFrames int
+}
+
+// LogSummary records what LogCompressor removed from a chunk.
+type LogSummary struct {
+	LinesIn        int           `json:"lines_in"`
+	LinesOut       int           `json:"lines_out"`
+	CollapsedLines int           `json:"collapsed_lines"`
+	FramesDropped  int           `json:"frames_dropped"`
+	Templates      []LogTemplate `json:"templates,omitempty"`
+}
+
+// LogTemplate is a group of similar log lines collapsed into one.
+type LogTemplate struct {
+	Template string `json:"template"`
+	Count    int    `json:"count"`
+}
+
+// NewLogCompressor creates a log compressor with default settings.
+func NewLogCompressor() *LogCompressor {
+	return &LogCompressor{
+		SimilarityThreshold: 0.6,
+		MinClusterSize:      2,
+		KeepFrames:          1,
+	}
+}
+
+// Compress compresses chunks that look like logs and records a LogSummary
+// in their metadata. Other chunks pass through unchanged. The reduction
+// follows from the log's repetition, so opts.TargetReduction is ignored.
+func (l *LogCompressor) Compress(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, Stats, error) {
+	start := time.Now()
+	stats := Stats{}
+
+	counter := tokenizer.OrDefault(opts.Tokenizer)
+	result := make([]types.Chunk, 0, len(chunks))
+
+	for _, chunk := range chunks {
+		inputTokens := counter.Count(chunk.Text)
+		stats.InputTokens += inputTokens
+
+		if len(chunk.Text) < opts.MinChunkLength || !LooksLikeLog(chunk.Text) {
+			stats.ChunksSkipped++
+			stats.OutputTokens += inputTokens
+			result = append(result, chunk)
+			continue
+		}
+
+		newChunk := l.compressChunk(chunk, opts)
+		stats.ChunksProcessed++
+		stats.OutputTokens += counter.Count(newChunk.Text)
+		result = append(result, *newChunk)
+	}
+
+	stats.Latency = time.Since(start)
+	if stats.InputTokens > 0 {
+		stats.ReductionPercent = float64(stats.InputTokens-stats.OutputTokens) / float64(stats.InputTokens) * 100
+	}
+
+	return result, stats, nil
+}
+
+// compressChunk returns a copy of chunk with its log text compressed and
+// the summary recorded under LogMetadataKey.
+func (l *LogCompressor) compressChunk(chunk types.Chunk, opts Options) *types.Chunk {
+	compressed, summary := l.compressLog(chunk.Text)
+	newChunk := chunk.Clone()
+	newChunk.Text = compressed
+	newChunk.Metadata[LogMetadataKey] = summary
+	recordProvenance(chunk, newChunk, string(ModeLog), opts)
+	return newChunk
+}
+
+// logLine is one output line: a kept line, possibly repeated, or the
+// first line of a cluster that may be collapsed into its template.
+type logLine struct {
+	text    string
+	repeat  int
+	cluster *logCluster
+}
+
+type logCluster struct {
+	tokens []string
+	count  int
+	extra  []string // members after the first, restored if the cluster stays small
+}
+
+// compressLog compresses log text and reports what it dropped.
+func (l *LogCompressor) compressLog(text string) (string, LogSummary) {
+	lines := strings.Split(text, "\n")
+	summary := LogSummary{LinesIn: len(lines)}
+
+	var out []logLine
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Stack traces: keep the first and last frames.
+		if end := stackTraceEnd(lines, i); end > i {
+			kept, dropped := l.trimFrames(lines[i:end])
+			for _, k := range kept {
+				out = append(out, logLine{text: k, repeat: 1})
+			}
+			summary.FramesDropped += dropped
+			i = end - 1
+			continue
+		}
+
+		// Blank lines, errors, and warnings are kept verbatim; identical
+		// consecutive repeats are counted rather than repeated.
+		if strings.TrimSpace(line) == "" || logImportantPattern.MatchString(line) {
+			if n := len(out); n > 0 && out[n-1].cluster == nil && out[n-1].text == line {
+				out[n-1].repeat++
+				summary.CollapsedLines++
+				continue
+			}
+			out = append(out, logLine{text: line, repeat: 1})
+			continue
+		}
+
+		// Only consecutive similar lines join a cluster, so collapsing
+		// never moves a line past the ones around it.
+		tokens := logTemplateTokens(line)
+		if n := len(out); n > 0 && out[n-1].cluster != nil {
+			if c := l.matchCluster(out[n-1].cluster, tokens); c != nil {
+				c.count++
+				c.extra = append(c.extra, line)
+				continue
+			}
+		}
+		c := &logCluster{tokens: tokens, count: 1}
+		out = append(out, logLine{text: line, repeat: 1, cluster: c})
+	}
+
+	var b strings.Builder
+	for i, o := range out {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch {
+		case o.cluster != nil && o.cluster.count > 1 && o.cluster.count >= l.MinClusterSize:
+			tmpl := strings.Join(o.cluster.tokens, " ")
+			fmt.Fprintf(&b, "%s  [x%d]", tmpl, o.cluster.count)
+			summary.CollapsedLines += o.cluster.count - 1
+			summary.Templates = append(summary.Templates, LogTemplate{Template: tmpl, Count: o.cluster.count})
+		case o.cluster != nil:
+			b.WriteString(o.text)
+			// Lines below MinClusterSize are restored verbatim.
+			for _, extra := range o.cluster.extra {
+				b.WriteString("\n")
+				b.WriteString(extra)
+			}
+		case o.repeat > 1 && strings.TrimSpace(o.text) != "":
+			fmt.Fprintf(&b, "%s  [x%d]", o.text, o.repeat)
+		default:
+			b.WriteString(o.text)
+		}
+	}
+	summary.LinesOut = strings.Count(b.String(), "\n") + 1
+
+	return b.String(), summary
+}
+
+// matchCluster returns c when its template is similar enough to tokens,
+// widening differing positions of the template to "<*>", or nil.
+func (l *LogCompressor) matchCluster(c *logCluster, tokens []string) *logCluster {
+	if len(c.tokens) != len(tokens) || len(tokens) == 0 {
+		return nil
+	}
+	equal := 0
+	for i, t := range tokens {
+		if c.tokens[i] == t {
+			equal++
+		}
+	}
+	if float64(equal)/float64(len(tokens)) < l.SimilarityThreshold {
+		return nil
+	}
+	for i, t := range tokens {
+		if c.tokens[i] != t {
+			c.tokens[i] = logWildcard
+		}
+	}
+	return c
+}
+
+// trimFrames keeps the header, the first and last KeepFrames frames, and
+// trailing lines of a stack trace, replacing the middle with a marker.
+func (l *LogCompressor) trimFrames(trace []string) ([]string, int) {
+	var header, trailer []string
+	var frames [][]string
+	for _, line := range trace {
+		switch {
+		case isFrameStart(line):
+			frames = append(frames, []string{line})
+		case len(frames) > 0 && isFrameContinuation(line):
+			frames[len(frames)-1] = append(frames[len(frames)-1], line)
+		case len(frames) == 0:
+			header = append(header, line)
+		default:
+			trailer = append(trailer, line)
+		}
+	}
+
+	keep := l.KeepFrames
+	if keep < 1 {
+		keep = 1
+	}
+	out := append([]string(nil), header...)
+	if len(frames) <= 2*keep+1 {
+		for _, f := range frames {
+			out = append(out, f...)
+		}
+		return append(out, trailer...), 0
+	}
+
+	for _, f := range frames[:keep] {
+		out = append(out, f...)
+	}
+	dropped := len(frames) - 2*keep
+	indent := leadingSpace(frames[keep][0])
+	out = append(out, fmt.Sprintf("%s... %d frames omitted", indent, dropped))
+	for _, f := range frames[len(frames)-keep:] {
+		out = append(out, f...)
+	}
+	return append(out, trailer...), dropped
+}
+
+const logWildcard = "<*>"
+
+// logTemplateTokens splits a line on whitespace and masks variable tokens
+// (anything containing a digit, or long hex strings) with "<*>".
+func logTemplateTokens(line string) []string {
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if strings.IndexFunc(f, unicode.IsDigit) >= 0 || logHexPattern.MatchString(f) {
+			fields[i] = logWildcard
+		}
+	}
+	return fields
+}
+
+var (
+	logImportantPattern = regexp.MustCompile(
+		`(?i)\b(error|err|errors|warn|warning|fatal|panic|exception|fail|failed|failure|critical|severe)\b`)
+	logHexPattern = regexp.MustCompile(`^[0-9a-fA-F]{8,}$`)
+
+	logLinePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\[?\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}`),                   // ISO timestamp
+		regexp.MustCompile(`^\[?\d{2}:\d{2}:\d{2}`),                                        // time of day
+		regexp.MustCompile(`^\w{3} [ \d]\d \d{2}:\d{2}:\d{2}`),                             // syslog
+		regexp.MustCompile(`(?i)^\[?(trace|debug|info|notice|warn|warning|error|fatal)\b`), // level first
+		regexp.MustCompile(`(?i)\b(level|lvl)=\w+`),                                        // logfmt
+		regexp.MustCompile(`^(=== RUN|--- (PASS|FAIL|SKIP)|ok  \t|FAIL\t|PASS$|FAIL$)`),    // go test
+		regexp.MustCompile(`^(npm (ERR|WARN)|\s*✓|\s*✗|\s*[✔✘])`),                          // js tooling
+	}
+
+	javaFramePattern   = regexp.MustCompile(`^\s+at \S`)
+	pythonFramePattern = regexp.MustCompile(`^\s+File ".*", line \d+`)
+	goFrameFuncPattern = regexp.MustCompile(`^[\w/.-]+\.[\w.*()\[\]-]+\(.*\)$`)
+	goFrameFilePattern = regexp.MustCompile(`^\t\S+:\d+( \+0x[0-9a-f]+)?$`)
+	traceHeaderPattern = regexp.MustCompile(
+		`^(Traceback \(most recent call last\):|goroutine \d+ \[|panic: |Exception in thread|\S*(Exception|Error)(: |$)|Caused by: )`)
+)
+
+// LooksLikeLog reports whether text is log output, test output, or a stack
+// trace: at least three lines, most of which look like log records.
+func LooksLikeLog(text string) bool {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) < 3 {
+		return false
+	}
+	matches, nonEmpty := 0, 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonEmpty++
+		if isLogRecord(line) {
+			matches++
+		}
+	}
+	return nonEmpty > 0 && matches*2 >= nonEmpty
+}
+
+func isLogRecord(line string) bool {
+	if isFrameStart(line) || goFrameFilePattern.MatchString(line) || traceHeaderPattern.MatchString(line) {
+		return true
+	}
+	for _, p := range logLinePatterns {
+		if p.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// stackTraceEnd returns the index after the stack trace starting at
+// lines[i], or i when no trace starts there. A trace starts at a header
+// line followed by frames, or directly at a frame.
+func stackTraceEnd(lines []string, i int) int {
+	j := i
+	if traceHeaderPattern.MatchString(lines[i]) {
+		j++
+		if j < len(lines) && !isFrameStart(lines[j]) {
+			return i
+		}
+	} else if !isFrameStart(lines[i]) {
+		return i
+	}
+	for j < len(lines) && (isFrameStart(lines[j]) || isFrameContinuation(lines[j])) {
+		j++
+	}
+	// A single frame is not worth treating as a trace.
+	if j-i < 2 {
+		return i
+	}
+	return j
+}
+
+func isFrameStart(line string) bool {
+	return javaFramePattern.MatchString(line) || pythonFramePattern.MatchString(line) ||
+		goFrameFuncPattern.MatchString(line)
+}
+
+// isFrameContinuation matches the source line under a Python frame and the
+// file:line under a Go frame.
+func isFrameContinuation(line string) bool {
+	return goFrameFilePattern.MatchString(line) ||
+		(strings.HasPrefix(line, "    ") && !javaFramePattern.MatchString(line) && !pythonFramePattern.MatchString(line))
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
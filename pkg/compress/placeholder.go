@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -39,10 +40,11 @@ func (p *PlaceholderCompressor) Compress(ctx context.Context, chunks []types.Chu
 	start := time.Now()
 	stats := Stats{}
 
+	counter := tokenizer.OrDefault(opts.Tokenizer)
 	result := make([]types.Chunk, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		inputTokens := estimateTokens(chunk.Text)
+		inputTokens := counter.Count(chunk.Text)
 		stats.InputTokens += inputTokens
 
 		if len(chunk.Text) < opts.MinChunkLength {
@@ -54,10 +56,11 @@ func (p *PlaceholderCompressor) Compress(ctx context.Context, chunks []types.Chu
 
 		compressed := p.compressStructured(chunk.Text, opts.PreserveStructure)
 		stats.ChunksProcessed++
-		stats.OutputTokens += estimateTokens(compressed)
+		stats.OutputTokens += counter.Count(compressed)
 
 		newChunk := chunk.Clone()
 		newChunk.Text = compressed
+		recordProvenance(chunk, newChunk, string(ModePlaceholder), opts)
 		result = append(result, *newChunk)
 	}
 
@@ -69,6 +72,19 @@ func (p *PlaceholderCompressor) Compress(ctx context.Context, chunks []types.Chu
 	return result, stats, nil
 }
 
+// IsStructured reports whether text is JSON, XML, or tabular content that
+// the placeholder compressor would replace.
+func (p *PlaceholderCompressor) IsStructured(text string) bool {
+	if _, ok := p.tryCompressJSON(text, false); ok {
+		return true
+	}
+	if _, ok := p.tryCompressXML(text); ok {
+		return true
+	}
+	_, ok := p.tryCompressTable(text)
+	return ok
+}
+
 // compressStructured detects and compresses structured content.
 func (p *PlaceholderCompressor) compressStructured(text string, preserveStructure bool) string {
 	// Try JSON compression
@@ -0,0 +1,289 @@
+package compress
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// ProvenanceMetadataKey is the chunk metadata key under which compressors
+// record a Provenance for every chunk whose text they change, when
+// Options.Explain is set.
+const ProvenanceMetadataKey = "provenance"
+
+// maxAlignCells bounds the word-level LCS table used to align a chunk with
+// its compressed form; larger inputs fall back to greedy matching.
+const maxAlignCells = 1 << 22
+
+// Span maps a byte range of the original text to the position where it
+// appears verbatim in the compressed text.
+type Span struct {
+	Start    int `json:"start"`     // offset in the original text
+	End      int `json:"end"`       // end offset (exclusive) in the original text
+	OutStart int `json:"out_start"` // offset in the compressed text
+}
+
+// Provenance records how a compressed chunk was derived from its original.
+type Provenance struct {
+	// OriginalHash is "sha256:" followed by the hex digest of the original text.
+	OriginalHash string `json:"original_hash"`
+
+	// OriginalLength is the original text length in bytes.
+	OriginalLength int `json:"original_length"`
+
+	// Compressors lists the compressors that changed the text, in order.
+	Compressors []string `json:"compressors"`
+
+	// Kept lists the spans of the original that survive verbatim, in order.
+	Kept []Span `json:"kept"`
+}
+
+// ProvenanceOf returns the provenance recorded on chunk, if any.
+func ProvenanceOf(chunk types.Chunk) (Provenance, bool) {
+	p, ok := chunk.Metadata[ProvenanceMetadataKey].(Provenance)
+	return p, ok
+}
+
+// VerifiedProvenance returns chunk's provenance if it was recorded against
+// original and still describes chunk's text: every kept span must appear
+// verbatim at its recorded output offset. Provenance goes stale when a later
+// stage rewrites the text but keeps the metadata.
+func VerifiedProvenance(original string, chunk types.Chunk) (Provenance, bool) {
+	p, ok := ProvenanceOf(chunk)
+	if !ok || p.OriginalHash != HashText(original) {
+		return Provenance{}, false
+	}
+	for _, s := range p.Kept {
+		outEnd := s.OutStart + (s.End - s.Start)
+		if s.Start < 0 || s.End > len(original) || s.OutStart < 0 || outEnd > len(chunk.Text) ||
+			original[s.Start:s.End] != chunk.Text[s.OutStart:outEnd] {
+			return Provenance{}, false
+		}
+	}
+	return p, true
+}
+
+// HashText returns the hash recorded in Provenance.OriginalHash for text.
+func HashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// recordProvenance records on out that compressor rewrote in, when
+// opts.Explain is set. When in already carries provenance from an earlier
+// compressor, the new kept spans are composed with it so they still refer
+// to the original text.
+func recordProvenance(in types.Chunk, out *types.Chunk, compressor string, opts Options) {
+	if !opts.Explain || out.Text == in.Text {
+		return
+	}
+
+	prev, ok := ProvenanceOf(in)
+	if !ok {
+		prev = Provenance{
+			OriginalHash:   HashText(in.Text),
+			OriginalLength: len(in.Text),
+			Kept:           []Span{{Start: 0, End: len(in.Text), OutStart: 0}},
+		}
+	}
+
+	if out.Metadata == nil {
+		out.Metadata = make(map[string]interface{})
+	}
+	out.Metadata[ProvenanceMetadataKey] = Provenance{
+		OriginalHash:   prev.OriginalHash,
+		OriginalLength: prev.OriginalLength,
+		Compressors:    append(append([]string(nil), prev.Compressors...), compressor),
+		Kept:           composeSpans(prev.Kept, alignText(in.Text, out.Text)),
+	}
+}
+
+// composeSpans maps next (intermediate → output) through prev
+// (original → intermediate), yielding original → output spans.
+func composeSpans(prev, next []Span) []Span {
+	var out []Span
+	i, j := 0, 0
+	for i < len(prev) && j < len(next) {
+		p, n := prev[i], next[j]
+		pStart, pEnd := p.OutStart, p.OutStart+(p.End-p.Start)
+		lo, hi := max(pStart, n.Start), min(pEnd, n.End)
+		if lo < hi {
+			out = append(out, Span{
+				Start:    p.Start + (lo - pStart),
+				End:      p.Start + (hi - pStart),
+				OutStart: n.OutStart + (lo - n.Start),
+			})
+		}
+		if pEnd <= n.End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// alignText finds the spans of a that appear verbatim, in order, in b. It
+// aligns whitespace-separated words by longest common subsequence and
+// merges runs of adjacent matches whose separating whitespace also agrees.
+func alignText(a, b string) []Span {
+	aw, bw := wordOffsets(a), wordOffsets(b)
+	pairs := alignWords(a, b, aw, bw)
+
+	var spans []Span
+	for _, pr := range pairs {
+		wa, wb := aw[pr[0]], bw[pr[1]]
+		if n := len(spans); n > 0 {
+			last := &spans[n-1]
+			gapA := a[last.End:wa[0]]
+			outEnd := last.OutStart + (last.End - last.Start)
+			if outEnd <= wb[0] && gapA == b[outEnd:wb[0]] {
+				last.End = wa[1]
+				continue
+			}
+		}
+		spans = append(spans, Span{Start: wa[0], End: wa[1], OutStart: wb[0]})
+	}
+	return spans
+}
+
+// alignWords returns index pairs of matching words in order.
+func alignWords(a, b string, aw, bw [][2]int) [][2]int {
+	n, m := len(aw), len(bw)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	word := func(s string, w [2]int) string { return s[w[0]:w[1]] }
+
+	if n*m > maxAlignCells {
+		// Greedy forward matching: good enough for compressors that keep
+		// an ordered subset of the input.
+		var pairs [][2]int
+		i := 0
+		for j := 0; j < m && i < n; j++ {
+			for k := i; k < n; k++ {
+				if word(a, aw[k]) == word(b, bw[j]) {
+					pairs = append(pairs, [2]int{k, j})
+					i = k + 1
+					break
+				}
+			}
+		}
+		return pairs
+	}
+
+	// Longest common subsequence, scored so that contiguous runs win ties:
+	// each matched word gains 2 and each run of matches costs 1. Without
+	// the run cost, a repeated word such as "The" can align with the wrong
+	// sentence and split one kept span into two.
+	//
+	// best[i][j] is the best score for aw[i:], bw[j:]; run[i][j] is the
+	// best score given aw[i] matches bw[j], not counting the cost of the
+	// run that match belongs to.
+	const negInf = -1 << 30
+	w := m + 1
+	best := make([]int32, (n+1)*w)
+	run := make([]int32, (n+1)*w)
+	for j := 0; j <= m; j++ {
+		run[n*w+j] = negInf
+	}
+	for i := 0; i < n; i++ {
+		run[i*w+m] = negInf
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			k := i*w + j
+			run[k] = negInf
+			if word(a, aw[i]) == word(b, bw[j]) {
+				run[k] = 2 + max(run[k+w+1], best[k+w+1])
+			}
+			best[k] = max(best[k+w], best[k+1], run[k]-1)
+		}
+	}
+
+	// On ties, end runs and skip words before matching: compressors drop
+	// whole sentences and lines, so a shared boundary word belongs to the
+	// run that follows the dropped text rather than the one before it.
+	var pairs [][2]int
+	inRun := false
+	for i, j := 0, 0; i < n && j < m; {
+		k := i*w + j
+		switch {
+		case inRun:
+			pairs = append(pairs, [2]int{i, j})
+			inRun = run[k+w+1] > best[k+w+1]
+			i++
+			j++
+		case best[k] == best[k+w]:
+			i++
+		case best[k] == best[k+1]:
+			j++
+		default:
+			inRun = true
+		}
+	}
+	return pairs
+}
+
+// wordOffsets returns the [start, end) byte offsets of each
+// whitespace-separated word in s.
+func wordOffsets(s string) [][2]int {
+	var words [][2]int
+	start := -1
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, [2]int{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, [2]int{start, len(s)})
+	}
+	return words
+}
+
+// Explain renders a diff-style report of how compressed was derived from
+// original: kept text is prefixed with "  ", dropped text with "- ", and
+// text the compressor inserted (placeholders, templates, markers) with
+// "+ ". It uses the chunk's recorded provenance when it is still valid for
+// original and otherwise aligns the two texts directly.
+func Explain(original string, compressed types.Chunk) string {
+	var spans []Span
+	if p, ok := VerifiedProvenance(original, compressed); ok {
+		spans = p.Kept
+	} else {
+		spans = alignText(original, compressed.Text)
+	}
+
+	var b strings.Builder
+	emit := func(prefix, text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		for _, line := range strings.Split(text, "\n") {
+			b.WriteString(prefix)
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	a, out := 0, 0
+	for _, s := range spans {
+		emit("- ", original[a:s.Start])
+		emit("+ ", compressed.Text[out:s.OutStart])
+		emit("  ", original[s.Start:s.End])
+		a, out = s.End, s.OutStart+(s.End-s.Start)
+	}
+	emit("- ", original[a:])
+	emit("+ ", compressed.Text[out:])
+
+	return b.String()
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -71,13 +72,17 @@ func (p *Pruner) Compress(ctx context.Context, chunks []types.Chunk, opts Option
 	start := time.Now()
 	stats := Stats{}
 
+	counter := tokenizer.OrDefault(opts.Tokenizer)
 	result := make([]types.Chunk, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		inputTokens := estimateTokens(chunk.Text)
+		inputTokens := counter.Count(chunk.Text)
 		stats.InputTokens += inputTokens
 
-		if len(chunk.Text) < opts.MinChunkLength {
+		// Whitespace collapsing would flatten indentation and line
+		// structure, so code and logs are left to their own compressors.
+		if len(chunk.Text) < opts.MinChunkLength ||
+			(opts.PreserveStructure && (LooksLikeCode(chunk.Text) || LooksLikeLog(chunk.Text))) {
 			stats.ChunksSkipped++
 			stats.OutputTokens += inputTokens
 			result = append(result, chunk)
@@ -86,10 +91,11 @@ func (p *Pruner) Compress(ctx context.Context, chunks []types.Chunk, opts Option
 
 		pruned := p.prune(chunk.Text)
 		stats.ChunksProcessed++
-		stats.OutputTokens += estimateTokens(pruned)
+		stats.OutputTokens += counter.Count(pruned)
 
 		newChunk := chunk.Clone()
 		newChunk.Text = pruned
+		recordProvenance(chunk, newChunk, string(ModePruner), opts)
 		result = append(result, *newChunk)
 	}
 
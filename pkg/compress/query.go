@@ -0,0 +1,135 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Siddhant-K-code/distill/pkg/embedding"
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+)
+
+// DefaultQueryWeight is the share of a sentence's score taken from its
+// relevance to the query when Options.QueryWeight is unset.
+const DefaultQueryWeight = 0.6
+
+// queryContext holds a query prepared once per Compress call.
+type queryContext struct {
+	terms     map[string]struct{}
+	embedding []float32
+	embedder  embedding.Provider
+	weight    float64
+}
+
+// PrepareQuery returns opts with Query embedded into QueryEmbedding, so
+// that compressing many chunks, or running several compressors, with the
+// same options embeds the query once. opts is returned unchanged when it
+// already has a QueryEmbedding, or has no Query or Embedder.
+func PrepareQuery(ctx context.Context, opts Options) (Options, error) {
+	if opts.Query == "" || opts.Embedder == nil || len(opts.QueryEmbedding) > 0 {
+		return opts, nil
+	}
+	emb, err := opts.Embedder.Embed(ctx, opts.Query)
+	if err != nil {
+		return opts, fmt.Errorf("embed query: %w", err)
+	}
+	opts.QueryEmbedding = emb
+	return opts, nil
+}
+
+// newQueryContext prepares the query from opts. It returns nil when no
+// query is set. The query text is embedded when an embedder is configured
+// and no query embedding was supplied; see PrepareQuery.
+func newQueryContext(ctx context.Context, opts Options) (*queryContext, error) {
+	if opts.Query == "" && len(opts.QueryEmbedding) == 0 {
+		return nil, nil
+	}
+	opts, err := PrepareQuery(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &queryContext{
+		terms:     queryTerms(opts.Query),
+		embedding: opts.QueryEmbedding,
+		embedder:  opts.Embedder,
+		weight:    opts.QueryWeight,
+	}
+	if q.weight <= 0 || q.weight > 1 {
+		q.weight = DefaultQueryWeight
+	}
+	if q.embedder == nil {
+		// Sentences cannot be embedded, so only lexical overlap counts.
+		q.embedding = nil
+	}
+	if len(q.terms) == 0 && len(q.embedding) == 0 {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// relevance scores each sentence's relevance to the query in [0, 1],
+// averaging lexical overlap with embedding similarity when both are
+// available. Sentence embeddings are requested in a single batch.
+func (q *queryContext) relevance(ctx context.Context, sentences []string) ([]float64, error) {
+	var embeddings [][]float32
+	if len(q.embedding) > 0 {
+		var err error
+		embeddings, err = q.embedder.EmbedBatch(ctx, sentences)
+		if err != nil {
+			return nil, fmt.Errorf("embed sentences: %w", err)
+		}
+		if len(embeddings) != len(sentences) {
+			return nil, fmt.Errorf("embed sentences: got %d embeddings for %d sentences", len(embeddings), len(sentences))
+		}
+	}
+
+	scores := make([]float64, len(sentences))
+	for i, s := range sentences {
+		var sum float64
+		var signals int
+		if len(q.terms) > 0 {
+			sum += q.lexicalOverlap(s)
+			signals++
+		}
+		if embeddings != nil && len(embeddings[i]) == len(q.embedding) {
+			if sim := distillmath.CosineSimilarity(q.embedding, embeddings[i]); sim > 0 {
+				sum += sim
+			}
+			signals++
+		}
+		if signals > 0 {
+			scores[i] = sum / float64(signals)
+		}
+	}
+	return scores, nil
+}
+
+// lexicalOverlap is the fraction of query terms present in sentence.
+func (q *queryContext) lexicalOverlap(sentence string) float64 {
+	words := queryTerms(sentence)
+	matched := 0
+	for t := range q.terms {
+		if _, ok := words[t]; ok {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(q.terms))
+}
+
+// queryTerms lowercases text and returns its distinct terms, dropping
+// stopwords of its language and of English and single-letter words.
+// Words are segmented per script, so CJK queries yield terms too.
+func queryTerms(text string) map[string]struct{} {
+	lang := nlp.DetectLanguage(text)
+	terms := make(map[string]struct{})
+	for _, w := range nlp.Words(strings.ToLower(text)) {
+		if nlp.IsStopword(lang, w) || nlp.IsStopword(nlp.English, w) || utf8.RuneCountInString(w) < 2 {
+			continue
+		}
+		terms[w] = struct{}{}
+	}
+	return terms
+}
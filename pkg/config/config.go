@@ -21,6 +21,26 @@ type Config struct {
 	Retriever RetrieverConfig `mapstructure:"retriever"`
 	Auth      AuthConfig      `mapstructure:"auth"`
 	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+	Tokenizer TokenizerConfig `mapstructure:"tokenizer"`
+
+	// Summarizer selects how the summarize stage, memory decay, and
+	// session compression write summaries.
+	Summarizer SummarizerConfig `mapstructure:"summarizer"`
+
+	// Rerank selects the reranker the retrieval broker applies before MMR.
+	Rerank RerankConfig `mapstructure:"rerank"`
+
+	// Expand adds the chunks around each result the retrieval broker
+	// returns.
+	Expand ExpandConfig `mapstructure:"expand"`
+
+	// MultiQuery retrieves several variants of each query in the
+	// retrieval broker and fuses the results.
+	MultiQuery MultiQueryConfig `mapstructure:"multi_query"`
+
+	// Pipelines holds named pipeline profiles, selected with
+	// `distill pipeline --profile` or /v1/pipeline?profile=.
+	Pipelines map[string]PipelineConfig `mapstructure:"pipelines"`
 }
 
 // ServerConfig holds HTTP server settings.
@@ -46,6 +66,10 @@ type DedupConfig struct {
 	Linkage   string  `mapstructure:"linkage"`
 	Lambda    float64 `mapstructure:"lambda"`
 	EnableMMR bool    `mapstructure:"enable_mmr"`
+
+	// AutoThreshold picks the threshold from each input's distance
+	// histogram, falling back to Threshold. See `distill calibrate`.
+	AutoThreshold bool `mapstructure:"auto_threshold"`
 }
 
 // RetrieverConfig holds vector DB settings.
@@ -77,6 +101,77 @@ type TracingConfig struct {
 	Insecure   bool    `mapstructure:"insecure"`
 }
 
+// TokenizerConfig selects the token counter shared by all packages.
+type TokenizerConfig struct {
+	Name     string `mapstructure:"name"`
+	VocabDir string `mapstructure:"vocab_dir"`
+}
+
+// SummarizerConfig selects the summarizer. Provider "extractive" (the
+// default) needs no model; "openai" calls an OpenAI-compatible chat
+// endpoint such as a local llama.cpp or Ollama server.
+type SummarizerConfig struct {
+	Provider      string        `mapstructure:"provider"`
+	BaseURL       string        `mapstructure:"base_url"`
+	Model         string        `mapstructure:"model"`
+	APIKey        string        `mapstructure:"api_key"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	Deterministic bool          `mapstructure:"deterministic"`
+	CacheSize     int           `mapstructure:"cache_size"`
+}
+
+// RerankConfig selects the reranker. Provider "bm25" scores term overlap
+// locally; "cohere" calls a Cohere Rerank-compatible API. Weight blends
+// reranker scores with retrieval scores (1.0 = reranker only).
+type RerankConfig struct {
+	Provider string        `mapstructure:"provider"`
+	BaseURL  string        `mapstructure:"base_url"`
+	Model    string        `mapstructure:"model"`
+	APIKey   string        `mapstructure:"api_key"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Weight   float64       `mapstructure:"weight"`
+}
+
+// ExpandConfig selects how broker results are expanded with surrounding
+// chunks. Mode "neighbors" adds Window chunks on each side; "parent" adds
+// the rest of the document. Chunks are located by the DocField and
+// IndexField metadata keys; MaxTokens caps the expanded results.
+type ExpandConfig struct {
+	Mode       string `mapstructure:"mode"`
+	Window     int    `mapstructure:"window"`
+	DocField   string `mapstructure:"doc_field"`
+	IndexField string `mapstructure:"index_field"`
+	MaxTokens  int    `mapstructure:"max_tokens"`
+}
+
+// MultiQueryConfig derives query variants for the retrieval broker.
+// Templates hold a {query} placeholder; Decompose splits a query into its
+// questions. Results are fused by reciprocal-rank fusion with constant
+// RRFK.
+type MultiQueryConfig struct {
+	Templates []string `mapstructure:"templates"`
+	Decompose bool     `mapstructure:"decompose"`
+	RRFK      int      `mapstructure:"rrf_k"`
+}
+
+// PipelineConfig declares a pipeline as an ordered list of stages.
+type PipelineConfig struct {
+	Description string        `mapstructure:"description"`
+	Stages      []StageConfig `mapstructure:"stages"`
+}
+
+// StageConfig declares one pipeline stage. Type is a built-in stage
+// (dedup, compress, summarize, or a compression mode such as pruner) or a
+// custom stage registered in Go. When restricts the stage to chunks whose
+// metadata matches every entry ("*" matches any present value). Viper
+// lower-cases its keys, so they match metadata keys case-insensitively.
+type StageConfig struct {
+	Name   string                 `mapstructure:"name"`
+	Type   string                 `mapstructure:"type"`
+	Params map[string]interface{} `mapstructure:"params"`
+	When   map[string]string      `mapstructure:"when"`
+}
+
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
@@ -115,6 +210,9 @@ func DefaultConfig() *Config {
 				Insecure:   true,
 			},
 		},
+		Tokenizer: TokenizerConfig{
+			Name: "heuristic",
+		},
 	}
 }
 
@@ -212,6 +310,78 @@ func Validate(cfg *Config) error {
 		errs = append(errs, fmt.Sprintf("telemetry.tracing.sample_rate: must be between 0 and 1, got %f", cfg.Telemetry.Tracing.SampleRate))
 	}
 
+	// Tokenizer validation
+	validTokenizers := map[string]bool{"heuristic": true, "cl100k_base": true, "o200k_base": true, "": true}
+	if !validTokenizers[cfg.Tokenizer.Name] {
+		errs = append(errs, fmt.Sprintf("tokenizer.name: unsupported tokenizer %q (supported: heuristic, cl100k_base, o200k_base)", cfg.Tokenizer.Name))
+	}
+
+	// Summarizer validation
+	validSummarizers := map[string]bool{"extractive": true, "openai": true, "": true}
+	if !validSummarizers[cfg.Summarizer.Provider] {
+		errs = append(errs, fmt.Sprintf("summarizer.provider: unsupported provider %q (supported: extractive, openai)", cfg.Summarizer.Provider))
+	}
+	if cfg.Summarizer.Provider == "openai" && cfg.Summarizer.BaseURL == "" {
+		errs = append(errs, "summarizer.base_url: required for the openai provider")
+	}
+	if cfg.Summarizer.Timeout < 0 {
+		errs = append(errs, "summarizer.timeout: must be non-negative")
+	}
+
+	// Rerank validation
+	validRerankers := map[string]bool{"bm25": true, "cohere": true, "none": true, "": true}
+	if !validRerankers[cfg.Rerank.Provider] {
+		errs = append(errs, fmt.Sprintf("rerank.provider: unsupported provider %q (supported: bm25, cohere, none)", cfg.Rerank.Provider))
+	}
+	if cfg.Rerank.Timeout < 0 {
+		errs = append(errs, "rerank.timeout: must be non-negative")
+	}
+	if cfg.Rerank.Weight < 0 || cfg.Rerank.Weight > 1 {
+		errs = append(errs, fmt.Sprintf("rerank.weight: must be between 0 and 1, got %f", cfg.Rerank.Weight))
+	}
+
+	// Expand validation
+	validExpandModes := map[string]bool{"neighbors": true, "parent": true, "none": true, "": true}
+	if !validExpandModes[cfg.Expand.Mode] {
+		errs = append(errs, fmt.Sprintf("expand.mode: unsupported mode %q (supported: neighbors, parent, none)", cfg.Expand.Mode))
+	}
+	if cfg.Expand.Window < 0 {
+		errs = append(errs, fmt.Sprintf("expand.window: must be non-negative, got %d", cfg.Expand.Window))
+	}
+	if cfg.Expand.MaxTokens < 0 {
+		errs = append(errs, fmt.Sprintf("expand.max_tokens: must be non-negative, got %d", cfg.Expand.MaxTokens))
+	}
+
+	// Multi-query validation
+	if cfg.MultiQuery.RRFK < 0 {
+		errs = append(errs, fmt.Sprintf("multi_query.rrf_k: must be non-negative, got %d", cfg.MultiQuery.RRFK))
+	}
+	for i, t := range cfg.MultiQuery.Templates {
+		if strings.TrimSpace(t) == "" {
+			errs = append(errs, fmt.Sprintf("multi_query.templates[%d]: must not be empty", i))
+		}
+	}
+
+	// Pipeline validation. Stage types and params are checked when the
+	// profile is built, since custom stages are registered at runtime.
+	for name, p := range cfg.Pipelines {
+		if len(p.Stages) == 0 {
+			errs = append(errs, fmt.Sprintf("pipelines.%s.stages: must declare at least one stage", name))
+		}
+		seen := make(map[string]bool, len(p.Stages))
+		for i, st := range p.Stages {
+			if st.Type == "" {
+				errs = append(errs, fmt.Sprintf("pipelines.%s.stages[%d].type: required", name, i))
+			}
+			if st.Name != "" {
+				if seen[st.Name] {
+					errs = append(errs, fmt.Sprintf("pipelines.%s.stages[%d].name: duplicate stage name %q", name, i, st.Name))
+				}
+				seen[st.Name] = true
+			}
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("configuration errors:\n  - %s", strings.Join(errs, "\n  - "))
 	}
@@ -267,6 +437,13 @@ func interpolateConfig(cfg *Config) {
 
 	cfg.Telemetry.Tracing.Exporter = InterpolateEnv(cfg.Telemetry.Tracing.Exporter)
 	cfg.Telemetry.Tracing.Endpoint = InterpolateEnv(cfg.Telemetry.Tracing.Endpoint)
+	cfg.Tokenizer.VocabDir = InterpolateEnv(cfg.Tokenizer.VocabDir)
+	cfg.Summarizer.BaseURL = InterpolateEnv(cfg.Summarizer.BaseURL)
+	cfg.Summarizer.Model = InterpolateEnv(cfg.Summarizer.Model)
+	cfg.Summarizer.APIKey = InterpolateEnv(cfg.Summarizer.APIKey)
+	cfg.Rerank.BaseURL = InterpolateEnv(cfg.Rerank.BaseURL)
+	cfg.Rerank.Model = InterpolateEnv(cfg.Rerank.Model)
+	cfg.Rerank.APIKey = InterpolateEnv(cfg.Rerank.APIKey)
 }
 
 // GenerateTemplate returns a YAML template string with all available
@@ -294,6 +471,7 @@ dedup:
   linkage: average
   lambda: 0.5
   enable_mmr: true
+  auto_threshold: false  # pick the threshold per request from the distance histogram (see distill calibrate)
 
 retriever:
   backend: pinecone    # pinecone or qdrant
@@ -314,5 +492,65 @@ telemetry:
     endpoint: localhost:4317
     sample_rate: 1.0     # 0.0 to 1.0
     insecure: true
+
+tokenizer:
+  name: heuristic      # heuristic, cl100k_base, or o200k_base
+  # vocab_dir: ""      # directory holding <name>.tiktoken vocab files
+
+summarizer:
+  provider: extractive # extractive, or openai for any OpenAI-compatible server
+  # base_url: http://localhost:11434/v1   # Ollama; llama.cpp: http://localhost:8080/v1
+  # model: llama3.2
+  # api_key: ${SUMMARIZER_API_KEY}
+  # timeout: 30s       # falls back to extractive on timeout or error
+  # deterministic: true  # temperature 0 and a fixed seed
+  # cache_size: 1024   # summaries cached by content hash
+
+# Rerank retrieved representatives against the query before MMR
+# (distill serve, distill query, and the retrieve_deduplicated MCP tool).
+# rerank:
+#   provider: bm25       # bm25, cohere, or none
+#   # model: rerank-english-v3.0
+#   # base_url: https://api.cohere.ai/v1   # any Cohere Rerank-compatible API
+#   # api_key: ${COHERE_API_KEY}
+#   # timeout: 10s       # falls back to bm25 on timeout or error
+#   # weight: 1.0        # 1.0 = reranker only, 0.5 = blend with retrieval scores
+
+# Expand each retrieved result with the chunks around it in its document
+# (distill serve and distill query). Overlapping windows are merged.
+# expand:
+#   mode: neighbors      # neighbors, parent (whole document), or none
+#   window: 1            # chunks on each side (neighbors)
+#   doc_field: doc_id    # metadata key naming the document
+#   index_field: chunk_index  # metadata key holding the chunk's position
+#   max_tokens: 4000     # cap on all returned chunks together (0 = none)
+
+# Retrieve several variants of each query in parallel and fuse them with
+# reciprocal-rank fusion before deduplication (distill serve and distill
+# query). Each result lists the variants that found it under query_hits.
+# multi_query:
+#   templates:
+#     - "how do I {query}"
+#     - "{query} troubleshooting"
+#   decompose: true      # one variant per question in multi-part queries
+#   rrf_k: 60            # larger = flatter fusion across ranks
+
+# Named pipelines for "distill pipeline --profile <name>" and
+# /v1/pipeline?profile=<name>. Stages run in order and may repeat.
+# pipelines:
+#   rag:
+#     description: prune, dedup, then keep the sentences that matter
+#     stages:
+#       - type: pruner
+#       - type: dedup
+#         params: { threshold: 0.1 }
+#       - type: extractive
+#         params: { target_reduction: 0.4 }
+#   agent:
+#     stages:
+#       - type: log
+#         when: { source: tool_output }   # only chunks with this metadata
+#       - type: compress
+#         params: { mode: extractive, max_tokens: 8000 }
 `
 }
@@ -85,6 +85,79 @@ func TestValidate_InvalidLinkage(t *testing.T) {
 	}
 }
 
+func TestValidate_InvalidTokenizer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tokenizer.Name = "gpt2"
+	err := Validate(cfg)
+	if err == nil {
+		t.Error("expected error for unsupported tokenizer")
+	}
+}
+
+func TestValidate_InvalidSummarizer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Summarizer.Provider = "openai"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "summarizer.base_url") {
+		t.Errorf("expected base_url error, got %v", err)
+	}
+	cfg.Summarizer.BaseURL = "http://localhost:11434/v1"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Summarizer.Provider = "anthropic"
+	if err := Validate(cfg); err == nil {
+		t.Error("expected error for unsupported summarizer provider")
+	}
+}
+
+func TestValidate_InvalidRerank(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Rerank.Provider = "cohere"
+	cfg.Rerank.Weight = 0.5
+	if err := Validate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Rerank.Weight = 1.5
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "rerank.weight") {
+		t.Errorf("expected weight error, got %v", err)
+	}
+	cfg.Rerank.Weight = 0
+	cfg.Rerank.Provider = "colbert"
+	if err := Validate(cfg); err == nil {
+		t.Error("expected error for unsupported rerank provider")
+	}
+}
+
+func TestValidate_InvalidExpand(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Expand.Mode = "neighbors"
+	cfg.Expand.Window = 2
+	if err := Validate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Expand.Mode = "sentence"
+	cfg.Expand.MaxTokens = -1
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "expand.mode") || !strings.Contains(err.Error(), "expand.max_tokens") {
+		t.Errorf("expected mode and max_tokens errors, got %v", err)
+	}
+}
+
+func TestValidate_InvalidMultiQuery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MultiQuery.Templates = []string{"how do I {query}"}
+	cfg.MultiQuery.Decompose = true
+	if err := Validate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.MultiQuery.Templates = append(cfg.MultiQuery.Templates, " ")
+	cfg.MultiQuery.RRFK = -1
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "multi_query.templates[1]") || !strings.Contains(err.Error(), "multi_query.rrf_k") {
+		t.Errorf("expected templates and rrf_k errors, got %v", err)
+	}
+}
+
 func TestValidate_MultipleErrors(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Server.Port = -1
@@ -291,3 +364,60 @@ func TestGenerateTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadFromFile_Pipelines(t *testing.T) {
+	content := `
+pipelines:
+  rag:
+    description: prune then dedup
+    stages:
+      - type: pruner
+      - type: dedup
+        params:
+          threshold: 0.1
+      - name: tools
+        type: log
+        when:
+          source: tool_output
+`
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "distill.yaml")
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+
+	rag, ok := cfg.Pipelines["rag"]
+	if !ok {
+		t.Fatal("expected pipeline rag")
+	}
+	if len(rag.Stages) != 3 {
+		t.Fatalf("expected 3 stages, got %d", len(rag.Stages))
+	}
+	if rag.Stages[1].Params["threshold"] != 0.1 {
+		t.Errorf("expected dedup threshold 0.1, got %v", rag.Stages[1].Params["threshold"])
+	}
+	if rag.Stages[2].Name != "tools" || rag.Stages[2].When["source"] != "tool_output" {
+		t.Errorf("unexpected conditional stage: %+v", rag.Stages[2])
+	}
+}
+
+func TestValidate_InvalidPipeline(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Pipelines = map[string]PipelineConfig{
+		"bad": {Stages: []StageConfig{{Name: "a", Type: "dedup"}, {Name: "a"}}},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for invalid pipeline")
+	}
+	for _, want := range []string{"type: required", "duplicate stage name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got: %v", want, err)
+		}
+	}
+}
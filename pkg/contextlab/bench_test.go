@@ -98,3 +98,87 @@ func BenchmarkSelector_50Clusters(b *testing.B) {
 		_ = sel.Select(result)
 	}
 }
+
+// makeNearDupChunks builds n chunks where each text appears twice with a
+// one-word edit.
+func makeNearDupChunks(n int) []types.Chunk {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"cache", "token", "budget", "chunk", "query", "vector", "index", "model", "prompt", "context"}
+	chunks := make([]types.Chunk, n)
+	for i := 0; i < n; i += 2 {
+		text := make([]byte, 0, 256)
+		for w := 0; w < 40; w++ {
+			text = append(text, words[rng.Intn(len(words))]...)
+			text = append(text, ' ')
+		}
+		chunks[i] = types.Chunk{Text: string(text)}
+		if i+1 < n {
+			chunks[i+1] = types.Chunk{Text: string(text) + "edited"}
+		}
+	}
+	return chunks
+}
+
+func BenchmarkNearDupMinHash_500Chunks(b *testing.B) {
+	chunks := makeNearDupChunks(500)
+	d := NewNearDupDetector(DefaultNearDupConfig())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = d.Cluster(chunks)
+	}
+}
+
+func BenchmarkNearDupSimHash_500Chunks(b *testing.B) {
+	chunks := makeNearDupChunks(500)
+	cfg := DefaultNearDupConfig()
+	cfg.Method = NearDupSimHash
+	d := NewNearDupDetector(cfg)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = d.Cluster(chunks)
+	}
+}
+
+// makeClusteredBenchChunks builds n chunks spread over k tight groups, the
+// shape of a large retrieval batch with many near-duplicate hits.
+func makeClusteredBenchChunks(n, k, dims int) []types.Chunk {
+	rng := rand.New(rand.NewSource(7))
+	centers := make([][]float32, k)
+	for i := range centers {
+		centers[i] = make([]float32, dims)
+		for d := range centers[i] {
+			centers[i][d] = float32(rng.NormFloat64())
+		}
+	}
+	chunks := make([]types.Chunk, n)
+	for i := range chunks {
+		c := centers[rng.Intn(k)]
+		emb := make([]float32, dims)
+		for d := range emb {
+			emb[d] = c[d] + float32(rng.NormFloat64()*0.1)
+		}
+		chunks[i] = types.Chunk{ID: string(rune('A' + i%26)), Embedding: emb}
+	}
+	return chunks
+}
+
+func benchmarkClusterScalable(b *testing.B, n int) {
+	chunks := makeClusteredBenchChunks(n, n/20, 128)
+	clusterer := NewClusterer(DefaultClusterConfig())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = clusterer.Cluster(chunks)
+	}
+}
+
+func BenchmarkClusterScalable_2000Chunks(b *testing.B)  { benchmarkClusterScalable(b, 2000) }
+func BenchmarkClusterScalable_10000Chunks(b *testing.B) { benchmarkClusterScalable(b, 10000) }
+
+func BenchmarkClusterAgglomerative_1000Chunks(b *testing.B) {
+	chunks := makeClusteredBenchChunks(1000, 50, 128)
+	clusterer := NewClusterer(ClusterConfig{Threshold: 0.15, ScalableAbove: -1})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = clusterer.Cluster(chunks)
+	}
+}
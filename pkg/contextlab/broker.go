@@ -2,9 +2,12 @@ package contextlab
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/rerank"
 	"github.com/Siddhant-K-code/distill/pkg/retriever"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
@@ -23,6 +26,11 @@ type BrokerConfig struct {
 	// Lower = more clusters, less aggressive deduplication.
 	ClusterThreshold float64
 
+	// AutoThreshold picks the clustering threshold from the retrieved
+	// chunks' nearest-neighbour distances, falling back to
+	// ClusterThreshold when there is no clear gap.
+	AutoThreshold bool
+
 	// ClusterLinkage determines how cluster distances are computed.
 	// Options: "single", "complete", "average"
 	ClusterLinkage string
@@ -38,6 +46,31 @@ type BrokerConfig struct {
 	// 1.0 = pure relevance, 0.0 = pure diversity, 0.5 = balanced
 	MMRLambda float64
 
+	// MultiQuery retrieves several variants of the query in parallel,
+	// derived from templates or by splitting the query into its questions,
+	// and fuses them by reciprocal-rank fusion before clustering. Variants
+	// passed in the request's Queries are always retrieved.
+	MultiQuery MultiQueryConfig
+
+	// Reranker rescores the selected representatives against the query
+	// text before MMR, so diversity is traded against better relevance
+	// scores. Nil disables reranking, as do vector-only queries.
+	Reranker rerank.Reranker
+
+	// RerankWeight blends reranker scores with retrieval scores, each
+	// min-max normalised (0-1). 1.0 = reranker only. Default: 1.0.
+	RerankWeight float64
+
+	// Constraints restrict the final selection by chunk metadata: a cap
+	// per source document, coverage of a field's distinct values, and a
+	// preference for recent chunks. Applied jointly with MMR when enabled.
+	Constraints SelectionConstraints
+
+	// Expand adds the chunks around each selected chunk in its source
+	// document, fetched through the retriever by metadata, merging
+	// overlapping windows within a token budget. Zero disables it.
+	Expand ExpandConfig
+
 	// IncludeEmbeddings requests embeddings in retrieval results.
 	// Required for clustering - will be enabled automatically if false.
 	IncludeEmbeddings bool
@@ -71,6 +104,7 @@ type Broker struct {
 	clusterer *Clusterer
 	selector  *Selector
 	mmr       *MMR
+	expander  *Expander
 }
 
 // NewBroker creates a new ContextLab broker.
@@ -94,8 +128,9 @@ func NewBroker(ret retriever.Retriever, cfg BrokerConfig) *Broker {
 
 	// Create sub-components
 	clusterer := NewClusterer(ClusterConfig{
-		Threshold: cfg.ClusterThreshold,
-		Linkage:   cfg.ClusterLinkage,
+		Threshold:     cfg.ClusterThreshold,
+		AutoThreshold: cfg.AutoThreshold,
+		Linkage:       cfg.ClusterLinkage,
 	})
 
 	selector := NewSelector(SelectorConfig{
@@ -105,8 +140,9 @@ func NewBroker(ret retriever.Retriever, cfg BrokerConfig) *Broker {
 	var mmr *MMR
 	if cfg.EnableMMR {
 		mmr = NewMMR(MMRConfig{
-			Lambda:  cfg.MMRLambda,
-			TargetK: cfg.TargetK,
+			Lambda:      cfg.MMRLambda,
+			TargetK:     cfg.TargetK,
+			Constraints: cfg.Constraints,
 		})
 	}
 
@@ -116,6 +152,7 @@ func NewBroker(ret retriever.Retriever, cfg BrokerConfig) *Broker {
 		clusterer: clusterer,
 		selector:  selector,
 		mmr:       mmr,
+		expander:  NewExpander(ret, cfg.Expand),
 	}
 }
 
@@ -132,7 +169,12 @@ func (b *Broker) Retrieve(ctx context.Context, req *types.RetrievalRequest) (*ty
 	stats := types.BrokerStats{}
 
 	// Step 1: Embed query if needed
-	if req.Query != "" && len(req.QueryEmbedding) == 0 {
+	variants := b.cfg.MultiQuery.Variants(req.Query, req.Queries)
+	if req.Query == "" && len(req.QueryEmbedding) > 0 && len(variants) > 0 {
+		variants = append([]string{""}, variants...)
+	}
+	multi := len(variants) > 1 || (len(variants) == 1 && len(req.Queries) > 0)
+	if !multi && req.Query != "" && len(req.QueryEmbedding) == 0 {
 		if b.embedder == nil {
 			return nil, fmt.Errorf("embedding provider required for text queries")
 		}
@@ -143,17 +185,28 @@ func (b *Broker) Retrieve(ctx context.Context, req *types.RetrievalRequest) (*ty
 		req.QueryEmbedding = embedding
 	}
 
-	if len(req.QueryEmbedding) == 0 {
+	if !multi && len(req.QueryEmbedding) == 0 {
 		return nil, retriever.ErrInvalidQuery
 	}
 
-	// Step 2: Over-fetch from vector DB
+	// Step 2: Over-fetch from vector DB, once per query variant
 	req.TopK = b.cfg.OverFetchK
 	req.IncludeEmbeddings = true
 	req.IncludeMetadata = b.cfg.IncludeMetadata
 
 	retrievalStart := time.Now()
-	result, err := b.retriever.Query(ctx, req)
+	var result *types.RetrievalResult
+	var err error
+	if multi {
+		result, err = b.retrieveMulti(ctx, req, variants)
+		if err == nil {
+			req.QueryEmbedding = result.QueryEmbedding
+		}
+		stats.Queries = len(variants)
+	} else {
+		result, err = b.retriever.Query(ctx, req)
+		stats.Queries = 1
+	}
 	if err != nil {
 		return nil, fmt.Errorf("retrieval failed: %w", err)
 	}
@@ -168,23 +221,47 @@ func (b *Broker) Retrieve(ctx context.Context, req *types.RetrievalRequest) (*ty
 	}
 
 	// Step 3: Cluster retrieved chunks
-	clusterStart := time.Now()
-	clusterResult := b.clusterer.Cluster(result.Chunks)
-	stats.ClusteringLatency = time.Since(clusterStart)
-	stats.Clustered = clusterResult.ClusterCount
+	clusterResult := b.cluster(result.Chunks, &stats)
 
 	// Step 4: Select representatives from each cluster
 	representatives := b.selector.Select(clusterResult)
 
-	// Step 5: Apply MMR if enabled
-	var finalChunks []types.Chunk
-	if b.cfg.EnableMMR && b.mmr != nil && len(representatives) > b.cfg.TargetK {
-		finalChunks = b.mmr.Rerank(representatives)
-	} else if len(representatives) > b.cfg.TargetK {
-		// Just take top K by score
-		finalChunks = SelectTopK(clusterResult, b.cfg.TargetK, b.cfg.SelectionStrategy)
-	} else {
-		finalChunks = representatives
+	// Multi-query results are ranked by their fused score
+	ranked := false
+	if multi {
+		SortByRRFScore(representatives)
+		ranked = true
+	}
+
+	// Step 5: Rerank representatives against the query text
+	if b.cfg.Reranker != nil && req.Query != "" && len(representatives) > 1 {
+		rerankStart := time.Now()
+		reranked, err := b.rerank(ctx, req.Query, representatives, &stats)
+		switch {
+		case errors.Is(err, rerank.ErrEmptyQuery):
+			// A query of only punctuation leaves nothing to score, so
+			// the representatives keep their order.
+		case err != nil:
+			return nil, fmt.Errorf("rerank failed: %w", err)
+		default:
+			representatives = reranked
+			stats.RerankLatency = time.Since(rerankStart)
+			stats.Reranked = len(representatives)
+			ranked = true
+		}
+	}
+
+	// Step 6: Select the final chunks (MMR, constraints, or top K)
+	finalChunks := b.selectFinal(clusterResult, representatives, ranked)
+
+	// Step 7: Expand the selection with surrounding chunks
+	if b.cfg.Expand.Enabled() {
+		expandStart := time.Now()
+		finalChunks, stats.Expanded, err = b.expander.Expand(ctx, finalChunks, req.QueryEmbedding, req.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("expand failed: %w", err)
+		}
+		stats.ExpandLatency = time.Since(expandStart)
 	}
 
 	stats.Returned = len(finalChunks)
@@ -196,6 +273,113 @@ func (b *Broker) Retrieve(ctx context.Context, req *types.RetrievalRequest) (*ty
 	}, nil
 }
 
+// cluster clusters chunks and records the clustering latency, the cluster
+// count, and the threshold used, resolved from the chunks when
+// AutoThreshold is set, in stats.
+func (b *Broker) cluster(chunks []types.Chunk, stats *types.BrokerStats) *types.ClusterResult {
+	clusterStart := time.Now()
+	clusterResult := b.clusterer.Cluster(chunks)
+	stats.ClusteringLatency = time.Since(clusterStart)
+	stats.Clustered = clusterResult.ClusterCount
+	stats.Threshold = clusterResult.Threshold
+	stats.ThresholdAuto = clusterResult.ThresholdAuto
+	return clusterResult
+}
+
+// selectFinal picks up to TargetK chunks from the cluster representatives:
+// by MMR when enabled, under the selection constraints when they are set,
+// and otherwise by rank. ranked reports that representatives are already
+// sorted, by the reranker or by fused score.
+func (b *Broker) selectFinal(clusterResult *types.ClusterResult, representatives []types.Chunk, ranked bool) []types.Chunk {
+	constrained := b.cfg.Constraints.Active()
+	switch {
+	case b.cfg.EnableMMR && b.mmr != nil && (len(representatives) > b.cfg.TargetK || constrained):
+		return b.mmr.Rerank(representatives)
+	case constrained:
+		return SelectConstrained(representatives, b.cfg.TargetK, b.cfg.Constraints)
+	case len(representatives) > b.cfg.TargetK && ranked:
+		// Ranked representatives are already sorted
+		return representatives[:b.cfg.TargetK]
+	case len(representatives) > b.cfg.TargetK:
+		// Just take top K by score
+		return SelectTopK(clusterResult, b.cfg.TargetK, b.cfg.SelectionStrategy)
+	}
+	return representatives
+}
+
+// rerank rescores chunks with the configured reranker, blends the result
+// with the retrieval scores, and returns the chunks sorted best first.
+// When the reranker scored with its fallback, the reason is recorded in
+// stats.
+func (b *Broker) rerank(ctx context.Context, query string, chunks []types.Chunk, stats *types.BrokerStats) ([]types.Chunk, error) {
+	docs := make([]string, len(chunks))
+	retrieval := make([]float64, len(chunks))
+	for i, c := range chunks {
+		docs[i] = c.Text
+		retrieval[i] = float64(c.Score)
+	}
+
+	var scores []float64
+	var err error
+	if f, ok := b.cfg.Reranker.(rerank.Fallbacker); ok {
+		var fallbackErr error
+		scores, fallbackErr, err = f.RerankFallback(ctx, query, docs)
+		if fallbackErr != nil {
+			stats.RerankFallback = fallbackErr.Error()
+		}
+	} else {
+		scores, err = b.cfg.Reranker.Rerank(ctx, query, docs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(chunks) {
+		return nil, fmt.Errorf("%s returned %d scores for %d chunks", b.cfg.Reranker.Name(), len(scores), len(chunks))
+	}
+
+	weight := b.cfg.RerankWeight
+	if weight <= 0 || weight > 1 {
+		weight = 1
+	}
+	scores = normalizeMinMax(scores)
+	retrieval = normalizeMinMax(retrieval)
+
+	out := make([]types.Chunk, len(chunks))
+	copy(out, chunks)
+	for i := range out {
+		out[i].Score = float32(weight*scores[i] + (1-weight)*retrieval[i])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out, nil
+}
+
+// normalizeMinMax scales values to [0, 1] in place. Equal values all
+// become 1.
+func normalizeMinMax(values []float64) []float64 {
+	if len(values) == 0 {
+		return values
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	for i, v := range values {
+		if hi == lo {
+			values[i] = 1
+		} else {
+			values[i] = (v - lo) / (hi - lo)
+		}
+	}
+	return values
+}
+
 // RetrieveByText is a convenience method for text queries.
 func (b *Broker) RetrieveByText(ctx context.Context, query string, namespace string) (*types.BrokerResult, error) {
 	req := &types.RetrievalRequest{
@@ -205,6 +389,20 @@ func (b *Broker) RetrieveByText(ctx context.Context, query string, namespace str
 	return b.Retrieve(ctx, req)
 }
 
+// RetrieveMultiQuery retrieves with several phrasings of one information
+// need. The first query is the primary one, used for reranking.
+func (b *Broker) RetrieveMultiQuery(ctx context.Context, queries []string, namespace string) (*types.BrokerResult, error) {
+	if len(queries) == 0 {
+		return nil, retriever.ErrInvalidQuery
+	}
+	req := &types.RetrievalRequest{
+		Query:     queries[0],
+		Queries:   queries[1:],
+		Namespace: namespace,
+	}
+	return b.Retrieve(ctx, req)
+}
+
 // RetrieveByVector is a convenience method for vector queries.
 func (b *Broker) RetrieveByVector(ctx context.Context, embedding []float32, namespace string) (*types.BrokerResult, error) {
 	req := &types.RetrievalRequest{
@@ -226,8 +424,9 @@ func (b *Broker) SetConfig(cfg BrokerConfig) {
 	b.cfg.IncludeEmbeddings = true
 
 	b.clusterer = NewClusterer(ClusterConfig{
-		Threshold: cfg.ClusterThreshold,
-		Linkage:   cfg.ClusterLinkage,
+		Threshold:     cfg.ClusterThreshold,
+		AutoThreshold: cfg.AutoThreshold,
+		Linkage:       cfg.ClusterLinkage,
 	})
 
 	b.selector = NewSelector(SelectorConfig{
@@ -236,12 +435,15 @@ func (b *Broker) SetConfig(cfg BrokerConfig) {
 
 	if cfg.EnableMMR {
 		b.mmr = NewMMR(MMRConfig{
-			Lambda:  cfg.MMRLambda,
-			TargetK: cfg.TargetK,
+			Lambda:      cfg.MMRLambda,
+			TargetK:     cfg.TargetK,
+			Constraints: cfg.Constraints,
 		})
 	} else {
 		b.mmr = nil
 	}
+
+	b.expander = NewExpander(b.retriever, cfg.Expand)
 }
 
 // GetConfig returns the current configuration.
@@ -273,23 +475,13 @@ func (b *Broker) ProcessChunks(chunks []types.Chunk) *types.BrokerResult {
 	}
 
 	// Cluster
-	clusterStart := time.Now()
-	clusterResult := b.clusterer.Cluster(chunks)
-	stats.ClusteringLatency = time.Since(clusterStart)
-	stats.Clustered = clusterResult.ClusterCount
+	clusterResult := b.cluster(chunks, &stats)
 
 	// Select representatives
 	representatives := b.selector.Select(clusterResult)
 
-	// Apply MMR if enabled
-	var finalChunks []types.Chunk
-	if b.cfg.EnableMMR && b.mmr != nil && len(representatives) > b.cfg.TargetK {
-		finalChunks = b.mmr.Rerank(representatives)
-	} else if len(representatives) > b.cfg.TargetK {
-		finalChunks = SelectTopK(clusterResult, b.cfg.TargetK, b.cfg.SelectionStrategy)
-	} else {
-		finalChunks = representatives
-	}
+	// Select the final chunks, as Retrieve does
+	finalChunks := b.selectFinal(clusterResult, representatives, false)
 
 	stats.Returned = len(finalChunks)
 	stats.TotalLatency = time.Since(totalStart)
@@ -0,0 +1,177 @@
+package contextlab
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/rerank"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// staticRetriever returns the same chunks for every query.
+type staticRetriever struct {
+	chunks []types.Chunk
+}
+
+func (r *staticRetriever) Query(_ context.Context, _ *types.RetrievalRequest) (*types.RetrievalResult, error) {
+	out := make([]types.Chunk, len(r.chunks))
+	copy(out, r.chunks)
+	return &types.RetrievalResult{Chunks: out}, nil
+}
+
+func (r *staticRetriever) QueryByID(ctx context.Context, _ string, _ int, _ string) (*types.RetrievalResult, error) {
+	return r.Query(ctx, nil)
+}
+
+func (r *staticRetriever) Close() error { return nil }
+
+// orthogonalChunks returns chunks with unit-axis embeddings, so none cluster
+// together, scored in descending order.
+func orthogonalChunks(texts []string) []types.Chunk {
+	chunks := make([]types.Chunk, len(texts))
+	for i, text := range texts {
+		emb := make([]float32, len(texts))
+		emb[i] = 1
+		chunks[i] = types.Chunk{
+			ID:        string(rune('a' + i)),
+			Text:      text,
+			Score:     float32(len(texts)-i) / float32(len(texts)),
+			Embedding: emb,
+		}
+	}
+	return chunks
+}
+
+func TestBroker_Rerank(t *testing.T) {
+	ret := &staticRetriever{chunks: orthogonalChunks([]string{
+		"Quarterly revenue grew in every region.",
+		"The cafeteria menu changes on Mondays.",
+		"Reset your password from the account settings page.",
+		"Password resets require a verified email address.",
+	})}
+	req := func() *types.RetrievalRequest {
+		return &types.RetrievalRequest{Query: "reset password", QueryEmbedding: []float32{1, 0, 0, 0}}
+	}
+
+	b := NewBroker(ret, BrokerConfig{TargetK: 2, ClusterThreshold: 0.1})
+	result, err := b.Retrieve(context.Background(), req())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Chunks[0].ID != "a" || result.Stats.Reranked != 0 {
+		t.Fatalf("expected retrieval order without a reranker, got %s (reranked %d)", result.Chunks[0].ID, result.Stats.Reranked)
+	}
+
+	b.SetConfig(BrokerConfig{TargetK: 2, ClusterThreshold: 0.1, Reranker: rerank.NewBM25(rerank.BM25Config{})})
+	result, err = b.Retrieve(context.Background(), req())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stats.Reranked != 4 {
+		t.Errorf("expected 4 reranked chunks, got %d", result.Stats.Reranked)
+	}
+	if len(result.Chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(result.Chunks))
+	}
+	for _, c := range result.Chunks {
+		if c.ID != "c" && c.ID != "d" {
+			t.Errorf("expected the password chunks, got %s: %q", c.ID, c.Text)
+		}
+	}
+
+	// A blended weight keeps some of the retrieval order.
+	b.SetConfig(BrokerConfig{TargetK: 2, ClusterThreshold: 0.1, Reranker: rerank.NewBM25(rerank.BM25Config{}), RerankWeight: 0.3})
+	result, err = b.Retrieve(context.Background(), req())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Chunks[0].ID != "a" {
+		t.Errorf("expected retrieval score to dominate at weight 0.3, got %s first", result.Chunks[0].ID)
+	}
+}
+
+// fallbackReranker scores documents in reverse order and reports that it
+// fell back.
+type fallbackReranker struct{}
+
+func (fallbackReranker) Name() string { return "fallback" }
+
+func (f fallbackReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	scores, _, err := f.RerankFallback(ctx, query, documents)
+	return scores, err
+}
+
+func (fallbackReranker) RerankFallback(_ context.Context, _ string, documents []string) ([]float64, error, error) {
+	scores := make([]float64, len(documents))
+	for i := range scores {
+		scores[i] = float64(i)
+	}
+	return scores, errors.New("rerank 401: invalid api key"), nil
+}
+
+func TestBroker_RerankFallbackAndEmptyQuery(t *testing.T) {
+	ret := &staticRetriever{chunks: orthogonalChunks([]string{
+		"Quarterly revenue grew in every region.",
+		"The cafeteria menu changes on Mondays.",
+		"Reset your password from the account settings page.",
+	})}
+	embedding := []float32{1, 0, 0}
+
+	b := NewBroker(ret, BrokerConfig{TargetK: 3, ClusterThreshold: 0.1, Reranker: fallbackReranker{}})
+	result, err := b.Retrieve(context.Background(), &types.RetrievalRequest{Query: "reset password", QueryEmbedding: embedding})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stats.RerankFallback != "rerank 401: invalid api key" || result.Chunks[0].ID != "c" {
+		t.Errorf("expected fallback scores used and the fallback reported, got %q with %s first",
+			result.Stats.RerankFallback, result.Chunks[0].ID)
+	}
+
+	// BM25 has no terms to score for a query of only punctuation: keep the
+	// retrieval order rather than failing the request.
+	b.SetConfig(BrokerConfig{TargetK: 3, ClusterThreshold: 0.1, Reranker: rerank.NewBM25(rerank.BM25Config{})})
+	result, err = b.Retrieve(context.Background(), &types.RetrievalRequest{Query: "?!", QueryEmbedding: embedding})
+	if err != nil {
+		t.Fatalf("expected a punctuation query to skip reranking, got error: %v", err)
+	}
+	if result.Stats.Reranked != 0 || len(result.Chunks) != 3 || result.Chunks[0].ID != "a" {
+		t.Errorf("expected retrieval order without reranking, got %s first (reranked %d)", result.Chunks[0].ID, result.Stats.Reranked)
+	}
+}
+
+func TestBroker_Constraints(t *testing.T) {
+	ret := &staticRetriever{chunks: sourcedChunks(
+		[]string{"a.md", "a.md", "a.md", "b.md", "c.md"},
+		[]string{"x", "x", "x", "x", "x"},
+	)}
+	req := &types.RetrievalRequest{QueryEmbedding: []float32{1, 0, 0, 0, 0}}
+	cons := SelectionConstraints{SourceField: "source", MaxPerSource: 1}
+
+	for _, enableMMR := range []bool{true, false} {
+		b := NewBroker(ret, BrokerConfig{TargetK: 4, ClusterThreshold: 0.1, EnableMMR: enableMMR, Constraints: cons})
+		result, err := b.Retrieve(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if counts := countBy(result.Chunks, "source"); len(result.Chunks) != 3 || counts["a.md"] != 1 {
+			t.Errorf("mmr=%v: expected one chunk per source, got %v", enableMMR, counts)
+		}
+	}
+}
+
+func TestBroker_ProcessChunksConstraints(t *testing.T) {
+	chunks := sourcedChunks(
+		[]string{"a.md", "a.md", "a.md", "b.md", "c.md"},
+		[]string{"x", "x", "x", "x", "x"},
+	)
+	cons := SelectionConstraints{SourceField: "source", MaxPerSource: 1}
+
+	for _, enableMMR := range []bool{true, false} {
+		b := NewBroker(&staticRetriever{}, BrokerConfig{TargetK: 4, ClusterThreshold: 0.1, EnableMMR: enableMMR, Constraints: cons})
+		result := b.ProcessChunks(copyChunks(chunks))
+		if counts := countBy(result.Chunks, "source"); len(result.Chunks) != 3 || counts["a.md"] != 1 {
+			t.Errorf("mmr=%v: expected one chunk per source, got %v", enableMMR, counts)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package contextlab
+
+import (
+	"sort"
+
+	"github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+const (
+	// HistogramBinWidth is the cosine distance width of each bin used to
+	// find the gap between duplicates and distinct chunks.
+	HistogramBinWidth = 0.01
+
+	// MaxAutoThreshold bounds the threshold AutoThreshold may pick. Gaps
+	// above it lie inside the spread of distinct chunks, not between
+	// duplicates and the rest.
+	MaxAutoThreshold = 0.4
+
+	// minGapBins is the narrowest run of empty bins accepted as a gap.
+	minGapBins = 2
+
+	// neighborPairBudget caps the distance computations NearestNeighbors
+	// spends; above it only a sample of vectors is queried.
+	neighborPairBudget = 1 << 20
+
+	// minNeighborQueries is the smallest sample NearestNeighbors queries.
+	minNeighborQueries = 64
+)
+
+// Neighbor records a vector's nearest neighbour.
+type Neighbor struct {
+	// Index is the queried vector.
+	Index int
+
+	// Neighbor is the closest other vector, or -1 if there is none.
+	Neighbor int
+
+	// Distance is the cosine distance between them (2 if there is none).
+	Distance float64
+}
+
+// NearestNeighbors finds each vector's nearest neighbour by cosine
+// distance. Empty vectors are skipped. When comparing every pair would be
+// too expensive, an evenly spaced sample of vectors is queried against all
+// of them, so the result describes the distribution without covering
+// every vector.
+func NearestNeighbors(embeddings [][]float32) []Neighbor {
+	var present []int
+	for i, e := range embeddings {
+		if len(e) > 0 {
+			present = append(present, i)
+		}
+	}
+	n := len(present)
+	if n < 2 {
+		return nil
+	}
+
+	vectors := make([][]float32, n)
+	for i, idx := range present {
+		vectors[i] = embeddings[idx]
+	}
+	if n*(n-1)/2 <= neighborPairBudget {
+		return nearestFromMatrix(math.CosineDistanceMatrix(vectors), present)
+	}
+
+	queries := neighborPairBudget / n
+	if queries < minNeighborQueries {
+		queries = minNeighborQueries
+	}
+	neighbors := make([]Neighbor, 0, queries)
+	var dists []float64
+	for q := 0; q < queries; q++ {
+		i := q * n / queries
+		dists = math.CosineDistances(dists, vectors[i], vectors)
+		dists[i] = 2
+		best := nearest(dists)
+		neighbors = append(neighbors, Neighbor{Index: present[i], Neighbor: present[best], Distance: dists[best]})
+	}
+	return neighbors
+}
+
+// nearestFromMatrix reads each row's nearest neighbour off a distance
+// matrix. ids maps matrix rows to the caller's indices; nil means
+// identity.
+func nearestFromMatrix(matrix [][]float64, ids []int) []Neighbor {
+	id := func(i int) int {
+		if ids == nil {
+			return i
+		}
+		return ids[i]
+	}
+	neighbors := make([]Neighbor, len(matrix))
+	for i, row := range matrix {
+		best, bestDist := -1, 2.0
+		for j, d := range row {
+			if j != i && (best < 0 || d < bestDist) {
+				best, bestDist = j, d
+			}
+		}
+		neighbors[i] = Neighbor{Index: id(i), Neighbor: -1, Distance: bestDist}
+		if best >= 0 {
+			neighbors[i].Neighbor = id(best)
+		}
+	}
+	return neighbors
+}
+
+// nearest returns the index of the smallest distance.
+func nearest(dists []float64) int {
+	best := 0
+	for j, d := range dists {
+		if d < dists[best] {
+			best = j
+		}
+	}
+	return best
+}
+
+// NeighborDistances returns the distances of the given neighbours.
+func NeighborDistances(neighbors []Neighbor) []float64 {
+	out := make([]float64, len(neighbors))
+	for i, nb := range neighbors {
+		out[i] = nb.Distance
+	}
+	return out
+}
+
+// DistanceHistogram counts cosine distances in fixed-width bins from 0.
+// The last bin also holds every distance at or beyond its start.
+type DistanceHistogram struct {
+	BinWidth float64
+	Counts   []int
+}
+
+// NewDistanceHistogram bins distances into bins of binWidth up to max.
+func NewDistanceHistogram(distances []float64, binWidth, max float64) DistanceHistogram {
+	bins := int(max/binWidth + 0.5)
+	if bins < 1 {
+		bins = 1
+	}
+	h := DistanceHistogram{BinWidth: binWidth, Counts: make([]int, bins+1)}
+	for _, d := range distances {
+		b := int(d / binWidth)
+		if b < 0 {
+			b = 0
+		}
+		if b > bins {
+			b = bins
+		}
+		h.Counts[b]++
+	}
+	return h
+}
+
+// Gap returns the widest run of empty bins [start, end) that has
+// distances on both sides, considering runs that start below maxBin. Ties
+// go to the lower run. ok is false if no run is at least minBins wide.
+func (h DistanceHistogram) Gap(maxBin, minBins int) (start, end int, ok bool) {
+	seen := false
+	bestLen := 0
+	for i := 0; i < len(h.Counts) && i < maxBin; {
+		if h.Counts[i] > 0 {
+			seen = true
+			i++
+			continue
+		}
+		j := i
+		for j < len(h.Counts) && h.Counts[j] == 0 {
+			j++
+		}
+		if seen && j < len(h.Counts) && j-i > bestLen {
+			start, end, bestLen = i, j, j-i
+		}
+		i = j
+	}
+	return start, end, bestLen >= minBins
+}
+
+// AutoThreshold picks a dedup threshold from nearest-neighbour distances:
+// duplicates sit close to their neighbour and distinct chunks further
+// away, so the threshold goes in the middle of the widest empty stretch of
+// the histogram below MaxAutoThreshold. ok is false when there is no clear
+// gap, e.g. when nothing or everything is duplicated.
+func AutoThreshold(distances []float64) (threshold float64, ok bool) {
+	if len(distances) < 3 {
+		return 0, false
+	}
+	h := NewDistanceHistogram(distances, HistogramBinWidth, MaxAutoThreshold)
+	start, end, ok := h.Gap(int(MaxAutoThreshold/HistogramBinWidth), minGapBins)
+	if !ok {
+		return 0, false
+	}
+	return float64(start+end) / 2 * h.BinWidth, true
+}
+
+// LabeledDistance is a pair of chunks a person judged to be duplicates or
+// not, with their cosine distance.
+type LabeledDistance struct {
+	Distance  float64
+	Duplicate bool
+}
+
+// FitThreshold returns the threshold that classifies the most labelled
+// pairs correctly, treating distance <= threshold as duplicate, and how
+// many it gets right. Among equally good thresholds it picks the middle of
+// the widest margin between labelled distances.
+func FitThreshold(labels []LabeledDistance) (threshold float64, correct int) {
+	if len(labels) == 0 {
+		return 0, 0
+	}
+	sorted := make([]LabeledDistance, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Distance < sorted[j].Distance })
+
+	// Start with every pair above the threshold: the distinct ones are
+	// right. Moving the threshold past a pair flips its classification.
+	for _, l := range sorted {
+		if !l.Duplicate {
+			correct++
+		}
+	}
+	best := correct
+	threshold, bestMargin := sorted[0].Distance/2, sorted[0].Distance
+
+	score := correct
+	for i, l := range sorted {
+		if l.Duplicate {
+			score++
+		} else {
+			score--
+		}
+		// Only cut between distinct distances.
+		if i+1 < len(sorted) && sorted[i+1].Distance == l.Distance {
+			continue
+		}
+		lo, hi := l.Distance, l.Distance+2*HistogramBinWidth
+		if i+1 < len(sorted) {
+			hi = sorted[i+1].Distance
+		}
+		if score > best || (score == best && hi-lo > bestMargin) {
+			best, bestMargin = score, hi-lo
+			threshold = (lo + hi) / 2
+		}
+	}
+	return threshold, best
+}
+
+// autoThreshold picks the threshold for chunks, reading nearest neighbours
+// off matrix when the caller already has one.
+func (c *Clusterer) autoThreshold(chunks []types.Chunk, matrix [][]float64) (float64, bool) {
+	var neighbors []Neighbor
+	if matrix != nil {
+		neighbors = nearestFromMatrix(matrix, nil)
+		// Rows of chunks without embeddings only hold the maximum distance.
+		kept := neighbors[:0]
+		for _, nb := range neighbors {
+			if len(chunks[nb.Index].Embedding) > 0 {
+				kept = append(kept, nb)
+			}
+		}
+		neighbors = kept
+	} else {
+		neighbors = NearestNeighbors(chunkEmbeddings(chunks))
+	}
+	if t, ok := AutoThreshold(NeighborDistances(neighbors)); ok {
+		return t, true
+	}
+	return c.cfg.Threshold, false
+}
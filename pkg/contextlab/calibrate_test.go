@@ -0,0 +1,144 @@
+package contextlab
+
+import (
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// pairedChunks returns pairs distinct chunks, each followed by a near
+// duplicate about 0.005 away in cosine distance, then singles chunks with
+// no duplicate.
+func pairedChunks(pairs, singles int) []types.Chunk {
+	dim := pairs + singles + 1
+	chunks := make([]types.Chunk, 0, 2*pairs+singles)
+	for i := 0; i < pairs; i++ {
+		orig := make([]float32, dim)
+		orig[i] = 1
+		dup := make([]float32, dim)
+		dup[i], dup[i+1] = 1, 0.1
+		chunks = append(chunks,
+			types.Chunk{ID: string(rune('a' + 2*i)), Embedding: orig, Score: 1},
+			types.Chunk{ID: string(rune('a' + 2*i + 1)), Embedding: dup, Score: 0.5},
+		)
+	}
+	for i := 0; i < singles; i++ {
+		emb := make([]float32, dim)
+		emb[pairs+1+i] = 1
+		chunks = append(chunks, types.Chunk{ID: string(rune('A' + i)), Embedding: emb, Score: 1})
+	}
+	return chunks
+}
+
+func TestAutoThreshold(t *testing.T) {
+	threshold, ok := AutoThreshold([]float64{0.01, 0.02, 0.015, 0.3, 0.35, 0.5, 0.6})
+	if !ok {
+		t.Fatal("expected a gap")
+	}
+	if threshold <= 0.02 || threshold >= 0.3 {
+		t.Errorf("expected a threshold inside the gap, got %v", threshold)
+	}
+
+	for _, distances := range [][]float64{
+		{0.5, 0.6, 0.7, 0.8},          // nothing duplicated
+		{0.01, 0.01, 0.02, 0.015},     // everything duplicated
+		{0.01, 0.5},                   // too few
+		{0.1, 0.11, 0.12, 0.13, 0.14}, // no empty run
+	} {
+		if threshold, ok := AutoThreshold(distances); ok {
+			t.Errorf("%v: expected no gap, got %v", distances, threshold)
+		}
+	}
+}
+
+func TestFitThreshold(t *testing.T) {
+	threshold, correct := FitThreshold([]LabeledDistance{
+		{Distance: 0.05, Duplicate: true},
+		{Distance: 0.08, Duplicate: true},
+		{Distance: 0.12, Duplicate: false},
+		{Distance: 0.2, Duplicate: false},
+	})
+	if correct != 4 {
+		t.Errorf("expected all 4 labels separable, got %d", correct)
+	}
+	if threshold != 0.1 {
+		t.Errorf("expected the middle of the margin, 0.1, got %v", threshold)
+	}
+
+	// A mislabelled pair costs one, but does not move the cut.
+	threshold, correct = FitThreshold([]LabeledDistance{
+		{Distance: 0.02, Duplicate: false},
+		{Distance: 0.05, Duplicate: true},
+		{Distance: 0.08, Duplicate: true},
+		{Distance: 0.12, Duplicate: false},
+		{Distance: 0.2, Duplicate: false},
+	})
+	if correct != 4 || threshold != 0.1 {
+		t.Errorf("expected 4 correct at 0.1, got %d at %v", correct, threshold)
+	}
+}
+
+func TestNearestNeighbors(t *testing.T) {
+	chunks := pairedChunks(3, 0)
+	embeddings := chunkEmbeddings(chunks)
+	embeddings = append(embeddings, nil)
+
+	neighbors := NearestNeighbors(embeddings)
+	if len(neighbors) != len(chunks) {
+		t.Fatalf("expected one neighbour per embedded chunk, got %d", len(neighbors))
+	}
+	for _, nb := range neighbors {
+		if nb.Index/2 != nb.Neighbor/2 {
+			t.Errorf("expected %d paired with its duplicate, got %d", nb.Index, nb.Neighbor)
+		}
+	}
+}
+
+func TestCluster_AutoThreshold(t *testing.T) {
+	chunks := pairedChunks(4, 4)
+
+	cfg := DefaultClusterConfig()
+	cfg.Threshold = 0.001
+	result := NewClusterer(cfg).Cluster(chunks)
+	if result.ClusterCount != 12 || result.ThresholdAuto {
+		t.Errorf("expected the fixed threshold to keep all 12 chunks, got %d clusters", result.ClusterCount)
+	}
+
+	cfg.AutoThreshold = true
+	result = NewClusterer(cfg).Cluster(chunks)
+	if !result.ThresholdAuto {
+		t.Fatal("expected the threshold to be picked from the gap")
+	}
+	if result.ClusterCount != 8 {
+		t.Errorf("expected each pair merged, leaving 8 clusters, got %d", result.ClusterCount)
+	}
+	if result.Threshold <= 0.005 || result.Threshold > MaxAutoThreshold {
+		t.Errorf("unexpected auto threshold %v", result.Threshold)
+	}
+
+	// Without a gap the configured threshold is kept.
+	result = NewClusterer(cfg).Cluster(orthogonalChunks(make([]string, 5)))
+	if result.ThresholdAuto || result.Threshold != 0.001 {
+		t.Errorf("expected the fallback threshold, got %v (auto=%v)", result.Threshold, result.ThresholdAuto)
+	}
+}
+
+func TestBroker_ProcessChunksThreshold(t *testing.T) {
+	chunks := pairedChunks(4, 4)
+
+	b := NewBroker(&staticRetriever{}, BrokerConfig{TargetK: 20, ClusterThreshold: 0.001, AutoThreshold: true})
+	result := b.ProcessChunks(chunks)
+	if !result.Stats.ThresholdAuto || result.Stats.Clustered != 8 {
+		t.Errorf("expected the threshold picked from the gap, got %v (auto=%v, %d clusters)",
+			result.Stats.Threshold, result.Stats.ThresholdAuto, result.Stats.Clustered)
+	}
+	if result.Stats.Threshold <= 0.005 || result.Stats.Threshold > MaxAutoThreshold {
+		t.Errorf("unexpected auto threshold %v", result.Stats.Threshold)
+	}
+
+	b = NewBroker(&staticRetriever{}, BrokerConfig{TargetK: 20, ClusterThreshold: 0.001})
+	result = b.ProcessChunks(chunks)
+	if result.Stats.ThresholdAuto || result.Stats.Threshold != 0.001 {
+		t.Errorf("expected the configured threshold reported, got %v (auto=%v)", result.Stats.Threshold, result.Stats.ThresholdAuto)
+	}
+}
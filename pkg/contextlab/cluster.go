@@ -15,6 +15,12 @@ type ClusterConfig struct {
 	// Typical range: 0.10-0.30
 	Threshold float64
 
+	// AutoThreshold picks the threshold from the gap in the histogram of
+	// nearest-neighbour distances (see AutoThreshold), falling back to
+	// Threshold when there is no clear gap. The value used is reported in
+	// ClusterResult.Threshold.
+	AutoThreshold bool
+
 	// MinClusters is the minimum number of clusters to form (optional).
 	// If 0, clustering stops only based on threshold.
 	MinClusters int
@@ -26,15 +32,22 @@ type ClusterConfig struct {
 	// Linkage determines how inter-cluster distance is computed.
 	// Options: "single", "complete", "average" (default: "average")
 	Linkage string
+
+	// ScalableAbove is the chunk count above which leader clustering
+	// replaces agglomerative merging, whose distance matrix grows with n².
+	// Linkage, MinClusters and MaxClusters do not apply to leader
+	// clustering. Default: 1000. Negative always uses agglomerative.
+	ScalableAbove int
 }
 
 // DefaultClusterConfig returns sensible defaults.
 func DefaultClusterConfig() ClusterConfig {
 	return ClusterConfig{
-		Threshold:   0.15,
-		MinClusters: 0,
-		MaxClusters: 0,
-		Linkage:     "average",
+		Threshold:     0.15,
+		MinClusters:   0,
+		MaxClusters:   0,
+		Linkage:       "average",
+		ScalableAbove: DefaultScalableAbove,
 	}
 }
 
@@ -51,6 +64,9 @@ func NewClusterer(cfg ClusterConfig) *Clusterer {
 	if cfg.Linkage == "" {
 		cfg.Linkage = "average"
 	}
+	if cfg.ScalableAbove == 0 {
+		cfg.ScalableAbove = DefaultScalableAbove
+	}
 	return &Clusterer{cfg: cfg}
 }
 
@@ -62,8 +78,9 @@ type clusterNode struct {
 	active   bool
 }
 
-// Cluster performs agglomerative clustering on the given chunks.
-// Returns clusters with assigned members and centroids.
+// Cluster performs agglomerative clustering on the given chunks, or leader
+// clustering above ScalableAbove chunks. Returns clusters with assigned
+// members and centroids.
 func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 	start := time.Now()
 
@@ -74,6 +91,7 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 			Representatives: []types.Chunk{},
 			InputCount:      0,
 			ClusterCount:    0,
+			Threshold:       c.cfg.Threshold,
 			Latency:         time.Since(start),
 		}
 	}
@@ -89,6 +107,7 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 			Representatives: []types.Chunk{chunks[0]},
 			InputCount:      1,
 			ClusterCount:    1,
+			Threshold:       c.cfg.Threshold,
 			Latency:         time.Since(start),
 		}
 	}
@@ -117,10 +136,21 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 			Representatives: chunks,
 			InputCount:      n,
 			ClusterCount:    n,
+			Threshold:       c.cfg.Threshold,
 			Latency:         time.Since(start),
 		}
 	}
 
+	if c.cfg.ScalableAbove > 0 && n > c.cfg.ScalableAbove {
+		threshold, auto := c.cfg.Threshold, false
+		if c.cfg.AutoThreshold {
+			threshold, auto = c.autoThreshold(chunks, nil)
+		}
+		result := c.clusterLeaders(chunks, threshold)
+		result.Threshold, result.ThresholdAuto = threshold, auto
+		return result
+	}
+
 	// Initialize each chunk as its own cluster
 	nodes := make([]*clusterNode, n)
 	for i := range chunks {
@@ -137,6 +167,11 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 	// Compute initial distance matrix (upper triangular)
 	distMatrix := c.computeDistanceMatrix(chunks)
 
+	threshold, auto := c.cfg.Threshold, false
+	if c.cfg.AutoThreshold {
+		threshold, auto = c.autoThreshold(chunks, distMatrix)
+	}
+
 	// Agglomerative merging
 	activeCount := n
 	for activeCount > 1 {
@@ -167,7 +202,7 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 		}
 
 		// Check if we should stop merging
-		if minDist > c.cfg.Threshold {
+		if minDist > threshold {
 			break
 		}
 
@@ -206,39 +241,28 @@ func (c *Clusterer) Cluster(chunks []types.Chunk) *types.ClusterResult {
 	}
 
 	return &types.ClusterResult{
-		Clusters:     clusters,
-		InputCount:   n,
-		ClusterCount: len(clusters),
-		Latency:      time.Since(start),
+		Clusters:      clusters,
+		InputCount:    n,
+		ClusterCount:  len(clusters),
+		Threshold:     threshold,
+		ThresholdAuto: auto,
+		Latency:       time.Since(start),
 	}
 }
 
-// computeDistanceMatrix computes pairwise cosine distances.
+// computeDistanceMatrix computes pairwise cosine distances. Chunks without
+// embeddings are at the maximum distance, 2, from every other chunk.
 func (c *Clusterer) computeDistanceMatrix(chunks []types.Chunk) [][]float64 {
-	n := len(chunks)
-	matrix := make([][]float64, n)
-
-	// Initialize all rows first
-	for i := 0; i < n; i++ {
-		matrix[i] = make([]float64, n)
-	}
+	return math.CosineDistanceMatrix(chunkEmbeddings(chunks))
+}
 
-	// Compute distances
-	for i := 0; i < n; i++ {
-		for j := i + 1; j < n; j++ {
-			// Handle missing embeddings gracefully
-			if len(chunks[i].Embedding) == 0 || len(chunks[j].Embedding) == 0 {
-				matrix[i][j] = 2.0 // Max distance
-				matrix[j][i] = 2.0
-				continue
-			}
-			dist := math.CosineDistance(chunks[i].Embedding, chunks[j].Embedding)
-			matrix[i][j] = dist
-			matrix[j][i] = dist
-		}
+// chunkEmbeddings returns the chunks' embeddings in order.
+func chunkEmbeddings(chunks []types.Chunk) [][]float32 {
+	embeddings := make([][]float32, len(chunks))
+	for i := range chunks {
+		embeddings[i] = chunks[i].Embedding
 	}
-
-	return matrix
+	return embeddings
 }
 
 // clusterDistance computes distance between two clusters based on linkage type.
@@ -0,0 +1,183 @@
+package contextlab
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// DefaultFreshnessHalfLife is the age at which the freshness bonus halves.
+const DefaultFreshnessHalfLife = 30 * 24 * time.Hour
+
+// SelectionConstraints restrict a selection by chunk metadata, so that
+// chunks from one document cannot crowd out the rest. The zero value
+// imposes nothing.
+type SelectionConstraints struct {
+	// SourceField is the metadata key naming a chunk's source document,
+	// e.g. "source" or "doc_id". Chunks without it count as their own
+	// source.
+	SourceField string
+
+	// MaxPerSource caps how many selected chunks share one SourceField
+	// value. 0 = no cap.
+	MaxPerSource int
+
+	// CoverField is a metadata key whose distinct values should each be
+	// represented at least once, as far as the selection size allows.
+	CoverField string
+
+	// FreshnessField is a metadata key holding a timestamp (RFC 3339,
+	// time.Time, or Unix seconds). Newer chunks rank higher; chunks
+	// without one get no freshness bonus.
+	FreshnessField string
+
+	// FreshnessHalfLife is the age at which the freshness bonus halves.
+	// Default: 30 days.
+	FreshnessHalfLife time.Duration
+
+	// FreshnessWeight blends freshness into relevance (0-1).
+	// Default: 0.3.
+	FreshnessWeight float64
+
+	// Now is the reference time for freshness. Zero uses time.Now.
+	Now time.Time
+}
+
+// Active reports whether any constraint is set.
+func (c SelectionConstraints) Active() bool {
+	return c.limitsSources() || c.CoverField != "" || c.FreshnessField != ""
+}
+
+// Validate reports a constraint that cannot be applied.
+func (c SelectionConstraints) Validate() error {
+	if c.MaxPerSource < 0 {
+		return fmt.Errorf("max_per_source must be non-negative, got %d", c.MaxPerSource)
+	}
+	if c.MaxPerSource > 0 && c.SourceField == "" {
+		return fmt.Errorf("max_per_source requires a source field")
+	}
+	if c.FreshnessWeight < 0 || c.FreshnessWeight > 1 {
+		return fmt.Errorf("freshness weight must be between 0 and 1, got %f", c.FreshnessWeight)
+	}
+	if c.FreshnessHalfLife < 0 {
+		return fmt.Errorf("freshness half-life must be non-negative")
+	}
+	return nil
+}
+
+func (c SelectionConstraints) limitsSources() bool {
+	return c.SourceField != "" && c.MaxPerSource > 0
+}
+
+// blendFreshness mixes a freshness score into relevance scores already
+// normalised to [0, 1], in place. Freshness decays exponentially with age.
+func (c SelectionConstraints) blendFreshness(chunks []types.Chunk, relevance []float64) []float64 {
+	if c.FreshnessField == "" {
+		return relevance
+	}
+	weight := c.FreshnessWeight
+	if weight <= 0 || weight > 1 {
+		weight = 0.3
+	}
+	halfLife := c.FreshnessHalfLife
+	if halfLife <= 0 {
+		halfLife = DefaultFreshnessHalfLife
+	}
+	now := c.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for i, chunk := range chunks {
+		var freshness float64
+		if ts, ok := types.MetadataTime(chunk.Metadata[c.FreshnessField]); ok {
+			age := now.Sub(ts)
+			if age < 0 {
+				age = 0
+			}
+			freshness = math.Exp2(-float64(age) / float64(halfLife))
+		}
+		relevance[i] = (1-weight)*relevance[i] + weight*freshness
+	}
+	return relevance
+}
+
+// constraintTracker records what a greedy selection has taken so far and
+// decides which candidates remain eligible.
+type constraintTracker struct {
+	cons    SelectionConstraints
+	sources map[string]int
+	covered map[string]bool
+}
+
+func newConstraintTracker(cons SelectionConstraints) *constraintTracker {
+	return &constraintTracker{
+		cons:    cons,
+		sources: make(map[string]int),
+		covered: make(map[string]bool),
+	}
+}
+
+// metadataValue returns the string form of a metadata field, if present.
+func metadataValue(chunk types.Chunk, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	v, ok := chunk.Metadata[key]
+	if !ok || v == nil {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// allows reports whether chunk fits under the per-source cap.
+func (t *constraintTracker) allows(chunk types.Chunk) bool {
+	if !t.cons.limitsSources() {
+		return true
+	}
+	source, ok := metadataValue(chunk, t.cons.SourceField)
+	return !ok || t.sources[source] < t.cons.MaxPerSource
+}
+
+// covers reports whether chunk adds a CoverField value not yet selected.
+func (t *constraintTracker) covers(chunk types.Chunk) bool {
+	v, ok := metadataValue(chunk, t.cons.CoverField)
+	return ok && !t.covered[v]
+}
+
+// mustCover reports whether the slots left are only enough to cover the
+// CoverField values still missing, so the next pick has to add one.
+func (t *constraintTracker) mustCover(chunks []types.Chunk, remaining map[int]bool, slots int) bool {
+	if t.cons.CoverField == "" {
+		return false
+	}
+	missing := make(map[string]bool)
+	for idx := range remaining {
+		if !t.allows(chunks[idx]) {
+			continue
+		}
+		if v, ok := metadataValue(chunks[idx], t.cons.CoverField); ok && !t.covered[v] {
+			missing[v] = true
+		}
+	}
+	return len(missing) > 0 && len(missing) >= slots
+}
+
+// add records chunk as selected.
+func (t *constraintTracker) add(chunk types.Chunk) {
+	if source, ok := metadataValue(chunk, t.cons.SourceField); ok {
+		t.sources[source]++
+	}
+	if v, ok := metadataValue(chunk, t.cons.CoverField); ok {
+		t.covered[v] = true
+	}
+}
+
+// SelectConstrained picks up to k chunks by score, with freshness blended
+// in, while respecting the constraints. It is MMR without the diversity
+// term.
+func SelectConstrained(chunks []types.Chunk, k int, cons SelectionConstraints) []types.Chunk {
+	return NewMMR(MMRConfig{Lambda: 1, TargetK: k, Constraints: cons}).Rerank(chunks)
+}
@@ -0,0 +1,105 @@
+package contextlab
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// sourcedChunks returns orthogonal chunks scored in descending order, with
+// the given source and section metadata.
+func sourcedChunks(sources, sections []string) []types.Chunk {
+	texts := make([]string, len(sources))
+	chunks := orthogonalChunks(texts)
+	for i := range chunks {
+		chunks[i].Metadata = map[string]interface{}{
+			"source":  sources[i],
+			"section": sections[i],
+		}
+	}
+	return chunks
+}
+
+func countBy(chunks []types.Chunk, key string) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range chunks {
+		counts[c.Metadata[key].(string)]++
+	}
+	return counts
+}
+
+func TestMMR_MaxPerSource(t *testing.T) {
+	chunks := sourcedChunks(
+		[]string{"a.md", "a.md", "a.md", "a.md", "b.md", "c.md"},
+		[]string{"x", "x", "x", "x", "x", "x"},
+	)
+	cons := SelectionConstraints{SourceField: "source", MaxPerSource: 2}
+
+	for _, lambda := range []float64{0.5, 1} {
+		got := NewMMR(MMRConfig{Lambda: lambda, TargetK: 4, Constraints: cons}).Rerank(chunks)
+		if len(got) != 4 {
+			t.Fatalf("lambda %v: expected 4 chunks, got %d", lambda, len(got))
+		}
+		if counts := countBy(got, "source"); counts["a.md"] != 2 || counts["b.md"] != 1 || counts["c.md"] != 1 {
+			t.Errorf("lambda %v: expected 2 from a.md and one each from b.md, c.md, got %v", lambda, counts)
+		}
+	}
+
+	// The cap applies even when there are fewer chunks than TargetK.
+	got := SelectConstrained(chunks, 10, cons)
+	if len(got) != 4 {
+		t.Errorf("expected the cap to drop 2 chunks, got %d", len(got))
+	}
+}
+
+func TestMMR_CoverField(t *testing.T) {
+	chunks := sourcedChunks(
+		[]string{"a", "b", "c", "d", "e", "f"},
+		[]string{"intro", "intro", "intro", "intro", "api", "faq"},
+	)
+	got := SelectConstrained(chunks, 3, SelectionConstraints{CoverField: "section"})
+	if counts := countBy(got, "section"); len(counts) != 3 {
+		t.Errorf("expected all 3 sections covered, got %v", counts)
+	}
+	if got[0].Metadata["source"] != "a" {
+		t.Errorf("expected the top chunk to be kept first, got %v", got[0].Metadata["source"])
+	}
+
+	got = SelectConstrained(chunks, 4, SelectionConstraints{CoverField: "section"})
+	if counts := countBy(got, "section"); counts["intro"] != 2 {
+		t.Errorf("expected spare slots to go to the best chunks, got %v", counts)
+	}
+}
+
+func TestSelectConstrained_Freshness(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	chunks := orthogonalChunks(make([]string, 3))
+	chunks[0].Metadata = map[string]interface{}{"updated_at": now.AddDate(-2, 0, 0).Format(time.RFC3339)}
+	chunks[1].Metadata = map[string]interface{}{"updated_at": float64(now.Add(-time.Hour).Unix())}
+	chunks[2].Metadata = map[string]interface{}{}
+
+	cons := SelectionConstraints{FreshnessField: "updated_at", FreshnessWeight: 0.8, Now: now}
+	got := SelectConstrained(chunks, 1, cons)
+	if got[0].ID != chunks[1].ID {
+		t.Errorf("expected the fresh chunk first, got %s", got[0].ID)
+	}
+
+	cons.FreshnessWeight = 0.1
+	got = SelectConstrained(chunks, 1, cons)
+	if got[0].ID != chunks[0].ID {
+		t.Errorf("expected relevance to dominate at low weight, got %s", got[0].ID)
+	}
+}
+
+func TestSelectionConstraints_Validate(t *testing.T) {
+	if err := (SelectionConstraints{MaxPerSource: 2}).Validate(); err == nil {
+		t.Error("expected error for max_per_source without a source field")
+	}
+	if err := (SelectionConstraints{FreshnessWeight: 2}).Validate(); err == nil {
+		t.Error("expected error for freshness weight above 1")
+	}
+	if err := (SelectionConstraints{SourceField: "doc", MaxPerSource: 1}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
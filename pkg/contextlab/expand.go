@@ -0,0 +1,414 @@
+package contextlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Siddhant-K-code/distill/pkg/retriever"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// ExpandMode selects the context added around each selected chunk.
+type ExpandMode string
+
+const (
+	// ExpandNone returns chunks as selected.
+	ExpandNone ExpandMode = ""
+
+	// ExpandNeighbors adds up to Window adjacent chunks on each side.
+	ExpandNeighbors ExpandMode = "neighbors"
+
+	// ExpandParent adds the rest of the parent document, nearest chunks
+	// first, as far as MaxTokens allows.
+	ExpandParent ExpandMode = "parent"
+)
+
+// Default metadata keys and limits for expansion.
+const (
+	DefaultExpandDocField   = "doc_id"
+	DefaultExpandIndexField = "chunk_index"
+	DefaultExpandFetchK     = 100
+)
+
+// ExpandConfig configures expansion of selected chunks with the chunks
+// around them in their source document. Chunks are located by two
+// metadata fields: the document they belong to and their position in it.
+type ExpandConfig struct {
+	// Mode is ExpandNeighbors or ExpandParent; empty disables expansion.
+	Mode ExpandMode
+
+	// Window is the number of chunks added on each side in
+	// ExpandNeighbors mode. Default: 1.
+	Window int
+
+	// DocField is the metadata key naming a chunk's document.
+	// Default: "doc_id".
+	DocField string
+
+	// IndexField is the metadata key holding a chunk's position in its
+	// document. Default: "chunk_index".
+	IndexField string
+
+	// MaxTokens caps the tokens of all returned chunks together. Chunks
+	// nearest a selected chunk are added first; selected chunks always
+	// stay. 0 = no cap.
+	MaxTokens int
+
+	// FetchK is the most chunks fetched per document. Default: 100.
+	FetchK int
+
+	// Tokenizer counts tokens for MaxTokens. Nil uses the default counter.
+	Tokenizer tokenizer.Counter
+}
+
+// Enabled reports whether expansion is on.
+func (c ExpandConfig) Enabled() bool {
+	return c.Mode != ExpandNone
+}
+
+// Validate reports settings that cannot be applied.
+func (c ExpandConfig) Validate() error {
+	switch c.Mode {
+	case ExpandNone, ExpandNeighbors, ExpandParent:
+	default:
+		return fmt.Errorf("unsupported expand mode %q (supported: neighbors, parent)", c.Mode)
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("expand window must be non-negative, got %d", c.Window)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("expand max tokens must be non-negative, got %d", c.MaxTokens)
+	}
+	if c.FetchK < 0 {
+		return fmt.Errorf("expand fetch k must be non-negative, got %d", c.FetchK)
+	}
+	return nil
+}
+
+func (c ExpandConfig) withDefaults() ExpandConfig {
+	if c.Window <= 0 {
+		c.Window = 1
+	}
+	if c.DocField == "" {
+		c.DocField = DefaultExpandDocField
+	}
+	if c.IndexField == "" {
+		c.IndexField = DefaultExpandIndexField
+	}
+	if c.FetchK <= 0 {
+		c.FetchK = DefaultExpandFetchK
+	}
+	if c.Tokenizer == nil {
+		c.Tokenizer = tokenizer.Default()
+	}
+	return c
+}
+
+// Expander adds surrounding chunks to selected chunks, fetching them
+// through a retriever with a metadata filter on the document field.
+type Expander struct {
+	cfg       ExpandConfig
+	retriever retriever.Retriever
+}
+
+// NewExpander creates an expander that fetches through ret.
+func NewExpander(ret retriever.Retriever, cfg ExpandConfig) *Expander {
+	return &Expander{cfg: cfg.withDefaults(), retriever: ret}
+}
+
+// expandDoc collects the selected chunks of one document.
+type expandDoc struct {
+	value    interface{}         // DocField value, as stored
+	first    int                 // selection position of its best chunk
+	selected map[int]int         // chunk index -> position in the selection
+	chunks   map[int]types.Chunk // chunk index -> chunk, selected and fetched
+	included map[int]bool        // chunk indices to return
+}
+
+// expandCandidate is a chunk that could be added to a document.
+type expandCandidate struct {
+	doc      *expandDoc
+	index    int
+	distance int // positions from the nearest selected chunk
+	rank     int // selection position of that chunk
+}
+
+// Expand returns chunks with their surrounding context added, and the
+// number of chunks added. Each run of adjacent chunks from one document
+// is merged into a single chunk, placed where its best-ranked selected
+// chunk was; its metadata lists the merged chunk IDs under
+// "expanded_ids". Chunks without document and position metadata are
+// returned unchanged. queryEmbedding orders the fetch when a document has
+// more than FetchK chunks.
+func (e *Expander) Expand(ctx context.Context, chunks []types.Chunk, queryEmbedding []float32, namespace string) ([]types.Chunk, int, error) {
+	if !e.cfg.Enabled() || len(chunks) == 0 {
+		return chunks, 0, nil
+	}
+
+	var docs []*expandDoc
+	byKey := make(map[string]*expandDoc)
+	for pos, c := range chunks {
+		key, ok := metadataValue(c, e.cfg.DocField)
+		idx, okIdx := chunkIndex(c.Metadata[e.cfg.IndexField])
+		if !ok || !okIdx {
+			continue
+		}
+		doc := byKey[key]
+		if doc == nil {
+			doc = &expandDoc{
+				value:    c.Metadata[e.cfg.DocField],
+				first:    pos,
+				selected: make(map[int]int),
+				chunks:   make(map[int]types.Chunk),
+				included: make(map[int]bool),
+			}
+			byKey[key] = doc
+			docs = append(docs, doc)
+		}
+		if _, dup := doc.selected[idx]; !dup {
+			doc.selected[idx] = pos
+		}
+		doc.chunks[idx] = c
+		doc.included[idx] = true
+	}
+	if len(docs) == 0 {
+		return chunks, 0, nil
+	}
+
+	for _, doc := range docs {
+		embedding := queryEmbedding
+		if len(embedding) == 0 {
+			embedding = chunks[doc.first].Embedding
+		}
+		if err := e.fetch(ctx, doc, embedding, namespace); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	used := 0
+	for _, c := range chunks {
+		used += e.cfg.Tokenizer.Count(c.Text)
+	}
+	added := 0
+	for _, cand := range e.candidates(docs) {
+		doc := cand.doc
+		// Keep each window contiguous: only extend from an included chunk.
+		if !doc.included[cand.index-1] && !doc.included[cand.index+1] {
+			continue
+		}
+		if doc.included[cand.index] {
+			continue
+		}
+		tokens := e.cfg.Tokenizer.Count(doc.chunks[cand.index].Text)
+		if e.cfg.MaxTokens > 0 && used+tokens > e.cfg.MaxTokens {
+			continue
+		}
+		doc.included[cand.index] = true
+		used += tokens
+		added++
+	}
+
+	return mergeExpanded(chunks, docs), added, nil
+}
+
+// fetch loads the chunks of doc from the retriever.
+func (e *Expander) fetch(ctx context.Context, doc *expandDoc, embedding []float32, namespace string) error {
+	if len(embedding) == 0 {
+		return nil
+	}
+	result, err := e.retriever.Query(ctx, &types.RetrievalRequest{
+		QueryEmbedding:  embedding,
+		TopK:            e.cfg.FetchK,
+		Namespace:       namespace,
+		Filter:          map[string]interface{}{e.cfg.DocField: doc.value},
+		IncludeMetadata: true,
+	})
+	if err != nil {
+		return fmt.Errorf("fetching %s %v: %w", e.cfg.DocField, doc.value, err)
+	}
+	want := fmt.Sprint(doc.value)
+	for _, c := range result.Chunks {
+		// Retrievers that ignore filters return other documents too.
+		if key, ok := metadataValue(c, e.cfg.DocField); !ok || key != want {
+			continue
+		}
+		idx, ok := chunkIndex(c.Metadata[e.cfg.IndexField])
+		if !ok {
+			continue
+		}
+		if _, have := doc.chunks[idx]; !have {
+			doc.chunks[idx] = c
+		}
+	}
+	return nil
+}
+
+// candidates lists the fetched chunks that could be added, nearest to a
+// selected chunk first, then by that chunk's rank.
+func (e *Expander) candidates(docs []*expandDoc) []expandCandidate {
+	var out []expandCandidate
+	for _, doc := range docs {
+		for idx := range doc.chunks {
+			if doc.included[idx] {
+				continue
+			}
+			best := expandCandidate{doc: doc, index: idx, distance: -1}
+			for sel, rank := range doc.selected {
+				d := idx - sel
+				if d < 0 {
+					d = -d
+				}
+				if best.distance < 0 || d < best.distance || (d == best.distance && rank < best.rank) {
+					best.distance, best.rank = d, rank
+				}
+			}
+			if e.cfg.Mode == ExpandNeighbors && best.distance > e.cfg.Window {
+				continue
+			}
+			out = append(out, best)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		return a.index < b.index
+	})
+	return out
+}
+
+// mergeExpanded replaces each selected chunk with the run of included
+// chunks around it. Runs holding several selected chunks become one
+// chunk at the position of the best-ranked of them.
+func mergeExpanded(chunks []types.Chunk, docs []*expandDoc) []types.Chunk {
+	replace := make(map[int]types.Chunk) // selection position -> merged chunk
+	drop := make(map[int]bool)
+	for _, doc := range docs {
+		indices := make([]int, 0, len(doc.included))
+		for idx := range doc.included {
+			indices = append(indices, idx)
+		}
+		sort.Ints(indices)
+
+		for start := 0; start < len(indices); {
+			end := start + 1
+			for end < len(indices) && indices[end] == indices[end-1]+1 {
+				end++
+			}
+			run := indices[start:end]
+			start = end
+
+			rank := -1
+			for _, idx := range run {
+				if pos, ok := doc.selected[idx]; ok {
+					if rank < 0 || pos < rank {
+						rank = pos
+					}
+				}
+			}
+			if rank < 0 {
+				continue
+			}
+			for _, idx := range run {
+				if pos, ok := doc.selected[idx]; ok && pos != rank {
+					drop[pos] = true
+				}
+			}
+			if len(run) > 1 {
+				replace[rank] = mergeRun(chunks[rank], doc, run)
+			}
+		}
+	}
+
+	out := make([]types.Chunk, 0, len(chunks))
+	for pos, c := range chunks {
+		if drop[pos] {
+			continue
+		}
+		if merged, ok := replace[pos]; ok {
+			c = merged
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// mergeRun joins the chunks of a run in document order into one chunk
+// that keeps rep's ID, score, and embedding.
+func mergeRun(rep types.Chunk, doc *expandDoc, run []int) types.Chunk {
+	ids := make([]string, len(run))
+	var text string
+	for i, idx := range run {
+		c := doc.chunks[idx]
+		ids[i] = c.ID
+		text = joinOverlapping(text, c.Text)
+	}
+
+	merged := rep
+	merged.Text = text
+	merged.Metadata = make(map[string]interface{}, len(rep.Metadata)+1)
+	for k, v := range rep.Metadata {
+		merged.Metadata[k] = v
+	}
+	merged.Metadata["expanded_ids"] = ids
+	return merged
+}
+
+// minTextOverlap is the shortest shared text treated as a chunker's
+// overlap between adjacent windows rather than coincidence.
+const minTextOverlap = 16
+
+// joinOverlapping appends b to a, dropping the longest prefix of b that a
+// already ends with, as chunkers with overlapping windows produce.
+func joinOverlapping(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	limit := len(a)
+	if len(b) < limit {
+		limit = len(b)
+	}
+	for k := limit; k >= minTextOverlap; k-- {
+		if strings.HasSuffix(a, b[:k]) {
+			return a + b[k:]
+		}
+	}
+	return a + "\n" + b
+}
+
+// chunkIndex reads a chunk position from a metadata value.
+func chunkIndex(v interface{}) (int, bool) {
+	switch x := v.(type) {
+	case int:
+		return x, true
+	case int32:
+		return int(x), true
+	case int64:
+		return int(x), true
+	case float64:
+		if x != math.Trunc(x) {
+			return 0, false
+		}
+		return int(x), true
+	case json.Number:
+		n, err := x.Int64()
+		return int(n), err == nil
+	case string:
+		n, err := strconv.Atoi(x)
+		return n, err == nil
+	}
+	return 0, false
+}
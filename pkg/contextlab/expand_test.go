@@ -0,0 +1,217 @@
+package contextlab
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// docRetriever serves a fixed corpus, applying equality filters.
+type docRetriever struct {
+	chunks  []types.Chunk
+	queries int
+}
+
+func (r *docRetriever) Query(_ context.Context, req *types.RetrievalRequest) (*types.RetrievalResult, error) {
+	r.queries++
+	var out []types.Chunk
+	for _, c := range r.chunks {
+		match := true
+		for k, v := range req.Filter {
+			if c.Metadata[k] != v {
+				match = false
+			}
+		}
+		if match && (req.TopK <= 0 || len(out) < req.TopK) {
+			out = append(out, c)
+		}
+	}
+	return &types.RetrievalResult{Chunks: out}, nil
+}
+
+func (r *docRetriever) QueryByID(ctx context.Context, _ string, _ int, _ string) (*types.RetrievalResult, error) {
+	return r.Query(ctx, &types.RetrievalRequest{})
+}
+
+func (r *docRetriever) Close() error { return nil }
+
+// wordCounter counts one token per word.
+type wordCounter struct{}
+
+func (wordCounter) Count(text string) int { return len(strings.Fields(text)) }
+func (wordCounter) Name() string          { return "words" }
+
+// docCorpus returns a six-chunk "guide" and a three-chunk "faq", each
+// chunk four words long with a unit-axis embedding.
+func docCorpus() []types.Chunk {
+	var chunks []types.Chunk
+	for _, doc := range []struct {
+		id string
+		n  int
+	}{{"guide", 6}, {"faq", 3}} {
+		for i := 0; i < doc.n; i++ {
+			emb := make([]float32, 9)
+			emb[len(chunks)] = 1
+			chunks = append(chunks, types.Chunk{
+				ID:        fmt.Sprintf("%s-%d", doc.id, i),
+				Text:      fmt.Sprintf("%s part %d text", doc.id, i),
+				Score:     float32(9-len(chunks)) / 10,
+				Embedding: emb,
+				ClusterID: -1,
+				Metadata:  map[string]interface{}{"doc_id": doc.id, "chunk_index": float64(i)},
+			})
+		}
+	}
+	return chunks
+}
+
+func chunkByID(chunks []types.Chunk, id string) types.Chunk {
+	for _, c := range chunks {
+		if c.ID == id {
+			return c
+		}
+	}
+	panic("no chunk " + id)
+}
+
+func expandedIDs(c types.Chunk) []string {
+	ids, _ := c.Metadata["expanded_ids"].([]string)
+	return ids
+}
+
+func TestExpander_Neighbors(t *testing.T) {
+	corpus := docCorpus()
+	ret := &docRetriever{chunks: corpus}
+	loose := types.Chunk{ID: "loose", Text: "no document metadata"}
+	selected := []types.Chunk{
+		chunkByID(corpus, "guide-2"),
+		chunkByID(corpus, "faq-1"),
+		loose,
+		chunkByID(corpus, "guide-3"),
+	}
+
+	got, added, err := NewExpander(ret, ExpandConfig{Mode: ExpandNeighbors}).
+		Expand(context.Background(), selected, []float32{1}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 4 {
+		t.Errorf("expected 4 chunks added, got %d", added)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected adjacent guide windows merged into 3 results, got %d", len(got))
+	}
+	if ids := expandedIDs(got[0]); got[0].ID != "guide-2" || !reflect.DeepEqual(ids, []string{"guide-1", "guide-2", "guide-3", "guide-4"}) {
+		t.Errorf("expected guide-1..4 merged under guide-2, got %s %v", got[0].ID, ids)
+	}
+	if want := "guide part 1 text\nguide part 2 text\nguide part 3 text\nguide part 4 text"; got[0].Text != want {
+		t.Errorf("unexpected merged text %q", got[0].Text)
+	}
+	if ids := expandedIDs(got[1]); !reflect.DeepEqual(ids, []string{"faq-0", "faq-1", "faq-2"}) {
+		t.Errorf("expected faq-0..2, got %v", ids)
+	}
+	if got[2].ID != "loose" || got[2].Metadata != nil {
+		t.Errorf("expected the chunk without metadata unchanged, got %+v", got[2])
+	}
+	if ret.queries != 2 {
+		t.Errorf("expected one fetch per document, got %d", ret.queries)
+	}
+	if _, ok := selected[0].Metadata["expanded_ids"]; ok {
+		t.Error("expected the input chunks' metadata left untouched")
+	}
+}
+
+func TestExpander_MaxTokens(t *testing.T) {
+	corpus := docCorpus()
+	selected := []types.Chunk{chunkByID(corpus, "guide-2"), chunkByID(corpus, "faq-1")}
+
+	// Room for the two selected chunks and one neighbour of the best.
+	cfg := ExpandConfig{Mode: ExpandNeighbors, MaxTokens: 12, Tokenizer: wordCounter{}}
+	got, added, err := NewExpander(&docRetriever{chunks: corpus}, cfg).
+		Expand(context.Background(), selected, []float32{1}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("expected 1 chunk added, got %d", added)
+	}
+	if ids := expandedIDs(got[0]); !reflect.DeepEqual(ids, []string{"guide-1", "guide-2"}) {
+		t.Errorf("expected guide-1 added first, got %v", ids)
+	}
+	if got[1].ID != "faq-1" || expandedIDs(got[1]) != nil {
+		t.Errorf("expected faq-1 unexpanded, got %+v", got[1])
+	}
+}
+
+func TestExpander_Parent(t *testing.T) {
+	corpus := docCorpus()
+	selected := []types.Chunk{chunkByID(corpus, "guide-2")}
+
+	got, added, err := NewExpander(&docRetriever{chunks: corpus}, ExpandConfig{Mode: ExpandParent}).
+		Expand(context.Background(), selected, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 5 || len(expandedIDs(got[0])) != 6 {
+		t.Errorf("expected the whole guide, got %d added, %v", added, expandedIDs(got[0]))
+	}
+
+	cfg := ExpandConfig{Mode: ExpandParent, MaxTokens: 16, Tokenizer: wordCounter{}}
+	got, _, err = NewExpander(&docRetriever{chunks: corpus}, cfg).
+		Expand(context.Background(), selected, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// guide-0 and guide-4 tie at distance 2; the earlier one wins.
+	if ids := expandedIDs(got[0]); !reflect.DeepEqual(ids, []string{"guide-0", "guide-1", "guide-2", "guide-3"}) {
+		t.Errorf("expected the nearest chunks within budget, got %v", ids)
+	}
+}
+
+func TestJoinOverlapping(t *testing.T) {
+	got := joinOverlapping("alpha beta gamma delta epsilon", "gamma delta epsilon zeta eta")
+	if want := "alpha beta gamma delta epsilon zeta eta"; got != want {
+		t.Errorf("expected the overlap dropped, got %q", got)
+	}
+	if got := joinOverlapping("one", "two"); got != "one\ntwo" {
+		t.Errorf("expected a newline join, got %q", got)
+	}
+}
+
+func TestExpandConfig_Validate(t *testing.T) {
+	if err := (ExpandConfig{Mode: "sentences"}).Validate(); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	if err := (ExpandConfig{Mode: ExpandNeighbors, Window: -1}).Validate(); err == nil {
+		t.Error("expected error for negative window")
+	}
+	if err := (ExpandConfig{Mode: ExpandParent, MaxTokens: 500}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBroker_Expand(t *testing.T) {
+	ret := &docRetriever{chunks: docCorpus()}
+	b := NewBroker(ret, BrokerConfig{
+		TargetK:          1,
+		ClusterThreshold: 0.1,
+		Expand:           ExpandConfig{Mode: ExpandNeighbors},
+	})
+	result, err := b.Retrieve(context.Background(), &types.RetrievalRequest{QueryEmbedding: []float32{1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Chunks) != 1 || result.Chunks[0].ID != "guide-0" {
+		t.Fatalf("expected guide-0 selected, got %+v", result.Chunks)
+	}
+	if ids := expandedIDs(result.Chunks[0]); !reflect.DeepEqual(ids, []string{"guide-0", "guide-1"}) {
+		t.Errorf("expected guide-1 added, got %v", ids)
+	}
+	if result.Stats.Expanded != 1 {
+		t.Errorf("expected Expanded=1, got %d", result.Stats.Expanded)
+	}
+}
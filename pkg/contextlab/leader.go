@@ -0,0 +1,195 @@
+package contextlab
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+const (
+	// DefaultScalableAbove is the chunk count above which Clusterer
+	// switches from agglomerative to leader clustering.
+	DefaultScalableAbove = 1000
+
+	// leaderTables and leaderBits shape the random-hyperplane LSH index
+	// used to find candidate leaders. At the default threshold (0.15) a
+	// leader at exactly the threshold distance is found ~93% of the time;
+	// closer leaders almost always.
+	leaderTables = 12
+	leaderBits   = 8
+
+	// leaderSeed fixes the hyperplanes so output is reproducible.
+	leaderSeed = 1
+
+	// leaderBruteForce is the number of leaders below which every leader
+	// is compared instead of querying the index.
+	leaderBruteForce = 64
+)
+
+// clusterLeaders groups chunks in O(n·k) time, where k is the number of
+// candidate leaders per chunk, instead of the O(n²) memory and O(n³) time
+// of agglomerative merging.
+//
+// Chunks are visited best first: by score, then ID, then input position.
+// Each joins the nearest existing leader within the threshold, or becomes
+// a new leader, so leaders are the best-scoring chunks. Candidate leaders
+// come from an LSH index over seeded random hyperplanes, so the result is
+// deterministic, and the same for any order of chunks with distinct IDs.
+// Every member is within the threshold of its cluster's leader, which
+// keeps clusters tighter than single linkage and avoids chaining.
+func (c *Clusterer) clusterLeaders(chunks []types.Chunk, threshold float64) *types.ClusterResult {
+	start := time.Now()
+	n := len(chunks)
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ca, cb := &chunks[order[a]], &chunks[order[b]]
+		if ca.Score != cb.Score {
+			return ca.Score > cb.Score
+		}
+		return ca.ID < cb.ID
+	})
+
+	idx := newLeaderIndex(embeddingDim(chunks))
+	var leaders []int   // chunk index of each cluster's leader
+	var members [][]int // chunk indices per cluster
+	for _, i := range order {
+		emb := chunks[i].Embedding
+		best := -1
+		if len(emb) == idx.dim {
+			bestDist := threshold
+			consider := func(cl int) {
+				lead := chunks[leaders[cl]].Embedding
+				if len(lead) != idx.dim {
+					return
+				}
+				if d := math.CosineDistance(emb, lead); d <= bestDist {
+					// Ties go to the earlier cluster.
+					if d < bestDist || best < 0 || cl < best {
+						best, bestDist = cl, d
+					}
+				}
+			}
+			keys := idx.keys(emb)
+			if len(leaders) <= leaderBruteForce {
+				for cl := range leaders {
+					consider(cl)
+				}
+			} else {
+				for _, cl := range idx.candidates(keys) {
+					consider(cl)
+				}
+			}
+			if best < 0 {
+				idx.add(keys, len(leaders))
+			}
+		}
+		if best < 0 {
+			best = len(leaders)
+			leaders = append(leaders, i)
+			members = append(members, nil)
+		}
+		members[best] = append(members[best], i)
+	}
+
+	clusters := make([]types.Cluster, len(members))
+	for id, m := range members {
+		cl := types.Cluster{ID: id, Members: make([]types.Chunk, len(m))}
+		for j, ci := range m {
+			chunks[ci].ClusterID = id
+			cl.Members[j] = chunks[ci]
+		}
+		cl.Centroid = meanEmbedding(cl.Members)
+		clusters[id] = cl
+	}
+
+	return &types.ClusterResult{
+		Clusters:     clusters,
+		InputCount:   n,
+		ClusterCount: len(clusters),
+		Latency:      time.Since(start),
+	}
+}
+
+// embeddingDim returns the dimension of the first embedding, or 0.
+func embeddingDim(chunks []types.Chunk) int {
+	for _, c := range chunks {
+		if len(c.Embedding) > 0 {
+			return len(c.Embedding)
+		}
+	}
+	return 0
+}
+
+// leaderIndex buckets leaders by the signs of their projections onto
+// random hyperplanes. Vectors at a small angle share a bucket in at least
+// one table with high probability.
+type leaderIndex struct {
+	dim     int
+	planes  [][]float32 // leaderTables*leaderBits hyperplanes
+	buckets []map[uint32][]int
+	mark    []int // per cluster, the query that last returned it
+	query   int
+}
+
+func newLeaderIndex(dim int) *leaderIndex {
+	rng := rand.New(rand.NewSource(leaderSeed))
+	planes := make([][]float32, leaderTables*leaderBits)
+	for i := range planes {
+		p := make([]float32, dim)
+		for d := range p {
+			p[d] = float32(rng.NormFloat64())
+		}
+		planes[i] = p
+	}
+	buckets := make([]map[uint32][]int, leaderTables)
+	for t := range buckets {
+		buckets[t] = make(map[uint32][]int)
+	}
+	return &leaderIndex{dim: dim, planes: planes, buckets: buckets}
+}
+
+// keys returns the bucket key of v in each table.
+func (x *leaderIndex) keys(v []float32) []uint32 {
+	keys := make([]uint32, leaderTables)
+	for t := range keys {
+		var key uint32
+		for b := 0; b < leaderBits; b++ {
+			if math.DotProduct(v, x.planes[t*leaderBits+b]) >= 0 {
+				key |= 1 << uint(b)
+			}
+		}
+		keys[t] = key
+	}
+	return keys
+}
+
+func (x *leaderIndex) add(keys []uint32, cluster int) {
+	for len(x.mark) <= cluster {
+		x.mark = append(x.mark, 0)
+	}
+	for t, key := range keys {
+		x.buckets[t][key] = append(x.buckets[t][key], cluster)
+	}
+}
+
+// candidates returns the distinct clusters sharing a bucket with keys.
+func (x *leaderIndex) candidates(keys []uint32) []int {
+	x.query++
+	var out []int
+	for t, key := range keys {
+		for _, cl := range x.buckets[t][key] {
+			if x.mark[cl] != x.query {
+				x.mark[cl] = x.query
+				out = append(out, cl)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package contextlab
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// topicChunks returns topics*perTopic chunks with distinct IDs: each topic
+// is a random direction and its chunks lie within ~0.005 cosine distance
+// of it, so topics are well separated at the default threshold.
+func topicChunks(topics, perTopic, dim int) []types.Chunk {
+	rng := rand.New(rand.NewSource(7))
+	var chunks []types.Chunk
+	for t := 0; t < topics; t++ {
+		center := make([]float32, dim)
+		for d := range center {
+			center[d] = float32(rng.NormFloat64())
+		}
+		for m := 0; m < perTopic; m++ {
+			emb := make([]float32, dim)
+			for d := range emb {
+				emb[d] = center[d] + float32(rng.NormFloat64()*0.05)
+			}
+			chunks = append(chunks, types.Chunk{
+				ID:        fmt.Sprintf("t%d-%d", t, m),
+				Score:     rng.Float32(),
+				Embedding: emb,
+			})
+		}
+	}
+	return chunks
+}
+
+// clusterSignature renders clusters as their member IDs, in cluster order.
+func clusterSignature(result *types.ClusterResult) string {
+	var b strings.Builder
+	for _, cl := range result.Clusters {
+		fmt.Fprintf(&b, "%d:", cl.ID)
+		for _, m := range cl.Members {
+			b.WriteString(" " + m.ID)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// partition renders clusters as sorted sets of member IDs, ignoring
+// cluster and member order.
+func partition(result *types.ClusterResult) []string {
+	var sets []string
+	for _, cl := range result.Clusters {
+		ids := make([]string, len(cl.Members))
+		for i, m := range cl.Members {
+			ids[i] = m.ID
+		}
+		sort.Strings(ids)
+		sets = append(sets, strings.Join(ids, ","))
+	}
+	sort.Strings(sets)
+	return sets
+}
+
+func copyChunks(chunks []types.Chunk) []types.Chunk {
+	out := make([]types.Chunk, len(chunks))
+	copy(out, chunks)
+	return out
+}
+
+func TestClusterLeaders_Deterministic(t *testing.T) {
+	// Above DefaultScalableAbove, with enough leaders to use the index.
+	chunks := topicChunks(300, 4, 32)
+	clusterer := NewClusterer(DefaultClusterConfig())
+
+	want := clusterSignature(clusterer.Cluster(copyChunks(chunks)))
+	for run := 0; run < 3; run++ {
+		if got := clusterSignature(clusterer.Cluster(copyChunks(chunks))); got != want {
+			t.Fatalf("run %d returned different clusters", run)
+		}
+	}
+
+	shuffled := copyChunks(chunks)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	if got := clusterSignature(clusterer.Cluster(shuffled)); got != want {
+		t.Error("expected shuffled input to give the same clusters")
+	}
+}
+
+func TestClusterLeaders_Result(t *testing.T) {
+	chunks := topicChunks(30, 3, 16)
+	clusterer := NewClusterer(ClusterConfig{Threshold: 0.15, ScalableAbove: 50})
+	result := clusterer.Cluster(chunks)
+
+	if result.InputCount != 90 || result.ClusterCount != len(result.Clusters) {
+		t.Fatalf("unexpected counts: input %d, clusters %d/%d", result.InputCount, result.ClusterCount, len(result.Clusters))
+	}
+	if result.ClusterCount != 30 {
+		t.Errorf("expected one cluster per topic, got %d", result.ClusterCount)
+	}
+	if result.Threshold != 0.15 || result.ThresholdAuto {
+		t.Errorf("expected the configured threshold reported, got %v (auto=%v)", result.Threshold, result.ThresholdAuto)
+	}
+
+	total := 0
+	for i, cl := range result.Clusters {
+		if cl.ID != i {
+			t.Errorf("expected cluster IDs in order, got %d at %d", cl.ID, i)
+		}
+		if len(cl.Centroid) != 16 {
+			t.Errorf("cluster %d: expected a 16-dim centroid, got %d", cl.ID, len(cl.Centroid))
+		}
+		for j, m := range cl.Members {
+			if m.ClusterID != cl.ID {
+				t.Errorf("member %s: ClusterID %d in cluster %d", m.ID, m.ClusterID, cl.ID)
+			}
+			// The leader is the best-scoring member.
+			if j > 0 && m.Score > cl.Members[0].Score {
+				t.Errorf("cluster %d: member %s outscores its leader", cl.ID, m.ID)
+			}
+		}
+		total += len(cl.Members)
+	}
+	if total != len(chunks) {
+		t.Errorf("expected every chunk in one cluster, got %d members", total)
+	}
+	for _, c := range chunks {
+		if c.ClusterID < 0 || c.ClusterID >= result.ClusterCount {
+			t.Errorf("input chunk %s: ClusterID %d not assigned", c.ID, c.ClusterID)
+		}
+	}
+}
+
+func TestClusterLeaders_MatchesAgglomerative(t *testing.T) {
+	chunks := topicChunks(40, 5, 32)
+
+	leader := NewClusterer(ClusterConfig{Threshold: 0.15, ScalableAbove: 100}).Cluster(copyChunks(chunks))
+	agglomerative := NewClusterer(ClusterConfig{Threshold: 0.15, Linkage: "average", ScalableAbove: -1}).Cluster(copyChunks(chunks))
+
+	got, want := partition(leader), partition(agglomerative)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the same partition on separable data: leader %d clusters, agglomerative %d", len(got), len(want))
+	}
+	if len(got) != 40 {
+		t.Errorf("expected 40 clusters, got %d", len(got))
+	}
+}
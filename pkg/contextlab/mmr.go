@@ -15,6 +15,11 @@ type MMRConfig struct {
 
 	// TargetK is the number of chunks to select.
 	TargetK int
+
+	// Constraints restrict the selection by chunk metadata. They are
+	// applied during the greedy selection, so diversity and metadata
+	// constraints are traded off jointly.
+	Constraints SelectionConstraints
 }
 
 // DefaultMMRConfig returns sensible defaults.
@@ -52,12 +57,14 @@ func (m *MMR) Rerank(chunks []types.Chunk) []types.Chunk {
 		return nil
 	}
 
-	if len(chunks) <= m.cfg.TargetK {
+	// Per-source caps can drop chunks even when there are few enough
+	if len(chunks) <= m.cfg.TargetK && !m.cfg.Constraints.limitsSources() {
 		return chunks
 	}
 
 	// Normalize scores to [0, 1] for fair comparison with similarity
-	normalizedScores := m.normalizeScores(chunks)
+	normalizedScores := m.cfg.Constraints.blendFreshness(chunks, m.normalizeScores(chunks))
+	tracker := newConstraintTracker(m.cfg.Constraints)
 
 	// Track selected and remaining indices
 	selected := make([]int, 0, m.cfg.TargetK)
@@ -66,15 +73,22 @@ func (m *MMR) Rerank(chunks []types.Chunk) []types.Chunk {
 		remaining[i] = true
 	}
 
-	// Precompute similarity matrix for efficiency
-	simMatrix := m.computeSimilarityMatrix(chunks)
+	// Precompute similarity matrix for efficiency; pure relevance needs none
+	var simMatrix [][]float64
+	if m.cfg.Lambda < 1 {
+		simMatrix = m.computeSimilarityMatrix(chunks)
+	}
 
 	// Greedy selection
 	for len(selected) < m.cfg.TargetK && len(remaining) > 0 {
 		bestIdx := -1
 		bestMMR := float64(-2) // MMR can be negative
+		mustCover := tracker.mustCover(chunks, remaining, m.cfg.TargetK-len(selected))
 
 		for idx := range remaining {
+			if !tracker.allows(chunks[idx]) || (mustCover && !tracker.covers(chunks[idx])) {
+				continue
+			}
 			mmrScore := m.computeMMRScore(idx, selected, normalizedScores, simMatrix)
 			if mmrScore > bestMMR {
 				bestMMR = mmrScore
@@ -85,6 +99,7 @@ func (m *MMR) Rerank(chunks []types.Chunk) []types.Chunk {
 		if bestIdx >= 0 {
 			selected = append(selected, bestIdx)
 			delete(remaining, bestIdx)
+			tracker.add(chunks[bestIdx])
 		} else {
 			break
 		}
@@ -137,28 +152,19 @@ func (m *MMR) normalizeScores(chunks []types.Chunk) []float64 {
 
 // computeSimilarityMatrix computes pairwise cosine similarities.
 func (m *MMR) computeSimilarityMatrix(chunks []types.Chunk) [][]float64 {
-	n := len(chunks)
-	matrix := make([][]float64, n)
-
-	// Initialize all rows first
-	for i := 0; i < n; i++ {
-		matrix[i] = make([]float64, n)
-		matrix[i][i] = 1.0 // Self-similarity
-	}
-
-	// Compute similarities
-	for i := 0; i < n; i++ {
-		for j := i + 1; j < n; j++ {
-			// Handle missing embeddings
-			if len(chunks[i].Embedding) == 0 || len(chunks[j].Embedding) == 0 {
-				matrix[i][j] = 0.0
-				matrix[j][i] = 0.0
-				continue
+	matrix := math.CosineDistanceMatrix(chunkEmbeddings(chunks))
+
+	// Convert distances to similarities in place
+	for i, row := range matrix {
+		for j := range row {
+			switch {
+			case i == j:
+				row[j] = 1.0 // Self-similarity
+			case len(chunks[i].Embedding) == 0 || len(chunks[j].Embedding) == 0:
+				row[j] = 0.0 // Missing embeddings
+			default:
+				row[j] = 1.0 - row[j]
 			}
-			// Similarity = 1 - distance
-			sim := 1.0 - math.CosineDistance(chunks[i].Embedding, chunks[j].Embedding)
-			matrix[i][j] = sim
-			matrix[j][i] = sim
 		}
 	}
 
@@ -170,8 +176,8 @@ func (m *MMR) computeSimilarityMatrix(chunks []types.Chunk) [][]float64 {
 func (m *MMR) computeMMRScore(candidateIdx int, selected []int, scores []float64, simMatrix [][]float64) float64 {
 	relevance := scores[candidateIdx]
 
-	// If nothing selected yet, MMR = λ * relevance
-	if len(selected) == 0 {
+	// If nothing selected yet, or diversity is ignored, MMR = λ * relevance
+	if len(selected) == 0 || simMatrix == nil {
 		return m.cfg.Lambda * relevance
 	}
 
@@ -196,9 +202,9 @@ func (m *MMR) RerankWithQuery(chunks []types.Chunk, queryEmbedding []float32) []
 	}
 
 	// Compute query similarities as relevance scores
+	dists := math.CosineDistances(nil, queryEmbedding, chunkEmbeddings(chunks))
 	for i := range chunks {
-		sim := 1.0 - math.CosineDistance(chunks[i].Embedding, queryEmbedding)
-		chunks[i].Score = float32(sim)
+		chunks[i].Score = float32(1.0 - dists[i])
 	}
 
 	return m.Rerank(chunks)
@@ -0,0 +1,258 @@
+package contextlab
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// DefaultRRFK is the rank offset in reciprocal-rank fusion. Larger values
+// flatten the advantage of top ranks.
+const DefaultRRFK = 60
+
+// QueryHitsKey is the metadata key under which multi-query retrieval
+// records, for each chunk, the query variants that returned it. Each hit
+// is a map with "query", "rank" (1-based), and "score".
+const QueryHitsKey = "query_hits"
+
+// RRFScoreKey is the metadata key under which multi-query retrieval
+// records a chunk's reciprocal-rank fusion score.
+const RRFScoreKey = "rrf_score"
+
+// QueryPlaceholder is replaced by the query text in query templates.
+const QueryPlaceholder = "{query}"
+
+// MultiQueryConfig configures retrieval with several variants of a query.
+// Variants come from the request's Queries, from Templates applied to the
+// query, and from Decompose; with none, the broker retrieves once.
+type MultiQueryConfig struct {
+	// Templates derive variants from the query text, e.g.
+	// "How do I {query}?" or "{query} example". Templates without
+	// QueryPlaceholder get the query appended.
+	Templates []string
+
+	// Decompose splits a query holding several questions ("How do I X?
+	// What about Y?") into one variant per question.
+	Decompose bool
+
+	// RRFK is the reciprocal-rank fusion constant. Default: 60.
+	RRFK int
+}
+
+// Variants returns the distinct query variants for query: the query
+// itself first, then its questions if decomposed, the templated forms,
+// and the extra variants.
+func (c MultiQueryConfig) Variants(query string, extra []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(q string) {
+		q = strings.TrimSpace(q)
+		if q != "" && !seen[q] {
+			seen[q] = true
+			out = append(out, q)
+		}
+	}
+
+	add(query)
+	if query != "" {
+		if c.Decompose {
+			for _, q := range DecomposeQuery(query) {
+				add(q)
+			}
+		}
+		for _, t := range c.Templates {
+			if strings.Contains(t, QueryPlaceholder) {
+				add(strings.ReplaceAll(t, QueryPlaceholder, query))
+			} else {
+				add(t + " " + query)
+			}
+		}
+	}
+	for _, q := range extra {
+		add(q)
+	}
+	return out
+}
+
+// DecomposeQuery splits text into its questions and sentences, breaking
+// after '?', ';', and line breaks, and after '.' or '!' followed by a
+// space. A query with a single part is returned as is.
+func DecomposeQuery(query string) []string {
+	var parts []string
+	start := 0
+	runes := []rune(query)
+	for i, r := range runes {
+		end := false
+		switch r {
+		case '?', ';', '\n':
+			end = true
+		case '.', '!':
+			end = i+1 < len(runes) && runes[i+1] == ' '
+		}
+		if end {
+			if p := strings.TrimSpace(string(runes[start : i+1])); len(p) > 1 {
+				parts = append(parts, strings.TrimRight(p, ";"))
+			}
+			start = i + 1
+		}
+	}
+	if p := strings.TrimSpace(string(runes[start:])); p != "" {
+		parts = append(parts, p)
+	}
+	if len(parts) < 2 {
+		return []string{strings.TrimSpace(query)}
+	}
+	return parts
+}
+
+// retrieveMulti embeds the variants, queries the retriever with each in
+// parallel, and fuses the results. variants[0] uses req.QueryEmbedding
+// when it is set; it is "" for a vector query with text variants.
+func (b *Broker) retrieveMulti(ctx context.Context, req *types.RetrievalRequest, variants []string) (*types.RetrievalResult, error) {
+	embeddings := make([][]float32, len(variants))
+	var texts []string
+	var textIdx []int
+	for i, q := range variants {
+		if i == 0 && len(req.QueryEmbedding) > 0 {
+			embeddings[0] = req.QueryEmbedding
+			continue
+		}
+		texts = append(texts, q)
+		textIdx = append(textIdx, i)
+	}
+	if len(texts) > 0 {
+		if b.embedder == nil {
+			return nil, fmt.Errorf("embedding provider required for query variants")
+		}
+		embedded, err := b.embedder.EmbedBatch(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed query variants: %w", err)
+		}
+		if len(embedded) != len(texts) {
+			return nil, fmt.Errorf("embedder returned %d embeddings for %d query variants", len(embedded), len(texts))
+		}
+		for j, i := range textIdx {
+			embeddings[i] = embedded[j]
+		}
+	}
+
+	lists := make([][]types.Chunk, len(variants))
+	errs := make([]error, len(variants))
+	var wg sync.WaitGroup
+	for i, q := range variants {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			sub := *req
+			sub.Query = q
+			sub.QueryEmbedding = embeddings[i]
+			sub.Queries = nil
+			res, err := b.retriever.Query(ctx, &sub)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			lists[i] = res.Chunks
+		}(i, q)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", variants[i], err)
+		}
+	}
+
+	fused := FuseRRF(variants, lists, b.cfg.MultiQuery.RRFK)
+	return &types.RetrievalResult{
+		Chunks:         fused,
+		QueryEmbedding: embeddings[0],
+		TotalMatches:   len(fused),
+	}, nil
+}
+
+// FuseRRF merges the ranked result lists of queries by reciprocal-rank
+// fusion: a chunk scores the sum of 1/(k+rank) over the lists that
+// returned it. Chunks are matched by ID, or by a hash of their text when
+// the ID is empty, and returned best first. Each keeps its best retrieval
+// score as Score; the fused score is recorded under RRFScoreKey and the
+// per-query hits under QueryHitsKey in its metadata. k <= 0 uses
+// DefaultRRFK.
+func FuseRRF(queries []string, lists [][]types.Chunk, k int) []types.Chunk {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+
+	type fusedChunk struct {
+		chunk types.Chunk
+		score float64
+		hits  []map[string]interface{}
+	}
+	byKey := make(map[string]*fusedChunk)
+	var order []*fusedChunk
+	for i, list := range lists {
+		for rank, c := range list {
+			key := fusionKey(c)
+			f := byKey[key]
+			if f == nil {
+				f = &fusedChunk{chunk: c}
+				byKey[key] = f
+				order = append(order, f)
+			} else if c.Score > f.chunk.Score {
+				f.chunk.Score = c.Score
+			}
+			f.score += 1 / float64(k+rank+1)
+			f.hits = append(f.hits, map[string]interface{}{
+				"query": queries[i],
+				"rank":  rank + 1,
+				"score": c.Score,
+			})
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].score > order[j].score
+	})
+	out := make([]types.Chunk, len(order))
+	for i, f := range order {
+		c := f.chunk
+		meta := make(map[string]interface{}, len(c.Metadata)+2)
+		for key, v := range c.Metadata {
+			meta[key] = v
+		}
+		meta[QueryHitsKey] = f.hits
+		meta[RRFScoreKey] = f.score
+		c.Metadata = meta
+		out[i] = c
+	}
+	return out
+}
+
+// SortByRRFScore sorts chunks by the fused score FuseRRF records under
+// RRFScoreKey, best first. Chunks without one sort last, in their order.
+func SortByRRFScore(chunks []types.Chunk) {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return rrfScore(chunks[i]) > rrfScore(chunks[j])
+	})
+}
+
+func rrfScore(c types.Chunk) float64 {
+	score, _ := c.Metadata[RRFScoreKey].(float64)
+	return score
+}
+
+// fusionKey identifies a chunk across result lists: its ID, or the hash of
+// its text for chunks without one.
+func fusionKey(c types.Chunk) string {
+	if c.ID != "" {
+		return "id:" + c.ID
+	}
+	sum := sha256.Sum256([]byte(c.Text))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
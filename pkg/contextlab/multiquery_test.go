@@ -0,0 +1,225 @@
+package contextlab
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// axisEmbedder embeds each known text as a unit vector on its own axis,
+// and other texts on axis 0.
+type axisEmbedder struct {
+	axes map[string]int
+}
+
+func (e *axisEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	emb := make([]float32, len(e.axes))
+	emb[e.axes[text]] = 1
+	return emb, nil
+}
+
+func (e *axisEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i], _ = e.Embed(ctx, text)
+	}
+	return out, nil
+}
+
+func (e *axisEmbedder) Dimension() int    { return len(e.axes) }
+func (e *axisEmbedder) ModelName() string { return "axis" }
+
+// axisRetriever returns a fixed ranking for each query embedding axis.
+type axisRetriever struct {
+	rankings [][]types.Chunk
+	mu       sync.Mutex
+	queries  int
+}
+
+func (r *axisRetriever) Query(_ context.Context, req *types.RetrievalRequest) (*types.RetrievalResult, error) {
+	r.mu.Lock()
+	r.queries++
+	r.mu.Unlock()
+	for axis, v := range req.QueryEmbedding {
+		if v == 1 {
+			return &types.RetrievalResult{Chunks: r.rankings[axis]}, nil
+		}
+	}
+	return &types.RetrievalResult{}, nil
+}
+
+func (r *axisRetriever) QueryByID(ctx context.Context, _ string, _ int, _ string) (*types.RetrievalResult, error) {
+	return r.Query(ctx, &types.RetrievalRequest{})
+}
+
+func (r *axisRetriever) Close() error { return nil }
+
+// queryHits returns the queries that retrieved c, in variant order.
+func queryHits(c types.Chunk) []string {
+	hits, _ := c.Metadata[QueryHitsKey].([]map[string]interface{})
+	var out []string
+	for _, h := range hits {
+		out = append(out, h["query"].(string))
+	}
+	return out
+}
+
+// multiQueryRankings ranks five chunks a-e for three queries, with "c"
+// found by all of them but first by none.
+func multiQueryRankings() [][]types.Chunk {
+	chunks := orthogonalChunks([]string{"a", "b", "c", "d", "e"})
+	for i := range chunks {
+		chunks[i].Metadata = map[string]interface{}{"source": chunks[i].Text}
+	}
+	a, b, c, d, e := chunks[0], chunks[1], chunks[2], chunks[3], chunks[4]
+	return [][]types.Chunk{{a, b, c}, {d, c}, {e, c}}
+}
+
+func TestFuseRRF(t *testing.T) {
+	lists := multiQueryRankings()
+	fused := FuseRRF([]string{"q0", "q1", "q2"}, lists, 0)
+
+	if len(fused) != 5 {
+		t.Fatalf("expected the union of 5 chunks, got %d", len(fused))
+	}
+	var order []string
+	for _, c := range fused {
+		order = append(order, c.ID)
+	}
+	if want := []string{"c", "a", "d", "e", "b"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected fused order %v, got %v", want, order)
+	}
+
+	c := fused[0]
+	if hits := queryHits(c); !reflect.DeepEqual(hits, []string{"q0", "q1", "q2"}) {
+		t.Errorf("expected c attributed to all three queries, got %v", hits)
+	}
+	hits := c.Metadata[QueryHitsKey].([]map[string]interface{})
+	if hits[0]["rank"] != 3 || hits[1]["rank"] != 2 {
+		t.Errorf("expected 1-based ranks 3 and 2, got %v and %v", hits[0]["rank"], hits[1]["rank"])
+	}
+	if want := 1.0/63 + 1.0/62 + 1.0/62; c.Metadata[RRFScoreKey] != want {
+		t.Errorf("expected RRF score %v, got %v", want, c.Metadata[RRFScoreKey])
+	}
+	if c.Score != lists[0][2].Score {
+		t.Errorf("expected the retrieval score kept, got %v", c.Score)
+	}
+	if c.Metadata["source"] != "c" {
+		t.Error("expected the original metadata kept")
+	}
+	if _, ok := lists[0][2].Metadata[QueryHitsKey]; ok {
+		t.Error("expected the input chunks' metadata left untouched")
+	}
+}
+
+func TestFuseRRF_EmptyIDs(t *testing.T) {
+	x := types.Chunk{Text: "rotate keys", Score: 0.9}
+	y := types.Chunk{Text: "revoke tokens", Score: 0.7}
+	fused := FuseRRF([]string{"q0", "q1"}, [][]types.Chunk{{x, y}, {y}}, 0)
+
+	if len(fused) != 2 {
+		t.Fatalf("expected chunks without IDs kept apart, got %d", len(fused))
+	}
+	if fused[0].Text != "revoke tokens" || len(queryHits(fused[0])) != 2 {
+		t.Errorf("expected the chunk found twice matched by text and ranked first, got %q", fused[0].Text)
+	}
+	if fused[0].Score != 0.7 || fused[1].Score != 0.9 {
+		t.Errorf("expected retrieval scores kept, got %v and %v", fused[0].Score, fused[1].Score)
+	}
+}
+
+func TestMultiQueryConfig_Variants(t *testing.T) {
+	cfg := MultiQueryConfig{
+		Templates: []string{"how to {query}", "{query}", "examples:"},
+		Decompose: true,
+	}
+	got := cfg.Variants("reset password? change email", []string{"account recovery", "reset password", " "})
+	want := []string{
+		"reset password? change email",
+		"reset password?",
+		"change email",
+		"how to reset password? change email",
+		"examples: reset password? change email",
+		"account recovery",
+		"reset password",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected variants:\n got %q\nwant %q", got, want)
+	}
+
+	if got := (MultiQueryConfig{}).Variants("reset password", nil); len(got) != 1 {
+		t.Errorf("expected the query alone, got %q", got)
+	}
+}
+
+func TestDecomposeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"How do I rotate keys? What breaks if I do?", []string{"How do I rotate keys?", "What breaks if I do?"}},
+		{"Compare v1.2 and v2. List the migration steps", []string{"Compare v1.2 and v2.", "List the migration steps"}},
+		{"rate limits; retries\nbackoff", []string{"rate limits", "retries", "backoff"}},
+		{"What is a token?", []string{"What is a token?"}},
+	}
+	for _, tt := range tests {
+		if got := DecomposeQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecomposeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestBroker_MultiQuery(t *testing.T) {
+	ret := &axisRetriever{rankings: multiQueryRankings()}
+	emb := &axisEmbedder{axes: map[string]int{"reset password": 0, "password help": 1, "forgot login": 2}}
+	b := NewBrokerWithEmbedder(ret, emb, BrokerConfig{TargetK: 3, ClusterThreshold: 0.1})
+
+	result, err := b.RetrieveMultiQuery(context.Background(), []string{"reset password", "password help", "forgot login"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ret.queries != 3 || result.Stats.Queries != 3 {
+		t.Errorf("expected 3 retrievals, got %d (stats %d)", ret.queries, result.Stats.Queries)
+	}
+	if result.Stats.Retrieved != 5 {
+		t.Errorf("expected the fused union of 5 chunks, got %d", result.Stats.Retrieved)
+	}
+	if len(result.Chunks) != 3 || result.Chunks[0].ID != "c" {
+		t.Fatalf("expected c, found by every query, first of 3, got %+v", result.Chunks)
+	}
+	if hits := queryHits(result.Chunks[0]); len(hits) != 3 {
+		t.Errorf("expected 3 query hits, got %v", hits)
+	}
+
+	// Templates derive the variants from a single query.
+	ret = &axisRetriever{rankings: multiQueryRankings()}
+	b = NewBrokerWithEmbedder(ret, emb, BrokerConfig{
+		TargetK:          3,
+		ClusterThreshold: 0.1,
+		MultiQuery:       MultiQueryConfig{Templates: []string{"how do I {query}", "{query} steps"}},
+	})
+	result, err = b.RetrieveByText(context.Background(), "reset password", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stats.Queries != 3 || ret.queries != 3 {
+		t.Errorf("expected 3 templated retrievals, got %d", ret.queries)
+	}
+
+	// Without variants the broker queries once.
+	ret = &axisRetriever{rankings: multiQueryRankings()}
+	b = NewBrokerWithEmbedder(ret, emb, BrokerConfig{TargetK: 3, ClusterThreshold: 0.1})
+	result, err = b.RetrieveByText(context.Background(), "reset password", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ret.queries != 1 || result.Stats.Queries != 1 || result.Stats.Retrieved != 3 {
+		t.Errorf("expected a single retrieval, got %d", ret.queries)
+	}
+	if _, ok := result.Chunks[0].Metadata[QueryHitsKey]; ok {
+		t.Error("expected no query hits for a single query")
+	}
+}
@@ -0,0 +1,388 @@
+package contextlab
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// NearDupMethod selects the lexical fingerprint used by NearDupDetector.
+type NearDupMethod string
+
+const (
+	// NearDupMinHash estimates the Jaccard similarity of word shingles
+	// with MinHash signatures and finds candidate pairs with LSH banding.
+	// Best for chunks that share most of their text in any order.
+	NearDupMinHash NearDupMethod = "minhash"
+
+	// NearDupSimHash compares 64-bit SimHash fingerprints by Hamming
+	// distance. Cheaper, best for copies with small edits.
+	NearDupSimHash NearDupMethod = "simhash"
+)
+
+// NearDupConfig holds lexical near-duplicate detection parameters.
+type NearDupConfig struct {
+	// Method selects the fingerprint (default: minhash).
+	Method NearDupMethod
+
+	// ShingleSize is the number of consecutive words per shingle.
+	// Default: 3.
+	ShingleSize int
+
+	// Threshold is the minimum estimated Jaccard similarity for two
+	// chunks to be near-duplicates (minhash). Default: 0.8.
+	Threshold float64
+
+	// NumHashes is the MinHash signature length. Default: 128.
+	NumHashes int
+
+	// Bands is the number of LSH bands the signature is split into. More
+	// bands find more candidate pairs at lower similarity. When NumHashes
+	// is not a multiple of Bands, the first bands take one extra row each.
+	// Default: 32.
+	Bands int
+
+	// MaxHammingDistance is the largest SimHash bit difference between
+	// near-duplicates (simhash). Default: 3.
+	MaxHammingDistance int
+}
+
+// DefaultNearDupConfig returns sensible defaults.
+func DefaultNearDupConfig() NearDupConfig {
+	return NearDupConfig{
+		Method:             NearDupMinHash,
+		ShingleSize:        3,
+		Threshold:          0.8,
+		NumHashes:          128,
+		Bands:              32,
+		MaxHammingDistance: 3,
+	}
+}
+
+// NearDupDetector groups chunks whose text is nearly identical. It needs
+// no embeddings, so it works offline, and it can run before embedding
+// clustering to avoid embedding chunks that are copies of each other.
+type NearDupDetector struct {
+	cfg NearDupConfig
+}
+
+// NewNearDupDetector creates a detector with the given config.
+func NewNearDupDetector(cfg NearDupConfig) *NearDupDetector {
+	def := DefaultNearDupConfig()
+	if cfg.Method == "" {
+		cfg.Method = def.Method
+	}
+	if cfg.ShingleSize <= 0 {
+		cfg.ShingleSize = def.ShingleSize
+	}
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		cfg.Threshold = def.Threshold
+	}
+	if cfg.NumHashes <= 0 {
+		cfg.NumHashes = def.NumHashes
+	}
+	if cfg.Bands <= 0 {
+		cfg.Bands = def.Bands
+	}
+	if cfg.Bands > cfg.NumHashes {
+		cfg.Bands = cfg.NumHashes
+	}
+	if cfg.MaxHammingDistance <= 0 {
+		cfg.MaxHammingDistance = def.MaxHammingDistance
+	}
+	return &NearDupDetector{cfg: cfg}
+}
+
+// Cluster groups near-duplicate chunks. Clusters are ordered by their
+// first member's position in chunks, and each chunk's ClusterID is set.
+// Centroids are the mean member embedding when embeddings are present.
+func (d *NearDupDetector) Cluster(chunks []types.Chunk) *types.ClusterResult {
+	start := time.Now()
+	n := len(chunks)
+
+	uf := newUnionFind(n)
+	switch d.cfg.Method {
+	case NearDupSimHash:
+		d.linkSimHash(chunks, uf)
+	default:
+		d.linkMinHash(chunks, uf)
+	}
+
+	byRoot := make(map[int]int, n)
+	clusters := make([]types.Cluster, 0, n)
+	for i := range chunks {
+		root := uf.find(i)
+		id, ok := byRoot[root]
+		if !ok {
+			id = len(clusters)
+			byRoot[root] = id
+			clusters = append(clusters, types.Cluster{ID: id})
+		}
+		chunks[i].ClusterID = id
+		clusters[id].Members = append(clusters[id].Members, chunks[i])
+	}
+	for i := range clusters {
+		clusters[i].Centroid = meanEmbedding(clusters[i].Members)
+	}
+
+	return &types.ClusterResult{
+		Clusters:     clusters,
+		InputCount:   n,
+		ClusterCount: len(clusters),
+		Latency:      time.Since(start),
+	}
+}
+
+// Filter returns one chunk per near-duplicate group, the one with the
+// highest score, in the order the groups first appear.
+func (d *NearDupDetector) Filter(chunks []types.Chunk) []types.Chunk {
+	return NewSelector(DefaultSelectorConfig()).Select(d.Cluster(chunks))
+}
+
+// linkMinHash unions chunks whose estimated Jaccard similarity reaches
+// the threshold. Only pairs sharing an LSH band are compared.
+func (d *NearDupDetector) linkMinHash(chunks []types.Chunk, uf *unionFind) {
+	sigs := make([][]uint64, len(chunks))
+	for i, c := range chunks {
+		sigs[i] = d.minHash(shingles(c.Text, d.cfg.ShingleSize))
+	}
+
+	checked := make(map[[2]int]bool)
+	for band := 0; band < d.cfg.Bands; band++ {
+		buckets := make(map[uint64][]int)
+		for i, sig := range sigs {
+			if sig == nil {
+				continue
+			}
+			key := uint64(band)
+			for _, v := range sig[d.bandStart(band):d.bandStart(band+1)] {
+				key = mix64(key ^ v)
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, members := range buckets {
+			for a := 0; a < len(members); a++ {
+				for b := a + 1; b < len(members); b++ {
+					pair := [2]int{members[a], members[b]}
+					if checked[pair] {
+						continue
+					}
+					checked[pair] = true
+					if jaccardEstimate(sigs[pair[0]], sigs[pair[1]]) >= d.cfg.Threshold {
+						uf.union(pair[0], pair[1])
+					}
+				}
+			}
+		}
+	}
+}
+
+// bandStart returns the first signature row of an LSH band. Rows are
+// spread evenly, so every row falls in some band.
+func (d *NearDupDetector) bandStart(band int) int {
+	return band*(d.cfg.NumHashes/d.cfg.Bands) + min(band, d.cfg.NumHashes%d.cfg.Bands)
+}
+
+// minHash returns the signature of a shingle set, or nil if it is empty.
+func (d *NearDupDetector) minHash(set []uint64) []uint64 {
+	if len(set) == 0 {
+		return nil
+	}
+	sig := make([]uint64, d.cfg.NumHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for _, s := range set {
+		for i := range sig {
+			// Each signature row uses a different seeded permutation.
+			if h := mix64(s ^ (uint64(i+1) * 0x9e3779b97f4a7c15)); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// jaccardEstimate is the fraction of matching signature rows.
+func jaccardEstimate(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// linkSimHash unions chunks whose SimHash fingerprints differ in at most
+// MaxHammingDistance bits. By the pigeonhole principle such a pair agrees
+// exactly on at least one of MaxHammingDistance+1 bit blocks, so only
+// pairs sharing a block are compared.
+func (d *NearDupDetector) linkSimHash(chunks []types.Chunk, uf *unionFind) {
+	prints := make([]uint64, len(chunks))
+	empty := make([]bool, len(chunks))
+	for i, c := range chunks {
+		set := shingles(c.Text, d.cfg.ShingleSize)
+		prints[i], empty[i] = simHash(set), len(set) == 0
+	}
+
+	blocks := d.cfg.MaxHammingDistance + 1
+	if blocks > 64 {
+		blocks = 64
+	}
+	width := 64 / blocks
+	checked := make(map[[2]int]bool)
+	for blk := 0; blk < blocks; blk++ {
+		shift := uint(blk * width)
+		bitsInBlock := width
+		if blk == blocks-1 {
+			bitsInBlock = 64 - blk*width
+		}
+		mask := uint64(1)<<uint(bitsInBlock) - 1
+		if bitsInBlock == 64 {
+			mask = ^uint64(0)
+		}
+
+		buckets := make(map[uint64][]int)
+		for i, fp := range prints {
+			if !empty[i] {
+				key := (fp >> shift) & mask
+				buckets[key] = append(buckets[key], i)
+			}
+		}
+		for _, members := range buckets {
+			for a := 0; a < len(members); a++ {
+				for b := a + 1; b < len(members); b++ {
+					pair := [2]int{members[a], members[b]}
+					if checked[pair] {
+						continue
+					}
+					checked[pair] = true
+					if bits.OnesCount64(prints[pair[0]]^prints[pair[1]]) <= d.cfg.MaxHammingDistance {
+						uf.union(pair[0], pair[1])
+					}
+				}
+			}
+		}
+	}
+}
+
+// simHash folds shingle hashes into a 64-bit fingerprint: each bit is set
+// when most shingle hashes have it set.
+func simHash(set []uint64) uint64 {
+	var votes [64]int
+	for _, s := range set {
+		for b := 0; b < 64; b++ {
+			if s&(1<<uint(b)) != 0 {
+				votes[b]++
+			} else {
+				votes[b]--
+			}
+		}
+	}
+	var fp uint64
+	for b, v := range votes {
+		if v > 0 {
+			fp |= 1 << uint(b)
+		}
+	}
+	return fp
+}
+
+// shingles returns the distinct hashes of the k-word shingles of text,
+// lower-cased. Texts shorter than k words form a single shingle.
+func shingles(text string, k int) []uint64 {
+	words := nlp.Words(strings.ToLower(text))
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) < k {
+		k = len(words)
+	}
+	seen := make(map[uint64]bool, len(words))
+	out := make([]uint64, 0, len(words)-k+1)
+	for i := 0; i+k <= len(words); i++ {
+		h := fnv.New64a()
+		for j, w := range words[i : i+k] {
+			if j > 0 {
+				_, _ = h.Write([]byte{0})
+			}
+			_, _ = h.Write([]byte(w))
+		}
+		if v := mix64(h.Sum64()); !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// mix64 is the splitmix64 finalizer, used to derive independent hashes.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// meanEmbedding averages member embeddings; nil if any member lacks one.
+func meanEmbedding(members []types.Chunk) []float32 {
+	if len(members) == 0 || len(members[0].Embedding) == 0 {
+		return nil
+	}
+	dim := len(members[0].Embedding)
+	centroid := make([]float32, dim)
+	for _, m := range members {
+		if len(m.Embedding) != dim {
+			return nil
+		}
+		for i, v := range m.Embedding {
+			centroid[i] += v
+		}
+	}
+	inv := float32(1.0 / float64(len(members)))
+	for i := range centroid {
+		centroid[i] *= inv
+	}
+	return centroid
+}
+
+// unionFind is a disjoint-set forest with path compression.
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &unionFind{parent: parent}
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+// union merges the sets of a and b, keeping the smaller index as root so
+// cluster order follows input order.
+func (u *unionFind) union(a, b int) {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	u.parent[rb] = ra
+}
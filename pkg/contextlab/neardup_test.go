@@ -0,0 +1,107 @@
+package contextlab
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// wordRange returns "<prefix><from> ... <prefix><to-1>" as one text.
+func wordRange(prefix string, from, to int) string {
+	words := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		words = append(words, fmt.Sprintf("%s%d", prefix, i))
+	}
+	return strings.Join(words, " ")
+}
+
+// nearDupChunks returns a long passage, a copy with one word appended,
+// and an unrelated passage.
+func nearDupChunks() []types.Chunk {
+	base := wordRange("w", 0, 400)
+	return []types.Chunk{
+		{ID: "a", Text: base},
+		{ID: "b", Text: base + " today"},
+		{ID: "c", Text: wordRange("v", 0, 400)},
+	}
+}
+
+func TestNearDupDetector_Methods(t *testing.T) {
+	for _, method := range []NearDupMethod{NearDupMinHash, NearDupSimHash} {
+		t.Run(string(method), func(t *testing.T) {
+			cfg := DefaultNearDupConfig()
+			cfg.Method = method
+			result := NewNearDupDetector(cfg).Cluster(nearDupChunks())
+
+			if got := clusterSignature(result); got != "0: a b\n1: c\n" {
+				t.Errorf("expected the edited copy grouped and the distinct text apart, got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestNearDupDetector_ThresholdBoundary(t *testing.T) {
+	text := wordRange("w", 0, 30)
+	edited := wordRange("w", 0, 29) + " changed"
+	chunks := []types.Chunk{{ID: "a", Text: text}, {ID: "b", Text: text}, {ID: "c", Text: edited}}
+
+	// Threshold is inclusive: exact copies reach a threshold of 1, a
+	// one-word edit does not.
+	cfg := DefaultNearDupConfig()
+	cfg.Threshold = 1
+	if got := clusterSignature(NewNearDupDetector(cfg).Cluster(chunks)); got != "0: a b\n1: c\n" {
+		t.Errorf("expected only the exact copies grouped at threshold 1, got:\n%s", got)
+	}
+
+	// Sharing the first half of their words gives two texts a Jaccard
+	// similarity of 8/28 on 3-word shingles.
+	half := []types.Chunk{
+		{ID: "a", Text: wordRange("w", 0, 20)},
+		{ID: "b", Text: wordRange("w", 0, 10) + " " + wordRange("x", 10, 20)},
+	}
+	for _, tc := range []struct {
+		threshold float64
+		want      string
+	}{
+		{0.15, "0: a b\n"},
+		{0.45, "0: a\n1: b\n"},
+	} {
+		cfg := DefaultNearDupConfig()
+		cfg.Threshold = tc.threshold
+		cfg.Bands = 64
+		if got := clusterSignature(NewNearDupDetector(cfg).Cluster(half)); got != tc.want {
+			t.Errorf("threshold %v: expected:\n%sgot:\n%s", tc.threshold, tc.want, got)
+		}
+	}
+}
+
+func TestNearDupDetector_EmptyText(t *testing.T) {
+	for _, method := range []NearDupMethod{NearDupMinHash, NearDupSimHash} {
+		t.Run(string(method), func(t *testing.T) {
+			cfg := DefaultNearDupConfig()
+			cfg.Method = method
+			chunks := []types.Chunk{{ID: "a", Text: ""}, {ID: "b", Text: "  ,. "}, {ID: "c", Text: "one two"}}
+			result := NewNearDupDetector(cfg).Cluster(chunks)
+
+			if got := clusterSignature(result); got != "0: a\n1: b\n2: c\n" {
+				t.Errorf("expected texts without words kept apart, got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestNearDupDetector_UnevenBands(t *testing.T) {
+	for _, tc := range []struct{ hashes, bands int }{{10, 4}, {128, 32}, {100, 7}, {5, 5}} {
+		d := NewNearDupDetector(NearDupConfig{NumHashes: tc.hashes, Bands: tc.bands})
+		if d.bandStart(0) != 0 || d.bandStart(tc.bands) != tc.hashes {
+			t.Errorf("%d hashes in %d bands: bands cover rows %d-%d", tc.hashes, tc.bands, d.bandStart(0), d.bandStart(tc.bands))
+		}
+		for b := 0; b < tc.bands; b++ {
+			if rows := d.bandStart(b+1) - d.bandStart(b); rows < tc.hashes/tc.bands || rows > tc.hashes/tc.bands+1 {
+				t.Errorf("%d hashes in %d bands: band %d has %d rows", tc.hashes, tc.bands, b, rows)
+			}
+		}
+	}
+}
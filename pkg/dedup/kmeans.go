@@ -5,6 +5,7 @@ import (
 	"math"
 	"math/rand"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,17 +28,38 @@ type Config struct {
 	// Workers is the number of parallel workers. Default: NumCPU
 	Workers int
 
-	// Seed for reproducible clustering. If 0, uses current time.
+	// Seed for reproducible clustering. If 0, DefaultSeed is used, so
+	// repeated runs on the same input give the same result.
 	Seed int64
+
+	// Probes is how many neighbouring clusters, besides its own, each
+	// vector is checked against, so near-duplicates split across a
+	// centroid boundary are still found. Default: 2. Negative disables.
+	Probes int
+
+	// BatchSize is the number of vectors held in memory at once by
+	// DeduplicateStream. Default: 10000.
+	BatchSize int
+
+	// MaxExemplars caps the kept vectors remembered per cluster by
+	// DeduplicateStream; new vectors are compared against them. Memory is
+	// bounded by K*MaxExemplars vectors. Default: 128.
+	MaxExemplars int
 }
 
+// DefaultSeed seeds clustering when Config.Seed is 0.
+const DefaultSeed = 42
+
 // DefaultConfig returns sensible defaults for deduplication.
 func DefaultConfig() Config {
 	return Config{
 		Threshold:     0.05,
 		MaxIterations: 10,
 		Workers:       runtime.NumCPU(),
-		Seed:          0,
+		Seed:          DefaultSeed,
+		Probes:        2,
+		BatchSize:     10000,
+		MaxExemplars:  128,
 	}
 }
 
@@ -49,21 +71,31 @@ type Engine struct {
 
 // NewEngine creates a deduplication engine with the given config.
 func NewEngine(cfg Config) *Engine {
-	seed := cfg.Seed
-	if seed == 0 {
-		seed = time.Now().UnixNano()
+	if cfg.Seed == 0 {
+		cfg.Seed = DefaultSeed
 	}
-
 	if cfg.Workers <= 0 {
 		cfg.Workers = runtime.NumCPU()
 	}
 	if cfg.MaxIterations <= 0 {
 		cfg.MaxIterations = 10
 	}
+	if cfg.Probes == 0 {
+		cfg.Probes = 2
+	}
+	if cfg.Probes < 0 {
+		cfg.Probes = 0
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10000
+	}
+	if cfg.MaxExemplars <= 0 {
+		cfg.MaxExemplars = 128
+	}
 
 	return &Engine{
 		cfg: cfg,
-		rng: rand.New(rand.NewSource(seed)),
+		rng: rand.New(rand.NewSource(cfg.Seed)),
 	}
 }
 
@@ -74,7 +106,9 @@ type cluster struct {
 }
 
 // Deduplicate performs semantic deduplication on the input vectors.
-// Returns unique vectors and deduplication statistics.
+// Returns unique vectors, in input order, and deduplication statistics.
+// All vectors are held in memory; use DeduplicateStream for inputs that
+// do not fit.
 func (e *Engine) Deduplicate(ctx context.Context, vectors []types.Vector) (*types.DeduplicationResult, error) {
 	start := time.Now()
 
@@ -82,17 +116,7 @@ func (e *Engine) Deduplicate(ctx context.Context, vectors []types.Vector) (*type
 		return &types.DeduplicationResult{}, nil
 	}
 
-	// Determine K
-	k := e.cfg.K
-	if k <= 0 {
-		k = int(math.Sqrt(float64(len(vectors)) / 2))
-		if k < 1 {
-			k = 1
-		}
-	}
-	if k > len(vectors) {
-		k = len(vectors)
-	}
+	k := e.clusterCount(len(vectors))
 
 	// Run K-Means clustering
 	clusters, err := e.kMeans(ctx, vectors, k)
@@ -100,7 +124,7 @@ func (e *Engine) Deduplicate(ctx context.Context, vectors []types.Vector) (*type
 		return nil, err
 	}
 
-	// Prune duplicates within each cluster
+	// Prune duplicates within and across neighbouring clusters
 	uniqueIndices := e.pruneClustersConcurrent(ctx, vectors, clusters)
 
 	// Build result
@@ -118,6 +142,21 @@ func (e *Engine) Deduplicate(ctx context.Context, vectors []types.Vector) (*type
 	}, nil
 }
 
+// clusterCount returns K for n vectors: the configured K, or sqrt(n/2).
+func (e *Engine) clusterCount(n int) int {
+	k := e.cfg.K
+	if k <= 0 {
+		k = int(math.Sqrt(float64(n) / 2))
+		if k < 1 {
+			k = 1
+		}
+	}
+	if k > n {
+		k = n
+	}
+	return k
+}
+
 // kMeans performs K-Means clustering on vectors.
 func (e *Engine) kMeans(ctx context.Context, vectors []types.Vector, k int) ([]cluster, error) {
 	if len(vectors) == 0 || k == 0 {
@@ -126,7 +165,7 @@ func (e *Engine) kMeans(ctx context.Context, vectors []types.Vector, k int) ([]c
 
 	dim := vectors[0].Dimension()
 
-	// Initialize centroids using random selection (K-Means++)
+	// Initialize centroids with K-Means++
 	centroids := e.initCentroids(vectors, k, dim)
 
 	// Cluster assignments: vectorIndex -> clusterIndex
@@ -165,15 +204,52 @@ func (e *Engine) kMeans(ctx context.Context, vectors []types.Vector, k int) ([]c
 	return clusters, nil
 }
 
-// initCentroids selects k random vectors as initial centroids.
+// initCentroids picks k initial centroids with K-Means++: the first
+// uniformly, each next one with probability proportional to its squared
+// distance from the nearest centroid chosen so far. Spreading the seeds
+// out this way converges faster and more consistently than random picks.
 func (e *Engine) initCentroids(vectors []types.Vector, k, dim int) [][]float32 {
-	centroids := make([][]float32, k)
+	n := len(vectors)
+	centroids := make([][]float32, 0, k)
+	pick := func(idx int) {
+		c := make([]float32, dim)
+		copy(c, vectors[idx].Values)
+		centroids = append(centroids, c)
+	}
 
-	// Simple random selection
-	perm := e.rng.Perm(len(vectors))
-	for i := 0; i < k; i++ {
-		centroids[i] = make([]float32, dim)
-		copy(centroids[i], vectors[perm[i]].Values)
+	pick(e.rng.Intn(n))
+
+	// minDist[i] is the squared distance from vector i to its nearest centroid.
+	minDist := make([]float64, n)
+	for i := range minDist {
+		minDist[i] = math.MaxFloat64
+	}
+	for len(centroids) < k {
+		last := centroids[len(centroids)-1]
+		var total float64
+		for i := range vectors {
+			d := simd.CosineDistance(vectors[i].Values, last)
+			if d *= d; d < minDist[i] {
+				minDist[i] = d
+			}
+			total += minDist[i]
+		}
+
+		// Every vector coincides with a centroid: fall back to uniform.
+		if total == 0 {
+			pick(e.rng.Intn(n))
+			continue
+		}
+
+		target := e.rng.Float64() * total
+		idx := n - 1
+		for i, d := range minDist {
+			if target -= d; target < 0 {
+				idx = i
+				break
+			}
+		}
+		pick(idx)
 	}
 
 	return centroids
@@ -229,6 +305,37 @@ func (e *Engine) assignVectorsConcurrent(vectors []types.Vector, centroids [][]f
 	return false
 }
 
+// nearestCentroids returns the indices of the n closest centroids, nearest
+// first, with ties broken by index.
+func nearestCentroids(vec []float32, centroids [][]float32, n int) []int {
+	if n > len(centroids) {
+		n = len(centroids)
+	}
+	type cand struct {
+		idx  int
+		dist float64
+	}
+	best := make([]cand, 0, n+1)
+	for i, c := range centroids {
+		d := simd.CosineDistance(vec, c)
+		if len(best) == n && d >= best[n-1].dist {
+			continue
+		}
+		pos := sort.Search(len(best), func(j int) bool { return best[j].dist > d })
+		best = append(best, cand{})
+		copy(best[pos+1:], best[pos:])
+		best[pos] = cand{idx: i, dist: d}
+		if len(best) > n {
+			best = best[:n]
+		}
+	}
+	out := make([]int, len(best))
+	for i, b := range best {
+		out[i] = b.idx
+	}
+	return out
+}
+
 // findNearestCentroid returns the index of the closest centroid.
 func (e *Engine) findNearestCentroid(vec []float32, centroids [][]float32) int {
 	minDist := math.MaxFloat64
@@ -274,53 +381,111 @@ func (e *Engine) updateCentroids(vectors []types.Vector, assignments []int, cent
 	}
 }
 
-// pruneClustersConcurrent identifies unique vectors within each cluster.
+// pruneClustersConcurrent identifies unique vectors. Medoids are settled
+// first, in input order: a medoid is a duplicate when it is within the
+// threshold of a kept medoid among the Probes nearest other clusters that
+// comes earlier in the input. Each other member is then a duplicate when
+// it is within the threshold of its own cluster's medoid or of one of
+// those neighbouring medoids, counting only medoids that were kept, so
+// duplicates never chain through a vector that was itself dropped.
+// Returns indices in input order.
 func (e *Engine) pruneClustersConcurrent(ctx context.Context, vectors []types.Vector, clusters []cluster) []int {
-	var mu sync.Mutex
-	uniqueIndices := make([]int, 0, len(vectors))
-
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, e.cfg.Workers)
-
-	for _, cl := range clusters {
-		if len(cl.members) == 0 {
-			continue
+	medoids := make([]int, len(clusters))
+	centroids := make([][]float32, len(clusters))
+	owner := make([]int, len(vectors))
+	for c, cl := range clusters {
+		medoids[c] = medoid(vectors, cl)
+		centroids[c] = cl.centroid
+		for _, idx := range cl.members {
+			owner[idx] = c
 		}
+	}
 
-		wg.Add(1)
-		sem <- struct{}{}
+	kept := e.keptMedoids(vectors, medoids, centroids)
 
-		go func(c cluster) {
-			defer wg.Done()
-			defer func() { <-sem }()
+	keep := make([]bool, len(vectors))
+	e.parallelRange(len(vectors), func(start, end int) {
+		for i := start; i < end; i++ {
+			if medoids[owner[i]] == i {
+				keep[i] = kept[owner[i]]
+				continue
+			}
+			keep[i] = e.isUnique(vectors, i, owner[i], medoids, centroids, kept)
+		}
+	})
 
-			unique := e.pruneCluster(vectors, c)
+	uniqueIndices := make([]int, 0, len(vectors))
+	for i, k := range keep {
+		if k {
+			uniqueIndices = append(uniqueIndices, i)
+		}
+	}
+	return uniqueIndices
+}
 
-			mu.Lock()
-			uniqueIndices = append(uniqueIndices, unique...)
-			mu.Unlock()
-		}(cl)
+// keptMedoids reports, per cluster, whether its medoid survives pruning.
+// Medoids are settled in input order against the kept medoids of the
+// Probes nearest other clusters.
+func (e *Engine) keptMedoids(vectors []types.Vector, medoids []int, centroids [][]float32) []bool {
+	kept := make([]bool, len(medoids))
+	order := make([]int, 0, len(medoids))
+	for c, m := range medoids {
+		if m >= 0 {
+			kept[c] = true
+			order = append(order, c)
+		}
+	}
+	if e.cfg.Probes == 0 {
+		return kept
 	}
 
-	wg.Wait()
-	return uniqueIndices
+	sort.Slice(order, func(a, b int) bool { return medoids[order[a]] < medoids[order[b]] })
+	for _, own := range order {
+		i := medoids[own]
+		for _, c := range nearestCentroids(vectors[i].Values, centroids, e.cfg.Probes+1) {
+			m := medoids[c]
+			if c == own || m < 0 || m > i || !kept[c] {
+				continue
+			}
+			if simd.CosineDistance(vectors[i].Values, vectors[m].Values) < e.cfg.Threshold {
+				kept[own] = false
+				break
+			}
+		}
+	}
+	return kept
 }
 
-// pruneCluster identifies unique vectors within a single cluster.
-// Uses medoid-based comparison for efficiency.
-func (e *Engine) pruneCluster(vectors []types.Vector, cl cluster) []int {
-	if len(cl.members) == 0 {
-		return nil
+// isUnique reports whether vector i, a non-medoid member of cluster own,
+// survives pruning against the kept medoids.
+func (e *Engine) isUnique(vectors []types.Vector, i, own int, medoids []int, centroids [][]float32, kept []bool) bool {
+	vec := vectors[i].Values
+	if kept[own] && simd.CosineDistance(vec, vectors[medoids[own]].Values) < e.cfg.Threshold {
+		return false
+	}
+	if e.cfg.Probes == 0 {
+		return true
 	}
 
-	if len(cl.members) == 1 {
-		return cl.members
+	for _, c := range nearestCentroids(vec, centroids, e.cfg.Probes+1) {
+		if c == own || !kept[c] {
+			continue
+		}
+		if simd.CosineDistance(vec, vectors[medoids[c]].Values) < e.cfg.Threshold {
+			return false
+		}
 	}
+	return true
+}
 
-	// Find medoid: vector closest to centroid
+// medoid returns the member closest to the cluster centroid, or -1 for an
+// empty cluster.
+func medoid(vectors []types.Vector, cl cluster) int {
+	if len(cl.members) == 0 {
+		return -1
+	}
 	medoidIdx := cl.members[0]
 	minDist := simd.CosineDistance(vectors[medoidIdx].Values, cl.centroid)
-
 	for _, idx := range cl.members[1:] {
 		dist := simd.CosineDistance(vectors[idx].Values, cl.centroid)
 		if dist < minDist {
@@ -328,24 +493,30 @@ func (e *Engine) pruneCluster(vectors []types.Vector, cl cluster) []int {
 			medoidIdx = idx
 		}
 	}
+	return medoidIdx
+}
 
-	// Mark duplicates: vectors too similar to medoid
-	unique := make([]int, 0, len(cl.members))
-	unique = append(unique, medoidIdx) // Medoid is always kept
-
-	medoidVec := vectors[medoidIdx].Values
-
-	for _, idx := range cl.members {
-		if idx == medoidIdx {
-			continue
-		}
-
-		dist := simd.CosineDistance(vectors[idx].Values, medoidVec)
-		if dist >= e.cfg.Threshold {
-			// Not a duplicate - distance exceeds threshold
-			unique = append(unique, idx)
+// parallelRange splits [0, n) into one contiguous range per worker.
+func (e *Engine) parallelRange(n int, fn func(start, end int)) {
+	workers := e.cfg.Workers
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		return
+	}
+	chunkSize := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunkSize {
+		end := start + chunkSize
+		if end > n {
+			end = n
 		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
 	}
-
-	return unique
+	wg.Wait()
 }
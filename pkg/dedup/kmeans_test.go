@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// makeVectors builds n vectors around k random centers, with every third
+// vector a near-copy of the one before it.
+func makeVectors(n, k, dim int) []types.Vector {
+	rng := rand.New(rand.NewSource(3))
+	centers := make([][]float32, k)
+	for i := range centers {
+		centers[i] = make([]float32, dim)
+		for d := range centers[i] {
+			centers[i][d] = float32(rng.NormFloat64())
+		}
+	}
+	vectors := make([]types.Vector, n)
+	for i := range vectors {
+		values := make([]float32, dim)
+		if i%3 == 2 {
+			for d, v := range vectors[i-1].Values {
+				values[d] = v + float32(rng.NormFloat64()*0.001)
+			}
+		} else {
+			c := centers[rng.Intn(k)]
+			for d := range values {
+				values[d] = c[d] + float32(rng.NormFloat64()*0.5)
+			}
+		}
+		vectors[i] = types.Vector{ID: fmt.Sprintf("v%d", i), Values: values}
+	}
+	return vectors
+}
+
+func TestDeduplicate_Deterministic(t *testing.T) {
+	vectors := makeVectors(600, 8, 32)
+
+	var first []string
+	for run := 0; run < 3; run++ {
+		result, err := NewEngine(Config{Threshold: 0.01}).Deduplicate(context.Background(), vectors)
+		if err != nil {
+			t.Fatalf("Deduplicate: %v", err)
+		}
+		ids := make([]string, len(result.UniqueVectors))
+		for i, v := range result.UniqueVectors {
+			ids[i] = v.ID
+		}
+		if run == 0 {
+			first = ids
+			continue
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(first) {
+			t.Fatalf("run %d returned different unique vectors", run)
+		}
+	}
+}
+
+func TestDeduplicate_AcrossClusters(t *testing.T) {
+	// Two identical vectors forced into different clusters: with K equal
+	// to the vector count every vector is its own medoid.
+	vectors := []types.Vector{
+		{ID: "a", Values: []float32{1, 0, 0}},
+		{ID: "b", Values: []float32{0, 1, 0}},
+		{ID: "c", Values: []float32{1, 0.001, 0}},
+	}
+	result, err := NewEngine(Config{Threshold: 0.01, K: 3}).Deduplicate(context.Background(), vectors)
+	if err != nil {
+		t.Fatalf("Deduplicate: %v", err)
+	}
+	if result.DuplicateCount != 1 {
+		t.Fatalf("expected 1 duplicate, got %d", result.DuplicateCount)
+	}
+	if got := result.UniqueVectors[0].ID + result.UniqueVectors[1].ID; got != "ab" {
+		t.Errorf("expected a and b kept in input order, got %s", got)
+	}
+
+	result, _ = NewEngine(Config{Threshold: 0.01, K: 3, Probes: -1}).Deduplicate(context.Background(), vectors)
+	if result.DuplicateCount != 0 {
+		t.Errorf("expected no duplicates without probing, got %d", result.DuplicateCount)
+	}
+}
+
+func TestDeduplicate_NoChaining(t *testing.T) {
+	// Each vector is 0.28 rad from the next: neighbours are duplicates,
+	// but the ends are 0.153 apart. Dropping b must not let it drop c.
+	vectors := make([]types.Vector, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		angle := 0.28 * float64(i)
+		vectors[i] = types.Vector{ID: id, Values: []float32{float32(math.Cos(angle)), float32(math.Sin(angle))}}
+	}
+	result, err := NewEngine(Config{Threshold: 0.05, K: 3}).Deduplicate(context.Background(), vectors)
+	if err != nil {
+		t.Fatalf("Deduplicate: %v", err)
+	}
+	var ids string
+	for _, v := range result.UniqueVectors {
+		ids += v.ID
+	}
+	if ids != "ac" {
+		t.Errorf("expected a and c kept, got %s", ids)
+	}
+}
+
+func TestDeduplicateStream(t *testing.T) {
+	vectors := makeVectors(900, 8, 32)
+	in := make(chan types.Vector)
+	go func() {
+		defer close(in)
+		for _, v := range vectors {
+			in <- v
+		}
+	}()
+
+	var emitted int
+	engine := NewEngine(Config{Threshold: 0.01, BatchSize: 200})
+	result, err := engine.DeduplicateStream(context.Background(), in, func(types.Vector) error {
+		emitted++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DeduplicateStream: %v", err)
+	}
+	if result.TotalProcessed != len(vectors) {
+		t.Errorf("expected %d processed, got %d", len(vectors), result.TotalProcessed)
+	}
+	if emitted != result.TotalProcessed-result.DuplicateCount {
+		t.Errorf("emitted %d, want %d", emitted, result.TotalProcessed-result.DuplicateCount)
+	}
+	// Every third vector is a near-copy of its predecessor.
+	if result.DuplicateCount < len(vectors)/3 {
+		t.Errorf("expected at least %d duplicates, got %d", len(vectors)/3, result.DuplicateCount)
+	}
+}
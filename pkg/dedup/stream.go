@@ -0,0 +1,177 @@
+package dedup
+
+import (
+	"context"
+	"time"
+
+	simd "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// exemplars is a fixed-size ring of kept vectors for one cluster.
+type exemplars struct {
+	values [][]float32
+	next   int
+}
+
+func (x *exemplars) add(v []float32, max int) {
+	if len(x.values) < max {
+		x.values = append(x.values, v)
+		return
+	}
+	x.values[x.next] = v
+	x.next = (x.next + 1) % max
+}
+
+// streamState is the clustering state DeduplicateStream carries between
+// batches.
+type streamState struct {
+	centroids [][]float32
+	counts    []int
+	kept      []exemplars
+}
+
+// DeduplicateStream deduplicates vectors read from in, calling emit for
+// each unique vector in input order, with memory bounded by BatchSize and
+// K*MaxExemplars vectors rather than the input size. Vectors whose
+// dimension differs from the first one's are emitted as soon as they are
+// read.
+//
+// Centroids are seeded with K-Means++ and refined by full K-Means on the
+// first batch, then updated by mini-batch K-Means as later batches arrive.
+// A vector is a duplicate when it is within the threshold of a kept vector
+// remembered for its own cluster or one of the Probes nearest others. K
+// defaults to sqrt(BatchSize/2). The returned result has counts only;
+// UniqueVectors is nil.
+func (e *Engine) DeduplicateStream(ctx context.Context, in <-chan types.Vector, emit func(types.Vector) error) (*types.DeduplicationResult, error) {
+	start := time.Now()
+	result := &types.DeduplicationResult{}
+
+	var state *streamState
+	dim := -1
+	batch := make([]types.Vector, 0, e.cfg.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if state == nil {
+			var err error
+			if state, err = e.initStream(ctx, batch); err != nil {
+				return err
+			}
+			result.ClusterCount = len(state.centroids)
+		}
+		if err := e.processBatch(state, batch, emit, result); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case v, ok := <-in:
+			if !ok {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				result.ProcessingTimeMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+			// Vectors that do not match the first vector's dimension cannot
+			// be clustered; pass them straight through as unique.
+			if dim < 0 {
+				dim = len(v.Values)
+			}
+			if len(v.Values) != dim || dim == 0 {
+				result.TotalProcessed++
+				if err := emit(v); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			batch = append(batch, v)
+			if len(batch) >= e.cfg.BatchSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+// initStream clusters the first batch to seed the stream's centroids.
+func (e *Engine) initStream(ctx context.Context, batch []types.Vector) (*streamState, error) {
+	k := e.cfg.K
+	if k <= 0 {
+		k = e.clusterCount(e.cfg.BatchSize)
+	}
+	if k > len(batch) {
+		k = len(batch)
+	}
+	clusters, err := e.kMeans(ctx, batch, k)
+	if err != nil {
+		return nil, err
+	}
+	state := &streamState{
+		centroids: make([][]float32, k),
+		counts:    make([]int, k),
+		kept:      make([]exemplars, k),
+	}
+	for i, cl := range clusters {
+		state.centroids[i] = cl.centroid
+	}
+	return state, nil
+}
+
+// processBatch assigns a batch to clusters, moves each centroid towards its
+// new members, and emits the vectors that are not duplicates.
+func (e *Engine) processBatch(state *streamState, batch []types.Vector, emit func(types.Vector) error, result *types.DeduplicationResult) error {
+	nearest := make([][]int, len(batch))
+	e.parallelRange(len(batch), func(start, end int) {
+		for i := start; i < end; i++ {
+			nearest[i] = nearestCentroids(batch[i].Values, state.centroids, e.cfg.Probes+1)
+		}
+	})
+
+	// Mini-batch update: each centroid moves towards a member with a
+	// learning rate of 1/(members seen so far).
+	for i, v := range batch {
+		c := nearest[i][0]
+		state.counts[c]++
+		rate := 1 / float32(state.counts[c])
+		centroid := state.centroids[c]
+		for d, x := range v.Values {
+			centroid[d] += (x - centroid[d]) * rate
+		}
+	}
+
+	// Dedup is sequential so earlier vectors win, as in the input order.
+	for i, v := range batch {
+		result.TotalProcessed++
+		if e.isStreamDuplicate(state, v.Values, nearest[i]) {
+			result.DuplicateCount++
+			continue
+		}
+		state.kept[nearest[i][0]].add(v.Values, e.cfg.MaxExemplars)
+		if err := emit(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStreamDuplicate reports whether vec is within the threshold of a kept
+// vector in any of the given clusters.
+func (e *Engine) isStreamDuplicate(state *streamState, vec []float32, clusters []int) bool {
+	for _, c := range clusters {
+		for _, kept := range state.kept[c].values {
+			if simd.CosineDistance(vec, kept) < e.cfg.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package math
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelMatrixAbove is the vector count from which CosineDistanceMatrix
+// splits rows across goroutines.
+const parallelMatrixAbove = 64
+
+// matrixTile is the number of vectors per side of a CosineDistanceMatrix
+// tile.
+const matrixTile = 16
+
+// CosineDistances computes the cosine distance from query to each vector,
+// writing into dst (grown if too short) and returning it. Empty vectors get
+// the maximum distance, 2.
+func CosineDistances(dst []float64, query []float32, vectors [][]float32) []float64 {
+	if cap(dst) < len(vectors) {
+		dst = make([]float64, len(vectors))
+	}
+	dst = dst[:len(vectors)]
+	for i, v := range vectors {
+		dst[i] = CosineDistance(query, v)
+	}
+	return dst
+}
+
+// CosineDistanceMatrix computes pairwise cosine distances. Magnitudes are
+// computed once per vector, so each pair costs one dot product, and large
+// inputs are split across CPUs. The diagonal is 0 and pairs involving an
+// empty vector get the maximum distance, 2.
+func CosineDistanceMatrix(vectors [][]float32) [][]float64 {
+	n := len(vectors)
+	backing := make([]float64, n*n)
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = backing[i*n : (i+1)*n]
+	}
+
+	mags := make([]float64, n)
+	for i, v := range vectors {
+		if len(v) > 0 {
+			mags[i] = active.dot(v, v)
+		}
+	}
+
+	pair := func(i, j int) {
+		a, b := vectors[i], vectors[j]
+		var dist float64
+		switch {
+		case len(a) == 0 || len(b) == 0:
+			dist = 2.0
+		case len(a) != len(b):
+			dist = CosineDistance(a, b)
+		default:
+			dist = cosineFromSums(active.dot(a, b), mags[i], mags[j])
+		}
+		matrix[i][j] = dist
+		matrix[j][i] = dist
+	}
+
+	// Pairs are visited in tiles of matrixTile x matrixTile vectors so both
+	// sides of a tile stay in cache. A block row covers the upper triangle
+	// for its tile of rows.
+	blockRow := func(bi int) {
+		iEnd := min(bi+matrixTile, n)
+		for bj := bi; bj < n; bj += matrixTile {
+			jEnd := min(bj+matrixTile, n)
+			for i := bi; i < iEnd; i++ {
+				for j := max(bj, i+1); j < jEnd; j++ {
+					pair(i, j)
+				}
+			}
+		}
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if n < parallelMatrixAbove || workers < 2 {
+		for bi := 0; bi < n; bi += matrixTile {
+			blockRow(bi)
+		}
+		return matrix
+	}
+
+	// Block rows shrink towards the end, so stripe them across workers to
+	// keep the work balanced. Each cell is written by the worker owning
+	// its upper-triangle block row only.
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for bi := w * matrixTile; bi < n; bi += workers * matrixTile {
+				blockRow(bi)
+			}
+		}(w)
+	}
+	wg.Wait()
+	return matrix
+}
@@ -0,0 +1,111 @@
+package math
+
+// kernels is a set of vector kernels for one instruction set. Callers pass
+// non-empty slices of equal length. Every implementation accumulates in
+// float64, so results differ from the generic kernels only by summation
+// order.
+type kernels struct {
+	name   string
+	dot    func(a, b []float32) float64
+	cosine func(a, b []float32) (dot, magA, magB float64)
+	l2     func(a, b []float32) float64
+}
+
+// genericKernels is the pure-Go fallback, used when the CPU has no
+// supported vector extension or the purego build tag is set.
+var genericKernels = kernels{
+	name:   "generic",
+	dot:    dotGeneric,
+	cosine: cosineGeneric,
+	l2:     l2Generic,
+}
+
+// active is the kernel set used by the exported functions.
+var active = genericKernels
+
+func init() {
+	// cpuKernels lists the kernel sets this CPU supports, best first.
+	if available := cpuKernels(); len(available) > 0 {
+		active = available[0]
+	}
+}
+
+// Implementation names the kernel set selected for this CPU: "avx512",
+// "avx2", "neon", or "generic".
+func Implementation() string {
+	return active.name
+}
+
+func dotGeneric(a, b []float32) float64 {
+	var sum float64
+	n := len(a)
+
+	// Process 4 elements at a time
+	i := 0
+	for ; i <= n-4; i += 4 {
+		sum += float64(a[i])*float64(b[i]) +
+			float64(a[i+1])*float64(b[i+1]) +
+			float64(a[i+2])*float64(b[i+2]) +
+			float64(a[i+3])*float64(b[i+3])
+	}
+
+	for ; i < n; i++ {
+		sum += float64(a[i]) * float64(b[i])
+	}
+
+	return sum
+}
+
+func cosineGeneric(a, b []float32) (dot, magA, magB float64) {
+	n := len(a)
+
+	// Process 4 elements at a time for better CPU pipelining
+	i := 0
+	for ; i <= n-4; i += 4 {
+		dot += float64(a[i])*float64(b[i]) +
+			float64(a[i+1])*float64(b[i+1]) +
+			float64(a[i+2])*float64(b[i+2]) +
+			float64(a[i+3])*float64(b[i+3])
+
+		magA += float64(a[i])*float64(a[i]) +
+			float64(a[i+1])*float64(a[i+1]) +
+			float64(a[i+2])*float64(a[i+2]) +
+			float64(a[i+3])*float64(a[i+3])
+
+		magB += float64(b[i])*float64(b[i]) +
+			float64(b[i+1])*float64(b[i+1]) +
+			float64(b[i+2])*float64(b[i+2]) +
+			float64(b[i+3])*float64(b[i+3])
+	}
+
+	// Handle remaining elements
+	for ; i < n; i++ {
+		dot += float64(a[i]) * float64(b[i])
+		magA += float64(a[i]) * float64(a[i])
+		magB += float64(b[i]) * float64(b[i])
+	}
+
+	return dot, magA, magB
+}
+
+func l2Generic(a, b []float32) float64 {
+	var sum float64
+	n := len(a)
+
+	// Process 4 elements at a time
+	i := 0
+	for ; i <= n-4; i += 4 {
+		d0 := float64(a[i]) - float64(b[i])
+		d1 := float64(a[i+1]) - float64(b[i+1])
+		d2 := float64(a[i+2]) - float64(b[i+2])
+		d3 := float64(a[i+3]) - float64(b[i+3])
+		sum += d0*d0 + d1*d1 + d2*d2 + d3*d3
+	}
+
+	for ; i < n; i++ {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+
+	return sum
+}
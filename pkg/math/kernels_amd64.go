@@ -0,0 +1,37 @@
+//go:build !purego
+
+package math
+
+import "golang.org/x/sys/cpu"
+
+//go:noescape
+func dotAVX2(a, b []float32) float64
+
+//go:noescape
+func cosineAVX2(a, b []float32) (dot, magA, magB float64)
+
+//go:noescape
+func l2AVX2(a, b []float32) float64
+
+//go:noescape
+func dotAVX512(a, b []float32) float64
+
+//go:noescape
+func cosineAVX512(a, b []float32) (dot, magA, magB float64)
+
+//go:noescape
+func l2AVX512(a, b []float32) float64
+
+func cpuKernels() []kernels {
+	var available []kernels
+	if !cpu.X86.HasFMA {
+		return nil
+	}
+	if cpu.X86.HasAVX512F {
+		available = append(available, kernels{name: "avx512", dot: dotAVX512, cosine: cosineAVX512, l2: l2AVX512})
+	}
+	if cpu.X86.HasAVX2 {
+		available = append(available, kernels{name: "avx2", dot: dotAVX2, cosine: cosineAVX2, l2: l2AVX2})
+	}
+	return available
+}
@@ -0,0 +1,428 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// Kernels widen float32 inputs to float64 (VCVTPS2PD) and accumulate with
+// FMA, matching the precision of the generic Go kernels. Both slices have
+// the length of a, which is at least 1.
+
+// HSUMY adds the four float64 lanes of y into the low lane of x, using tx
+// as scratch. x must be the low half of y.
+#define HSUMY(y, x, tx) \
+	VEXTRACTF128 $1, y, tx; \
+	VADDPD       tx, x, x;  \
+	VUNPCKHPD    x, x, tx;  \
+	VADDSD       tx, x, x
+
+// HSUMZ adds the eight float64 lanes of z into the low lane of x, using ty
+// and tx as scratch. y and x must be the low halves of z.
+#define HSUMZ(z, y, x, ty, tx) \
+	VEXTRACTF64X4 $1, z, ty; \
+	VADDPD        ty, y, y;  \
+	HSUMY(y, x, tx)
+
+// func dotAVX2(a, b []float32) float64
+TEXT ·dotAVX2(SB), NOSPLIT, $0-56
+	MOVQ   a_base+0(FP), SI
+	MOVQ   a_len+8(FP), CX
+	MOVQ   b_base+24(FP), DI
+	VXORPD Y0, Y0, Y0
+	VXORPD Y1, Y1, Y1
+	VXORPD Y2, Y2, Y2
+	VXORPD Y3, Y3, Y3
+
+loop16:
+	CMPQ        CX, $16
+	JL          loop4
+	VCVTPS2PD   (SI), Y4
+	VCVTPS2PD   16(SI), Y5
+	VCVTPS2PD   32(SI), Y6
+	VCVTPS2PD   48(SI), Y7
+	VCVTPS2PD   (DI), Y8
+	VCVTPS2PD   16(DI), Y9
+	VCVTPS2PD   32(DI), Y10
+	VCVTPS2PD   48(DI), Y11
+	VFMADD231PD Y8, Y4, Y0
+	VFMADD231PD Y9, Y5, Y1
+	VFMADD231PD Y10, Y6, Y2
+	VFMADD231PD Y11, Y7, Y3
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+loop4:
+	CMPQ        CX, $4
+	JL          reduce
+	VCVTPS2PD   (SI), Y4
+	VCVTPS2PD   (DI), Y8
+	VFMADD231PD Y8, Y4, Y0
+	ADDQ        $16, SI
+	ADDQ        $16, DI
+	SUBQ        $4, CX
+	JMP         loop4
+
+reduce:
+	VADDPD Y1, Y0, Y0
+	VADDPD Y3, Y2, Y2
+	VADDPD Y2, Y0, Y0
+	HSUMY(Y0, X0, X1)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X4, X4
+	VCVTSS2SD   (DI), X8, X8
+	VFMADD231SD X8, X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, ret+48(FP)
+	RET
+
+// func cosineAVX2(a, b []float32) (dot, magA, magB float64)
+TEXT ·cosineAVX2(SB), NOSPLIT, $0-72
+	MOVQ   a_base+0(FP), SI
+	MOVQ   a_len+8(FP), CX
+	MOVQ   b_base+24(FP), DI
+	VXORPD Y0, Y0, Y0
+	VXORPD Y1, Y1, Y1
+	VXORPD Y2, Y2, Y2
+	VXORPD Y3, Y3, Y3
+	VXORPD Y4, Y4, Y4
+	VXORPD Y5, Y5, Y5
+
+loop8:
+	CMPQ        CX, $8
+	JL          loop4
+	VCVTPS2PD   (SI), Y6
+	VCVTPS2PD   16(SI), Y7
+	VCVTPS2PD   (DI), Y8
+	VCVTPS2PD   16(DI), Y9
+	VFMADD231PD Y8, Y6, Y0
+	VFMADD231PD Y9, Y7, Y1
+	VFMADD231PD Y6, Y6, Y2
+	VFMADD231PD Y7, Y7, Y3
+	VFMADD231PD Y8, Y8, Y4
+	VFMADD231PD Y9, Y9, Y5
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+loop4:
+	CMPQ        CX, $4
+	JL          reduce
+	VCVTPS2PD   (SI), Y6
+	VCVTPS2PD   (DI), Y8
+	VFMADD231PD Y8, Y6, Y0
+	VFMADD231PD Y6, Y6, Y2
+	VFMADD231PD Y8, Y8, Y4
+	ADDQ        $16, SI
+	ADDQ        $16, DI
+	SUBQ        $4, CX
+	JMP         loop4
+
+reduce:
+	VADDPD Y1, Y0, Y0
+	VADDPD Y3, Y2, Y2
+	VADDPD Y5, Y4, Y4
+	HSUMY(Y0, X0, X1)
+	HSUMY(Y2, X2, X3)
+	HSUMY(Y4, X4, X5)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X6, X6
+	VCVTSS2SD   (DI), X8, X8
+	VFMADD231SD X8, X6, X0
+	VFMADD231SD X6, X6, X2
+	VFMADD231SD X8, X8, X4
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, dot+48(FP)
+	MOVSD X2, magA+56(FP)
+	MOVSD X4, magB+64(FP)
+	RET
+
+// func l2AVX2(a, b []float32) float64
+TEXT ·l2AVX2(SB), NOSPLIT, $0-56
+	MOVQ   a_base+0(FP), SI
+	MOVQ   a_len+8(FP), CX
+	MOVQ   b_base+24(FP), DI
+	VXORPD Y0, Y0, Y0
+	VXORPD Y1, Y1, Y1
+	VXORPD Y2, Y2, Y2
+	VXORPD Y3, Y3, Y3
+
+loop16:
+	CMPQ        CX, $16
+	JL          loop4
+	VCVTPS2PD   (SI), Y4
+	VCVTPS2PD   16(SI), Y5
+	VCVTPS2PD   32(SI), Y6
+	VCVTPS2PD   48(SI), Y7
+	VCVTPS2PD   (DI), Y8
+	VCVTPS2PD   16(DI), Y9
+	VCVTPS2PD   32(DI), Y10
+	VCVTPS2PD   48(DI), Y11
+	VSUBPD      Y8, Y4, Y4
+	VSUBPD      Y9, Y5, Y5
+	VSUBPD      Y10, Y6, Y6
+	VSUBPD      Y11, Y7, Y7
+	VFMADD231PD Y4, Y4, Y0
+	VFMADD231PD Y5, Y5, Y1
+	VFMADD231PD Y6, Y6, Y2
+	VFMADD231PD Y7, Y7, Y3
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+loop4:
+	CMPQ        CX, $4
+	JL          reduce
+	VCVTPS2PD   (SI), Y4
+	VCVTPS2PD   (DI), Y8
+	VSUBPD      Y8, Y4, Y4
+	VFMADD231PD Y4, Y4, Y0
+	ADDQ        $16, SI
+	ADDQ        $16, DI
+	SUBQ        $4, CX
+	JMP         loop4
+
+reduce:
+	VADDPD Y1, Y0, Y0
+	VADDPD Y3, Y2, Y2
+	VADDPD Y2, Y0, Y0
+	HSUMY(Y0, X0, X1)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X4, X4
+	VCVTSS2SD   (DI), X8, X8
+	VSUBSD      X8, X4, X4
+	VFMADD231SD X4, X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, ret+48(FP)
+	RET
+
+// func dotAVX512(a, b []float32) float64
+TEXT ·dotAVX512(SB), NOSPLIT, $0-56
+	MOVQ    a_base+0(FP), SI
+	MOVQ    a_len+8(FP), CX
+	MOVQ    b_base+24(FP), DI
+	VPXORQ  Z0, Z0, Z0
+	VPXORQ  Z1, Z1, Z1
+	VPXORQ  Z2, Z2, Z2
+	VPXORQ  Z3, Z3, Z3
+
+loop32:
+	CMPQ        CX, $32
+	JL          loop8
+	VCVTPS2PD   (SI), Z4
+	VCVTPS2PD   32(SI), Z5
+	VCVTPS2PD   64(SI), Z6
+	VCVTPS2PD   96(SI), Z7
+	VCVTPS2PD   (DI), Z8
+	VCVTPS2PD   32(DI), Z9
+	VCVTPS2PD   64(DI), Z10
+	VCVTPS2PD   96(DI), Z11
+	VFMADD231PD Z8, Z4, Z0
+	VFMADD231PD Z9, Z5, Z1
+	VFMADD231PD Z10, Z6, Z2
+	VFMADD231PD Z11, Z7, Z3
+	ADDQ        $128, SI
+	ADDQ        $128, DI
+	SUBQ        $32, CX
+	JMP         loop32
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VCVTPS2PD   (SI), Z4
+	VCVTPS2PD   (DI), Z8
+	VFMADD231PD Z8, Z4, Z0
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPD Z1, Z0, Z0
+	VADDPD Z3, Z2, Z2
+	VADDPD Z2, Z0, Z0
+	HSUMZ(Z0, Y0, X0, Y1, X1)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X4, X4
+	VCVTSS2SD   (DI), X8, X8
+	VFMADD231SD X8, X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, ret+48(FP)
+	RET
+
+// func cosineAVX512(a, b []float32) (dot, magA, magB float64)
+TEXT ·cosineAVX512(SB), NOSPLIT, $0-72
+	MOVQ   a_base+0(FP), SI
+	MOVQ   a_len+8(FP), CX
+	MOVQ   b_base+24(FP), DI
+	VPXORQ Z0, Z0, Z0
+	VPXORQ Z1, Z1, Z1
+	VPXORQ Z2, Z2, Z2
+	VPXORQ Z3, Z3, Z3
+	VPXORQ Z4, Z4, Z4
+	VPXORQ Z5, Z5, Z5
+
+loop16:
+	CMPQ        CX, $16
+	JL          loop8
+	VCVTPS2PD   (SI), Z6
+	VCVTPS2PD   32(SI), Z7
+	VCVTPS2PD   (DI), Z8
+	VCVTPS2PD   32(DI), Z9
+	VFMADD231PD Z8, Z6, Z0
+	VFMADD231PD Z9, Z7, Z1
+	VFMADD231PD Z6, Z6, Z2
+	VFMADD231PD Z7, Z7, Z3
+	VFMADD231PD Z8, Z8, Z4
+	VFMADD231PD Z9, Z9, Z5
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VCVTPS2PD   (SI), Z6
+	VCVTPS2PD   (DI), Z8
+	VFMADD231PD Z8, Z6, Z0
+	VFMADD231PD Z6, Z6, Z2
+	VFMADD231PD Z8, Z8, Z4
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPD Z1, Z0, Z0
+	VADDPD Z3, Z2, Z2
+	VADDPD Z5, Z4, Z4
+	HSUMZ(Z0, Y0, X0, Y1, X1)
+	HSUMZ(Z2, Y2, X2, Y3, X3)
+	HSUMZ(Z4, Y4, X4, Y5, X5)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X6, X6
+	VCVTSS2SD   (DI), X8, X8
+	VFMADD231SD X8, X6, X0
+	VFMADD231SD X6, X6, X2
+	VFMADD231SD X8, X8, X4
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, dot+48(FP)
+	MOVSD X2, magA+56(FP)
+	MOVSD X4, magB+64(FP)
+	RET
+
+// func l2AVX512(a, b []float32) float64
+TEXT ·l2AVX512(SB), NOSPLIT, $0-56
+	MOVQ   a_base+0(FP), SI
+	MOVQ   a_len+8(FP), CX
+	MOVQ   b_base+24(FP), DI
+	VPXORQ Z0, Z0, Z0
+	VPXORQ Z1, Z1, Z1
+	VPXORQ Z2, Z2, Z2
+	VPXORQ Z3, Z3, Z3
+
+loop32:
+	CMPQ        CX, $32
+	JL          loop8
+	VCVTPS2PD   (SI), Z4
+	VCVTPS2PD   32(SI), Z5
+	VCVTPS2PD   64(SI), Z6
+	VCVTPS2PD   96(SI), Z7
+	VCVTPS2PD   (DI), Z8
+	VCVTPS2PD   32(DI), Z9
+	VCVTPS2PD   64(DI), Z10
+	VCVTPS2PD   96(DI), Z11
+	VSUBPD      Z8, Z4, Z4
+	VSUBPD      Z9, Z5, Z5
+	VSUBPD      Z10, Z6, Z6
+	VSUBPD      Z11, Z7, Z7
+	VFMADD231PD Z4, Z4, Z0
+	VFMADD231PD Z5, Z5, Z1
+	VFMADD231PD Z6, Z6, Z2
+	VFMADD231PD Z7, Z7, Z3
+	ADDQ        $128, SI
+	ADDQ        $128, DI
+	SUBQ        $32, CX
+	JMP         loop32
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VCVTPS2PD   (SI), Z4
+	VCVTPS2PD   (DI), Z8
+	VSUBPD      Z8, Z4, Z4
+	VFMADD231PD Z4, Z4, Z0
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPD Z1, Z0, Z0
+	VADDPD Z3, Z2, Z2
+	VADDPD Z2, Z0, Z0
+	HSUMZ(Z0, Y0, X0, Y1, X1)
+
+tail:
+	TESTQ       CX, CX
+	JE          done
+	VCVTSS2SD   (SI), X4, X4
+	VCVTSS2SD   (DI), X8, X8
+	VSUBSD      X8, X4, X4
+	VFMADD231SD X4, X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSD X0, ret+48(FP)
+	RET
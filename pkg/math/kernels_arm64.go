@@ -0,0 +1,21 @@
+//go:build !purego
+
+package math
+
+import "golang.org/x/sys/cpu"
+
+//go:noescape
+func dotNEON(a, b []float32) float64
+
+//go:noescape
+func cosineNEON(a, b []float32) (dot, magA, magB float64)
+
+//go:noescape
+func l2NEON(a, b []float32) float64
+
+func cpuKernels() []kernels {
+	if !cpu.ARM64.HasASIMD {
+		return nil
+	}
+	return []kernels{{name: "neon", dot: dotNEON, cosine: cosineNEON, l2: l2NEON}}
+}
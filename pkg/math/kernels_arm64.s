@@ -0,0 +1,190 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// Kernels widen float32 inputs to float64 (FCVTL) and accumulate with
+// FMLA, matching the precision of the generic Go kernels. Both slices have
+// the length of a, which is at least 1.
+
+// FCVTL and FCVTL2 widen the low and high two float32 lanes of Vn into the
+// float64 lanes of Vd. They are encoded by hand for older assemblers.
+#define FCVTL(d, n) WORD $(0x0E617800 | ((n)<<5) | (d))
+#define FCVTL2(d, n) WORD $(0x4E617800 | ((n)<<5) | (d))
+
+// HSUM adds the two float64 lanes of Vv into Fv, using R3 and Ft as
+// scratch.
+#define HSUM(v, f, ft) \
+	VMOV  v.D[1], R3; \
+	FMOVD R3, ft;     \
+	FADDD ft, f
+
+// func dotNEON(a, b []float32) float64
+TEXT ·dotNEON(SB), NOSPLIT, $0-56
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	MOVD b_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop8:
+	CMP    $8, R2
+	BLT    reduce
+	VLD1.P 32(R0), [V16.S4, V17.S4]
+	VLD1.P 32(R1), [V18.S4, V19.S4]
+	FCVTL(20, 16)
+	FCVTL2(21, 16)
+	FCVTL(22, 17)
+	FCVTL2(23, 17)
+	FCVTL(24, 18)
+	FCVTL2(25, 18)
+	FCVTL(26, 19)
+	FCVTL2(27, 19)
+	VFMLA  V24.D2, V20.D2, V0.D2
+	VFMLA  V25.D2, V21.D2, V1.D2
+	VFMLA  V26.D2, V22.D2, V2.D2
+	VFMLA  V27.D2, V23.D2, V3.D2
+	SUB    $8, R2
+	B      loop8
+
+reduce:
+	VFADD V1.D2, V0.D2, V0.D2
+	VFADD V3.D2, V2.D2, V2.D2
+	VFADD V2.D2, V0.D2, V0.D2
+	HSUM(V0, F0, F1)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F16
+	FMOVS.P 4(R1), F17
+	FCVTSD  F16, F16
+	FCVTSD  F17, F17
+	FMADDD  F17, F0, F16, F0
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVD F0, ret+48(FP)
+	RET
+
+// func cosineNEON(a, b []float32) (dot, magA, magB float64)
+TEXT ·cosineNEON(SB), NOSPLIT, $0-72
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	MOVD b_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+	VEOR V4.B16, V4.B16, V4.B16
+	VEOR V5.B16, V5.B16, V5.B16
+
+loop8:
+	CMP    $8, R2
+	BLT    reduce
+	VLD1.P 32(R0), [V16.S4, V17.S4]
+	VLD1.P 32(R1), [V18.S4, V19.S4]
+	FCVTL(20, 16)
+	FCVTL2(21, 16)
+	FCVTL(22, 17)
+	FCVTL2(23, 17)
+	FCVTL(24, 18)
+	FCVTL2(25, 18)
+	FCVTL(26, 19)
+	FCVTL2(27, 19)
+	VFMLA  V24.D2, V20.D2, V0.D2
+	VFMLA  V25.D2, V21.D2, V1.D2
+	VFMLA  V26.D2, V22.D2, V0.D2
+	VFMLA  V27.D2, V23.D2, V1.D2
+	VFMLA  V20.D2, V20.D2, V2.D2
+	VFMLA  V21.D2, V21.D2, V3.D2
+	VFMLA  V22.D2, V22.D2, V2.D2
+	VFMLA  V23.D2, V23.D2, V3.D2
+	VFMLA  V24.D2, V24.D2, V4.D2
+	VFMLA  V25.D2, V25.D2, V5.D2
+	VFMLA  V26.D2, V26.D2, V4.D2
+	VFMLA  V27.D2, V27.D2, V5.D2
+	SUB    $8, R2
+	B      loop8
+
+reduce:
+	VFADD V1.D2, V0.D2, V0.D2
+	VFADD V3.D2, V2.D2, V2.D2
+	VFADD V5.D2, V4.D2, V4.D2
+	HSUM(V0, F0, F1)
+	HSUM(V2, F2, F3)
+	HSUM(V4, F4, F5)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F16
+	FMOVS.P 4(R1), F17
+	FCVTSD  F16, F16
+	FCVTSD  F17, F17
+	FMADDD  F17, F0, F16, F0
+	FMADDD  F16, F2, F16, F2
+	FMADDD  F17, F4, F17, F4
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVD F0, dot+48(FP)
+	FMOVD F2, magA+56(FP)
+	FMOVD F4, magB+64(FP)
+	RET
+
+// func l2NEON(a, b []float32) float64
+TEXT ·l2NEON(SB), NOSPLIT, $0-56
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	MOVD b_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop8:
+	CMP    $8, R2
+	BLT    reduce
+	VLD1.P 32(R0), [V16.S4, V17.S4]
+	VLD1.P 32(R1), [V18.S4, V19.S4]
+	FCVTL(20, 16)
+	FCVTL2(21, 16)
+	FCVTL(22, 17)
+	FCVTL2(23, 17)
+	FCVTL(24, 18)
+	FCVTL2(25, 18)
+	FCVTL(26, 19)
+	FCVTL2(27, 19)
+	VFSUB  V24.D2, V20.D2, V20.D2
+	VFSUB  V25.D2, V21.D2, V21.D2
+	VFSUB  V26.D2, V22.D2, V22.D2
+	VFSUB  V27.D2, V23.D2, V23.D2
+	VFMLA  V20.D2, V20.D2, V0.D2
+	VFMLA  V21.D2, V21.D2, V1.D2
+	VFMLA  V22.D2, V22.D2, V2.D2
+	VFMLA  V23.D2, V23.D2, V3.D2
+	SUB    $8, R2
+	B      loop8
+
+reduce:
+	VFADD V1.D2, V0.D2, V0.D2
+	VFADD V3.D2, V2.D2, V2.D2
+	VFADD V2.D2, V0.D2, V0.D2
+	HSUM(V0, F0, F1)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F16
+	FMOVS.P 4(R1), F17
+	FCVTSD  F16, F16
+	FCVTSD  F17, F17
+	FSUBD   F17, F16, F16
+	FMADDD  F16, F0, F16, F0
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVD F0, ret+48(FP)
+	RET
@@ -0,0 +1,7 @@
+//go:build purego || !(amd64 || arm64)
+
+package math
+
+func cpuKernels() []kernels {
+	return nil
+}
@@ -0,0 +1,273 @@
+package math
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Quantization selects how a stored embedding is compressed.
+type Quantization string
+
+const (
+	// QuantizeNone keeps float32 values.
+	QuantizeNone Quantization = ""
+
+	// QuantizeInt8 scales each vector by its largest absolute value into
+	// int8, 4x smaller than float32. Cosine distances stay within ~0.01 of
+	// the float32 distance for typical embeddings.
+	QuantizeInt8 Quantization = "int8"
+
+	// QuantizeBinary keeps one sign bit per dimension, 32x smaller than
+	// float32. Distances are coarse estimates; pair it with rescoring.
+	QuantizeBinary Quantization = "binary"
+)
+
+// ParseQuantization validates a quantization name. "none" and "float32"
+// are accepted for QuantizeNone.
+func ParseQuantization(s string) (Quantization, error) {
+	switch s {
+	case "", "none", "float32":
+		return QuantizeNone, nil
+	case string(QuantizeInt8):
+		return QuantizeInt8, nil
+	case string(QuantizeBinary):
+		return QuantizeBinary, nil
+	}
+	return QuantizeNone, fmt.Errorf("unknown quantization %q (supported: none, int8, binary)", s)
+}
+
+// Blob format tags, stored as the first byte of an encoded Quantized.
+const (
+	blobInt8   byte = 1
+	blobBinary byte = 2
+)
+
+// Quantized is a compressed embedding. Exactly one of Int8 or Bits is set,
+// according to Kind.
+type Quantized struct {
+	Kind  Quantization
+	Dim   int
+	Scale float32  // int8: value = Int8[i] * Scale
+	Int8  []int8   // int8 values
+	Bits  []uint64 // binary: bit i set when dimension i is positive
+}
+
+// Quantize compresses v. QuantizeNone, or an empty v, returns the zero
+// Quantized.
+func Quantize(v []float32, kind Quantization) Quantized {
+	if len(v) == 0 {
+		return Quantized{}
+	}
+	switch kind {
+	case QuantizeInt8:
+		var maxAbs float32
+		for _, x := range v {
+			if x < 0 {
+				x = -x
+			}
+			if x > maxAbs {
+				maxAbs = x
+			}
+		}
+		q := Quantized{Kind: kind, Dim: len(v), Int8: make([]int8, len(v))}
+		if maxAbs == 0 {
+			return q
+		}
+		q.Scale = maxAbs / 127
+		inv := 127 / maxAbs
+		for i, x := range v {
+			q.Int8[i] = int8(math.Round(float64(x * inv)))
+		}
+		return q
+	case QuantizeBinary:
+		q := Quantized{Kind: kind, Dim: len(v), Bits: make([]uint64, (len(v)+63)/64)}
+		for i, x := range v {
+			if x > 0 {
+				q.Bits[i/64] |= 1 << uint(i%64)
+			}
+		}
+		return q
+	}
+	return Quantized{}
+}
+
+// IsZero reports whether q holds no embedding.
+func (q Quantized) IsZero() bool {
+	return q.Kind == QuantizeNone
+}
+
+// Dequantize returns an approximation of the original vector. Binary
+// vectors become ±1/sqrt(dim), which preserves cosine direction only.
+func (q Quantized) Dequantize() []float32 {
+	switch q.Kind {
+	case QuantizeInt8:
+		v := make([]float32, q.Dim)
+		for i, x := range q.Int8 {
+			v[i] = float32(x) * q.Scale
+		}
+		return v
+	case QuantizeBinary:
+		v := make([]float32, q.Dim)
+		unit := float32(1 / math.Sqrt(float64(q.Dim)))
+		for i := range v {
+			if q.Bits[i/64]&(1<<uint(i%64)) != 0 {
+				v[i] = unit
+			} else {
+				v[i] = -unit
+			}
+		}
+		return v
+	}
+	return nil
+}
+
+// Encode serialises q for BLOB storage: a format byte, the dimension, and
+// the packed values.
+func (q Quantized) Encode() []byte {
+	switch q.Kind {
+	case QuantizeInt8:
+		buf := make([]byte, 9+q.Dim)
+		buf[0] = blobInt8
+		binary.LittleEndian.PutUint32(buf[1:], uint32(q.Dim))
+		binary.LittleEndian.PutUint32(buf[5:], math.Float32bits(q.Scale))
+		for i, x := range q.Int8 {
+			buf[9+i] = byte(x)
+		}
+		return buf
+	case QuantizeBinary:
+		buf := make([]byte, 5+8*len(q.Bits))
+		buf[0] = blobBinary
+		binary.LittleEndian.PutUint32(buf[1:], uint32(q.Dim))
+		for i, w := range q.Bits {
+			binary.LittleEndian.PutUint64(buf[5+8*i:], w)
+		}
+		return buf
+	}
+	return nil
+}
+
+// DecodeQuantized parses a blob written by Encode. It returns false for
+// empty or malformed input.
+func DecodeQuantized(buf []byte) (Quantized, bool) {
+	if len(buf) < 5 {
+		return Quantized{}, false
+	}
+	dim := int(binary.LittleEndian.Uint32(buf[1:]))
+	switch buf[0] {
+	case blobInt8:
+		if len(buf) != 9+dim {
+			return Quantized{}, false
+		}
+		q := Quantized{
+			Kind:  QuantizeInt8,
+			Dim:   dim,
+			Scale: math.Float32frombits(binary.LittleEndian.Uint32(buf[5:])),
+			Int8:  make([]int8, dim),
+		}
+		for i := range q.Int8 {
+			q.Int8[i] = int8(buf[9+i])
+		}
+		return q, true
+	case blobBinary:
+		words := (dim + 63) / 64
+		if len(buf) != 5+8*words {
+			return Quantized{}, false
+		}
+		q := Quantized{Kind: QuantizeBinary, Dim: dim, Bits: make([]uint64, words)}
+		for i := range q.Bits {
+			q.Bits[i] = binary.LittleEndian.Uint64(buf[5+8*i:])
+		}
+		return q, true
+	}
+	return Quantized{}, false
+}
+
+// QuantizedCosineDistance estimates the cosine distance between two
+// vectors quantised the same way. Mismatched kinds or dimensions return
+// the maximum distance, 2.
+func QuantizedCosineDistance(a, b Quantized) float64 {
+	if a.Kind != b.Kind || a.Dim != b.Dim || a.Dim == 0 {
+		return 2.0
+	}
+	switch a.Kind {
+	case QuantizeInt8:
+		return CosineDistanceInt8(a.Int8, b.Int8)
+	case QuantizeBinary:
+		return BinaryCosineDistance(a.Bits, b.Bits, a.Dim)
+	}
+	return 2.0
+}
+
+// CosineDistanceInt8 computes cosine distance between int8 vectors. The
+// per-vector scales cancel out, so they are not needed.
+func CosineDistanceInt8(a, b []int8) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 2.0
+	}
+	var dot, magA, magB int64
+	for i, x := range a {
+		y := int64(b[i])
+		dot += int64(x) * y
+		magA += int64(x) * int64(x)
+		magB += y * y
+	}
+	denom := math.Sqrt(float64(magA) * float64(magB))
+	if denom == 0 {
+		return 2.0
+	}
+	similarity := float64(dot) / denom
+	if similarity > 1.0 {
+		similarity = 1.0
+	} else if similarity < -1.0 {
+		similarity = -1.0
+	}
+	return 1.0 - similarity
+}
+
+// HammingDistance counts the differing bits of two bit vectors.
+func HammingDistance(a, b []uint64) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	d := 0
+	for i := 0; i < n; i++ {
+		d += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return d
+}
+
+// BinaryCosineDistance estimates cosine distance from sign bits: the
+// fraction of differing bits approximates angle/π for random-hyperplane
+// style embeddings.
+func BinaryCosineDistance(a, b []uint64, dim int) float64 {
+	if dim == 0 || len(a) != len(b) {
+		return 2.0
+	}
+	angle := math.Pi * float64(HammingDistance(a, b)) / float64(dim)
+	return 1.0 - math.Cos(angle)
+}
+
+// QuantizedQuery compares a full-precision query with stored vectors of
+// any quantization, quantising the query once per kind it meets.
+type QuantizedQuery struct {
+	v     []float32
+	forms map[Quantization]Quantized
+}
+
+// NewQuantizedQuery wraps a query vector.
+func NewQuantizedQuery(v []float32) *QuantizedQuery {
+	return &QuantizedQuery{v: v, forms: make(map[Quantization]Quantized, 1)}
+}
+
+// Distance estimates the cosine distance between the query and stored.
+func (q *QuantizedQuery) Distance(stored Quantized) float64 {
+	form, ok := q.forms[stored.Kind]
+	if !ok {
+		form = Quantize(q.v, stored.Kind)
+		q.forms[stored.Kind] = form
+	}
+	return QuantizedCosineDistance(form, stored)
+}
@@ -0,0 +1,98 @@
+package math
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for d := range vectors[i] {
+			vectors[i][d] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func TestQuantizeInt8_Accuracy(t *testing.T) {
+	vectors := randomVectors(200, 384, 1)
+	query := NewQuantizedQuery(vectors[0])
+
+	var worst float64
+	for _, v := range vectors[1:] {
+		exact := CosineDistance(vectors[0], v)
+		approx := query.Distance(Quantize(v, QuantizeInt8))
+		if diff := exact - approx; diff > worst {
+			worst = diff
+		} else if -diff > worst {
+			worst = -diff
+		}
+	}
+	if worst > 0.01 {
+		t.Errorf("int8 distance error %.4f exceeds 0.01", worst)
+	}
+}
+
+func TestQuantizeBinary_Accuracy(t *testing.T) {
+	// Sign bits only estimate the angle, so allow a wide margin, but
+	// near-duplicates must still rank ahead of unrelated vectors.
+	vectors := randomVectors(100, 768, 2)
+	rng := rand.New(rand.NewSource(3))
+	near := make([]float32, len(vectors[0]))
+	for d, x := range vectors[0] {
+		near[d] = x + float32(rng.NormFloat64()*0.05)
+	}
+
+	query := NewQuantizedQuery(vectors[0])
+	nearDist := query.Distance(Quantize(near, QuantizeBinary))
+	for _, v := range vectors[1:] {
+		exact := CosineDistance(vectors[0], v)
+		approx := query.Distance(Quantize(v, QuantizeBinary))
+		if diff := exact - approx; diff > 0.15 || diff < -0.15 {
+			t.Fatalf("binary distance %.3f too far from float32 %.3f", approx, exact)
+		}
+		if approx <= nearDist {
+			t.Fatalf("unrelated vector (%.3f) ranked ahead of near-duplicate (%.3f)", approx, nearDist)
+		}
+	}
+}
+
+func TestQuantized_EncodeRoundtrip(t *testing.T) {
+	v := randomVectors(1, 100, 4)[0]
+	for _, kind := range []Quantization{QuantizeInt8, QuantizeBinary} {
+		q := Quantize(v, kind)
+		decoded, ok := DecodeQuantized(q.Encode())
+		if !ok {
+			t.Fatalf("%s: decode failed", kind)
+		}
+		if QuantizedCosineDistance(q, decoded) > 1e-9 {
+			t.Errorf("%s: roundtrip changed the vector", kind)
+		}
+		if got := CosineDistance(v, decoded.Dequantize()); kind == QuantizeInt8 && got > 0.001 {
+			t.Errorf("int8 dequantize drifted by %.4f", got)
+		}
+	}
+
+	if _, ok := DecodeQuantized([]byte{1, 2, 3}); ok {
+		t.Error("expected short blob to be rejected")
+	}
+	blob := Quantize(v, QuantizeInt8).Encode()
+	if _, ok := DecodeQuantized(blob[:len(blob)-1]); ok {
+		t.Error("expected a truncated blob to be rejected")
+	}
+}
+
+func TestParseQuantization(t *testing.T) {
+	for in, want := range map[string]Quantization{"": QuantizeNone, "none": QuantizeNone, "int8": QuantizeInt8, "binary": QuantizeBinary} {
+		got, err := ParseQuantization(in)
+		if err != nil || got != want {
+			t.Errorf("ParseQuantization(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseQuantization("fp16"); err == nil {
+		t.Error("expected error for unknown quantization")
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 // CosineDistance computes cosine distance between two float32 vectors.
 // Returns a value in [0, 2] where 0 = identical, 2 = opposite.
-// Optimized for float32 to minimize memory usage.
+// Sums are accumulated in float64 by the fastest kernel for this CPU.
 func CosineDistance(a, b []float32) float64 {
 	if len(a) == 0 || len(b) == 0 {
 		return 2.0 // Maximum distance for empty input
@@ -21,36 +21,13 @@ func CosineDistance(a, b []float32) float64 {
 	}
 
 	// Compute dot product and magnitudes in a single pass
-	var dot, magA, magB float64
-	n := len(a)
-
-	// Process 4 elements at a time for better CPU pipelining
-	i := 0
-	for ; i <= n-4; i += 4 {
-		dot += float64(a[i])*float64(b[i]) +
-			float64(a[i+1])*float64(b[i+1]) +
-			float64(a[i+2])*float64(b[i+2]) +
-			float64(a[i+3])*float64(b[i+3])
-
-		magA += float64(a[i])*float64(a[i]) +
-			float64(a[i+1])*float64(a[i+1]) +
-			float64(a[i+2])*float64(a[i+2]) +
-			float64(a[i+3])*float64(a[i+3])
-
-		magB += float64(b[i])*float64(b[i]) +
-			float64(b[i+1])*float64(b[i+1]) +
-			float64(b[i+2])*float64(b[i+2]) +
-			float64(b[i+3])*float64(b[i+3])
-	}
-
-	// Handle remaining elements
-	for ; i < n; i++ {
-		dot += float64(a[i]) * float64(b[i])
-		magA += float64(a[i]) * float64(a[i])
-		magB += float64(b[i]) * float64(b[i])
-	}
+	dot, magA, magB := active.cosine(a, b)
+	return cosineFromSums(dot, magA, magB)
+}
 
-	// Compute cosine similarity
+// cosineFromSums turns a dot product and squared magnitudes into a cosine
+// distance.
+func cosineFromSums(dot, magA, magB float64) float64 {
 	denom := math.Sqrt(magA * magB)
 	if denom == 0 {
 		return 2.0
@@ -79,26 +56,7 @@ func EuclideanDistance(a, b []float32) float64 {
 	if len(a) != len(b) || len(a) == 0 {
 		return math.MaxFloat64
 	}
-
-	var sum float64
-	n := len(a)
-
-	// Process 4 elements at a time
-	i := 0
-	for ; i <= n-4; i += 4 {
-		d0 := float64(a[i]) - float64(b[i])
-		d1 := float64(a[i+1]) - float64(b[i+1])
-		d2 := float64(a[i+2]) - float64(b[i+2])
-		d3 := float64(a[i+3]) - float64(b[i+3])
-		sum += d0*d0 + d1*d1 + d2*d2 + d3*d3
-	}
-
-	for ; i < n; i++ {
-		d := float64(a[i]) - float64(b[i])
-		sum += d * d
-	}
-
-	return sum
+	return active.l2(a, b)
 }
 
 // DotProduct computes inner product between two float32 vectors.
@@ -106,24 +64,7 @@ func DotProduct(a, b []float32) float64 {
 	if len(a) != len(b) || len(a) == 0 {
 		return 0
 	}
-
-	var sum float64
-	n := len(a)
-
-	// Process 4 elements at a time
-	i := 0
-	for ; i <= n-4; i += 4 {
-		sum += float64(a[i])*float64(b[i]) +
-			float64(a[i+1])*float64(b[i+1]) +
-			float64(a[i+2])*float64(b[i+2]) +
-			float64(a[i+3])*float64(b[i+3])
-	}
-
-	for ; i < n; i++ {
-		sum += float64(a[i]) * float64(b[i])
-	}
-
-	return sum
+	return active.dot(a, b)
 }
 
 // NormalizeInPlace normalizes a vector to unit length in-place.
@@ -0,0 +1,132 @@
+package math
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// kernelTolerance bounds the relative difference between kernel sets, which
+// all accumulate in float64 and differ only in summation order.
+const kernelTolerance = 1e-12
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) <= kernelTolerance*math.Max(1, math.Abs(want))
+}
+
+func TestKernels_MatchGeneric(t *testing.T) {
+	available := cpuKernels()
+	if len(available) == 0 {
+		t.Skipf("no vector kernels for this CPU (using %s)", Implementation())
+	}
+
+	lengths := []int{1, 2, 3, 4, 5, 7, 8, 9, 15, 16, 17, 31, 32, 33, 63, 64, 65, 100, 384, 1536, 3072}
+	for _, k := range available {
+		for _, n := range lengths {
+			// Offset by one element so loads are not 16-byte aligned.
+			vectors := randomVectors(2, n+1, int64(n))
+			a, b := vectors[0][1:], vectors[1][1:]
+
+			if got, want := k.dot(a, b), dotGeneric(a, b); !closeTo(got, want) {
+				t.Errorf("%s dot n=%d: got %v, want %v", k.name, n, got, want)
+			}
+			if got, want := k.l2(a, b), l2Generic(a, b); !closeTo(got, want) {
+				t.Errorf("%s l2 n=%d: got %v, want %v", k.name, n, got, want)
+			}
+			dot, magA, magB := k.cosine(a, b)
+			wantDot, wantA, wantB := cosineGeneric(a, b)
+			if !closeTo(dot, wantDot) || !closeTo(magA, wantA) || !closeTo(magB, wantB) {
+				t.Errorf("%s cosine n=%d: got (%v, %v, %v), want (%v, %v, %v)",
+					k.name, n, dot, magA, magB, wantDot, wantA, wantB)
+			}
+		}
+	}
+}
+
+func TestCosineDistance_Identical(t *testing.T) {
+	v := randomVectors(1, 1536, 5)[0]
+	if d := CosineDistance(v, v); d > 1e-12 {
+		t.Errorf("expected 0 distance for identical vectors, got %v", d)
+	}
+	if d := CosineDistance(v, nil); d != 2.0 {
+		t.Errorf("expected 2 for an empty vector, got %v", d)
+	}
+}
+
+func TestCosineDistanceMatrix(t *testing.T) {
+	// Enough vectors to take the parallel path, with an empty vector and a
+	// shorter one among them.
+	vectors := randomVectors(parallelMatrixAbove+10, 96, 6)
+	vectors[3] = nil
+	vectors[7] = vectors[7][:50]
+
+	matrix := CosineDistanceMatrix(vectors)
+	for i := range vectors {
+		if matrix[i][i] != 0 {
+			t.Fatalf("expected 0 on the diagonal, got %v at %d", matrix[i][i], i)
+		}
+		for j := range vectors {
+			if i == j {
+				continue
+			}
+			if want := CosineDistance(vectors[i], vectors[j]); math.Abs(matrix[i][j]-want) > 1e-9 {
+				t.Fatalf("matrix[%d][%d] = %v, want %v", i, j, matrix[i][j], want)
+			}
+		}
+	}
+
+	query := vectors[0]
+	dists := CosineDistances(nil, query, vectors)
+	for j, d := range dists {
+		if want := CosineDistance(query, vectors[j]); d != want {
+			t.Fatalf("CosineDistances[%d] = %v, want %v", j, d, want)
+		}
+	}
+}
+
+func BenchmarkKernels(b *testing.B) {
+	sets := append([]kernels{genericKernels}, cpuKernels()...)
+	for _, dim := range []int{384, 1536, 3072} {
+		vectors := randomVectors(2, dim, 7)
+		x, y := vectors[0], vectors[1]
+		for _, k := range sets {
+			b.Run(fmt.Sprintf("%s/dot/%d", k.name, dim), func(b *testing.B) {
+				b.SetBytes(int64(8 * dim))
+				for i := 0; i < b.N; i++ {
+					_ = k.dot(x, y)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/cosine/%d", k.name, dim), func(b *testing.B) {
+				b.SetBytes(int64(8 * dim))
+				for i := 0; i < b.N; i++ {
+					_, _, _ = k.cosine(x, y)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCosineDistanceMatrix_500x1536(b *testing.B) {
+	vectors := randomVectors(500, 1536, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = CosineDistanceMatrix(vectors)
+	}
+}
+
+func BenchmarkCosineDistanceMatrix_Pairwise_500x1536(b *testing.B) {
+	vectors := randomVectors(500, 1536, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matrix := make([][]float64, len(vectors))
+		for r := range matrix {
+			matrix[r] = make([]float64, len(vectors))
+		}
+		for r := range vectors {
+			for c := r + 1; c < len(vectors); c++ {
+				d := CosineDistance(vectors[r], vectors[c])
+				matrix[r][c], matrix[c][r] = d, d
+			}
+		}
+	}
+}
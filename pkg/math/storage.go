@@ -0,0 +1,106 @@
+package math
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// EncodeEmbedding encodes v as little-endian float32 values, the blob
+// format the SQLite stores keep embeddings in. An empty v encodes to nil.
+func EncodeEmbedding(v []float32) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	buf := make([]byte, len(v)*4)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// DecodeEmbedding decodes a blob written by EncodeEmbedding. Empty or
+// malformed blobs decode to nil.
+func DecodeEmbedding(buf []byte) []float32 {
+	if len(buf) == 0 || len(buf)%4 != 0 {
+		return nil
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return v
+}
+
+// rescoreMargins widens the quantised cutoff so vectors whose quantised
+// distance overestimates the true distance are still rescored.
+var rescoreMargins = map[Quantization]float64{
+	QuantizeInt8:   0.02,
+	QuantizeBinary: 0.15,
+}
+
+// RescoreMargin returns how far past a distance cutoff a quantised
+// estimate of kind may fall and still be worth rescoring at full
+// precision. It is 0 for QuantizeNone.
+func RescoreMargin(kind Quantization) float64 {
+	return rescoreMargins[kind]
+}
+
+// EmbeddingColumns returns the float32 and quantised blobs to store for v.
+// Without quantization only the float32 blob is stored; with it the
+// float32 blob is kept only when rescore is set.
+func EmbeddingColumns(v []float32, kind Quantization, rescore bool) (full, quantized []byte) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	if kind == QuantizeNone {
+		return EncodeEmbedding(v), nil
+	}
+	quantized = Quantize(v, kind).Encode()
+	if rescore {
+		full = EncodeEmbedding(v)
+	}
+	return full, quantized
+}
+
+// Requantize converts stored embedding blobs to kind, as EmbeddingColumns
+// would store them. ok is false when the blobs need no change, or when
+// there is no float32 blob to convert from.
+func Requantize(full, quantized []byte, kind Quantization, rescore bool) (newFull, newQuantized []byte, ok bool) {
+	if kind == QuantizeNone {
+		return nil, nil, false
+	}
+	if current, valid := DecodeQuantized(quantized); valid && current.Kind == kind && rescore {
+		return nil, nil, false
+	}
+	v := DecodeEmbedding(full)
+	if len(v) == 0 {
+		return nil, nil, false
+	}
+	newFull, newQuantized = EmbeddingColumns(v, kind, rescore)
+	return newFull, newQuantized, true
+}
+
+// StoredEmbedding returns a stored embedding at full precision, or an
+// approximation from its quantised form when only that is stored.
+func StoredEmbedding(full, quantized []byte) []float32 {
+	if v := DecodeEmbedding(full); len(v) > 0 {
+		return v
+	}
+	if q, ok := DecodeQuantized(quantized); ok {
+		return q.Dequantize()
+	}
+	return nil
+}
+
+// StoredDistance compares the query with a stored embedding, using the
+// quantised form when there is one. approx reports whether the distance
+// is a quantised estimate; ok is false when there is no embedding.
+func (q *QuantizedQuery) StoredDistance(full, quantized []byte) (dist float64, approx, ok bool) {
+	if stored, valid := DecodeQuantized(quantized); valid {
+		return q.Distance(stored), true, true
+	}
+	if v := DecodeEmbedding(full); len(v) > 0 {
+		return CosineDistance(q.v, v), false, true
+	}
+	return 0, false, false
+}
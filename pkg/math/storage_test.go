@@ -0,0 +1,73 @@
+package math
+
+import "testing"
+
+func TestEmbeddingRoundtrip(t *testing.T) {
+	original := []float32{0.1, 0.2, 0.3, -0.5, 1.0}
+	decoded := DecodeEmbedding(EncodeEmbedding(original))
+
+	if len(decoded) != len(original) {
+		t.Fatalf("length mismatch: %d vs %d", len(decoded), len(original))
+	}
+	for i := range original {
+		if decoded[i] != original[i] {
+			t.Errorf("index %d: expected %f, got %f", i, original[i], decoded[i])
+		}
+	}
+	if DecodeEmbedding([]byte{1, 2, 3}) != nil {
+		t.Error("expected a malformed blob to decode to nil")
+	}
+}
+
+func TestEmbeddingColumns(t *testing.T) {
+	v := randomVectors(1, 64, 3)[0]
+
+	full, q := EmbeddingColumns(v, QuantizeNone, true)
+	if len(full) != 256 || q != nil {
+		t.Errorf("expected only the float32 blob without quantization, got %d and %d bytes", len(full), len(q))
+	}
+	full, q = EmbeddingColumns(v, QuantizeInt8, false)
+	if full != nil || len(q) == 0 {
+		t.Errorf("expected only the quantised blob without rescore, got %d and %d bytes", len(full), len(q))
+	}
+	full, q = EmbeddingColumns(v, QuantizeBinary, true)
+	if len(full) != 256 || len(q) == 0 {
+		t.Errorf("expected both blobs with rescore, got %d and %d bytes", len(full), len(q))
+	}
+
+	query := NewQuantizedQuery(v)
+	if dist, approx, ok := query.StoredDistance(full, q); !ok || !approx || dist > 0.01 {
+		t.Errorf("expected a quantised estimate near 0, got %v (approx=%v, ok=%v)", dist, approx, ok)
+	}
+	if dist, approx, ok := query.StoredDistance(full, nil); !ok || approx || dist > 1e-6 {
+		t.Errorf("expected an exact distance of 0, got %v (approx=%v, ok=%v)", dist, approx, ok)
+	}
+	if _, _, ok := query.StoredDistance(nil, nil); ok {
+		t.Error("expected no distance without an embedding")
+	}
+}
+
+func TestRequantize(t *testing.T) {
+	v := randomVectors(1, 32, 4)[0]
+	full, _ := EmbeddingColumns(v, QuantizeNone, false)
+
+	newFull, newQ, ok := Requantize(full, nil, QuantizeInt8, false)
+	if !ok || newFull != nil || len(newQ) == 0 {
+		t.Fatalf("expected float32 converted to int8 only, got ok=%v", ok)
+	}
+	if _, _, ok := Requantize(nil, newQ, QuantizeBinary, false); ok {
+		t.Error("expected no conversion without a float32 blob")
+	}
+
+	full, q := EmbeddingColumns(v, QuantizeInt8, true)
+	if _, _, ok := Requantize(full, q, QuantizeInt8, true); ok {
+		t.Error("expected blobs already in the target form left alone")
+	}
+	if _, newQ, ok := Requantize(full, q, QuantizeBinary, true); !ok || len(newQ) == 0 {
+		t.Error("expected a kept float32 blob re-quantised to the new kind")
+	}
+
+	if RescoreMargin(QuantizeNone) != 0 || RescoreMargin(QuantizeBinary) <= RescoreMargin(QuantizeInt8) {
+		t.Error("expected wider rescore margins for coarser quantization")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/Siddhant-K-code/distill/pkg/compress"
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -86,7 +88,7 @@ func (w *DecayWorker) runOnce(ctx context.Context) error {
 	// Decay to summary: compress old full-text memories.
 	if w.cfg.SummaryAge > 0 {
 		summaryBefore := now.Add(-w.cfg.SummaryAge).Format(time.RFC3339Nano)
-		if err := w.decayRows(ctx, summaryBefore, DecayFull, DecaySummary, extractSummary); err != nil {
+		if err := w.decayRows(ctx, summaryBefore, DecayFull, DecaySummary, w.summaryFunc(ctx)); err != nil {
 			return err
 		}
 	}
@@ -98,7 +100,7 @@ func (w *DecayWorker) runOnce(ctx context.Context) error {
 // emits EventEvicted for each removed entry.
 func (w *DecayWorker) evictRows(ctx context.Context, cutoff string) error {
 	rows, err := w.store.db.QueryContext(ctx,
-		"SELECT id, LENGTH(text) FROM memories WHERE last_referenced < ? AND decay_level >= ?",
+		"SELECT id, text FROM memories WHERE last_referenced < ? AND decay_level >= ?",
 		cutoff, int(DecayKeywords),
 	)
 	if err != nil {
@@ -106,13 +108,12 @@ func (w *DecayWorker) evictRows(ctx context.Context, cutoff string) error {
 	}
 
 	type entry struct {
-		id     string
-		length int
+		id, text string
 	}
 	var entries []entry
 	for rows.Next() {
 		var e entry
-		if err := rows.Scan(&e.id, &e.length); err != nil {
+		if err := rows.Scan(&e.id, &e.text); err != nil {
 			continue
 		}
 		entries = append(entries, e)
@@ -124,7 +125,7 @@ func (w *DecayWorker) evictRows(ctx context.Context, cutoff string) error {
 		w.store.emit(MemoryEvent{
 			Type:         EventEvicted,
 			EntryID:      e.id,
-			TokensBefore: (e.length + 3) / 4,
+			TokensBefore: estimateTokens(e.text),
 			TokensAfter:  0,
 			OccurredAt:   time.Now().UTC(),
 		})
@@ -166,8 +167,8 @@ func (w *DecayWorker) decayRows(ctx context.Context, cutoff string, fromLevel, t
 		w.store.emit(MemoryEvent{
 			Type:             EventCompressed,
 			EntryID:          e.id,
-			TokensBefore:     (len(e.text) + 3) / 4,
-			TokensAfter:      (len(compressed) + 3) / 4,
+			TokensBefore:     estimateTokens(e.text),
+			TokensAfter:      estimateTokens(compressed),
 			CompressionLevel: toLevel,
 			OccurredAt:       time.Now().UTC(),
 		})
@@ -176,6 +177,21 @@ func (w *DecayWorker) decayRows(ctx context.Context, cutoff string, fromLevel, t
 	return nil
 }
 
+// summaryFunc returns the transform for the summary level: the configured
+// summarizer's paragraph summary, or extractSummary when it is unset or
+// fails.
+func (w *DecayWorker) summaryFunc(ctx context.Context) func(string) string {
+	if w.cfg.Summarizer == nil {
+		return extractSummary
+	}
+	return func(text string) string {
+		if s, err := w.cfg.Summarizer.SummarizeText(ctx, text, summarize.LevelParagraph); err == nil && s != "" {
+			return s
+		}
+		return extractSummary(text)
+	}
+}
+
 // summaryCompressor is reused across decay passes to avoid per-call allocation.
 var summaryCompressor = compress.NewExtractiveCompressor()
 
@@ -194,49 +210,8 @@ func extractSummary(text string) string {
 	return text
 }
 
-// extractKeywords produces a keyword-only representation.
-// Keeps words that appear significant (longer words, capitalized, numbers).
+// extractKeywords produces a keyword-only representation: up to 20
+// lower-cased content words, using the stopwords of the text's language.
 func extractKeywords(text string) string {
-	words := strings.Fields(text)
-	seen := make(map[string]bool)
-	var keywords []string
-
-	for _, w := range words {
-		lower := strings.ToLower(strings.Trim(w, ".,;:!?\"'()[]{}"))
-		if lower == "" || len(lower) < 4 {
-			continue
-		}
-		if isStopWord(lower) {
-			continue
-		}
-		if seen[lower] {
-			continue
-		}
-		seen[lower] = true
-		keywords = append(keywords, lower)
-	}
-
-	// Limit to ~20 keywords
-	if len(keywords) > 20 {
-		keywords = keywords[:20]
-	}
-
-	return strings.Join(keywords, ", ")
-}
-
-// stopWords is the set of common English stop words filtered during keyword extraction.
-var stopWords = map[string]bool{
-	"that": true, "this": true, "with": true, "from": true,
-	"have": true, "been": true, "were": true, "they": true,
-	"their": true, "which": true, "would": true, "there": true,
-	"about": true, "could": true, "other": true, "into": true,
-	"more": true, "some": true, "than": true, "them": true,
-	"very": true, "when": true, "what": true, "your": true,
-	"also": true, "each": true, "does": true, "will": true,
-	"just": true, "should": true, "because": true, "these": true,
-}
-
-// isStopWord returns true for common English stop words.
-func isStopWord(w string) bool {
-	return stopWords[w]
+	return strings.ToLower(strings.Join(nlp.Keywords(text, 20), ", "))
 }
@@ -2,10 +2,10 @@ package memory
 
 import (
 	"crypto/rand"
-	"encoding/binary"
 	"encoding/hex"
-	"math"
 	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 )
 
 // generateID creates a random 16-char hex ID with a time prefix for ordering.
@@ -22,32 +22,8 @@ func generateID() string {
 	return hex.EncodeToString(b)
 }
 
-// encodeEmbedding converts a float32 slice to a byte slice for SQLite BLOB storage.
-func encodeEmbedding(emb []float32) []byte {
-	if len(emb) == 0 {
-		return nil
-	}
-	buf := make([]byte, len(emb)*4)
-	for i, v := range emb {
-		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
-	}
-	return buf
-}
-
-// decodeEmbedding converts a byte slice back to a float32 slice.
-func decodeEmbedding(buf []byte) []float32 {
-	if len(buf) == 0 || len(buf)%4 != 0 {
-		return nil
-	}
-	emb := make([]float32, len(buf)/4)
-	for i := range emb {
-		emb[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
-	}
-	return emb
-}
-
-// estimateTokens returns a rough token count for a text string.
-// Uses the same heuristic as pkg/compress: ~4 chars per token.
+// estimateTokens counts tokens with the default tokenizer shared by all
+// packages (see pkg/tokenizer).
 func estimateTokens(text string) int {
-	return (len(text) + 3) / 4
+	return tokenizer.Count(text)
 }
@@ -466,21 +466,6 @@ func TestRecall_CacheBoundaryHint(t *testing.T) {
 	}
 }
 
-func TestEmbeddingRoundtrip(t *testing.T) {
-	original := []float32{0.1, 0.2, 0.3, -0.5, 1.0}
-	encoded := encodeEmbedding(original)
-	decoded := decodeEmbedding(encoded)
-
-	if len(decoded) != len(original) {
-		t.Fatalf("length mismatch: %d vs %d", len(decoded), len(original))
-	}
-	for i := range original {
-		if decoded[i] != original[i] {
-			t.Errorf("index %d: expected %f, got %f", i, original[i], decoded[i])
-		}
-	}
-}
-
 func TestEmptyStore(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
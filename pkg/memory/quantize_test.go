@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+)
+
+func newQuantizedStore(t *testing.T, dsn string, quant distillmath.Quantization, rescore bool) *SQLiteStore {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.DedupThreshold = 0.15
+	cfg.Quantization = quant
+	cfg.Rescore = rescore
+	s, err := NewSQLiteStore(dsn, cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// rankedEntries returns n entries on their own axes, too far apart to be
+// deduplicated, sharing a component along the last axis that grows with
+// i so that rankedQuery ranks them in reverse order.
+func rankedEntries(n int) []StoreEntry {
+	entries := make([]StoreEntry, n)
+	for i := range entries {
+		emb := make([]float32, 64)
+		emb[i] = 1
+		emb[63] = 0.5 + 0.1*float32(i)
+		entries[i] = StoreEntry{Text: fmt.Sprintf("memory %d", i), Embedding: emb}
+	}
+	return entries
+}
+
+func rankedQuery() []float32 {
+	q := make([]float32, 64)
+	q[63] = 1
+	return q
+}
+
+func recallTexts(t *testing.T, s *SQLiteStore, query []float32) []string {
+	t.Helper()
+	recall, err := s.Recall(context.Background(), RecallRequest{
+		Query:          "q",
+		QueryEmbedding: query,
+		MaxResults:     5,
+		RecencyWeight:  -1,
+	})
+	if err != nil {
+		t.Fatalf("Recall: %v", err)
+	}
+	texts := make([]string, len(recall.Memories))
+	for i, m := range recall.Memories {
+		texts[i] = m.Text
+	}
+	return texts
+}
+
+func TestQuantizedRecall_MatchesFloat32(t *testing.T) {
+	ctx := context.Background()
+	entries := rankedEntries(8)
+	query := rankedQuery()
+
+	baseline := newQuantizedStore(t, ":memory:", distillmath.QuantizeNone, false)
+	if _, err := baseline.Store(ctx, StoreRequest{Entries: entries}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	want := fmt.Sprint(recallTexts(t, baseline, query))
+	if want != "[memory 7 memory 6 memory 5 memory 4 memory 3]" {
+		t.Fatalf("unexpected float32 baseline order %s", want)
+	}
+
+	for _, tc := range []struct {
+		quant   distillmath.Quantization
+		rescore bool
+	}{
+		{distillmath.QuantizeInt8, false},
+		{distillmath.QuantizeInt8, true},
+		{distillmath.QuantizeBinary, true},
+	} {
+		s := newQuantizedStore(t, ":memory:", tc.quant, tc.rescore)
+		if _, err := s.Store(ctx, StoreRequest{Entries: entries}); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+		if got := fmt.Sprint(recallTexts(t, s, query)); got != want {
+			t.Errorf("%s rescore=%v: recall order %s, float32 order %s", tc.quant, tc.rescore, got, want)
+		}
+	}
+}
+
+func TestQuantizedDedup(t *testing.T) {
+	ctx := context.Background()
+	s := newQuantizedStore(t, ":memory:", distillmath.QuantizeInt8, false)
+
+	emb := makeEmbedding(0, 64)
+	if _, err := s.Store(ctx, StoreRequest{Entries: []StoreEntry{{Text: "JWT uses RS256", Embedding: emb}}}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	r, err := s.Store(ctx, StoreRequest{Entries: []StoreEntry{
+		{Text: "Tokens are signed with RS256", Embedding: makeEmbedding(0.01, 64)},
+		{Text: "Payments use Stripe", Embedding: makeEmbedding(math.Pi/2, 64)},
+	}})
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if r.Deduplicated != 1 || r.Stored != 1 {
+		t.Errorf("expected 1 deduplicated and 1 stored, got %d and %d", r.Deduplicated, r.Stored)
+	}
+}
+
+func TestQuantizeEmbeddings_Migration(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "memory.db")
+	entries := rankedEntries(6)
+	query := rankedQuery()
+
+	s := newQuantizedStore(t, dsn, distillmath.QuantizeNone, false)
+	if _, err := s.Store(ctx, StoreRequest{Entries: entries}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	want := fmt.Sprint(recallTexts(t, s, query))
+	_ = s.Close()
+
+	// Reopening with quantization converts the existing float32 rows.
+	s = newQuantizedStore(t, dsn, distillmath.QuantizeInt8, false)
+	var floats, quantized int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(embedding), COUNT(embedding_q) FROM memories",
+	).Scan(&floats, &quantized); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if floats != 0 || quantized != len(entries) {
+		t.Errorf("expected 0 float32 and %d quantised rows, got %d and %d", len(entries), floats, quantized)
+	}
+	if got := fmt.Sprint(recallTexts(t, s, query)); got != want {
+		t.Errorf("recall after migration %s, before %s", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -106,13 +107,98 @@ func (s *SQLiteStore) migrate() error {
 		{"superseded_by", "TEXT DEFAULT ''"},
 		{"expires_at", "TEXT DEFAULT ''"},
 		{"sensitivity", "INTEGER DEFAULT 0"},
+		{"embedding_q", "BLOB"},
 	} {
 		_, _ = s.db.Exec("ALTER TABLE memories ADD COLUMN " + col.name + " " + col.def)
 	}
 
+	if _, err := s.QuantizeEmbeddings(context.Background()); err != nil {
+		return fmt.Errorf("quantize embeddings: %w", err)
+	}
 	return nil
 }
 
+// QuantizeEmbeddings converts stored float32 embeddings to the configured
+// Quantization, and drops the float32 copy unless Rescore is set. It runs
+// when the store opens, so switching a database to quantised storage only
+// needs the config change. Entries already quantised another way are
+// re-quantised when their float32 copy was kept. Returns the number of
+// entries converted.
+func (s *SQLiteStore) QuantizeEmbeddings(ctx context.Context) (int, error) {
+	quant := s.cfg.Quantization
+	if quant == distillmath.QuantizeNone {
+		return 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, embedding, embedding_q FROM memories WHERE embedding IS NOT NULL")
+	if err != nil {
+		return 0, err
+	}
+	type update struct {
+		id      string
+		full, q []byte
+	}
+	var updates []update
+	for rows.Next() {
+		var id string
+		var full, q []byte
+		if err := rows.Scan(&id, &full, &q); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		newFull, newQ, ok := distillmath.Requantize(full, q, quant, s.cfg.Rescore)
+		if !ok {
+			continue
+		}
+		updates = append(updates, update{id: id, full: newFull, q: newQ})
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, err
+	}
+	_ = rows.Close()
+
+	for _, u := range updates {
+		if _, err := s.db.ExecContext(ctx,
+			"UPDATE memories SET embedding = ?, embedding_q = ? WHERE id = ?", u.full, u.q, u.id,
+		); err != nil {
+			return 0, fmt.Errorf("update embedding: %w", err)
+		}
+	}
+	return len(updates), nil
+}
+
+// loadFullEmbeddings returns the float32 embeddings kept for rescoring.
+func (s *SQLiteStore) loadFullEmbeddings(ctx context.Context, ids []string) (map[string][]float32, error) {
+	out := make(map[string][]float32, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, embedding FROM memories WHERE embedding IS NOT NULL AND id IN ("+strings.Join(placeholders, ",")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		if emb := distillmath.DecodeEmbedding(blob); len(emb) > 0 {
+			out[id] = emb
+		}
+	}
+	return out, rows.Err()
+}
+
 // Store adds entries with write-time deduplication.
 func (s *SQLiteStore) Store(ctx context.Context, req StoreRequest) (*StoreResult, error) {
 
@@ -167,7 +253,7 @@ func (s *SQLiteStore) Store(ctx context.Context, req StoreRequest) (*StoreResult
 		now := time.Now().UTC().Format(time.RFC3339Nano)
 
 		metaJSON, _ := json.Marshal(entry.Metadata)
-		embBlob := encodeEmbedding(entry.Embedding)
+		embBlob, embQBlob := distillmath.EmbeddingColumns(entry.Embedding, s.cfg.Quantization, s.cfg.Rescore)
 
 		sessionID := req.SessionID
 
@@ -186,9 +272,9 @@ func (s *SQLiteStore) Store(ctx context.Context, req StoreRequest) (*StoreResult
 		}
 
 		_, err := s.db.ExecContext(ctx,
-			`INSERT INTO memories (id, text, embedding, source, session_id, metadata, decay_level, sensitivity, created_at, last_referenced, access_count, expires_at)
-			 VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?, 0, ?)`,
-			id, entry.Text, embBlob, entry.Source, sessionID, string(metaJSON), int(sens), now, now, expiresAt,
+			`INSERT INTO memories (id, text, embedding, embedding_q, source, session_id, metadata, decay_level, sensitivity, created_at, last_referenced, access_count, expires_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, 0, ?)`,
+			id, entry.Text, embBlob, embQBlob, entry.Source, sessionID, string(metaJSON), int(sens), now, now, expiresAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("insert memory: %w", err)
@@ -236,46 +322,136 @@ type similarEntry struct {
 }
 
 // findSimilar scans existing embeddings and returns duplicates and conflicts.
+// Quantised embeddings are compared in quantised form; with Rescore, the
+// entries near a threshold are then compared at full precision.
 //
 // TODO: This does a full table scan (O(n) per insert). Fine for < 10K entries.
 // At larger scale, consider an approximate nearest-neighbor index or caching
 // embeddings in memory.
 func (s *SQLiteStore) findSimilar(ctx context.Context, embedding []float32) ([]similarEntry, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, text, embedding FROM memories WHERE embedding IS NOT NULL AND expired = 0")
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, text, embedding_q, CASE WHEN embedding_q IS NULL THEN embedding END
+		 FROM memories WHERE (embedding IS NOT NULL OR embedding_q IS NOT NULL) AND expired = 0`)
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = rows.Close() }()
 
 	conflictThreshold := s.cfg.ConflictThreshold
 	if conflictThreshold <= 0 {
 		conflictThreshold = 0.35
 	}
+	margin := 0.0
+	if s.cfg.Rescore {
+		margin = distillmath.RescoreMargin(s.cfg.Quantization)
+	}
 
-	var results []similarEntry
+	query := distillmath.NewQuantizedQuery(embedding)
+	var candidates []similarEntry
+	var rescoreIDs []string
 	for rows.Next() {
 		var id, text string
-		var embBlob []byte
-		if err := rows.Scan(&id, &text, &embBlob); err != nil {
+		var qBlob, fullBlob []byte
+		if err := rows.Scan(&id, &text, &qBlob, &fullBlob); err != nil {
+			_ = rows.Close()
 			return nil, err
 		}
 
-		existing := decodeEmbedding(embBlob)
-		if len(existing) == 0 {
+		dist, approx, ok := query.StoredDistance(fullBlob, qBlob)
+		if !ok || dist >= conflictThreshold+margin {
 			continue
 		}
+		if approx && s.cfg.Rescore {
+			rescoreIDs = append(rescoreIDs, id)
+		} else if dist < s.cfg.DedupThreshold && len(rescoreIDs) == 0 {
+			// Exact dup found and nothing earlier awaits rescoring.
+			_ = rows.Close()
+			return []similarEntry{{id: id, text: text, distance: dist, isDup: true}}, nil
+		}
+		candidates = append(candidates, similarEntry{id: id, text: text, distance: dist})
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	_ = rows.Close()
 
-		dist := distillmath.CosineDistance(embedding, existing)
-		if dist < s.cfg.DedupThreshold {
-			results = append(results, similarEntry{id: id, text: text, distance: dist, isDup: true})
-			return results, nil // exact dup found, no need to continue
+	full, err := s.loadFullEmbeddings(ctx, rescoreIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []similarEntry
+	for _, c := range candidates {
+		if emb, ok := full[c.id]; ok {
+			c.distance = distillmath.CosineDistance(embedding, emb)
 		}
-		if dist < conflictThreshold {
-			results = append(results, similarEntry{id: id, text: text, distance: dist, isDup: false})
+		if c.distance < s.cfg.DedupThreshold {
+			c.isDup = true
+			return []similarEntry{c}, nil
+		}
+		if c.distance < conflictThreshold {
+			results = append(results, c)
 		}
 	}
+	return results, nil
+}
 
-	return results, rows.Err()
+// storedEmbedding is a row's embedding columns.
+type storedEmbedding struct {
+	id        string
+	full      []byte
+	quantized []byte
+}
+
+// recallSimilarities returns the cosine similarity between query and each
+// stored embedding, 0 where there is none. With Rescore, the entries with
+// the highest quantised similarity are rescored at full precision.
+func (s *SQLiteStore) recallSimilarities(ctx context.Context, query []float32, stored []storedEmbedding, maxResults int) ([]float64, error) {
+	sims := make([]float64, len(stored))
+	if len(query) == 0 {
+		return sims, nil
+	}
+
+	q := distillmath.NewQuantizedQuery(query)
+	var approx []int
+	for i, e := range stored {
+		dist, isApprox, ok := q.StoredDistance(e.full, e.quantized)
+		if !ok {
+			continue
+		}
+		sims[i] = 1.0 - dist
+		if isApprox {
+			approx = append(approx, i)
+		}
+	}
+	if !s.cfg.Rescore || len(approx) == 0 {
+		return sims, nil
+	}
+
+	// Rescore a few times more entries than can be returned, since recency
+	// and boosts also affect the final ranking.
+	limit := maxResults * 4
+	if limit < 32 {
+		limit = 32
+	}
+	sort.SliceStable(approx, func(a, b int) bool { return sims[approx[a]] > sims[approx[b]] })
+	if len(approx) > limit {
+		approx = approx[:limit]
+	}
+	ids := make([]string, len(approx))
+	for j, i := range approx {
+		ids[j] = stored[i].id
+	}
+	full, err := s.loadFullEmbeddings(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range approx {
+		if emb, ok := full[stored[i].id]; ok {
+			sims[i] = 1.0 - distillmath.CosineDistance(query, emb)
+		}
+	}
+	return sims, nil
 }
 
 // Recall retrieves memories matching a query, ranked by relevance and recency.
@@ -299,7 +475,8 @@ func (s *SQLiteStore) Recall(ctx context.Context, req RecallRequest) (*RecallRes
 	}
 
 	// Build query with optional tag filter and expiry exclusion
-	query := "SELECT m.id, m.text, m.embedding, m.source, m.decay_level, m.sensitivity, m.last_referenced FROM memories m"
+	query := `SELECT m.id, m.text, m.embedding_q, CASE WHEN m.embedding_q IS NULL THEN m.embedding END,
+		m.source, m.decay_level, m.sensitivity, m.last_referenced FROM memories m`
 	var args []interface{}
 	var conditions []string
 
@@ -333,14 +510,14 @@ func (s *SQLiteStore) Recall(ctx context.Context, req RecallRequest) (*RecallRes
 	// SQLite with MaxOpenConns(1) requires the connection to be free.
 	type rawRow struct {
 		id, text, source, refStr string
-		embBlob                  []byte
+		embQBlob, embBlob        []byte
 		decayLevel               int
 		sensitivity              int
 	}
 	var rawRows []rawRow
 	for rows.Next() {
 		var r rawRow
-		if err := rows.Scan(&r.id, &r.text, &r.embBlob, &r.source, &r.decayLevel, &r.sensitivity, &r.refStr); err != nil {
+		if err := rows.Scan(&r.id, &r.text, &r.embQBlob, &r.embBlob, &r.source, &r.decayLevel, &r.sensitivity, &r.refStr); err != nil {
 			_ = rows.Close()
 			return nil, err
 		}
@@ -352,6 +529,15 @@ func (s *SQLiteStore) Recall(ctx context.Context, req RecallRequest) (*RecallRes
 	}
 	_ = rows.Close()
 
+	stored := make([]storedEmbedding, len(rawRows))
+	for i, r := range rawRows {
+		stored[i] = storedEmbedding{id: r.id, full: r.embBlob, quantized: r.embQBlob}
+	}
+	similarities, err := s.recallSimilarities(ctx, req.QueryEmbedding, stored, maxResults)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build boost tag set for O(1) lookup
 	boostTagSet := make(map[string]bool, len(req.BoostTags))
 	for _, t := range req.BoostTags {
@@ -364,19 +550,12 @@ func (s *SQLiteStore) Recall(ctx context.Context, req RecallRequest) (*RecallRes
 	var candidates []scored
 	now := time.Now()
 
-	for _, r := range rawRows {
+	for i, r := range rawRows {
 		tags, _ := s.loadTags(ctx, r.id)
 		lastRef, _ := time.Parse(time.RFC3339Nano, r.refStr)
 
-		// Compute relevance score from embedding similarity
-		var similarity float64
-		if len(req.QueryEmbedding) > 0 {
-			existing := decodeEmbedding(r.embBlob)
-			if len(existing) > 0 {
-				dist := distillmath.CosineDistance(req.QueryEmbedding, existing)
-				similarity = 1.0 - dist
-			}
-		}
+		// Relevance starts from embedding similarity
+		similarity := similarities[i]
 
 		// Compute recency score (exponential decay, half-life = 24h)
 		age := now.Sub(lastRef).Hours()
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"time"
 
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
 	"github.com/Siddhant-K-code/distill/pkg/sensitivity"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
 )
 
 // Common errors returned by memory stores.
@@ -255,6 +257,20 @@ type Config struct {
 	// EvictAge is the age after which unreferenced memories are evicted.
 	// Default: 720h (30 days).
 	EvictAge time.Duration
+
+	// Summarizer writes the summaries memories decay to, e.g. a
+	// summarize.AbstractiveSummarizer. Nil uses the extractive compressor.
+	Summarizer summarize.TextSummarizer
+
+	// Quantization stores embeddings as int8 or binary instead of float32,
+	// and dedup and recall compare the quantised form. Existing float32
+	// rows are converted when the store opens. Default: float32.
+	Quantization distillmath.Quantization
+
+	// Rescore keeps the float32 embedding next to the quantised one and
+	// re-ranks the closest candidates at full precision. Without it the
+	// float32 copy is dropped, including from converted rows.
+	Rescore bool
 }
 
 // DefaultConfig returns sensible defaults.
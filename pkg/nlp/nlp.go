@@ -0,0 +1,173 @@
+// Package nlp provides the language-aware text analysis shared by the
+// compressors, summarizers, and keyword extractors: script and language
+// detection, sentence segmentation, word segmentation, and stopword lists
+// for the most widely used languages.
+package nlp
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Script is the writing system text is (mostly) written in.
+type Script string
+
+// Scripts reported by DetectScript.
+const (
+	ScriptUnknown    Script = ""
+	ScriptLatin      Script = "latin"
+	ScriptCyrillic   Script = "cyrillic"
+	ScriptGreek      Script = "greek"
+	ScriptArabic     Script = "arabic"
+	ScriptHebrew     Script = "hebrew"
+	ScriptDevanagari Script = "devanagari"
+	ScriptThai       Script = "thai"
+	ScriptHan        Script = "han"  // Chinese, or Japanese without kana
+	ScriptKana       Script = "kana" // Japanese: Han mixed with hiragana/katakana
+	ScriptHangul     Script = "hangul"
+)
+
+// Language is an ISO 639-1 language code.
+type Language string
+
+// Languages with stopword lists. DetectLanguage returns one of these.
+const (
+	English    Language = "en"
+	Spanish    Language = "es"
+	French     Language = "fr"
+	German     Language = "de"
+	Italian    Language = "it"
+	Portuguese Language = "pt"
+	Dutch      Language = "nl"
+	Swedish    Language = "sv"
+	Polish     Language = "pl"
+	Turkish    Language = "tr"
+	Indonesian Language = "id"
+	Russian    Language = "ru"
+	Arabic     Language = "ar"
+	Hindi      Language = "hi"
+	Chinese    Language = "zh"
+	Japanese   Language = "ja"
+	Korean     Language = "ko"
+)
+
+// Languages returns every language with a stopword list.
+func Languages() []Language {
+	return []Language{
+		English, Spanish, French, German, Italian, Portuguese, Dutch, Swedish,
+		Polish, Turkish, Indonesian, Russian, Arabic, Hindi, Chinese, Japanese, Korean,
+	}
+}
+
+// scriptOf classifies a single rune; ScriptUnknown for digits,
+// punctuation, and symbols.
+func scriptOf(r rune) Script {
+	switch {
+	case r < 0x80:
+		if unicode.IsLetter(r) {
+			return ScriptLatin
+		}
+		return ScriptUnknown
+	case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r), r == 'ー':
+		return ScriptKana
+	case unicode.Is(unicode.Han, r):
+		return ScriptHan
+	case unicode.Is(unicode.Hangul, r):
+		return ScriptHangul
+	case unicode.Is(unicode.Latin, r):
+		return ScriptLatin
+	case unicode.Is(unicode.Cyrillic, r):
+		return ScriptCyrillic
+	case unicode.Is(unicode.Greek, r):
+		return ScriptGreek
+	case unicode.Is(unicode.Arabic, r):
+		return ScriptArabic
+	case unicode.Is(unicode.Hebrew, r):
+		return ScriptHebrew
+	case unicode.Is(unicode.Devanagari, r):
+		return ScriptDevanagari
+	case unicode.Is(unicode.Thai, r):
+		return ScriptThai
+	}
+	return ScriptUnknown
+}
+
+// DetectScript returns the script most letters of text are written in.
+// Han text that also contains kana is reported as ScriptKana (Japanese).
+func DetectScript(text string) Script {
+	counts := make(map[Script]int)
+	for _, r := range text {
+		if s := scriptOf(r); s != ScriptUnknown {
+			counts[s]++
+		}
+	}
+	if counts[ScriptKana] > 0 && counts[ScriptKana]+counts[ScriptHan] >= counts[ScriptLatin] {
+		// Kana rarely outnumber kanji in running Japanese text.
+		counts[ScriptKana] += counts[ScriptHan]
+		counts[ScriptHan] = 0
+	}
+
+	best, bestN := ScriptUnknown, 0
+	for _, s := range []Script{
+		ScriptLatin, ScriptCyrillic, ScriptGreek, ScriptArabic, ScriptHebrew,
+		ScriptDevanagari, ScriptThai, ScriptHan, ScriptKana, ScriptHangul,
+	} {
+		if counts[s] > bestN {
+			best, bestN = s, counts[s]
+		}
+	}
+	return best
+}
+
+// latinLanguages are told apart by stopword frequency.
+var latinLanguages = []Language{
+	English, Spanish, French, German, Italian, Portuguese, Dutch, Swedish,
+	Polish, Turkish, Indonesian,
+}
+
+// DetectLanguage guesses the language of text from its script and, for
+// Latin-script text, from which language's stopwords it uses most. It
+// defaults to English when there is no signal.
+func DetectLanguage(text string) Language {
+	switch DetectScript(text) {
+	case ScriptCyrillic:
+		return Russian
+	case ScriptArabic:
+		return Arabic
+	case ScriptDevanagari:
+		return Hindi
+	case ScriptHan:
+		return Chinese
+	case ScriptKana:
+		return Japanese
+	case ScriptHangul:
+		return Korean
+	case ScriptLatin:
+	default:
+		return English
+	}
+
+	hits := make(map[Language]int)
+	for _, w := range Words(text) {
+		w = strings.ToLower(w)
+		for _, lang := range latinLanguages {
+			if IsStopword(lang, w) {
+				hits[lang]++
+			}
+		}
+	}
+	best, bestN := English, hits[English]
+	for _, lang := range latinLanguages {
+		if hits[lang] > bestN {
+			best, bestN = lang, hits[lang]
+		}
+	}
+	return best
+}
+
+// isCJK reports whether r belongs to a script written without spaces
+// between words.
+func isCJK(r rune) bool {
+	s := scriptOf(r)
+	return s == ScriptHan || s == ScriptKana
+}
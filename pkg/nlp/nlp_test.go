@@ -0,0 +1,168 @@
+package nlp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectScript(t *testing.T) {
+	tests := []struct {
+		text string
+		want Script
+	}{
+		{"The quick brown fox.", ScriptLatin},
+		{"Быстрая коричневая лиса.", ScriptCyrillic},
+		{"这是一个测试。", ScriptHan},
+		{"これはテストです。東京に行きます。", ScriptKana},
+		{"이것은 테스트입니다.", ScriptHangul},
+		{"هذا اختبار", ScriptArabic},
+		{"यह एक परीक्षण है।", ScriptDevanagari},
+		{"12345 !!!", ScriptUnknown},
+	}
+	for _, tt := range tests {
+		if got := DetectScript(tt.text); got != tt.want {
+			t.Errorf("DetectScript(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want Language
+	}{
+		{"The service is running and the queue is empty.", English},
+		{"El servicio está funcionando y la cola está vacía para los usuarios.", Spanish},
+		{"Le service est en cours et la file est vide pour les utilisateurs.", French},
+		{"Der Dienst läuft und die Warteschlange ist leer für die Nutzer.", German},
+		{"Сервис работает, и очередь пуста.", Russian},
+		{"服务正在运行，队列是空的。", Chinese},
+		{"サービスは稼働中で、キューは空です。", Japanese},
+		{"서비스가 실행 중이며 대기열이 비어 있습니다.", Korean},
+		{"", English},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "english",
+			text: "First sentence. Second one! Third? Trailing text",
+			want: []string{"First sentence.", "Second one!", "Third?", "Trailing text"},
+		},
+		{
+			name: "decimals, hosts and abbreviations",
+			text: "Pi is 3.14 on example.com today. Dr. Smith agreed, e.g. with J. Doe. Done.",
+			want: []string{"Pi is 3.14 on example.com today.", "Dr. Smith agreed, e.g. with J. Doe.", "Done."},
+		},
+		{
+			name: "closing quotes",
+			text: `He said "stop." Then he left.`,
+			want: []string{`He said "stop."`, "Then he left."},
+		},
+		{
+			name: "chinese",
+			text: "服务正在运行。队列是空的！还有问题吗？",
+			want: []string{"服务正在运行。", "队列是空的！", "还有问题吗？"},
+		},
+		{
+			name: "japanese with brackets",
+			text: "「こんにちは。」と言った。東京に行きます",
+			want: []string{"「こんにちは。」", "と言った。", "東京に行きます"},
+		},
+		{
+			name: "hindi danda",
+			text: "यह पहला वाक्य है। यह दूसरा है।",
+			want: []string{"यह पहला वाक्य है।", "यह दूसरा है।"},
+		},
+		{
+			name: "blank line",
+			text: "Heading without punctuation\n\nBody text here.",
+			want: []string{"Heading without punctuation", "Body text here."},
+		},
+	}
+	for _, tt := range tests {
+		got := SplitSentences(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSentencesWith(t *testing.T) {
+	got := SplitSentencesWith("first; second; third", ";")
+	if len(got) != 3 || got[1] != "second;" {
+		t.Errorf("expected split on extra delimiter, got %q", got)
+	}
+}
+
+func TestEndsSentence(t *testing.T) {
+	for s, want := range map[string]bool{
+		"Done.": true, `He said "stop."`: true, "完成。": true, "no terminator": false, "": false,
+	} {
+		if got := EndsSentence(s); got != want {
+			t.Errorf("EndsSentence(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	if got := strings.Join(Words("don't stop-gap, 42 times!"), "|"); got != "don't|stop-gap|42|times" {
+		t.Errorf("latin words: %q", got)
+	}
+	// Japanese splits at script changes: kanji / hiragana / katakana.
+	if got := strings.Join(Words("東京のサーバーが停止した"), "|"); got != "東京|の|サーバー|が|停止|した" {
+		t.Errorf("japanese words: %q", got)
+	}
+	// Chinese splits at function characters.
+	if got := strings.Join(Words("数据库的连接池和缓存"), "|"); got != "数据库|的|连接池|和|缓存" {
+		t.Errorf("chinese words: %q", got)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"english", "The Database connection pool should be resized because the database is slow", []string{"Database", "connection", "pool", "resized", "slow"}},
+		{"spanish", "La base de datos para los usuarios está muy lenta", []string{"base", "datos", "usuarios", "está", "lenta"}},
+		{"chinese", "数据库的连接池和缓存都很慢", []string{"数据库", "连接池", "缓存", "很慢"}},
+		{"japanese", "東京のサーバーが停止した", []string{"東京", "サーバー", "停止"}},
+		{"korean", "데이터베이스를 서버에서 다시 시작했습니다", []string{"데이터베이스", "서버", "다시", "시작했습니다"}},
+	}
+	for _, tt := range tests {
+		got := Keywords(tt.text, 0)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Keywords("alpha bravo charlie delta", 2); len(got) != 2 {
+		t.Errorf("expected limit of 2 keywords, got %q", got)
+	}
+}
+
+func TestStopwords(t *testing.T) {
+	for _, lang := range Languages() {
+		if len(Stopwords(lang)) < 20 {
+			t.Errorf("%s: expected a stopword list, got %d words", lang, len(Stopwords(lang)))
+		}
+	}
+	if Stopwords("xx") != nil {
+		t.Error("expected nil for unsupported language")
+	}
+	if !IsStopword(English, "the") || IsStopword(English, "database") {
+		t.Error("unexpected English stopword lookup")
+	}
+}
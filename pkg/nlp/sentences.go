@@ -0,0 +1,122 @@
+package nlp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fullStops end a sentence wherever they appear: CJK full stops and marks,
+// the Devanagari danda, and Arabic/Urdu question and full stop marks.
+const fullStops = "。！？．｡।॥؟۔"
+
+// spacedStops end a sentence only when followed by whitespace or the end
+// of the text, so that "3.14" and "example.com" stay whole.
+const spacedStops = ".!?…"
+
+// closers may trail a terminator and belong to the sentence it ends.
+const closers = "\"')]}”’»」』）】"
+
+// abbreviations are lower-case words whose trailing period does not end a
+// sentence.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true,
+	"sr": true, "jr": true, "st": true, "vs": true, "e.g": true,
+	"i.e": true, "cf": true, "approx": true, "fig": true,
+}
+
+// SplitSentences splits text into trimmed sentences using the terminators
+// of every supported script. Blank lines also end a sentence, so headings
+// and list items without punctuation are not merged into what follows.
+func SplitSentences(text string) []string {
+	return SplitSentencesWith(text, "")
+}
+
+// SplitSentencesWith is SplitSentences with extra runes that end a
+// sentence wherever they appear. Runes SplitSentences already treats as
+// terminators keep their usual rules.
+func SplitSentencesWith(text, extra string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	emit := func(end int) {
+		if s := strings.TrimSpace(string(runes[start:end])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = end
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\n' && i+1 < len(runes) && isBlankLineAhead(runes[i+1:]):
+			emit(i + 1)
+		case strings.ContainsRune(fullStops, r):
+			i = skipClosers(runes, i)
+			emit(i + 1)
+		case strings.ContainsRune(spacedStops, r):
+			end := skipClosers(runes, i)
+			if end+1 < len(runes) && !unicode.IsSpace(runes[end+1]) {
+				continue
+			}
+			if r == '.' && isAbbreviation(runes[start:i]) {
+				continue
+			}
+			i = end
+			emit(i + 1)
+		case strings.ContainsRune(extra, r) && !unicode.IsSpace(r):
+			i = skipClosers(runes, i)
+			emit(i + 1)
+		}
+	}
+	emit(len(runes))
+	return sentences
+}
+
+// EndsSentence reports whether s ends with a sentence terminator,
+// optionally followed by closing quotes or brackets.
+func EndsSentence(s string) bool {
+	s = strings.TrimRight(strings.TrimSpace(s), closers)
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r != utf8.RuneError && strings.ContainsRune(spacedStops+fullStops, r)
+}
+
+// skipClosers returns the index of the last terminator or closing
+// quote/bracket in the run starting at i ("?!", `."`, "。」").
+func skipClosers(runes []rune, i int) int {
+	for i+1 < len(runes) && (strings.ContainsRune(closers, runes[i+1]) ||
+		strings.ContainsRune(spacedStops+fullStops, runes[i+1])) {
+		i++
+	}
+	return i
+}
+
+// isBlankLineAhead reports whether rest begins with a line containing only
+// whitespace.
+func isBlankLineAhead(rest []rune) bool {
+	for _, r := range rest {
+		if r == '\n' {
+			return true
+		}
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return false
+}
+
+// isAbbreviation reports whether the sentence so far ends in a known
+// abbreviation or a single initial ("J. Smith").
+func isAbbreviation(sentence []rune) bool {
+	end := len(sentence)
+	begin := end
+	for begin > 0 && !unicode.IsSpace(sentence[begin-1]) && sentence[begin-1] != '(' {
+		begin--
+	}
+	word := strings.ToLower(string(sentence[begin:end]))
+	if abbreviations[word] {
+		return true
+	}
+	w := []rune(word)
+	return len(w) == 1 && unicode.IsLetter(w[0]) && unicode.IsUpper(sentence[begin])
+}
@@ -0,0 +1,124 @@
+package nlp
+
+import (
+	"sort"
+	"strings"
+)
+
+// IsStopword reports whether the lower-cased word is a stopword in lang.
+// Unsupported languages have no stopwords.
+func IsStopword(lang Language, word string) bool {
+	return stopwords[lang][word]
+}
+
+// Stopwords returns the sorted stopword list for lang, or nil if
+// unsupported.
+func Stopwords(lang Language) []string {
+	set, ok := stopwords[lang]
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(stopwords[lang]))
+	for w := range set {
+		out = append(out, w)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var stopwords = map[Language]map[string]bool{
+	English: wordSet(`a about above after again against all also am an and any are
+		as at be because been before being below between both but by can could
+		did do does doing down during each few for from further had has have
+		having he her here hers herself him himself his how i if in into is it
+		its itself just like me more most my myself no nor not now of off on
+		once only or other our ours ourselves out over own same she should so
+		some such than that the their theirs them themselves then there these
+		they this those through to too under until up very was we were what
+		when where which while who whom why will with would you your yours
+		yourself yourselves`),
+	Spanish: wordSet(`a al algo algunas algunos ante antes como con contra cual
+		cuando de del desde donde durante e el ella ellas ellos en entre era
+		eran es esa esas ese eso esos esta estaba estas este esto estos fue
+		fueron ha hay la las le les lo los mas me mi mucho muy más nada ni no
+		nos nosotros o otra otras otro otros para pero poco por porque que
+		quien se sea ser si sin sobre son su sus también tan te tiene todo
+		todos tu un una uno unos y ya yo`),
+	French: wordSet(`à au aux avec ce ces cette comme dans de des du elle elles
+		en est et eux il ils je la le les leur leurs lui ma mais me même mes
+		moi mon ne nos notre nous on ont ou où par pas pour qu que qui sa sans
+		se ses son sont sur ta te tes toi ton tous tout très tu un une vos
+		votre vous y été être avoir fait plus peut aussi était`),
+	German: wordSet(`aber alle als also am an auch auf aus bei bin bis bist da
+		damit dann das dass dein dem den der des dich die dir doch du durch ein
+		eine einem einen einer eines er es für hat hatte ich ihm ihn ihr im in
+		ist ja jede kann kein mein mich mir mit muss nach nicht noch nur ob
+		oder ohne sehr sein sich sie sind so über um und uns unter vom von vor
+		war waren was weil wenn wer wie wir wird wo zu zum zur`),
+	Italian: wordSet(`a ad al alla alle anche che chi ci come con da dal dalla
+		dei del della delle di dove e è ed era gli ha hanno i il in io la le
+		lei li lo loro lui ma mi mio molto ne nei nel nella noi non o per più
+		perché quale quando quello questa questo se si sono su sua suo sul
+		sulla tra tu tutto un una uno voi`),
+	Portuguese: wordSet(`a ao aos as até com como da das de dela dele do dos
+		e ela elas ele eles em entre era essa esse está eu foi há isso isto já
+		lhe mais mas me mesmo meu muito na nas não nem no nos nós o os ou para
+		pela pelo por qual quando que quem se sem ser seu sua são também te
+		tem um uma você`),
+	Dutch: wordSet(`aan al als bij dan dat de der deze die dit door een en er
+		had heb heeft het hij hoe hun ik in is je kan maar me meer met mij
+		naar niet nog nu of om ons ook op over te tot u uit van veel voor was
+		wat we wel werd wie wij worden zal ze zich zij zijn zo`),
+	Swedish: wordSet(`alla att av blev bli där de dem den denna det detta dig
+		din du efter eller en ett från för ha hade han hans har henne hennes
+		hon honom hur här i inte jag kan man med mellan men mig min mot mycket
+		ni nu när och om oss på sedan sig sin ska som så till under upp ut vad
+		var vi vid än är över`),
+	Polish: wordSet(`a aby ale bardzo bez być był była było były ci co czy dla
+		do go gdy i ich jak jako je jego jej jest jeszcze jeśli już ją ma
+		mi mnie może na nad nie nich nim o od oraz po pod przez przy się
+		ta tak także te tego tej ten to tu tylko tym w we więc z za ze że`),
+	Turkish: wordSet(`acaba ama ancak bana bazı belki ben benim beri bir biri
+		birkaç biz bu bunu buna da daha de diye en gibi göre hem hep her hiç
+		için ile ise kadar ki kim mi mu mı mü ne neden nasıl o olan olarak
+		oldu olduğu ona onu onun sen siz şey şu ve veya ya yani`),
+	Indonesian: wordSet(`ada adalah agar akan aku anda apa atau bagi bahwa
+		banyak belum bisa dalam dan dari dengan di dia ia ini itu jika juga
+		kami kamu karena ke kita lagi lebih mereka namun oleh pada para saat
+		saja sama sangat satu saya sebagai sudah tentang tetapi tidak untuk
+		yang`),
+	Russian: wordSet(`а без более бы был была были было быть в вам вас весь во
+		вот все всё вы где да даже для до его ее её если есть ещё же за здесь
+		и из или им их к как ко когда который кто ли либо мне может мы на над
+		не него нее неё нет ни них но ну о об один он она они оно от очень по
+		под при с со так также такой там те тем то того тоже только том ты у
+		уже хотя чего чей чем что чтобы эта эти это я`),
+	Arabic: wordSet(`إلى إن أن أو أي التي الذي الذين ثم حتى على عن عند في
+		قد كان كانت كل لا لم لن ما مع من هذا هذه هو هي و يا بين بعد قبل
+		كما لكن ذلك تلك هناك هنا نحن أنا أنت هم`),
+	Hindi: wordSet(`और का की के को से में है हैं था थी थे यह वह ये वे
+		एक पर भी तो ही नहीं या लिए कर किया करने जो कि इस उस इसके उसके
+		हो गया गई अपने अपनी साथ तक बहुत कुछ जब तब अब`),
+	Chinese: wordSet(`的 了 是 在 和 与 及 或 也 都 就 而 把 被 从 对 这 那
+		我 你 他 她 它 我们 你们 他们 有 没有 不 个 之 其 这个 那个 什么
+		怎么 因为 所以 但是 如果 可以 已经 还 又 很 会 要 能 吗 呢 吧 啊`),
+	Japanese: wordSet(`の に は を た が で て と し れ さ ある いる も する から
+		な こと として い や れる など なっ ない この ため その あっ よう また
+		もの という あり まで られ なる へ か だ これ によって により おり より
+		による ず なり られる において ば なかっ なく しかし について せ だっ
+		その後 できる それ う ので なお のみ でき き つ における および いう
+		さらに でも ら たり その他 に関する たち ます ん なら です`),
+	Korean: wordSet(`이 그 저 것 수 등 및 또는 그리고 하지만 그러나 그래서 또
+		더 및 의 가 을 를 은 는 에 에서 으로 로 와 과 도 만 까지 부터
+		있다 없다 하다 되다 이다 있는 없는 하는 되는 같은 우리 나 너
+		그것 이것 저것 때 중 안 못`),
+}
+
+// wordSet builds a lookup set from a whitespace-separated word list.
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,138 @@
+package nlp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Words splits text into words. Space-delimited scripts split on anything
+// that is not a letter, digit, mark, or an apostrophe or hyphen inside a
+// word. Text without spaces between words is segmented heuristically:
+// Japanese at changes between kanji, hiragana, and katakana, and Chinese
+// at common single-character function words.
+func Words(text string) []string {
+	japanese := DetectScript(text) == ScriptKana
+	splitHan := func(r rune) bool { return !japanese && chineseFunctionChars[r] }
+
+	var words []string
+	var cur []rune
+	var curKind rune // 'w' word, 'h' han, 'k' katakana, 'g' hiragana
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.Trim(string(cur), "'-’"))
+			cur = cur[:0]
+		}
+	}
+
+	for _, r := range text {
+		kind := rune(0)
+		switch {
+		case unicode.Is(unicode.Han, r):
+			kind = 'h'
+		case unicode.Is(unicode.Katakana, r), r == 'ー':
+			kind = 'k'
+		case unicode.Is(unicode.Hiragana, r):
+			kind = 'g'
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+			kind = 'w'
+		case (r == '\'' || r == '-' || r == '’') && curKind == 'w' && len(cur) > 0:
+			cur = append(cur, r)
+			continue
+		}
+
+		if kind == 0 {
+			flush()
+			curKind = 0
+			continue
+		}
+		if kind != curKind || (kind == 'h' && splitHan(r)) {
+			flush()
+		}
+		cur = append(cur, r)
+		curKind = kind
+		if kind == 'h' && splitHan(r) {
+			flush()
+			curKind = 0
+		}
+	}
+	flush()
+
+	out := words[:0]
+	for _, w := range words {
+		if w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// chineseFunctionChars are particles, pronouns, and conjunctions that
+// separate content words in Chinese text and rarely start a compound.
+var chineseFunctionChars = func() map[rune]bool {
+	m := make(map[rune]bool)
+	for _, r := range "的了是和与及或也都就把被我你他她它们这那吗呢吧啊" {
+		m[r] = true
+	}
+	return m
+}()
+
+// Keywords returns the distinct content words of text in order of first
+// appearance, up to limit (0 = no limit). Stopwords of the detected
+// language and of English (common in mixed-language text) are dropped, as
+// are words too short to carry meaning: under four characters in
+// space-delimited scripts, under two in CJK and Hangul, and Japanese
+// hiragana runs, which are mostly grammatical endings. Words keep their
+// original case; duplicates are compared case-insensitively.
+func Keywords(text string, limit int) []string {
+	lang := DetectLanguage(text)
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, w := range Words(text) {
+		if lang == Korean {
+			w = trimKoreanParticle(w)
+		}
+		lower := strings.ToLower(w)
+		if seen[lower] || IsStopword(lang, lower) || IsStopword(English, lower) || !isContentWord(w) {
+			continue
+		}
+		seen[lower] = true
+		keywords = append(keywords, w)
+		if limit > 0 && len(keywords) >= limit {
+			break
+		}
+	}
+	return keywords
+}
+
+// isContentWord applies the per-script minimum length to w.
+func isContentWord(w string) bool {
+	first, _ := utf8.DecodeRuneInString(w)
+	n := utf8.RuneCountInString(w)
+	switch {
+	case unicode.Is(unicode.Hiragana, first):
+		return false
+	case isCJK(first), unicode.Is(unicode.Hangul, first):
+		return n >= 2
+	default:
+		return n >= 4
+	}
+}
+
+// koreanParticles are case markers and postpositions written attached to
+// the preceding word, longest first.
+var koreanParticles = []string{
+	"에서", "으로", "까지", "부터", "에게", "을", "를", "이", "가", "은", "는",
+	"에", "로", "와", "과", "의", "도", "만",
+}
+
+// trimKoreanParticle strips one trailing particle from a Hangul word when
+// at least two syllables remain.
+func trimKoreanParticle(w string) string {
+	for _, p := range koreanParticles {
+		if stem, ok := strings.CutSuffix(w, p); ok && utf8.RuneCountInString(stem) >= 2 {
+			return stem
+		}
+	}
+	return w
+}
@@ -0,0 +1,244 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/Siddhant-K-code/distill/pkg/compress"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// minKeepRatio is the smallest share of a chunk's tokens the packer asks a
+// compressor to keep. Chunks that cannot fit even at this ratio are dropped.
+const minKeepRatio = 0.2
+
+// ImportanceMetadataKey is the optional chunk metadata key (float64, 0–1)
+// that scales a chunk's utility when packing into Options.MaxTokens. The
+// summarize stage also uses it as the turn's importance.
+const ImportanceMetadataKey = "importance"
+
+// BudgetStats reports how Options.MaxTokens was spent.
+type BudgetStats struct {
+	MaxTokens     int
+	InputTokens   int // tokens entering the packing step (after dedup)
+	UsedTokens    int // tokens in the packed output
+	DroppedChunks int
+	Allocations   []ChunkAllocation
+}
+
+// ChunkAllocation records the packer's decision for one chunk.
+type ChunkAllocation struct {
+	ID           string
+	Utility      float64 // score × importance
+	InputTokens  int
+	Allocated    int     // token allocation (0 if dropped before compression)
+	KeepRatio    float64 // target passed to the compressor (1 = untouched)
+	OutputTokens int
+	Dropped      bool
+}
+
+// packChunks fits chunks into maxTokens. Every chunk gets a keep ratio that
+// grows with its utility and shrinks with its size, so large low-value
+// chunks are compressed hardest. If the budget cannot be met even at
+// minKeepRatio, the lowest-utility chunks are dropped, knapsack-style, and
+// any overshoot left after compression is trimmed the same way. When c is
+// nil chunks are only selected, never compressed.
+func packChunks(ctx context.Context, chunks []types.Chunk, maxTokens int, c compress.Compressor, compOpts compress.Options, counter tokenizer.Counter) ([]types.Chunk, BudgetStats, error) {
+	stats := BudgetStats{MaxTokens: maxTokens}
+	scored := false
+	for _, ch := range chunks {
+		if ch.Score > 0 {
+			scored = true
+			break
+		}
+	}
+	allocs := make([]ChunkAllocation, len(chunks))
+	for i, ch := range chunks {
+		allocs[i] = ChunkAllocation{
+			ID:          ch.ID,
+			Utility:     chunkUtility(ch, scored),
+			InputTokens: counter.Count(ch.Text),
+		}
+		stats.InputTokens += allocs[i].InputTokens
+	}
+
+	minRatio := minKeepRatio
+	if c == nil {
+		minRatio = 1
+	}
+
+	// Knapsack selection on the minimum footprint of each chunk, greedy by
+	// utility per token: the lowest-utility chunks go first, but only if
+	// compressing everything as hard as allowed still does not fit.
+	selected := selectForBudget(allocs, maxTokens, minRatio)
+	for i := range allocs {
+		allocs[i].Dropped = !selected[i]
+	}
+
+	ratios := keepRatios(allocs, maxTokens, minRatio)
+
+	out := make([]types.Chunk, 0, len(chunks))
+	outIdx := make([]int, 0, len(chunks))
+	for i, ch := range chunks {
+		if allocs[i].Dropped {
+			continue
+		}
+		allocs[i].KeepRatio = ratios[i]
+		allocs[i].Allocated = int(math.Round(float64(allocs[i].InputTokens) * ratios[i]))
+
+		if c != nil && ratios[i] < 1 {
+			opts := compOpts
+			opts.TargetReduction = ratios[i]
+			compressed, _, err := c.Compress(ctx, []types.Chunk{ch}, opts)
+			if err != nil {
+				return nil, stats, err
+			}
+			if len(compressed) == 1 {
+				ch = compressed[0]
+			}
+		}
+		allocs[i].OutputTokens = counter.Count(ch.Text)
+		out = append(out, ch)
+		outIdx = append(outIdx, i)
+	}
+
+	// Compressors do not always hit their target (placeholders, single
+	// sentences), so drop the lowest-utility survivors until it fits.
+	used := 0
+	for _, i := range outIdx {
+		used += allocs[i].OutputTokens
+	}
+	for used > maxTokens && len(outIdx) > 0 {
+		worst := 0
+		for k := 1; k < len(outIdx); k++ {
+			a, b := allocs[outIdx[k]], allocs[outIdx[worst]]
+			if a.Utility < b.Utility || (a.Utility == b.Utility && a.OutputTokens > b.OutputTokens) {
+				worst = k
+			}
+		}
+		i := outIdx[worst]
+		used -= allocs[i].OutputTokens
+		allocs[i].Dropped = true
+		allocs[i].OutputTokens = 0
+		out = append(out[:worst], out[worst+1:]...)
+		outIdx = append(outIdx[:worst], outIdx[worst+1:]...)
+	}
+
+	for _, a := range allocs {
+		if a.Dropped {
+			stats.DroppedChunks++
+		}
+	}
+	stats.UsedTokens = used
+	stats.Allocations = allocs
+	return out, stats, nil
+}
+
+// selectForBudget chooses which chunks to keep so that their minimum
+// footprint fits in maxTokens, preferring high utility per token.
+func selectForBudget(allocs []ChunkAllocation, maxTokens int, minRatio float64) []bool {
+	selected := make([]bool, len(allocs))
+	minSize := func(i int) float64 { return float64(allocs[i].InputTokens) * minRatio }
+
+	total := 0.0
+	for i := range allocs {
+		total += minSize(i)
+		selected[i] = true
+	}
+	if total <= float64(maxTokens) {
+		return selected
+	}
+
+	order := make([]int, len(allocs))
+	for i := range order {
+		order[i] = i
+		selected[i] = false
+	}
+	density := func(i int) float64 { return allocs[i].Utility / math.Max(minSize(i), 1) }
+	sort.SliceStable(order, func(a, b int) bool { return density(order[a]) > density(order[b]) })
+
+	room := float64(maxTokens)
+	for _, i := range order {
+		if minSize(i) <= room {
+			selected[i] = true
+			room -= minSize(i)
+		}
+	}
+	return selected
+}
+
+// keepRatios assigns each kept chunk a ratio in [minRatio, 1] proportional
+// to its utility and inversely to the square root of its relative size,
+// scaled by the largest factor whose total allocation fits maxTokens.
+func keepRatios(allocs []ChunkAllocation, maxTokens int, minRatio float64) []float64 {
+	ratios := make([]float64, len(allocs))
+	weights := make([]float64, len(allocs))
+
+	kept, sum := 0, 0
+	for _, a := range allocs {
+		if !a.Dropped {
+			kept++
+			sum += a.InputTokens
+		}
+	}
+	if kept == 0 {
+		return ratios
+	}
+	mean := float64(sum) / float64(kept)
+	for i, a := range allocs {
+		if !a.Dropped && a.InputTokens > 0 {
+			weights[i] = a.Utility / math.Sqrt(float64(a.InputTokens)/mean)
+		}
+	}
+
+	ratiosAt := func(k float64) float64 {
+		total := 0.0
+		for i, a := range allocs {
+			if a.Dropped {
+				continue
+			}
+			ratios[i] = 1
+			if weights[i] > 0 {
+				ratios[i] = math.Min(1, math.Max(minRatio, k*weights[i]))
+			}
+			total += float64(a.InputTokens) * ratios[i]
+		}
+		return total
+	}
+
+	// Everything fits uncompressed.
+	if ratiosAt(math.Inf(1)) <= float64(maxTokens) {
+		return ratios
+	}
+
+	// Binary search the largest scale whose allocation fits.
+	lo, hi := 0.0, 1.0
+	for ratiosAt(hi) <= float64(maxTokens) {
+		hi *= 2
+	}
+	for iter := 0; iter < 50; iter++ {
+		mid := (lo + hi) / 2
+		if ratiosAt(mid) <= float64(maxTokens) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	ratiosAt(lo)
+	return ratios
+}
+
+// chunkUtility is a chunk's retrieval score (or 1 when no chunk in the set
+// is scored) multiplied by its importance metadata (default 1).
+func chunkUtility(ch types.Chunk, scored bool) float64 {
+	utility := 1.0
+	if scored {
+		utility = math.Max(float64(ch.Score), 1e-6)
+	}
+	if imp, ok := ch.Metadata[ImportanceMetadataKey].(float64); ok && imp > 0 {
+		utility *= imp
+	}
+	return utility
+}
@@ -1,19 +1,27 @@
 // Package pipeline chains dedup → compress → summarize into a single pass.
-// Each stage is independently configurable and can be disabled.
+// Each stage is independently configurable and can be disabled, or the
+// fixed order can be replaced by a user-defined list of stages.
 package pipeline
 
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Siddhant-K-code/distill/pkg/compress"
 	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	"github.com/Siddhant-K-code/distill/pkg/embedding"
 	"github.com/Siddhant-K-code/distill/pkg/summarize"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
 // StageStats records token counts and latency for one pipeline stage.
+// Enabled reports whether the stage ran; compression also runs when only
+// MaxTokens is set.
 type StageStats struct {
 	Enabled      bool
 	InputTokens  int
@@ -29,6 +37,35 @@ type Stats struct {
 	TotalReduction float64
 	Stages         map[string]StageStats
 	TotalLatency   time.Duration
+
+	// StageOrder lists the keys of Stages in the order the stages ran.
+	StageOrder []string
+
+	// Summarize reports what the summarize stage did to the conversation
+	// turns; nil when it did not run.
+	Summarize *summarize.SummarizeStats
+
+	// Budget reports how Options.MaxTokens was spent; nil when unset.
+	Budget *BudgetStats
+
+	// Dedup reports how the last dedup stage clustered the chunks; nil
+	// when it did not run.
+	Dedup *DedupStats
+}
+
+// DedupStats reports how the dedup stage clustered chunks.
+type DedupStats struct {
+	// Method is the dedup method used: embedding, lexical, or hybrid.
+	Method string
+
+	// Clusters is the number of clusters formed.
+	Clusters int
+
+	// Threshold is the cosine distance threshold used for embedding
+	// clustering, and ThresholdAuto reports whether DedupAutoThreshold
+	// picked it. Zero for the lexical method.
+	Threshold     float64
+	ThresholdAuto bool
 }
 
 // Options configures which stages run and how.
@@ -38,15 +75,63 @@ type Options struct {
 	DedupThreshold float64 // cosine distance threshold (default 0.15)
 	DedupLambda    float64 // MMR diversity weight (default 0.7)
 	DedupTargetK   int     // max chunks to keep (0 = no limit)
+	DedupMethod    string  // DedupAuto, DedupEmbedding, DedupLexical, or DedupHybrid
+
+	// DedupAutoThreshold picks the threshold from the gap in the chunks'
+	// nearest-neighbour distance histogram, falling back to
+	// DedupThreshold when there is no clear gap. Stats.Dedup reports the
+	// value used.
+	DedupAutoThreshold bool
+
+	// DedupConstraints restrict which cluster representatives are kept by
+	// metadata (per-source cap, field coverage, freshness), jointly with
+	// the MMR pass. A per-source cap applies even without DedupTargetK.
+	DedupConstraints contextlab.SelectionConstraints
 
 	// Compress stage.
 	CompressEnabled         bool
-	CompressTargetReduction float64 // e.g. 0.5 = reduce to 50% of tokens
+	CompressTargetReduction float64         // e.g. 0.5 = reduce to 50% of tokens
+	CompressMode            compress.Mode   // default extractive
+	CompressChain           []compress.Mode // applied in order; overrides CompressMode
+
+	// Query conditions extractive compression on the question being
+	// answered. QueryEmbedding is used as-is when set; otherwise Embedder
+	// (if any) embeds Query. Embedder also embeds sentences for scoring.
+	Query          string
+	QueryEmbedding []float32
+	Embedder       embedding.Provider
+
+	// MaxTokens, when positive, is the token budget for the chunks leaving
+	// the compress stage. After dedup the budget is allocated across chunks
+	// by utility (score × importance metadata): large low-value chunks are
+	// compressed hardest and the lowest-utility chunks are dropped only
+	// when compression alone cannot fit. Overrides CompressTargetReduction.
+	MaxTokens int
 
 	// Summarize stage.
 	SummarizeEnabled   bool
 	SummarizeMaxTokens int
 	SummarizeRecent    int // turns to preserve at full fidelity
+
+	// Summarizer writes the per-turn summaries, e.g. a
+	// summarize.AbstractiveSummarizer. Nil summarizes extractively.
+	Summarizer summarize.TextSummarizer
+
+	// Stages, when set, replaces the fixed dedup → compress → summarize
+	// order and its Enabled toggles: each stage runs in turn, and the other
+	// fields act as defaults for stage parameters left unset. MaxTokens is
+	// the exception: a budget applies only through a compress stage's
+	// max_tokens parameter. Build stages from specs with BuildStages.
+	Stages []Stage
+
+	// Tokenizer counts tokens for every stage. Nil uses the package-wide
+	// default from pkg/tokenizer.
+	Tokenizer tokenizer.Counter
+
+	// Explain makes the compressors record the provenance Explain reports
+	// kept spans from. Without it Explain still diffs each chunk, but
+	// cannot name the compressors that rewrote it.
+	Explain bool
 }
 
 // DefaultOptions returns sensible defaults with all stages enabled.
@@ -72,121 +157,276 @@ func New() *Runner { return &Runner{} }
 // Run executes the configured stages against chunks and returns the result.
 func (r *Runner) Run(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, Stats, error) {
 	start := time.Now()
+	counter := tokenizer.OrDefault(opts.Tokenizer)
 	stats := Stats{
 		Stages:         make(map[string]StageStats),
-		OriginalTokens: estimateTokens(chunks),
+		OriginalTokens: countTokens(counter, chunks),
 	}
 
 	current := chunks
 
-	// ── Stage 1: Dedup ────────────────────────────────────────────────────────
-	dedupStats := StageStats{Enabled: opts.DedupEnabled}
-	if opts.DedupEnabled && len(current) > 1 {
-		t0 := time.Now()
-		dedupStats.InputTokens = estimateTokens(current)
-
-		threshold := opts.DedupThreshold
-		if threshold <= 0 {
-			threshold = 0.15
+	if len(opts.Stages) > 0 {
+		var err error
+		if current, err = runStages(ctx, opts.Stages, current, opts, counter, &stats); err != nil {
+			return nil, stats, err
 		}
-		lambda := opts.DedupLambda
-		if lambda <= 0 {
-			lambda = 0.7
+	} else {
+		var err error
+		if current, err = r.runFixed(ctx, current, opts, counter, &stats); err != nil {
+			return nil, stats, err
 		}
+	}
 
-		clusterResult := contextlab.ClusterByThreshold(current, threshold)
-		sel := contextlab.NewSelector(contextlab.DefaultSelectorConfig())
-		selected := sel.Select(clusterResult)
+	stats.FinalTokens = countTokens(counter, current)
+	stats.TotalReduction = reduction(stats.OriginalTokens, stats.FinalTokens)
+	stats.TotalLatency = time.Since(start)
 
-		if opts.DedupTargetK > 0 && len(selected) > opts.DedupTargetK {
-			mmrResult := contextlab.MMRRerank(selected, lambda, opts.DedupTargetK)
-			current = mmrResult
-		} else {
-			current = selected
-		}
+	return current, stats, nil
+}
 
-		dedupStats.OutputTokens = estimateTokens(current)
+// runFixed runs the built-in dedup → compress → summarize order, honouring
+// the per-stage Enabled toggles.
+func (r *Runner) runFixed(ctx context.Context, current []types.Chunk, opts Options, counter tokenizer.Counter, stats *Stats) ([]types.Chunk, error) {
+	// ── Stage 1: Dedup ────────────────────────────────────────────────────────
+	dedupStats := StageStats{Enabled: opts.DedupEnabled}
+	dedupStats.InputTokens = countTokens(counter, current)
+	if opts.DedupEnabled && len(current) > 1 {
+		t0 := time.Now()
+		var err error
+		if current, err = dedupChunks(current, opts, stats); err != nil {
+			return nil, fmt.Errorf("dedup stage: %w", err)
+		}
+		dedupStats.OutputTokens = countTokens(counter, current)
 		dedupStats.Reduction = reduction(dedupStats.InputTokens, dedupStats.OutputTokens)
 		dedupStats.Latency = time.Since(t0)
 	} else {
-		dedupStats.InputTokens = estimateTokens(current)
 		dedupStats.OutputTokens = dedupStats.InputTokens
 	}
-	stats.Stages["dedup"] = dedupStats
+	stats.addStage("dedup", dedupStats)
 
-	// ── Stage 2: Compress ─────────────────────────────────────────────────────
+	// ── Stage 2: Compress (with budget packing) ──────────────────────────────
 	compressStats := StageStats{Enabled: opts.CompressEnabled}
-	if opts.CompressEnabled && len(current) > 0 {
+	compressStats.InputTokens = countTokens(counter, current)
+	if (opts.MaxTokens > 0 || opts.CompressEnabled) && len(current) > 0 {
+		compressStats.Enabled = true
 		t0 := time.Now()
-		compressStats.InputTokens = estimateTokens(current)
-
-		compOpts := compress.DefaultOptions()
-		if opts.CompressTargetReduction > 0 {
-			compOpts.TargetReduction = opts.CompressTargetReduction
+		var err error
+		if current, err = compressChunks(ctx, current, opts, counter, stats); err != nil {
+			return nil, fmt.Errorf("compress stage: %w", err)
 		}
-
-		c := compress.NewExtractiveCompressor()
-		compressed, _, err := c.Compress(ctx, current, compOpts)
-		if err != nil {
-			return nil, stats, fmt.Errorf("compress stage: %w", err)
-		}
-		current = compressed
-
-		compressStats.OutputTokens = estimateTokens(current)
+		compressStats.OutputTokens = countTokens(counter, current)
 		compressStats.Reduction = reduction(compressStats.InputTokens, compressStats.OutputTokens)
 		compressStats.Latency = time.Since(t0)
 	} else {
-		compressStats.InputTokens = estimateTokens(current)
 		compressStats.OutputTokens = compressStats.InputTokens
 	}
-	stats.Stages["compress"] = compressStats
+	stats.addStage("compress", compressStats)
 
 	// ── Stage 3: Summarize ────────────────────────────────────────────────────
 	summarizeStats := StageStats{Enabled: opts.SummarizeEnabled}
+	summarizeStats.InputTokens = countTokens(counter, current)
 	if opts.SummarizeEnabled && len(current) > 0 {
 		t0 := time.Now()
-		summarizeStats.InputTokens = estimateTokens(current)
-
-		turns := chunksToTurns(current)
-		sumOpts := summarize.DefaultOptions()
-		sumOpts.MaxTokens = opts.SummarizeMaxTokens
-		sumOpts.PreserveRecent = opts.SummarizeRecent
-
-		s := summarize.NewHierarchicalSummarizer()
-		summarized, sumStats, err := s.Summarize(ctx, turns, sumOpts)
-		if err != nil {
-			return nil, stats, fmt.Errorf("summarize stage: %w", err)
+		var err error
+		if current, err = summarizeChunks(ctx, current, opts, counter, stats); err != nil {
+			return nil, fmt.Errorf("summarize stage: %w", err)
 		}
-		current = turnsToChunks(summarized, current)
-		_ = sumStats
-
-		summarizeStats.OutputTokens = estimateTokens(current)
+		summarizeStats.OutputTokens = countTokens(counter, current)
 		summarizeStats.Reduction = reduction(summarizeStats.InputTokens, summarizeStats.OutputTokens)
 		summarizeStats.Latency = time.Since(t0)
 	} else {
-		summarizeStats.InputTokens = estimateTokens(current)
 		summarizeStats.OutputTokens = summarizeStats.InputTokens
 	}
-	stats.Stages["summarize"] = summarizeStats
+	stats.addStage("summarize", summarizeStats)
 
-	stats.FinalTokens = estimateTokens(current)
-	stats.TotalReduction = reduction(stats.OriginalTokens, stats.FinalTokens)
-	stats.TotalLatency = time.Since(start)
+	return current, nil
+}
 
-	return current, stats, nil
+// addStage records a stage's statistics under name, in execution order.
+func (s *Stats) addStage(name string, st StageStats) {
+	s.Stages[name] = st
+	s.StageOrder = append(s.StageOrder, name)
 }
 
-// estimateTokens approximates total token count across chunks (4 chars ≈ 1 token).
-func estimateTokens(chunks []types.Chunk) int {
-	total := 0
-	for _, c := range chunks {
-		n := 0
-		for _, r := range c.Text {
-			if r != ' ' && r != '\n' && r != '\t' {
-				n++
+// Dedup methods for Options.DedupMethod.
+const (
+	// DedupAuto clusters by embedding when every chunk has one and falls
+	// back to lexical near-duplicate detection otherwise.
+	DedupAuto = ""
+
+	// DedupEmbedding clusters by cosine distance between embeddings.
+	DedupEmbedding = "embedding"
+
+	// DedupLexical groups chunks whose text is nearly identical
+	// (MinHash over word shingles). No embeddings are needed.
+	DedupLexical = "lexical"
+
+	// DedupHybrid removes lexical near-duplicates first, then clusters
+	// the rest by embedding.
+	DedupHybrid = "hybrid"
+)
+
+// ValidateDedupMethod reports whether method is a supported dedup method.
+func ValidateDedupMethod(method string) error {
+	switch method {
+	case DedupAuto, DedupEmbedding, DedupLexical, DedupHybrid:
+		return nil
+	}
+	return fmt.Errorf("unknown dedup method %q (supported: embedding, lexical, hybrid)", method)
+}
+
+// dedupChunks clusters near-duplicate chunks, keeps one per cluster and,
+// when DedupTargetK or DedupConstraints are set, MMR-reranks down to at
+// most that many within the constraints. The clustering is recorded in
+// stats.Dedup.
+func dedupChunks(current []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error) {
+	if err := ValidateDedupMethod(opts.DedupMethod); err != nil {
+		return nil, err
+	}
+	if err := opts.DedupConstraints.Validate(); err != nil {
+		return nil, err
+	}
+	method := opts.DedupMethod
+	if method == DedupAuto {
+		method = DedupEmbedding
+		for _, c := range current {
+			if len(c.Embedding) == 0 {
+				method = DedupLexical
+				break
 			}
 		}
-		total += (n + 3) / 4
+	}
+
+	threshold := opts.DedupThreshold
+	if threshold <= 0 {
+		threshold = 0.15
+	}
+	lambda := opts.DedupLambda
+	if lambda <= 0 {
+		lambda = 0.7
+	}
+
+	near := contextlab.NewNearDupDetector(contextlab.DefaultNearDupConfig())
+	clusterCfg := contextlab.DefaultClusterConfig()
+	clusterCfg.Threshold = threshold
+	clusterCfg.AutoThreshold = opts.DedupAutoThreshold
+	clusterer := contextlab.NewClusterer(clusterCfg)
+	var clusterResult *types.ClusterResult
+	switch method {
+	case DedupLexical:
+		clusterResult = near.Cluster(current)
+	case DedupHybrid:
+		clusterResult = clusterer.Cluster(near.Filter(current))
+	default:
+		clusterResult = clusterer.Cluster(current)
+	}
+	stats.Dedup = &DedupStats{
+		Method:        method,
+		Clusters:      clusterResult.ClusterCount,
+		Threshold:     clusterResult.Threshold,
+		ThresholdAuto: clusterResult.ThresholdAuto,
+	}
+	sel := contextlab.NewSelector(contextlab.DefaultSelectorConfig())
+	selected := sel.Select(clusterResult)
+
+	targetK := opts.DedupTargetK
+	if targetK <= 0 || targetK > len(selected) {
+		targetK = len(selected)
+	}
+	if len(selected) > targetK || opts.DedupConstraints.Active() {
+		mmr := contextlab.NewMMR(contextlab.MMRConfig{
+			Lambda:      lambda,
+			TargetK:     targetK,
+			Constraints: opts.DedupConstraints,
+		})
+		return mmr.Rerank(selected), nil
+	}
+	return selected, nil
+}
+
+// compressChunks runs the compress stage. With MaxTokens set it packs the
+// chunks into the budget (compressing only if CompressEnabled) and records
+// stats.Budget; otherwise it applies the configured compressor.
+func compressChunks(ctx context.Context, current []types.Chunk, opts Options, counter tokenizer.Counter, stats *Stats) ([]types.Chunk, error) {
+	compOpts := compressOptions(opts, counter)
+
+	var c compress.Compressor
+	if opts.CompressEnabled {
+		var err error
+		if c, err = compressorFor(opts); err != nil {
+			return nil, err
+		}
+		// Budget packing compresses chunk by chunk; embed the query once.
+		if compOpts, err = compress.PrepareQuery(ctx, compOpts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.MaxTokens > 0 {
+		packed, budget, err := packChunks(ctx, current, opts.MaxTokens, c, compOpts, counter)
+		if err != nil {
+			return nil, err
+		}
+		stats.Budget = &budget
+		return packed, nil
+	}
+
+	compressed, _, err := c.Compress(ctx, current, compOpts)
+	if err != nil {
+		return nil, err
+	}
+	return compressed, nil
+}
+
+// summarizeChunks runs the hierarchical summarizer over chunks as turns and
+// records its statistics in stats.Summarize.
+func summarizeChunks(ctx context.Context, current []types.Chunk, opts Options, counter tokenizer.Counter, stats *Stats) ([]types.Chunk, error) {
+	turns := chunksToTurns(current)
+	sumOpts := summarize.DefaultOptions()
+	sumOpts.MaxTokens = opts.SummarizeMaxTokens
+	sumOpts.PreserveRecent = opts.SummarizeRecent
+	sumOpts.Tokenizer = counter
+
+	s := &summarize.HierarchicalSummarizer{Text: opts.Summarizer}
+	summarized, sumStats, err := s.Summarize(ctx, turns, sumOpts)
+	if err != nil {
+		return nil, err
+	}
+	stats.Summarize = &sumStats
+	return turnsToChunks(summarized, current), nil
+}
+
+// compressOptions builds the options passed to the compress stage.
+func compressOptions(opts Options, counter tokenizer.Counter) compress.Options {
+	compOpts := compress.DefaultOptions()
+	if opts.CompressTargetReduction > 0 {
+		compOpts.TargetReduction = opts.CompressTargetReduction
+	}
+	compOpts.Tokenizer = counter
+	compOpts.Query = opts.Query
+	compOpts.QueryEmbedding = opts.QueryEmbedding
+	compOpts.Embedder = opts.Embedder
+	compOpts.Explain = opts.Explain
+	return compOpts
+}
+
+// compressorFor builds the compress stage from CompressChain, falling back to
+// CompressMode and then to extractive compression.
+func compressorFor(opts Options) (compress.Compressor, error) {
+	if len(opts.CompressChain) > 0 {
+		return compress.NewChain(opts.CompressChain...)
+	}
+	if opts.CompressMode == "" {
+		return compress.NewExtractiveCompressor(), nil
+	}
+	return compress.ForMode(opts.CompressMode)
+}
+
+// countTokens counts total tokens across chunks with counter.
+func countTokens(counter tokenizer.Counter, chunks []types.Chunk) int {
+	total := 0
+	for _, c := range chunks {
+		total += counter.Count(c.Text)
 	}
 	return total
 }
@@ -203,33 +443,188 @@ func reduction(in, out int) float64 {
 	return r
 }
 
-// chunksToTurns converts chunks to summarize.Turn for the summarize stage.
+// Metadata keys the summarize stage reads to rebuild conversation turns.
+// Importance uses ImportanceMetadataKey.
+const (
+	// RoleMetadataKey holds the turn's role: "system", "user", "assistant",
+	// or "tool". Defaults to "user".
+	RoleMetadataKey = "role"
+
+	// TimestampMetadataKey holds when the turn happened, as an RFC 3339
+	// string, a time.Time, or Unix seconds. Turns without one are treated
+	// as current, so only the token budget compresses them.
+	TimestampMetadataKey = "timestamp"
+
+	// SummaryLevelMetadataKey is set on chunks the summarize stage
+	// compressed, to the summarize.Level (1 paragraph, 2 sentence,
+	// 3 keywords) they were reduced to.
+	SummaryLevelMetadataKey = "summary_level"
+)
+
+// chunksToTurns converts chunks to summarize.Turn for the summarize stage,
+// taking role, timestamp, and importance from chunk metadata. Each turn's
+// ID is its chunk's index, since chunk IDs may be empty or repeated.
 func chunksToTurns(chunks []types.Chunk) []summarize.Turn {
+	now := time.Now()
 	turns := make([]summarize.Turn, len(chunks))
 	for i, c := range chunks {
-		turns[i] = summarize.Turn{
-			ID:      c.ID,
-			Role:    "user",
-			Content: c.Text,
+		t := summarize.Turn{
+			ID:        strconv.Itoa(i),
+			Role:      "user",
+			Content:   c.Text,
+			Timestamp: now,
+		}
+		if role, ok := c.Metadata[RoleMetadataKey].(string); ok && role != "" {
+			t.Role = strings.ToLower(role)
+		}
+		if ts, ok := types.MetadataTime(c.Metadata[TimestampMetadataKey]); ok {
+			t.Timestamp = ts
+		}
+		if imp, ok := c.Metadata[ImportanceMetadataKey].(float64); ok && imp > 0 {
+			t.Importance = math.Min(imp, 1)
 		}
+		turns[i] = t
 	}
 	return turns
 }
 
-// turnsToChunks maps summarized turns back to chunks, preserving metadata.
+// turnsToChunks maps summarized turns back to the chunks they came from by
+// index, preserving metadata and recording the summary level of compressed
+// turns. The summarizer keeps turn order and only drops evicted turns.
 func turnsToChunks(turns []summarize.Turn, original []types.Chunk) []types.Chunk {
-	byID := make(map[string]types.Chunk, len(original))
-	for _, c := range original {
-		byID[c.ID] = c
-	}
 	out := make([]types.Chunk, 0, len(turns))
 	for _, t := range turns {
-		c, ok := byID[t.ID]
-		if !ok {
-			c = types.Chunk{ID: t.ID}
+		var c types.Chunk
+		if i, err := strconv.Atoi(t.ID); err == nil && i >= 0 && i < len(original) {
+			c = original[i]
+		}
+		if t.Level > summarize.LevelFull && t.Content != c.Text {
+			c = *c.Clone()
+			c.Metadata[SummaryLevelMetadataKey] = int(t.Level)
 		}
 		c.Text = t.Content
 		out = append(out, c)
 	}
 	return out
 }
+
+// Chunk statuses reported by Explain.
+const (
+	StatusUnchanged  = "unchanged"
+	StatusCompressed = "compressed"
+	StatusRewritten  = "rewritten"
+	StatusDropped    = "dropped"
+	StatusAdded      = "added"
+)
+
+// ChunkReport explains what the pipeline did to one chunk.
+type ChunkReport struct {
+	ID string
+
+	// Status is one of StatusUnchanged, StatusCompressed (changed by a
+	// compressor, with provenance), StatusRewritten (changed by a later
+	// stage such as summarize), StatusDropped (removed by dedup), or
+	// StatusAdded (not among the inputs).
+	Status string
+
+	OriginalHash   string
+	OriginalTokens int
+	FinalTokens    int
+
+	// Compressors lists the compressors that changed the chunk, in order.
+	Compressors []string
+
+	// Kept lists the spans of the original text kept verbatim.
+	Kept []compress.Span
+
+	// Diff is a line-oriented report: "  " kept, "- " dropped, "+ " inserted.
+	Diff string
+}
+
+// Explain compares the chunks passed to Run with the chunks it returned
+// and reports, per chunk, what was kept, dropped, and inserted. Inputs are
+// reported in order, followed by any outputs that have no matching input.
+// Chunks are matched by ID; chunks without one are matched by the text
+// their output derives from, and repeated keys pair up in order. Run with
+// Options.Explain set so compressed chunks carry the provenance naming
+// their compressors. A nil counter uses the package-wide default.
+func Explain(input, output []types.Chunk, counter tokenizer.Counter) []ChunkReport {
+	counter = tokenizer.OrDefault(counter)
+	pending := make(map[string][]int, len(output))
+	for j, c := range output {
+		key := outputKey(c)
+		pending[key] = append(pending[key], j)
+	}
+	matched := make([]bool, len(output))
+
+	reports := make([]ChunkReport, 0, len(input))
+	for _, in := range input {
+		r := ChunkReport{
+			ID:             in.ID,
+			OriginalHash:   compress.HashText(in.Text),
+			OriginalTokens: counter.Count(in.Text),
+		}
+
+		var out types.Chunk
+		ok := false
+		key := inputKey(in)
+		if queue := pending[key]; len(queue) > 0 {
+			out, ok = output[queue[0]], true
+			matched[queue[0]] = true
+			pending[key] = queue[1:]
+		}
+		switch {
+		case !ok:
+			r.Status = StatusDropped
+			r.Diff = compress.Explain(in.Text, types.Chunk{})
+		case out.Text == in.Text:
+			r.Status = StatusUnchanged
+			r.FinalTokens = r.OriginalTokens
+			r.Kept = []compress.Span{{Start: 0, End: len(in.Text)}}
+		default:
+			r.Status = StatusRewritten
+			if p, ok := compress.VerifiedProvenance(in.Text, out); ok {
+				r.Compressors = p.Compressors
+				r.Kept = p.Kept
+				r.Status = StatusCompressed
+			}
+			r.FinalTokens = counter.Count(out.Text)
+			r.Diff = compress.Explain(in.Text, out)
+		}
+		reports = append(reports, r)
+	}
+
+	for j, out := range output {
+		if matched[j] {
+			continue
+		}
+		reports = append(reports, ChunkReport{
+			ID:          out.ID,
+			Status:      StatusAdded,
+			FinalTokens: counter.Count(out.Text),
+			Diff:        compress.Explain("", out),
+		})
+	}
+	return reports
+}
+
+// inputKey identifies an input chunk for Explain: its ID, or the hash of
+// its text when it has none.
+func inputKey(c types.Chunk) string {
+	if c.ID != "" {
+		return "id:" + c.ID
+	}
+	return compress.HashText(c.Text)
+}
+
+// outputKey identifies the input an output chunk came from: its ID, or the
+// original hash its provenance records, or the hash of its own text.
+func outputKey(c types.Chunk) string {
+	if c.ID != "" {
+		return "id:" + c.ID
+	}
+	if p, ok := compress.ProvenanceOf(c); ok {
+		return p.OriginalHash
+	}
+	return compress.HashText(c.Text)
+}
@@ -2,8 +2,15 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Siddhant-K-code/distill/pkg/compress"
+	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 )
 
@@ -29,6 +36,24 @@ func TestRun_AllStagesDisabled(t *testing.T) {
 	}
 }
 
+type wordCounter struct{}
+
+func (wordCounter) Count(text string) int { return len(strings.Fields(text)) }
+func (wordCounter) Name() string          { return "words" }
+
+func TestRun_CustomTokenizer(t *testing.T) {
+	r := New()
+	chunks := []types.Chunk{makeChunk("a", "hello world"), makeChunk("b", "foo bar baz")}
+
+	_, stats, err := r.Run(context.Background(), chunks, Options{Tokenizer: wordCounter{}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.OriginalTokens != 5 {
+		t.Errorf("want 5 tokens from the request tokenizer, got %d", stats.OriginalTokens)
+	}
+}
+
 func TestRun_DedupOnly(t *testing.T) {
 	r := New()
 	ctx := context.Background()
@@ -54,6 +79,127 @@ func TestRun_DedupOnly(t *testing.T) {
 	}
 }
 
+func TestRun_DedupLexical(t *testing.T) {
+	r := New()
+	ctx := context.Background()
+
+	base := "Distill deduplicates retrieved chunks before they reach the model so the context window holds more distinct information"
+	chunks := []types.Chunk{
+		makeChunk("a", base),
+		makeChunk("b", "A completely different sentence about tokenizers and how they split text into pieces"),
+		makeChunk("c", base+" today"), // near-duplicate of a
+	}
+	chunks[2].Score = 0.9
+
+	// No embeddings: the auto method falls back to lexical detection.
+	result, _, err := r.Run(ctx, chunks, Options{DedupEnabled: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 chunks after lexical dedup, got %d", len(result))
+	}
+	ids := map[string]bool{}
+	for _, c := range result {
+		ids[c.ID] = true
+	}
+	if !ids["b"] || !ids["c"] {
+		t.Errorf("expected distinct chunk and higher-scored near-duplicate, got %v", ids)
+	}
+
+	if _, _, err := r.Run(ctx, chunks, Options{DedupEnabled: true, DedupMethod: "fuzzy"}); err == nil {
+		t.Error("expected error for unknown dedup method")
+	}
+}
+
+func TestRun_DedupConstraints(t *testing.T) {
+	texts := []string{
+		"Install the command line tool with go install",
+		"Configure retries and timeouts in the yaml file",
+		"Rotate API keys from the dashboard every ninety days",
+		"The broker clusters chunks before selecting representatives",
+	}
+	chunks := make([]types.Chunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = makeChunk(string(rune('a'+i)), text)
+		chunks[i].Score = float32(len(texts) - i)
+		chunks[i].Metadata = map[string]interface{}{"doc": "guide.md"}
+	}
+	chunks[3].Metadata["doc"] = "design.md"
+
+	stages, err := BuildStages([]StageSpec{
+		{Type: "dedup", Params: map[string]interface{}{"source_field": "doc", "max_per_source": 2}},
+	})
+	if err != nil {
+		t.Fatalf("BuildStages: %v", err)
+	}
+	result, _, err := New().Run(context.Background(), chunks, Options{Stages: stages})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, c := range result {
+		ids[c.ID] = true
+	}
+	if len(result) != 3 || !ids["a"] || !ids["b"] || !ids["d"] {
+		t.Errorf("expected the top 2 guide.md chunks and design.md, got %v", ids)
+	}
+
+	opts := Options{DedupEnabled: true, DedupConstraints: contextlab.SelectionConstraints{MaxPerSource: 1}}
+	if _, _, err := New().Run(context.Background(), chunks, opts); err == nil {
+		t.Error("expected error for max_per_source without a source field")
+	}
+}
+
+func TestRun_DedupAutoThreshold(t *testing.T) {
+	// Two near-duplicate pairs and three distinct chunks.
+	vecs := [][]float32{
+		{1, 0, 0, 0, 0, 0}, {1, 0.1, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0}, {0, 1, 0.1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0}, {0, 0, 0, 0, 1, 0}, {0, 0, 0, 0, 0, 1},
+	}
+	chunks := make([]types.Chunk, len(vecs))
+	for i, v := range vecs {
+		chunks[i] = makeChunk(fmt.Sprintf("c%d", i), fmt.Sprintf("chunk number %d", i))
+		chunks[i].Embedding = v
+	}
+
+	opts := Options{DedupEnabled: true, DedupThreshold: 0.001}
+	result, stats, err := New().Run(context.Background(), chunks, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result) != 7 || stats.Dedup == nil || stats.Dedup.ThresholdAuto {
+		t.Fatalf("expected the fixed threshold to keep all chunks, got %d (%+v)", len(result), stats.Dedup)
+	}
+
+	opts.DedupAutoThreshold = true
+	result, stats, err = New().Run(context.Background(), chunks, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result) != 5 {
+		t.Errorf("expected both pairs merged, got %d chunks", len(result))
+	}
+	if d := stats.Dedup; d == nil || !d.ThresholdAuto || d.Clusters != 5 || d.Method != DedupEmbedding {
+		t.Errorf("unexpected dedup stats %+v", stats.Dedup)
+	}
+
+	stages, err := BuildStages([]StageSpec{
+		{Type: "dedup", Params: map[string]interface{}{"threshold": 0.001, "auto_threshold": true}},
+	})
+	if err != nil {
+		t.Fatalf("BuildStages: %v", err)
+	}
+	result, stats, err = New().Run(context.Background(), chunks, Options{Stages: stages})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result) != 5 || stats.Dedup == nil || !stats.Dedup.ThresholdAuto {
+		t.Errorf("expected the dedup stage to pick the threshold, got %d chunks (%+v)", len(result), stats.Dedup)
+	}
+}
+
 func TestRun_CompressOnly(t *testing.T) {
 	r := New()
 	ctx := context.Background()
@@ -73,6 +219,56 @@ func TestRun_CompressOnly(t *testing.T) {
 	}
 }
 
+func TestRun_CompressMode(t *testing.T) {
+	r := New()
+	ctx := context.Background()
+	toolOutput := `[{"id": 1, "status": "ok"}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}, {"id": 6}]`
+	chunks := []types.Chunk{makeChunk("a", toolOutput)}
+
+	// The default extractive compressor leaves single-"sentence" JSON alone.
+	result, _, err := r.Run(ctx, chunks, Options{CompressEnabled: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result[0].Text != toolOutput {
+		t.Errorf("expected extractive mode to keep JSON, got %q", result[0].Text)
+	}
+
+	result, _, err = r.Run(ctx, chunks, Options{CompressEnabled: true, CompressMode: compress.ModeAuto})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(result[0].Text, "more") {
+		t.Errorf("expected auto mode to placeholder-compress JSON, got %q", result[0].Text)
+	}
+
+	_, _, err = r.Run(ctx, chunks, Options{CompressEnabled: true, CompressChain: []compress.Mode{"bogus"}})
+	if !errors.Is(err, compress.ErrUnknownMode) {
+		t.Errorf("expected ErrUnknownMode, got %v", err)
+	}
+}
+
+func TestRun_Query(t *testing.T) {
+	r := New()
+	text := "This important overview must be read first. " +
+		"The cache layer stores rendered pages for ten minutes. " +
+		"Database migrations run automatically on deploy. " +
+		"Operators should review the release notes key points."
+	chunks := []types.Chunk{makeChunk("a", text)}
+
+	result, _, err := r.Run(context.Background(), chunks, Options{
+		CompressEnabled:         true,
+		CompressTargetReduction: 0.3,
+		Query:                   "When do database migrations run?",
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(result[0].Text, "migrations run automatically") {
+		t.Errorf("expected compression to keep the query-relevant sentence, got %q", result[0].Text)
+	}
+}
+
 func TestRun_SummarizeEnabled(t *testing.T) {
 	r := New()
 	ctx := context.Background()
@@ -96,6 +292,88 @@ func TestRun_SummarizeEnabled(t *testing.T) {
 	_ = result
 }
 
+func TestRun_SummarizeTurnMetadata(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	text := "The deployment pipeline rebuilt every container image after the base layer changed. " +
+		"Integration tests then ran against the staging cluster for twenty minutes. " +
+		"Two flaky tests were retried before the release was promoted."
+	turn := func(id, role, ts string) types.Chunk {
+		c := makeChunk(id, text)
+		c.Metadata = map[string]interface{}{RoleMetadataKey: role}
+		if ts != "" {
+			c.Metadata[TimestampMetadataKey] = ts
+		}
+		return c
+	}
+	chunks := []types.Chunk{
+		turn("sys", "system", old),
+		turn("old", "assistant", old),
+		turn("pinned", "assistant", old),
+		turn("new", "assistant", ""),
+	}
+	chunks[2].Metadata[ImportanceMetadataKey] = 0.9
+
+	result, stats, err := New().Run(context.Background(), chunks, Options{SummarizeEnabled: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	byID := make(map[string]types.Chunk)
+	for _, c := range result {
+		byID[c.ID] = c
+	}
+	if byID["sys"].Text != text || byID["new"].Text != text {
+		t.Error("system and current turns should be preserved")
+	}
+	if byID["old"].Text == text || byID["old"].Metadata[SummaryLevelMetadataKey] == nil {
+		t.Errorf("old assistant turn should be summarized, got %+v", byID["old"])
+	}
+	if lvl, _ := byID["pinned"].Metadata[SummaryLevelMetadataKey].(int); lvl > 1 {
+		t.Errorf("important turn should be kept at paragraph level, got level %d", lvl)
+	}
+	if _, ok := chunks[1].Metadata[SummaryLevelMetadataKey]; ok {
+		t.Error("input chunk metadata should not be modified")
+	}
+	if stats.Summarize == nil || stats.Summarize.InputTurns != 4 || stats.Summarize.CompressedTurns == 0 {
+		t.Errorf("expected summarize stats, got %+v", stats.Summarize)
+	}
+}
+
+func TestRun_SummarizeChunksWithoutIDs(t *testing.T) {
+	roles := []string{"system", "user", "assistant"}
+	var chunks []types.Chunk
+	for _, role := range roles {
+		chunks = append(chunks, types.Chunk{
+			Text:     "A " + role + " turn about the release.",
+			Metadata: map[string]interface{}{RoleMetadataKey: role},
+		})
+	}
+
+	result, _, err := New().Run(context.Background(), chunks, Options{SummarizeEnabled: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result) != len(roles) {
+		t.Fatalf("expected %d chunks, got %d", len(roles), len(result))
+	}
+	for i, c := range result {
+		if c.Metadata[RoleMetadataKey] != roles[i] || c.Text != chunks[i].Text {
+			t.Errorf("chunk %d: expected the %s turn, got %q with role %v", i, roles[i], c.Text, c.Metadata[RoleMetadataKey])
+		}
+	}
+}
+
+func TestMetadataTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, v := range []interface{}{want, "2024-05-01T12:00:00Z", float64(want.Unix()), want.Unix()} {
+		if got, ok := types.MetadataTime(v); !ok || !got.Equal(want) {
+			t.Errorf("MetadataTime(%v) = %v, %v", v, got, ok)
+		}
+	}
+	if _, ok := types.MetadataTime("yesterday"); ok {
+		t.Error("expected unparseable timestamp to be ignored")
+	}
+}
+
 func TestRun_DefaultOptions(t *testing.T) {
 	r := New()
 	ctx := context.Background()
@@ -146,9 +424,9 @@ func TestStats_StagesPresent(t *testing.T) {
 	}
 }
 
-func TestEstimateTokens(t *testing.T) {
+func TestCountTokens(t *testing.T) {
 	chunks := []types.Chunk{{Text: "hello world"}}
-	n := estimateTokens(chunks)
+	n := countTokens(tokenizer.Default(), chunks)
 	if n == 0 {
 		t.Error("expected non-zero token estimate")
 	}
@@ -165,3 +443,304 @@ func TestReduction(t *testing.T) {
 		t.Errorf("want 0 for negative reduction, got %.2f", r)
 	}
 }
+
+func TestExplain(t *testing.T) {
+	r := New()
+	prose := "This important overview must be read first. " +
+		"The cache layer stores rendered pages for ten minutes. " +
+		"Database migrations run automatically on deploy. " +
+		"Operators should review the release notes key points."
+	chunks := []types.Chunk{
+		makeChunk("prose", prose),
+		makeChunk("short", "tiny"),
+		{ID: "dup1", Text: "duplicate", Embedding: []float32{1, 0}},
+		{ID: "dup2", Text: "duplicate", Embedding: []float32{1, 0}},
+	}
+
+	result, _, err := r.Run(context.Background(), chunks, Options{
+		DedupEnabled:            true,
+		CompressEnabled:         true,
+		CompressTargetReduction: 0.5,
+		Explain:                 true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	reports := Explain(chunks, result, nil)
+	byID := make(map[string]ChunkReport)
+	for _, rep := range reports {
+		byID[rep.ID] = rep
+	}
+
+	p := byID["prose"]
+	if p.Status != StatusCompressed || len(p.Compressors) != 1 || p.Compressors[0] != "extractive" {
+		t.Errorf("expected prose compressed by extractive, got %+v", p)
+	}
+	if !strings.Contains(p.Diff, "- ") || !strings.Contains(p.Diff, "  This important overview") {
+		t.Errorf("expected diff with kept and dropped lines, got:\n%s", p.Diff)
+	}
+	if p.FinalTokens >= p.OriginalTokens {
+		t.Errorf("expected fewer tokens, got %d -> %d", p.OriginalTokens, p.FinalTokens)
+	}
+	if byID["short"].Status != StatusUnchanged {
+		t.Errorf("expected short chunk unchanged, got %+v", byID["short"])
+	}
+	dropped1, dropped2 := byID["dup1"].Status == StatusDropped, byID["dup2"].Status == StatusDropped
+	if dropped1 == dropped2 {
+		t.Errorf("expected exactly one duplicate dropped, got %q and %q", byID["dup1"].Status, byID["dup2"].Status)
+	}
+}
+
+func TestExplain_ChunksWithoutIDs(t *testing.T) {
+	prose := "This important overview must be read first. " +
+		"The cache layer stores rendered pages for ten minutes. " +
+		"Database migrations run automatically on deploy. " +
+		"Operators should review the release notes key points."
+	chunks := []types.Chunk{{Text: prose}, {Text: "tiny"}, {Text: "gone"}}
+
+	result, _, err := New().Run(context.Background(), chunks[:2], Options{
+		CompressEnabled:         true,
+		CompressTargetReduction: 0.5,
+		Explain:                 true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	reports := Explain(chunks, result, nil)
+	var statuses []string
+	for _, rep := range reports {
+		statuses = append(statuses, rep.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "compressed,unchanged,dropped" {
+		t.Errorf("expected each input matched to its own output, got %s", got)
+	}
+}
+
+func TestRun_MaxTokens(t *testing.T) {
+	r := New()
+	sentences := func(topic string, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "The %s service handled request batch number %d without errors. ", topic, i)
+		}
+		return strings.TrimSpace(b.String())
+	}
+	chunks := []types.Chunk{
+		{ID: "high", Text: sentences("billing", 6), Score: 0.9},
+		{ID: "low-large", Text: sentences("logging", 12), Score: 0.2},
+		{ID: "mid", Text: sentences("search", 6), Score: 0.5},
+	}
+	input := countTokens(tokenizer.Default(), chunks)
+
+	result, stats, err := r.Run(context.Background(), chunks, Options{
+		CompressEnabled: true,
+		MaxTokens:       input / 2,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Budget == nil {
+		t.Fatal("expected budget stats")
+	}
+	if stats.FinalTokens > input/2 || stats.Budget.UsedTokens != stats.FinalTokens {
+		t.Errorf("expected output within budget %d, got final=%d used=%d", input/2, stats.FinalTokens, stats.Budget.UsedTokens)
+	}
+	if len(result) != 3 || stats.Budget.DroppedChunks != 0 {
+		t.Errorf("expected compression alone to fit, got %d chunks and %d dropped", len(result), stats.Budget.DroppedChunks)
+	}
+
+	alloc := make(map[string]ChunkAllocation)
+	for _, a := range stats.Budget.Allocations {
+		alloc[a.ID] = a
+	}
+	if alloc["low-large"].KeepRatio >= alloc["mid"].KeepRatio || alloc["mid"].KeepRatio >= alloc["high"].KeepRatio {
+		t.Errorf("expected keep ratio to rise with utility, got %+v", stats.Budget.Allocations)
+	}
+
+	// A budget too small for everything drops the lowest-utility chunk.
+	result, stats, err = r.Run(context.Background(), chunks, Options{
+		CompressEnabled: true,
+		MaxTokens:       input / 8,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.FinalTokens > input/8 {
+		t.Errorf("expected output within budget %d, got %d", input/8, stats.FinalTokens)
+	}
+	for _, c := range result {
+		if c.ID == "low-large" {
+			t.Errorf("expected lowest-utility chunk dropped, got %v", result)
+		}
+	}
+	if stats.Budget.DroppedChunks == 0 {
+		t.Error("expected dropped chunks to be reported")
+	}
+
+	// MaxTokens alone still runs the compress stage, which stats report.
+	_, stats, err = r.Run(context.Background(), chunks, Options{MaxTokens: input / 2})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !stats.Stages["compress"].Enabled || stats.Budget == nil {
+		t.Errorf("expected the compress stage reported as run, got %+v", stats.Stages["compress"])
+	}
+}
+
+// countingEmbedder embeds every text as the same vector and counts single
+// embedding calls.
+type countingEmbedder struct{ calls int }
+
+func (e *countingEmbedder) Embed(context.Context, string) ([]float32, error) {
+	e.calls++
+	return []float32{1, 0}, nil
+}
+
+func (e *countingEmbedder) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range out {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func (e *countingEmbedder) Dimension() int    { return 2 }
+func (e *countingEmbedder) ModelName() string { return "counting" }
+
+func TestRun_MaxTokensEmbedsQueryOnce(t *testing.T) {
+	var chunks []types.Chunk
+	for i := 0; i < 4; i++ {
+		chunks = append(chunks, makeChunk(fmt.Sprintf("c%d", i), strings.Repeat(
+			fmt.Sprintf("The service %d handled a request batch without errors. ", i), 8)))
+	}
+	embedder := &countingEmbedder{}
+	_, stats, err := New().Run(context.Background(), chunks, Options{
+		CompressEnabled: true,
+		MaxTokens:       countTokens(tokenizer.Default(), chunks) / 2,
+		Query:           "Which batches failed?",
+		Embedder:        embedder,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Budget == nil || len(stats.Budget.Allocations) != 4 {
+		t.Fatalf("expected every chunk packed, got %+v", stats.Budget)
+	}
+	if embedder.calls != 1 {
+		t.Errorf("expected the query embedded once, got %d calls", embedder.calls)
+	}
+}
+
+func TestMatchMetadata(t *testing.T) {
+	ch := types.Chunk{Metadata: map[string]interface{}{"sourceType": "tool", "pinned": true}}
+	for _, tt := range []struct {
+		when map[string]string
+		want bool
+	}{
+		{map[string]string{"sourceType": "tool"}, true},
+		// distill.yaml keys arrive lower-cased.
+		{map[string]string{"sourcetype": "tool"}, true},
+		{map[string]string{"sourcetype": "tool", "pinned": "*"}, true},
+		{map[string]string{"sourcetype": "user"}, false},
+		{map[string]string{"missing": "*"}, false},
+	} {
+		if got := matchMetadata(ch, tt.when); got != tt.want {
+			t.Errorf("matchMetadata(%v) = %v, want %v", tt.when, got, tt.want)
+		}
+	}
+}
+
+type upperStage struct{ suffix string }
+
+func (upperStage) Name() string { return "upper" }
+
+func (s upperStage) Process(_ context.Context, chunks []types.Chunk, _ Options) ([]types.Chunk, error) {
+	out := make([]types.Chunk, len(chunks))
+	for i, c := range chunks {
+		c.Text = strings.ToUpper(c.Text) + s.suffix
+		out[i] = c
+	}
+	return out, nil
+}
+
+func TestBuildStages(t *testing.T) {
+	stages, err := BuildStages([]StageSpec{
+		{Type: "pruner"},
+		{Type: "dedup", Params: map[string]interface{}{"threshold": 0.1}},
+		{Type: "dedup"},
+		{Name: "final", Type: "compress", Params: map[string]interface{}{"mode": "extractive", "max_tokens": 100}},
+	})
+	if err != nil {
+		t.Fatalf("BuildStages: %v", err)
+	}
+	var names []string
+	for _, s := range stages {
+		names = append(names, s.Name())
+	}
+	if got := strings.Join(names, ","); got != "pruner,dedup,dedup#2,final" {
+		t.Errorf("unexpected stage names %q", got)
+	}
+
+	bad := []struct {
+		name string
+		spec []StageSpec
+	}{
+		{"missing type", []StageSpec{{Name: "x"}}},
+		{"unknown type", []StageSpec{{Type: "shrink"}}},
+		{"unknown param", []StageSpec{{Type: "dedup", Params: map[string]interface{}{"threshhold": 0.1}}}},
+		{"bad half-life", []StageSpec{{Type: "dedup", Params: map[string]interface{}{"freshness_field": "ts", "freshness_half_life": "a week"}}}},
+		{"bad mode", []StageSpec{{Type: "compress", Params: map[string]interface{}{"mode": "zip"}}}},
+		{"mode on mode stage", []StageSpec{{Type: "pruner", Params: map[string]interface{}{"mode": "log"}}}},
+		{"duplicate name", []StageSpec{{Name: "a", Type: "dedup"}, {Name: "a", Type: "pruner"}}},
+	}
+	for _, tt := range bad {
+		if _, err := BuildStages(tt.spec); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRun_Stages(t *testing.T) {
+	RegisterStage("upper", func(params map[string]interface{}) (Stage, error) {
+		suffix, _ := params["suffix"].(string)
+		return upperStage{suffix: suffix}, nil
+	})
+
+	stages, err := BuildStages([]StageSpec{
+		{Type: "upper", Params: map[string]interface{}{"suffix": "!"}, When: map[string]string{"source": "tool"}},
+		{Type: "dedup"},
+		{Type: "upper", When: map[string]string{"pinned": "*"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildStages: %v", err)
+	}
+
+	chunks := []types.Chunk{
+		makeChunk("a", "plain prose"),
+		{ID: "b", Text: "tool output", Metadata: map[string]interface{}{"source": "tool"}},
+		{ID: "c", Text: "pinned note", Metadata: map[string]interface{}{"pinned": true}},
+	}
+	// Toggles are ignored when Stages is set.
+	result, stats, err := New().Run(context.Background(), chunks, Options{Stages: stages, CompressEnabled: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []string{"plain prose", "TOOL OUTPUT!", "PINNED NOTE"}
+	if len(result) != len(want) {
+		t.Fatalf("want %d chunks, got %d", len(want), len(result))
+	}
+	for i, w := range want {
+		if result[i].Text != w {
+			t.Errorf("chunk %d: want %q, got %q", i, w, result[i].Text)
+		}
+	}
+	if got := strings.Join(stats.StageOrder, ","); got != "upper,dedup,upper#2" {
+		t.Errorf("unexpected stage order %q", got)
+	}
+	if _, ok := stats.Stages["compress"]; ok {
+		t.Error("fixed compress stage should not run when Stages is set")
+	}
+}
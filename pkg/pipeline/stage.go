@@ -0,0 +1,471 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/compress"
+	"github.com/Siddhant-K-code/distill/pkg/contextlab"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+	"github.com/Siddhant-K-code/distill/pkg/types"
+)
+
+// Built-in stage types accepted by BuildStages. Every compress.Mode
+// (extractive, pruner, code, ...) is also a stage type, shorthand for a
+// compress stage with that mode.
+const (
+	StageDedup     = "dedup"
+	StageCompress  = "compress"
+	StageSummarize = "summarize"
+)
+
+// Stage is one step of a user-defined pipeline. Process receives the
+// chunks left by the previous stage and the run's Options, which carry the
+// tokenizer, query, and defaults for any parameter the stage leaves unset.
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, error)
+}
+
+// StageFactory builds a Stage from the parameters in its StageSpec.
+// Register custom stage types with RegisterStage.
+type StageFactory func(params map[string]interface{}) (Stage, error)
+
+// StageSpec declares one stage of a pipeline, typically from the
+// "pipelines" section of distill.yaml.
+type StageSpec struct {
+	// Name keys the stage in Stats.Stages. Defaults to Type; repeated
+	// unnamed types are numbered ("dedup", "dedup#2", ...).
+	Name string
+
+	// Type selects a built-in stage or one registered with RegisterStage.
+	Type string
+
+	// Params configures the stage; keys are the snake_case JSON names of
+	// the stage's parameters.
+	Params map[string]interface{}
+
+	// When restricts the stage to chunks whose metadata matches every
+	// entry. A value of "*" matches any value that is present. Keys match
+	// case-insensitively when no key matches exactly. Other chunks pass
+	// through untouched and keep their position.
+	When map[string]string
+}
+
+var (
+	stageMu        sync.RWMutex
+	stageFactories = map[string]StageFactory{}
+)
+
+// RegisterStage registers a custom stage type for use in StageSpec.Type.
+// Call this from an init() function in the package defining the stage.
+// Registered types take precedence over built-ins.
+func RegisterStage(typ string, f StageFactory) {
+	stageMu.Lock()
+	defer stageMu.Unlock()
+	stageFactories[typ] = f
+}
+
+// StageTypes returns the built-in and registered stage types, sorted.
+func StageTypes() []string {
+	names := []string{StageDedup, StageCompress, StageSummarize}
+	for _, m := range compress.Modes() {
+		names = append(names, string(m))
+	}
+	stageMu.RLock()
+	for t := range stageFactories {
+		names = append(names, t)
+	}
+	stageMu.RUnlock()
+	sort.Strings(names)
+
+	out := names[:0]
+	for i, t := range names {
+		if i == 0 || t != names[i-1] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// BuildStages validates specs and builds the stages they declare, ready to
+// be set as Options.Stages.
+func BuildStages(specs []StageSpec) ([]Stage, error) {
+	stages := make([]Stage, 0, len(specs))
+	names := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if spec.Type == "" {
+			return nil, fmt.Errorf("stage %d: type is required", i+1)
+		}
+		st, err := newStage(spec.Type, spec.Params)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i+1, spec.Type, err)
+		}
+
+		name := spec.Name
+		if name == "" {
+			name = spec.Type
+			for n := 2; names[name]; n++ {
+				name = fmt.Sprintf("%s#%d", spec.Type, n)
+			}
+		} else if names[name] {
+			return nil, fmt.Errorf("stage %d: duplicate stage name %q", i+1, name)
+		}
+		names[name] = true
+
+		stages = append(stages, &specStage{name: name, when: spec.When, stage: st})
+	}
+	return stages, nil
+}
+
+// newStage resolves a stage type to a Stage.
+func newStage(typ string, params map[string]interface{}) (Stage, error) {
+	stageMu.RLock()
+	f, ok := stageFactories[typ]
+	stageMu.RUnlock()
+	if ok {
+		return f(params)
+	}
+
+	switch typ {
+	case StageDedup:
+		st := &dedupStage{}
+		if err := decodeParams(params, st); err != nil {
+			return nil, err
+		}
+		if _, err := st.Apply(contextlab.SelectionConstraints{}); err != nil {
+			return nil, err
+		}
+		return st, ValidateDedupMethod(st.Method)
+	case StageCompress:
+		st := &compressStage{}
+		if err := decodeParams(params, st); err != nil {
+			return nil, err
+		}
+		return st, st.validate()
+	case StageSummarize:
+		st := &summarizeStage{}
+		return st, decodeParams(params, st)
+	}
+
+	mode := compress.Mode(typ)
+	if _, err := compress.ForMode(mode); err != nil {
+		return nil, fmt.Errorf("unknown stage type %q (supported: %s)", typ, strings.Join(StageTypes(), ", "))
+	}
+	st := &compressStage{Mode: typ}
+	if err := decodeParams(params, st); err != nil {
+		return nil, err
+	}
+	if st.Mode != typ || len(st.Compressors) > 0 {
+		return nil, fmt.Errorf("%s stage does not take mode or compressors; use a compress stage", typ)
+	}
+	return st, nil
+}
+
+// decodeParams copies params into dst through their JSON names, rejecting
+// unknown keys so that typos in config files are reported.
+func decodeParams(params map[string]interface{}, dst interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+// statsStage is implemented by built-in stages that report more than token
+// counts (such as budget packing) on the run's Stats.
+type statsStage interface {
+	process(ctx context.Context, chunks []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error)
+}
+
+// runStages runs each stage in turn, recording per-stage statistics.
+func runStages(ctx context.Context, stages []Stage, current []types.Chunk, opts Options, counter tokenizer.Counter, stats *Stats) ([]types.Chunk, error) {
+	opts.Stages = nil
+	opts.Tokenizer = counter
+	for _, st := range stages {
+		t0 := time.Now()
+		ss := StageStats{Enabled: true, InputTokens: countTokens(counter, current)}
+
+		var err error
+		if s, ok := st.(statsStage); ok {
+			current, err = s.process(ctx, current, opts, stats)
+		} else {
+			current, err = st.Process(ctx, current, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s stage: %w", st.Name(), err)
+		}
+
+		ss.OutputTokens = countTokens(counter, current)
+		ss.Reduction = reduction(ss.InputTokens, ss.OutputTokens)
+		ss.Latency = time.Since(t0)
+		stats.addStage(st.Name(), ss)
+	}
+	return current, nil
+}
+
+// specStage names a stage and applies its When condition.
+type specStage struct {
+	name  string
+	when  map[string]string
+	stage Stage
+}
+
+func (s *specStage) Name() string { return s.name }
+
+func (s *specStage) Process(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, error) {
+	return s.process(ctx, chunks, opts, &Stats{})
+}
+
+func (s *specStage) process(ctx context.Context, chunks []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error) {
+	run := func(in []types.Chunk) ([]types.Chunk, error) {
+		if len(in) == 0 {
+			return in, nil
+		}
+		if st, ok := s.stage.(statsStage); ok {
+			return st.process(ctx, in, opts, stats)
+		}
+		return s.stage.Process(ctx, in, opts)
+	}
+	if len(s.when) == 0 {
+		return run(chunks)
+	}
+
+	matched := make([]bool, len(chunks))
+	var subset []types.Chunk
+	for i, ch := range chunks {
+		if matchMetadata(ch, s.when) {
+			matched[i] = true
+			subset = append(subset, ch)
+		}
+	}
+	if len(subset) == 0 {
+		return chunks, nil
+	}
+	processed, err := run(subset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put processed chunks back in their original slots; chunks the stage
+	// dropped leave no slot, and chunks it created go at the end.
+	byID := make(map[string]int, len(processed))
+	for i, ch := range processed {
+		if _, dup := byID[ch.ID]; !dup {
+			byID[ch.ID] = i
+		}
+	}
+	used := make([]bool, len(processed))
+	out := make([]types.Chunk, 0, len(chunks))
+	for i, ch := range chunks {
+		if !matched[i] {
+			out = append(out, ch)
+			continue
+		}
+		if j, ok := byID[ch.ID]; ok && !used[j] {
+			out = append(out, processed[j])
+			used[j] = true
+		}
+	}
+	for j, ch := range processed {
+		if !used[j] {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+// matchMetadata reports whether every entry of when matches ch.Metadata.
+func matchMetadata(ch types.Chunk, when map[string]string) bool {
+	for key, want := range when {
+		v, ok := metadataValue(ch.Metadata, key)
+		if !ok {
+			return false
+		}
+		if want != "*" && fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataValue looks key up in metadata, falling back to a
+// case-insensitive match: profiles read from distill.yaml arrive with
+// their keys lower-cased.
+func metadataValue(metadata map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := metadata[key]; ok {
+		return v, true
+	}
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// SelectionParams are the JSON form of contextlab.SelectionConstraints,
+// shared by the dedup stage and the /v1/pipeline dedup options.
+type SelectionParams struct {
+	SourceField       string  `json:"source_field,omitempty"`
+	MaxPerSource      int     `json:"max_per_source,omitempty"`
+	CoverField        string  `json:"cover_field,omitempty"`
+	FreshnessField    string  `json:"freshness_field,omitempty"`
+	FreshnessHalfLife string  `json:"freshness_half_life,omitempty"` // Go duration, e.g. "720h"
+	FreshnessWeight   float64 `json:"freshness_weight,omitempty"`
+}
+
+// Apply overrides the fields of base that p sets and validates the result.
+func (p SelectionParams) Apply(base contextlab.SelectionConstraints) (contextlab.SelectionConstraints, error) {
+	if p.SourceField != "" {
+		base.SourceField = p.SourceField
+	}
+	if p.MaxPerSource != 0 {
+		base.MaxPerSource = p.MaxPerSource
+	}
+	if p.CoverField != "" {
+		base.CoverField = p.CoverField
+	}
+	if p.FreshnessField != "" {
+		base.FreshnessField = p.FreshnessField
+	}
+	if p.FreshnessHalfLife != "" {
+		d, err := time.ParseDuration(p.FreshnessHalfLife)
+		if err != nil {
+			return base, fmt.Errorf("invalid freshness_half_life: %w", err)
+		}
+		base.FreshnessHalfLife = d
+	}
+	if p.FreshnessWeight != 0 {
+		base.FreshnessWeight = p.FreshnessWeight
+	}
+	return base, base.Validate()
+}
+
+// dedupStage runs near-duplicate removal; unset params fall back to Options.
+type dedupStage struct {
+	Threshold float64 `json:"threshold"`
+	Lambda    float64 `json:"lambda"`
+	TargetK   int     `json:"target_k"`
+	Method    string  `json:"method"`
+
+	// AutoThreshold picks the threshold from the distance histogram,
+	// with threshold as the fallback.
+	AutoThreshold bool `json:"auto_threshold"`
+
+	SelectionParams
+}
+
+func (s *dedupStage) Name() string { return StageDedup }
+
+func (s *dedupStage) Process(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, error) {
+	return s.process(ctx, chunks, opts, &Stats{})
+}
+
+func (s *dedupStage) process(_ context.Context, chunks []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error) {
+	if len(chunks) < 2 {
+		return chunks, nil
+	}
+	if s.Threshold > 0 {
+		opts.DedupThreshold = s.Threshold
+	}
+	if s.Lambda > 0 {
+		opts.DedupLambda = s.Lambda
+	}
+	if s.TargetK > 0 {
+		opts.DedupTargetK = s.TargetK
+	}
+	if s.Method != "" {
+		opts.DedupMethod = s.Method
+	}
+	if s.AutoThreshold {
+		opts.DedupAutoThreshold = true
+	}
+	var err error
+	if opts.DedupConstraints, err = s.Apply(opts.DedupConstraints); err != nil {
+		return nil, err
+	}
+	return dedupChunks(chunks, opts, stats)
+}
+
+// compressStage runs a compressor or compressor chain, optionally packing
+// into a token budget; unset params fall back to Options.
+type compressStage struct {
+	Mode            string   `json:"mode"`
+	Compressors     []string `json:"compressors"`
+	TargetReduction float64  `json:"target_reduction"`
+	MaxTokens       int      `json:"max_tokens"`
+}
+
+func (s *compressStage) validate() error {
+	for _, m := range append([]string{s.Mode}, s.Compressors...) {
+		if m == "" {
+			continue
+		}
+		if _, err := compress.ForMode(compress.Mode(m)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *compressStage) Name() string { return StageCompress }
+
+func (s *compressStage) Process(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, error) {
+	return s.process(ctx, chunks, opts, &Stats{})
+}
+
+func (s *compressStage) process(ctx context.Context, chunks []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error) {
+	opts.CompressEnabled = true
+	if s.Mode != "" || len(s.Compressors) > 0 {
+		opts.CompressMode = compress.Mode(s.Mode)
+		opts.CompressChain = nil
+		for _, m := range s.Compressors {
+			opts.CompressChain = append(opts.CompressChain, compress.Mode(m))
+		}
+	}
+	if s.TargetReduction > 0 {
+		opts.CompressTargetReduction = s.TargetReduction
+	}
+	// A budget applies only where a stage asks for one, so that a chain
+	// such as pruner → extractive is not packed twice.
+	opts.MaxTokens = s.MaxTokens
+	return compressChunks(ctx, chunks, opts, tokenizer.OrDefault(opts.Tokenizer), stats)
+}
+
+// summarizeStage runs the hierarchical summarizer; unset params fall back
+// to Options.
+type summarizeStage struct {
+	MaxTokens  int `json:"max_tokens"`
+	KeepRecent int `json:"keep_recent"`
+}
+
+func (s *summarizeStage) Name() string { return StageSummarize }
+
+func (s *summarizeStage) Process(ctx context.Context, chunks []types.Chunk, opts Options) ([]types.Chunk, error) {
+	return s.process(ctx, chunks, opts, &Stats{})
+}
+
+func (s *summarizeStage) process(ctx context.Context, chunks []types.Chunk, opts Options, stats *Stats) ([]types.Chunk, error) {
+	if s.MaxTokens > 0 {
+		opts.SummarizeMaxTokens = s.MaxTokens
+	}
+	if s.KeepRecent > 0 {
+		opts.SummarizeRecent = s.KeepRecent
+	}
+	return summarizeChunks(ctx, chunks, opts, tokenizer.OrDefault(opts.Tokenizer), stats)
+}
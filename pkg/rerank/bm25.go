@@ -0,0 +1,111 @@
+package rerank
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+)
+
+// BM25Config holds Okapi BM25 parameters.
+type BM25Config struct {
+	// K1 controls term-frequency saturation. Default: 1.2.
+	K1 float64
+
+	// B controls document-length normalisation (0-1). Default: 0.75.
+	B float64
+}
+
+// BM25 reranks with Okapi BM25. Document frequencies come from the
+// documents being reranked, so no index is needed; this suits the tens of
+// candidates a retrieval returns.
+type BM25 struct {
+	cfg BM25Config
+}
+
+// NewBM25 creates a BM25 reranker.
+func NewBM25(cfg BM25Config) *BM25 {
+	if cfg.K1 <= 0 {
+		cfg.K1 = 1.2
+	}
+	if cfg.B <= 0 || cfg.B > 1 {
+		cfg.B = 0.75
+	}
+	return &BM25{cfg: cfg}
+}
+
+// Name returns "bm25".
+func (r *BM25) Name() string { return string(ProviderBM25) }
+
+// Rerank scores each document against the query's content words.
+func (r *BM25) Rerank(_ context.Context, query string, documents []string) ([]float64, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	scores := make([]float64, len(documents))
+	if len(documents) == 0 {
+		return scores, nil
+	}
+
+	// Term frequencies per document, restricted to query terms.
+	freqs := make([]map[string]int, len(documents))
+	lengths := make([]int, len(documents))
+	docFreq := make(map[string]int, len(terms))
+	var totalLen int
+	for i, doc := range documents {
+		words := nlp.Words(doc)
+		lengths[i] = len(words)
+		totalLen += len(words)
+		freqs[i] = make(map[string]int)
+		for _, w := range words {
+			w = strings.ToLower(w)
+			if _, ok := terms[w]; ok {
+				freqs[i][w]++
+			}
+		}
+		for t := range freqs[i] {
+			docFreq[t]++
+		}
+	}
+
+	n := float64(len(documents))
+	avgLen := float64(totalLen) / n
+	if avgLen == 0 {
+		return scores, nil
+	}
+	for i := range documents {
+		norm := r.cfg.K1 * (1 - r.cfg.B + r.cfg.B*float64(lengths[i])/avgLen)
+		for t, weight := range terms {
+			tf := float64(freqs[i][t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += float64(weight) * idf * tf * (r.cfg.K1 + 1) / (tf + norm)
+		}
+	}
+	return scores, nil
+}
+
+// queryTerms returns the lower-cased query words, with stopwords dropped
+// unless nothing else is left, mapped to how often each occurs.
+func queryTerms(query string) map[string]int {
+	lang := nlp.DetectLanguage(query)
+	all := make(map[string]int)
+	content := make(map[string]int)
+	for _, w := range nlp.Words(query) {
+		w = strings.ToLower(w)
+		all[w]++
+		if !nlp.IsStopword(lang, w) && !nlp.IsStopword(nlp.English, w) {
+			content[w]++
+		}
+	}
+	if len(content) == 0 {
+		return all
+	}
+	return content
+}
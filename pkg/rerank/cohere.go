@@ -0,0 +1,173 @@
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCohereBaseURL = "https://api.cohere.ai/v1"
+	defaultCohereModel   = "rerank-english-v3.0"
+	defaultCohereTimeout = 10 * time.Second
+)
+
+// CohereConfig configures a Cohere Rerank-compatible client. Services that
+// accept the same request shape, such as Jina or a self-hosted server,
+// work by changing BaseURL.
+type CohereConfig struct {
+	// BaseURL is the API root; requests go to BaseURL + "/rerank".
+	// Default: https://api.cohere.ai/v1
+	BaseURL string
+
+	// Model is the rerank model. Default: rerank-english-v3.0
+	Model string
+
+	// APIKey is sent as a bearer token. Required for the default BaseURL.
+	APIKey string
+
+	// Timeout bounds each request. Default: 10s.
+	Timeout time.Duration
+
+	// Fallback scores documents when the API fails. Default: BM25.
+	Fallback Reranker
+
+	// HTTPClient overrides the client used for requests.
+	HTTPClient *http.Client
+}
+
+// Cohere reranks with a Cohere Rerank-compatible HTTP API.
+type Cohere struct {
+	cfg        CohereConfig
+	httpClient *http.Client
+}
+
+// NewCohere creates a Cohere rerank client.
+func NewCohere(cfg CohereConfig) (*Cohere, error) {
+	if cfg.BaseURL == "" {
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("cohere API key is required")
+		}
+		cfg.BaseURL = defaultCohereBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Model == "" {
+		cfg.Model = defaultCohereModel
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultCohereTimeout
+	}
+	if cfg.Fallback == nil {
+		cfg.Fallback = NewBM25(BM25Config{})
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &Cohere{cfg: cfg, httpClient: httpClient}, nil
+}
+
+// Name returns "cohere".
+func (c *Cohere) Name() string { return string(ProviderCohere) }
+
+type cohereRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type cohereResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// Rerank scores documents with the API, falling back to the configured
+// Fallback when the request fails. Context cancellation is returned as is.
+// Use RerankFallback to learn why the API was not used.
+func (c *Cohere) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	scores, _, err := c.RerankFallback(ctx, query, documents)
+	return scores, err
+}
+
+// RerankFallback is Rerank, also returning the API error when the scores
+// came from the Fallback.
+func (c *Cohere) RerankFallback(ctx context.Context, query string, documents []string) ([]float64, error, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil, ErrEmptyQuery
+	}
+	if len(documents) == 0 {
+		return []float64{}, nil, nil
+	}
+
+	scores, apiErr := c.rerank(ctx, query, documents)
+	if apiErr == nil {
+		return scores, nil, nil
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	scores, err := c.cfg.Fallback.Rerank(ctx, query, documents)
+	if err != nil {
+		return nil, nil, err
+	}
+	return scores, fmt.Errorf("%s: %w", c.Name(), apiErr), nil
+}
+
+func (c *Cohere) rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	body, err := json.Marshal(cohereRequest{
+		Model:     c.cfg.Model,
+		Query:     query,
+		Documents: documents,
+		TopN:      len(documents),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+"/rerank", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rerank request: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("rerank %d: %s", resp.StatusCode, string(b))
+	}
+
+	var result cohereResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Results) != len(documents) {
+		return nil, fmt.Errorf("expected %d results, got %d", len(documents), len(result.Results))
+	}
+
+	scores := make([]float64, len(documents))
+	seen := make([]bool, len(documents))
+	for _, r := range result.Results {
+		if r.Index < 0 || r.Index >= len(documents) || seen[r.Index] {
+			return nil, fmt.Errorf("invalid result index %d", r.Index)
+		}
+		seen[r.Index] = true
+		scores[r.Index] = r.RelevanceScore
+	}
+	return scores, nil
+}
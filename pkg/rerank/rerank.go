@@ -0,0 +1,81 @@
+// Package rerank rescores retrieved documents against the query text.
+//
+// Bi-encoder retrieval compares independently computed embeddings, which
+// is fast but coarse. A reranker looks at the query and each document
+// together: Cohere reranks with a cross-encoder over HTTP, and BM25 scores
+// term overlap locally with no model at all.
+package rerank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrEmptyQuery is returned when a reranker is called without query text.
+var ErrEmptyQuery = errors.New("rerank: query text is required")
+
+// Reranker scores documents by relevance to a query.
+type Reranker interface {
+	// Rerank returns one score per document, in document order. Higher is
+	// more relevant. Scores are comparable within one call only.
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+
+	// Name identifies the reranker in logs and stats.
+	Name() string
+}
+
+// Fallbacker is implemented by rerankers that score with a fallback
+// reranker when their backend fails. RerankFallback behaves like Rerank
+// and also returns the backend error when the scores came from the
+// fallback, so callers can report it.
+type Fallbacker interface {
+	RerankFallback(ctx context.Context, query string, documents []string) (scores []float64, fallbackErr, err error)
+}
+
+// Provider names a Reranker implementation.
+type Provider string
+
+const (
+	// ProviderNone disables reranking.
+	ProviderNone Provider = ""
+
+	// ProviderBM25 scores documents locally with Okapi BM25.
+	ProviderBM25 Provider = "bm25"
+
+	// ProviderCohere calls a Cohere Rerank-compatible HTTP API.
+	ProviderCohere Provider = "cohere"
+)
+
+// Config selects and configures a reranker.
+type Config struct {
+	// Provider is the reranker to build. ProviderNone returns nil.
+	Provider Provider
+
+	// Model, BaseURL, APIKey, and Timeout configure HTTP rerankers; see
+	// CohereConfig.
+	Model   string
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+}
+
+// New builds the reranker selected by cfg. It returns nil, nil for
+// ProviderNone.
+func New(cfg Config) (Reranker, error) {
+	switch cfg.Provider {
+	case ProviderNone, "none":
+		return nil, nil
+	case ProviderBM25:
+		return NewBM25(BM25Config{}), nil
+	case ProviderCohere:
+		return NewCohere(CohereConfig{
+			Model:   cfg.Model,
+			BaseURL: cfg.BaseURL,
+			APIKey:  cfg.APIKey,
+			Timeout: cfg.Timeout,
+		})
+	}
+	return nil, fmt.Errorf("unknown reranker %q (supported: bm25, cohere)", cfg.Provider)
+}
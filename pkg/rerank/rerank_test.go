@@ -0,0 +1,134 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var docs = []string{
+	"The weather in Paris is mild in spring.",
+	"To rotate an API key, open the dashboard and click regenerate.",
+	"API keys expire after ninety days unless rotated.",
+	"Our office dog is called Biscuit.",
+}
+
+func TestBM25_RanksMatchingDocumentsFirst(t *testing.T) {
+	scores, err := NewBM25(BM25Config{}).Rerank(context.Background(), "how do I rotate my API key", docs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scores) != len(docs) {
+		t.Fatalf("expected %d scores, got %d", len(docs), len(scores))
+	}
+	if scores[1] <= scores[2] || scores[2] <= scores[0] {
+		t.Errorf("expected doc 1 > doc 2 > doc 0, got %v", scores)
+	}
+	if scores[0] != 0 || scores[3] != 0 {
+		t.Errorf("expected zero scores for unrelated docs, got %v", scores)
+	}
+}
+
+func TestBM25_EmptyQuery(t *testing.T) {
+	if _, err := NewBM25(BM25Config{}).Rerank(context.Background(), "  ", docs); err != ErrEmptyQuery {
+		t.Errorf("expected ErrEmptyQuery, got %v", err)
+	}
+}
+
+func TestCohere_Success(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		var req cohereRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Model != "rerank-test" || req.Query != "rotate key" || req.TopN != len(docs) {
+			t.Errorf("unexpected request %+v", req)
+		}
+		// Results come back sorted by relevance, not by index.
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":[
+			{"index":1,"relevance_score":0.9},
+			{"index":2,"relevance_score":0.6},
+			{"index":0,"relevance_score":0.1},
+			{"index":3,"relevance_score":0.05}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewCohere(CohereConfig{BaseURL: srv.URL + "/", Model: "rerank-test", APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scores, err := c.Rerank(context.Background(), "rotate key", docs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []float64{0.1, 0.9, 0.6, 0.05}
+	for i := range want {
+		if scores[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, scores)
+		}
+	}
+}
+
+func TestCohere_FallsBackOnServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := NewCohere(CohereConfig{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scores, err := c.Rerank(context.Background(), "rotate API key", docs)
+	if err != nil {
+		t.Fatalf("expected fallback, got error: %v", err)
+	}
+	if scores[1] <= scores[0] {
+		t.Errorf("expected BM25 fallback scores, got %v", scores)
+	}
+
+	_, fallbackErr, err := c.RerankFallback(context.Background(), "rotate API key", docs)
+	if err != nil || fallbackErr == nil || !strings.Contains(fallbackErr.Error(), "503") {
+		t.Errorf("expected the API error reported with fallback scores, got %v (err %v)", fallbackErr, err)
+	}
+}
+
+func TestCohere_CancelledContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unreachable", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, _ := NewCohere(CohereConfig{BaseURL: srv.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Rerank(ctx, "rotate API key", docs); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if r, err := New(Config{}); r != nil || err != nil {
+		t.Errorf("expected nil reranker for no provider, got %v, %v", r, err)
+	}
+	if r, err := New(Config{Provider: ProviderBM25}); err != nil || r.Name() != "bm25" {
+		t.Errorf("expected bm25 reranker, got %v, %v", r, err)
+	}
+	if _, err := New(Config{Provider: ProviderCohere}); err == nil {
+		t.Error("expected error for cohere without an API key")
+	}
+	if _, err := New(Config{Provider: "colbert"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Siddhant-K-code/distill/pkg/retriever"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 	"github.com/pinecone-io/go-pinecone/v3/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // Client implements the Retriever interface for Pinecone.
@@ -99,6 +100,13 @@ func (c *Client) Query(ctx context.Context, req *types.RetrievalRequest) (*types
 		IncludeValues:   req.IncludeEmbeddings,
 		IncludeMetadata: req.IncludeMetadata,
 	}
+	if len(req.Filter) > 0 {
+		filter, err := structpb.NewStruct(req.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		queryReq.MetadataFilter = filter
+	}
 
 	// Note: namespace is set at connection level in NewClient
 	// Per-query namespace override would require creating a new connection
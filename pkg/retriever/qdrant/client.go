@@ -275,13 +275,19 @@ func buildFilter(filter map[string]interface{}) *pb.Filter {
 					},
 				},
 			}
-		case int, int64:
+		case int, int64, float64:
 			var intVal int64
 			switch iv := v.(type) {
 			case int:
 				intVal = int64(iv)
 			case int64:
 				intVal = iv
+			case float64:
+				// JSON numbers decode as float64; only whole ones match.
+				if iv != float64(int64(iv)) {
+					continue
+				}
+				intVal = int64(iv)
 			}
 			condition = &pb.Condition{
 				ConditionOneOf: &pb.Condition_Field{
@@ -93,6 +93,34 @@ func (m *CacheBoundaryManager) Evaluate(ctx context.Context, sessionID string) (
 		return &CacheBoundaryResult{}, nil
 	}
 
+	result, err := m.markers(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect advance/retreat by comparing with the stored boundary.
+	prev, err := m.loadStoredBoundary(ctx, sessionID)
+	if err == nil {
+		if result.TotalStableTokens > prev {
+			result.Advanced = true
+		} else if result.TotalStableTokens < prev && prev > 0 {
+			result.Retreated = true
+		}
+	}
+
+	// Persist the new boundary position.
+	_ = m.storeBoundary(ctx, sessionID, result.TotalStableTokens)
+
+	return result, nil
+}
+
+// markers computes the current marker placement without touching the
+// stored boundary, so it is safe to call on reads.
+func (m *CacheBoundaryManager) markers(ctx context.Context, sessionID string) (*CacheBoundaryResult, error) {
+	if !m.cfg.Enabled {
+		return &CacheBoundaryResult{}, nil
+	}
+
 	// Load entries ordered by sequence.
 	rows, err := m.db.QueryContext(ctx,
 		`SELECT id, tokens, stable_since_turn, content_hash
@@ -185,19 +213,6 @@ func (m *CacheBoundaryManager) Evaluate(ctx context.Context, sessionID string) (
 		result.TotalStableTokens = c.cumTokens
 	}
 
-	// Detect advance/retreat by comparing with the stored boundary.
-	prev, err := m.loadStoredBoundary(ctx, sessionID)
-	if err == nil {
-		if result.TotalStableTokens > prev {
-			result.Advanced = true
-		} else if result.TotalStableTokens < prev && prev > 0 {
-			result.Retreated = true
-		}
-	}
-
-	// Persist the new boundary position.
-	_ = m.storeBoundary(ctx, sessionID, result.TotalStableTokens)
-
 	return result, nil
 }
 
@@ -226,12 +241,18 @@ func (m *CacheBoundaryManager) storeBoundary(ctx context.Context, sessionID stri
 // RecordPush increments the push counter for a session and updates
 // stable_since_turn for entries that have now survived minStableTurns pushes.
 func (m *CacheBoundaryManager) RecordPush(ctx context.Context, sessionID string) error {
+	return m.recordPush(ctx, m.db, sessionID)
+}
+
+// recordPush is RecordPush run through q, so Push can count the push in
+// its own transaction.
+func (m *CacheBoundaryManager) recordPush(ctx context.Context, q dbtx, sessionID string) error {
 	if !m.cfg.Enabled {
 		return nil
 	}
 
 	// Increment push count.
-	_, err := m.db.ExecContext(ctx,
+	_, err := q.ExecContext(ctx,
 		"UPDATE sessions SET push_count = push_count + 1 WHERE id = ?",
 		sessionID,
 	)
@@ -241,7 +262,7 @@ func (m *CacheBoundaryManager) RecordPush(ctx context.Context, sessionID string)
 
 	// Fetch current push count.
 	var pushCount int
-	if err := m.db.QueryRowContext(ctx,
+	if err := q.QueryRowContext(ctx,
 		"SELECT push_count FROM sessions WHERE id = ?",
 		sessionID,
 	).Scan(&pushCount); err != nil {
@@ -252,7 +273,7 @@ func (m *CacheBoundaryManager) RecordPush(ctx context.Context, sessionID string)
 	// without modification (stable_since_turn == 0 means not yet stable).
 	stableThreshold := pushCount - m.cfg.MinStableTurns
 	if stableThreshold > 0 {
-		_, err = m.db.ExecContext(ctx,
+		_, err = q.ExecContext(ctx,
 			`UPDATE session_entries
 			 SET stable_since_turn = inserted_at_push
 			 WHERE session_id = ?
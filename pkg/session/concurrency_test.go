@@ -0,0 +1,219 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentPushSameSession(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "race", MaxTokens: 100000})
+
+	const workers = 8
+	const pushes = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*pushes)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < pushes; i++ {
+				_, err := s.Push(ctx, PushRequest{
+					SessionID: "race",
+					Entries: []PushEntry{
+						{Role: "user", Content: fmt.Sprintf("worker %d message %d", w, i)},
+						{Role: "assistant", Content: fmt.Sprintf("worker %d reply %d", w, i)},
+					},
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Push: %v", err)
+	}
+
+	var total, distinct int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT seq) FROM session_entries WHERE session_id = ?", "race",
+	).Scan(&total, &distinct); err != nil {
+		t.Fatal(err)
+	}
+	if total != workers*pushes*2 || distinct != total {
+		t.Errorf("expected %d entries with unique seq, got %d entries and %d distinct seq",
+			workers*pushes*2, total, distinct)
+	}
+
+	// Entries of one push are contiguous and share inserted_at_push.
+	var pushGroups int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(DISTINCT inserted_at_push) FROM session_entries WHERE session_id = ?", "race",
+	).Scan(&pushGroups); err != nil {
+		t.Fatal(err)
+	}
+	if pushGroups != workers*pushes {
+		t.Errorf("expected %d distinct inserted_at_push values, got %d", workers*pushes, pushGroups)
+	}
+
+	sess, err := s.Get(ctx, "race")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if sess.Version != workers*pushes {
+		t.Errorf("expected version %d, got %d", workers*pushes, sess.Version)
+	}
+}
+
+func TestConcurrentPushBudget(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "budget", MaxTokens: 200, PreserveRecent: 2})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 6; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				_, err := s.Push(ctx, PushRequest{
+					SessionID: "budget",
+					Entries: []PushEntry{{
+						Role:    "user",
+						Content: fmt.Sprintf("Worker %d step %d: the build failed because the config file is missing a required key.", w, i),
+					}},
+				})
+				if err != nil {
+					t.Errorf("Push: %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	sess, err := s.Get(ctx, "budget")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if sess.CurrentTokens > sess.MaxTokens {
+		t.Errorf("session over budget after concurrent pushes: %d > %d", sess.CurrentTokens, sess.MaxTokens)
+	}
+}
+
+func TestPushExpectedVersion(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "cas", MaxTokens: 10000})
+
+	v0 := 0
+	res, err := s.Push(ctx, PushRequest{
+		SessionID:       "cas",
+		ExpectedVersion: &v0,
+		Entries:         []PushEntry{{Role: "user", Content: "first"}},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if res.Version != 1 {
+		t.Fatalf("expected version 1, got %d", res.Version)
+	}
+
+	// A stale writer still expecting version 0 must be rejected.
+	_, err = s.Push(ctx, PushRequest{
+		SessionID:       "cas",
+		ExpectedVersion: &v0,
+		Entries:         []PushEntry{{Role: "user", Content: "stale"}},
+	})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	sess, _ := s.Get(ctx, "cas")
+	if sess.EntryCount != 1 || sess.Version != 1 {
+		t.Errorf("rejected push must not change the session: entries=%d version=%d", sess.EntryCount, sess.Version)
+	}
+}
+
+func TestPushFailedLeavesSessionUnchanged(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "atomic", MaxTokens: 50})
+
+	v0 := 0
+	_, err := s.Push(ctx, PushRequest{
+		SessionID:       "atomic",
+		ExpectedVersion: &v0,
+		Entries: []PushEntry{
+			{Role: "user", Content: "hello there"},
+			{Role: "assistant", Content: strings.Repeat("word ", 400)},
+		},
+	})
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected ErrOverBudget, got %v", err)
+	}
+
+	sess, _ := s.Get(ctx, "atomic")
+	if sess.EntryCount != 0 || sess.Version != 0 {
+		t.Fatalf("failed push must not change the session: entries=%d version=%d", sess.EntryCount, sess.Version)
+	}
+
+	// The retry still holds the version it read before the failure.
+	res, err := s.Push(ctx, PushRequest{
+		SessionID:       "atomic",
+		ExpectedVersion: &v0,
+		Entries:         []PushEntry{{Role: "user", Content: "hello there"}},
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if res.Version != 1 || res.Accepted != 1 {
+		t.Errorf("expected the retry accepted at version 1, got version %d, %d accepted", res.Version, res.Accepted)
+	}
+}
+
+func TestConcurrentExpectedVersionSingleWinner(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "cas2", MaxTokens: 10000})
+
+	const writers = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won, conflicted := 0, 0
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			v := 0
+			_, err := s.Push(ctx, PushRequest{
+				SessionID:       "cas2",
+				ExpectedVersion: &v,
+				Entries:         []PushEntry{{Role: "user", Content: fmt.Sprintf("writer %d", w)}},
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, ErrVersionConflict):
+				conflicted++
+			default:
+				t.Errorf("Push: %v", err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if won != 1 || conflicted != writers-1 {
+		t.Errorf("expected exactly one winner, got %d winners and %d conflicts", won, conflicted)
+	}
+}
@@ -0,0 +1,44 @@
+package session
+
+import "sync"
+
+// sessionLocks hands out one mutex per session ID so operations on the same
+// session run one at a time while different sessions proceed in parallel.
+// Entries are reference-counted and dropped once no caller holds them.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newSessionLocks() *sessionLocks {
+	return &sessionLocks{locks: make(map[string]*sessionLock)}
+}
+
+// lock blocks until the session's mutex is held and returns the function
+// that releases it.
+func (l *sessionLocks) lock(sessionID string) func() {
+	l.mu.Lock()
+	sl, ok := l.locks[sessionID]
+	if !ok {
+		sl = &sessionLock{}
+		l.locks[sessionID] = sl
+	}
+	sl.refs++
+	l.mu.Unlock()
+
+	sl.mu.Lock()
+	return func() {
+		sl.mu.Unlock()
+		l.mu.Lock()
+		sl.refs--
+		if sl.refs == 0 {
+			delete(l.locks, sessionID)
+		}
+		l.mu.Unlock()
+	}
+}
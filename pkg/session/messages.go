@@ -0,0 +1,485 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Message formats accepted by Push and emitted by Context.
+const (
+	FormatOpenAI    = "openai"    // OpenAI Chat Completions messages
+	FormatAnthropic = "anthropic" // Anthropic Messages API
+)
+
+// ErrUnknownFormat is returned for an unsupported message format.
+var ErrUnknownFormat = errors.New("unknown message format (supported: openai, anthropic)")
+
+// Content part types.
+const (
+	PartText       = "text"
+	PartImage      = "image"
+	PartToolCall   = "tool_call"
+	PartToolResult = "tool_result"
+)
+
+// ContentPart is a provider-neutral piece of a message. Entries pushed from
+// provider-native messages keep their parts so Context can re-emit them
+// without loss.
+type ContentPart struct {
+	Type string `json:"type"`
+
+	// Text is set for text parts and tool results.
+	Text string `json:"text,omitempty"`
+
+	// ImageURL is an http(s) or data: URL. MediaType and Data are set
+	// instead for base64 images imported from Anthropic.
+	ImageURL  string `json:"image_url,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// ToolCallID links a tool call to its result.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	// Arguments is the tool input as a JSON object string.
+	Arguments string `json:"arguments,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// flattenParts renders parts as plain text for token counting, dedup and
+// compression.
+func flattenParts(parts []ContentPart) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		switch p.Type {
+		case PartText, PartToolResult:
+			b.WriteString(p.Text)
+		case PartImage:
+			b.WriteString("[image]")
+		case PartToolCall:
+			b.WriteString(p.ToolName)
+			b.WriteByte('(')
+			b.WriteString(p.Arguments)
+			b.WriteByte(')')
+		}
+	}
+	return b.String()
+}
+
+// toolCallIDs returns the IDs of tool calls made in parts.
+func toolCallIDs(parts []ContentPart) []string {
+	var ids []string
+	for _, p := range parts {
+		if p.Type == PartToolCall && p.ToolCallID != "" {
+			ids = append(ids, p.ToolCallID)
+		}
+	}
+	return ids
+}
+
+// toolResultIDs returns the tool call IDs answered by results in parts.
+func toolResultIDs(parts []ContentPart) []string {
+	var ids []string
+	for _, p := range parts {
+		if p.Type == PartToolResult && p.ToolCallID != "" {
+			ids = append(ids, p.ToolCallID)
+		}
+	}
+	return ids
+}
+
+// ParseMessages converts provider-native messages into push entries.
+func ParseMessages(format string, messages []json.RawMessage) ([]PushEntry, error) {
+	switch format {
+	case FormatOpenAI:
+		return parseOpenAI(messages)
+	case FormatAnthropic:
+		return parseAnthropic(messages)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// --- OpenAI Chat Completions ---
+
+type openAIMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
+}
+
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+func parseOpenAI(messages []json.RawMessage) ([]PushEntry, error) {
+	entries := make([]PushEntry, 0, len(messages))
+	for i, raw := range messages {
+		var m openAIMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+
+		var parts []ContentPart
+		if len(m.Content) > 0 && string(m.Content) != "null" {
+			var text string
+			if err := json.Unmarshal(m.Content, &text); err == nil {
+				if text != "" {
+					parts = append(parts, ContentPart{Type: PartText, Text: text})
+				}
+			} else {
+				var oparts []openAIPart
+				if err := json.Unmarshal(m.Content, &oparts); err != nil {
+					return nil, fmt.Errorf("message %d content: %w", i, err)
+				}
+				for _, op := range oparts {
+					switch op.Type {
+					case "text":
+						parts = append(parts, ContentPart{Type: PartText, Text: op.Text})
+					case "image_url":
+						if op.ImageURL != nil {
+							parts = append(parts, ContentPart{Type: PartImage, ImageURL: op.ImageURL.URL})
+						}
+					}
+				}
+			}
+		}
+
+		if m.Role == "tool" {
+			// A tool message's content is the result of a single call.
+			parts = []ContentPart{{
+				Type:       PartToolResult,
+				Text:       flattenParts(parts),
+				ToolCallID: m.ToolCallID,
+				ToolName:   m.Name,
+			}}
+		}
+
+		for _, tc := range m.ToolCalls {
+			parts = append(parts, ContentPart{
+				Type:       PartToolCall,
+				ToolCallID: tc.ID,
+				ToolName:   tc.Function.Name,
+				Arguments:  tc.Function.Arguments,
+			})
+		}
+
+		entries = append(entries, PushEntry{Role: m.Role, Parts: parts})
+	}
+	return entries, nil
+}
+
+// --- Anthropic Messages ---
+
+type anthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Source    *struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type,omitempty"`
+		Data      string `json:"data,omitempty"`
+		URL       string `json:"url,omitempty"`
+	} `json:"source,omitempty"`
+}
+
+func parseAnthropic(messages []json.RawMessage) ([]PushEntry, error) {
+	entries := make([]PushEntry, 0, len(messages))
+	for i, raw := range messages {
+		var m anthropicMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		blocks, err := parseAnthropicBlocks(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("message %d content: %w", i, err)
+		}
+
+		var parts []ContentPart
+		allResults := len(blocks) > 0
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				parts = append(parts, ContentPart{Type: PartText, Text: b.Text})
+			case "image":
+				if b.Source != nil {
+					parts = append(parts, ContentPart{
+						Type:      PartImage,
+						ImageURL:  b.Source.URL,
+						MediaType: b.Source.MediaType,
+						Data:      b.Source.Data,
+					})
+				}
+			case "tool_use":
+				args := string(b.Input)
+				if args == "" {
+					args = "{}"
+				}
+				parts = append(parts, ContentPart{
+					Type:       PartToolCall,
+					ToolCallID: b.ID,
+					ToolName:   b.Name,
+					Arguments:  args,
+				})
+			case "tool_result":
+				inner, err := parseAnthropicBlocks(b.Content)
+				if err != nil {
+					return nil, fmt.Errorf("message %d tool_result: %w", i, err)
+				}
+				var texts []string
+				for _, ib := range inner {
+					if ib.Type == "text" {
+						texts = append(texts, ib.Text)
+					}
+				}
+				parts = append(parts, ContentPart{
+					Type:       PartToolResult,
+					Text:       strings.Join(texts, "\n"),
+					ToolCallID: b.ToolUseID,
+					IsError:    b.IsError,
+				})
+			}
+			if b.Type != "tool_result" {
+				allResults = false
+			}
+		}
+
+		// A user turn carrying only tool results is the tool's reply.
+		role := m.Role
+		if role == "user" && allResults {
+			role = "tool"
+		}
+		entries = append(entries, PushEntry{Role: role, Parts: parts})
+	}
+	return entries, nil
+}
+
+// parseAnthropicBlocks accepts either a plain string or an array of blocks.
+func parseAnthropicBlocks(raw json.RawMessage) ([]anthropicBlock, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if text == "" {
+			return nil, nil
+		}
+		return []anthropicBlock{{Type: "text", Text: text}}, nil
+	}
+	var blocks []anthropicBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// --- rendering ---
+
+// renderMessages converts context entries into provider-native messages.
+// Entries whose ID appears in cacheAt get an ephemeral cache_control marker
+// (Anthropic only; OpenAI caches prefixes automatically). For Anthropic,
+// system entries are returned separately since the API takes them as a
+// top-level parameter.
+func renderMessages(format string, entries []ContextEntry, cacheAt map[string]bool) (messages, system []map[string]interface{}, err error) {
+	switch format {
+	case FormatOpenAI:
+		for _, e := range entries {
+			messages = append(messages, renderOpenAI(e)...)
+		}
+		return messages, nil, nil
+	case FormatAnthropic:
+		for _, e := range entries {
+			blocks := renderAnthropicBlocks(e)
+			if len(blocks) == 0 {
+				continue
+			}
+			if cacheAt[e.ID] {
+				blocks[len(blocks)-1]["cache_control"] = map[string]interface{}{"type": "ephemeral"}
+			}
+			if e.Role == "system" {
+				system = append(system, blocks...)
+				continue
+			}
+			role := e.Role
+			if role != "assistant" {
+				role = "user"
+			}
+			// Anthropic expects alternating turns; merge consecutive
+			// messages from the same side.
+			if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+				prev := messages[n-1]["content"].([]map[string]interface{})
+				messages[n-1]["content"] = append(prev, blocks...)
+				continue
+			}
+			messages = append(messages, map[string]interface{}{"role": role, "content": blocks})
+		}
+		return messages, system, nil
+	default:
+		return nil, nil, ErrUnknownFormat
+	}
+}
+
+// entryParts returns the parts to render for an entry. Compressed entries
+// are rendered from their current text since the original parts no longer
+// match it.
+func entryParts(e ContextEntry) []ContentPart {
+	if len(e.Parts) > 0 && e.Level == LevelFull {
+		return e.Parts
+	}
+	return []ContentPart{{Type: PartText, Text: e.Content}}
+}
+
+// renderOpenAI renders an entry as one or more OpenAI messages. Tool
+// results become "tool" messages, followed by a message holding any text
+// sent alongside them. A "tool" message must answer a call made earlier in
+// the conversation, so results whose call is not in the session, and tool
+// entries compressed to plain text, are rendered as user text instead.
+func renderOpenAI(e ContextEntry) []map[string]interface{} {
+	parts := entryParts(e)
+	answered := e.GroupID != ""
+
+	var content []map[string]interface{}
+	var toolCalls []map[string]interface{}
+	var results []map[string]interface{}
+	for _, p := range parts {
+		switch p.Type {
+		case PartText:
+			content = append(content, map[string]interface{}{"type": "text", "text": p.Text})
+		case PartImage:
+			url := p.ImageURL
+			if url == "" && p.Data != "" {
+				url = "data:" + p.MediaType + ";base64," + p.Data
+			}
+			content = append(content, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": url},
+			})
+		case PartToolCall:
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   p.ToolCallID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      p.ToolName,
+					"arguments": p.Arguments,
+				},
+			})
+		case PartToolResult:
+			if !answered || p.ToolCallID == "" {
+				content = append(content, map[string]interface{}{"type": "text", "text": p.Text})
+				continue
+			}
+			results = append(results, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": p.ToolCallID,
+				"content":      p.Text,
+			})
+		}
+	}
+
+	if len(results) > 0 && len(content) == 0 && len(toolCalls) == 0 {
+		return results
+	}
+
+	role := e.Role
+	if role == "tool" {
+		role = "user"
+	}
+	msg := map[string]interface{}{"role": role}
+	if len(content) == 1 && content[0]["type"] == "text" {
+		msg["content"] = content[0]["text"]
+	} else if len(content) > 0 {
+		msg["content"] = content
+	} else {
+		msg["content"] = nil
+	}
+	if len(toolCalls) > 0 {
+		msg["tool_calls"] = toolCalls
+	}
+	return append(results, msg)
+}
+
+func renderAnthropicBlocks(e ContextEntry) []map[string]interface{} {
+	var blocks []map[string]interface{}
+	for _, p := range entryParts(e) {
+		switch p.Type {
+		case PartText:
+			if p.Text == "" {
+				continue
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": p.Text})
+		case PartImage:
+			source := map[string]interface{}{"type": "url", "url": p.ImageURL}
+			if p.Data != "" {
+				source = map[string]interface{}{"type": "base64", "media_type": p.MediaType, "data": p.Data}
+			} else if strings.HasPrefix(p.ImageURL, "data:") {
+				if mt, data, ok := splitDataURL(p.ImageURL); ok {
+					source = map[string]interface{}{"type": "base64", "media_type": mt, "data": data}
+				}
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+		case PartToolCall:
+			var input interface{} = map[string]interface{}{}
+			if p.Arguments != "" {
+				_ = json.Unmarshal([]byte(p.Arguments), &input)
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    p.ToolCallID,
+				"name":  p.ToolName,
+				"input": input,
+			})
+		case PartToolResult:
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": p.ToolCallID,
+				"content":     p.Text,
+			}
+			if p.IsError {
+				block["is_error"] = true
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// splitDataURL splits "data:<media type>;base64,<data>".
+func splitDataURL(url string) (mediaType, data string, ok bool) {
+	rest := strings.TrimPrefix(url, "data:")
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !isBase64 {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
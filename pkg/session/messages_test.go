@@ -0,0 +1,317 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func rawMessages(t *testing.T, s string) []json.RawMessage {
+	t.Helper()
+	var msgs []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &msgs); err != nil {
+		t.Fatalf("unmarshal messages: %v", err)
+	}
+	return msgs
+}
+
+const openAITranscript = `[
+	{"role": "system", "content": "You are a coding agent."},
+	{"role": "user", "content": [
+		{"type": "text", "text": "What is in this screenshot?"},
+		{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0KGgo="}}
+	]},
+	{"role": "assistant", "content": null, "tool_calls": [
+		{"id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"main.go\"}"}}
+	]},
+	{"role": "tool", "tool_call_id": "call_1", "content": "package main\nfunc main() {}"},
+	{"role": "assistant", "content": "The file defines an empty main."}
+]`
+
+func TestParseOpenAIMessages(t *testing.T) {
+	entries, err := ParseMessages(FormatOpenAI, rawMessages(t, openAITranscript))
+	if err != nil {
+		t.Fatalf("ParseMessages: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	if got := entries[1].Parts[1].Type; got != PartImage {
+		t.Errorf("expected image part, got %s", got)
+	}
+	call := entries[2].Parts[0]
+	if call.Type != PartToolCall || call.ToolCallID != "call_1" || call.ToolName != "read_file" {
+		t.Errorf("unexpected tool call part: %+v", call)
+	}
+	res := entries[3].Parts[0]
+	if res.Type != PartToolResult || res.ToolCallID != "call_1" {
+		t.Errorf("unexpected tool result part: %+v", res)
+	}
+}
+
+func TestParseAnthropicMessages(t *testing.T) {
+	entries, err := ParseMessages(FormatAnthropic, rawMessages(t, `[
+		{"role": "user", "content": "Read main.go"},
+		{"role": "assistant", "content": [
+			{"type": "text", "text": "Reading it."},
+			{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"path": "main.go"}}
+		]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "package main"}]}
+		]}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseMessages: %v", err)
+	}
+	if entries[2].Role != "tool" {
+		t.Errorf("expected tool_result-only user turn to map to role tool, got %s", entries[2].Role)
+	}
+	if entries[2].Parts[0].Text != "package main" {
+		t.Errorf("unexpected tool result text %q", entries[2].Parts[0].Text)
+	}
+	if entries[1].Parts[1].Arguments != `{"path": "main.go"}` {
+		t.Errorf("unexpected tool input %q", entries[1].Parts[1].Arguments)
+	}
+}
+
+func TestPushMessagesRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "m1", MaxTokens: 50000})
+
+	_, err := s.Push(ctx, PushRequest{
+		SessionID: "m1",
+		Format:    FormatOpenAI,
+		Messages:  rawMessages(t, openAITranscript),
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "m1", Format: FormatOpenAI})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	if len(res.Messages) != 5 {
+		t.Fatalf("expected 5 openai messages, got %d", len(res.Messages))
+	}
+	if res.Entries[2].GroupID == "" || res.Entries[2].GroupID != res.Entries[3].GroupID {
+		t.Errorf("expected tool call and result to share a group, got %q and %q",
+			res.Entries[2].GroupID, res.Entries[3].GroupID)
+	}
+	if res.Messages[3]["role"] != "tool" || res.Messages[3]["tool_call_id"] != "call_1" {
+		t.Errorf("unexpected tool message: %v", res.Messages[3])
+	}
+
+	res, err = s.Context(ctx, ContextRequest{SessionID: "m1", Format: FormatAnthropic})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	if len(res.System) != 1 {
+		t.Errorf("expected system prompt to be split out, got %d blocks", len(res.System))
+	}
+	// user, assistant(tool_use), user(tool_result), assistant
+	if len(res.Messages) != 4 {
+		t.Fatalf("expected 4 anthropic messages, got %d", len(res.Messages))
+	}
+	blocks := res.Messages[2]["content"].([]map[string]interface{})
+	if blocks[0]["type"] != "tool_result" || blocks[0]["tool_use_id"] != "call_1" {
+		t.Errorf("unexpected tool_result block: %v", blocks[0])
+	}
+}
+
+func TestToolGroupNotSplitByMaxTokens(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "m2", MaxTokens: 50000})
+
+	_, err := s.Push(ctx, PushRequest{
+		SessionID: "m2",
+		Format:    FormatOpenAI,
+		Messages: rawMessages(t, `[
+			{"role": "user", "content": "Run the tests"},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "c1", "type": "function", "function": {"name": "run", "arguments": "{}"}}
+			]},
+			{"role": "tool", "tool_call_id": "c1", "content": "ok   pkg/session   0.05s\nok   pkg/memory   0.08s\nok   pkg/cache   0.11s"}
+		]`),
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// Room for the user turn and the call, but not the result.
+	res, err := s.Context(ctx, ContextRequest{SessionID: "m2", MaxTokens: 12})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	for _, e := range res.Entries {
+		if e.GroupID != "" {
+			t.Errorf("expected partial tool group to be dropped, got entry %s", e.Role)
+		}
+	}
+}
+
+func TestToolGroupEvictedAtomically(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "m3", MaxTokens: 25, PreserveRecent: 1})
+
+	push, err := s.Push(ctx, PushRequest{
+		SessionID: "m3",
+		Format:    FormatOpenAI,
+		Messages: rawMessages(t, `[
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "c1", "type": "function", "function": {"name": "grep", "arguments": "{\"pattern\":\"TODO\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "c1", "content": "main.go:10: TODO handle errors\nserver.go:42: TODO add tracing"},
+			{"role": "user", "content": "Now fix the first TODO in main.go and explain the change."}
+		]`),
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "m3"})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	grouped := 0
+	for _, e := range res.Entries {
+		if e.GroupID != "" {
+			grouped++
+			if e.Level != LevelFull {
+				t.Errorf("tool group entries must not be compressed, got level %d", e.Level)
+			}
+		}
+	}
+	if grouped != 0 || push.Evicted != 2 {
+		t.Errorf("expected the whole tool group evicted, %d of 2 entries remain (evicted %d)", grouped, push.Evicted)
+	}
+}
+
+func TestAnthropicCacheControlFromBoundary(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CacheBoundary.MinPrefixTokens = 1
+	s, err := NewSQLiteStore(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "c1", MaxTokens: 50000})
+
+	for _, content := range []string{"Project uses Go 1.24.", "Tests live next to code.", "Fix the bug."} {
+		if _, err := s.Push(ctx, PushRequest{
+			SessionID: "c1",
+			Format:    FormatAnthropic,
+			Messages:  rawMessages(t, `[{"role": "user", "content": "`+content+`"}]`),
+		}); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "c1", Format: FormatAnthropic})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	marked := 0
+	for _, m := range res.Messages {
+		for _, b := range m["content"].([]map[string]interface{}) {
+			if _, ok := b["cache_control"]; ok {
+				marked++
+			}
+		}
+	}
+	if marked == 0 {
+		t.Error("expected a cache_control marker on the stable prefix")
+	}
+}
+
+func TestRenderOpenAIMixedToolResult(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "m5", MaxTokens: 50000})
+
+	_, err := s.Push(ctx, PushRequest{
+		SessionID: "m5",
+		Format:    FormatAnthropic,
+		Messages: rawMessages(t, `[
+			{"role": "assistant", "content": [
+				{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"path": "main.go"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "package main"},
+				{"type": "text", "text": "Now add a test."}
+			]}
+		]`),
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "m5", Format: FormatOpenAI})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	// assistant(tool_calls), tool, user
+	if len(res.Messages) != 3 {
+		t.Fatalf("expected 3 openai messages, got %d: %v", len(res.Messages), res.Messages)
+	}
+	if res.Messages[1]["role"] != "tool" || res.Messages[1]["tool_call_id"] != "toolu_1" {
+		t.Errorf("unexpected tool message: %v", res.Messages[1])
+	}
+	if res.Messages[2]["role"] != "user" || res.Messages[2]["content"] != "Now add a test." {
+		t.Errorf("expected the text kept as a user message, got %v", res.Messages[2])
+	}
+}
+
+func TestRenderOpenAIOrphanToolResult(t *testing.T) {
+	msgs := renderOpenAI(ContextEntry{
+		Role:    "tool",
+		Content: "ok pkg/session",
+		Level:   LevelSentence,
+		Parts:   []ContentPart{{Type: PartToolResult, ToolCallID: "c1", Text: "ok pkg/session 0.05s"}},
+	})
+	if len(msgs) != 1 || msgs[0]["role"] != "user" || msgs[0]["content"] != "ok pkg/session" {
+		t.Errorf("expected a compressed result rendered as user text, got %v", msgs)
+	}
+
+	msgs = renderOpenAI(ContextEntry{
+		Role:  "tool",
+		Level: LevelFull,
+		Parts: []ContentPart{{Type: PartToolResult, ToolCallID: "c1", Text: "ok"}},
+	})
+	if len(msgs) != 1 || msgs[0]["role"] != "user" || msgs[0]["content"] != "ok" {
+		t.Errorf("expected a result without its call rendered as user text, got %v", msgs)
+	}
+	if _, ok := msgs[0]["tool_call_id"]; ok {
+		t.Error("expected no tool_call_id on the fallback message")
+	}
+}
+
+func TestContextRoleFilterKeepsToolGroup(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "m6", MaxTokens: 50000})
+
+	_, err := s.Push(ctx, PushRequest{
+		SessionID: "m6",
+		Format:    FormatOpenAI,
+		Messages:  rawMessages(t, openAITranscript),
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "m6", Role: "tool", Format: FormatOpenAI})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	if len(res.Entries) != 2 || res.Entries[0].Role != "assistant" || res.Entries[1].Role != "tool" {
+		t.Fatalf("expected the whole tool group, got %+v", res.Entries)
+	}
+	if _, ok := res.Messages[0]["tool_calls"]; !ok {
+		t.Errorf("expected the call rendered before its result, got %v", res.Messages[0])
+	}
+}
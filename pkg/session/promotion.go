@@ -0,0 +1,231 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/memory"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
+)
+
+// PromotionPolicy controls when and which session entries are copied into
+// long-term memory. Promotion is opt-in: it only runs once a memory store
+// has been attached with SetPromotion.
+type PromotionPolicy struct {
+	// OnDelete promotes qualifying entries before a session is deleted or
+	// expired by ExpireIdle.
+	OnDelete bool
+
+	// EveryNPushes promotes qualifying entries after every N pushes.
+	// 0 disables periodic promotion.
+	EveryNPushes int
+
+	// MinImportance is the summarize.ScoreImportance threshold an entry must
+	// reach to be promoted. Default: 0.7.
+	MinImportance float64
+
+	// Sources lists entry sources that are always promoted regardless of
+	// importance (e.g. "decision", "user_preference").
+	Sources []string
+
+	// Tags are attached to every promoted memory.
+	Tags []string
+}
+
+// DefaultPromotionPolicy returns sensible defaults.
+func DefaultPromotionPolicy() PromotionPolicy {
+	return PromotionPolicy{
+		OnDelete:      true,
+		EveryNPushes:  0,
+		MinImportance: 0.7,
+	}
+}
+
+// promoter pairs a memory store with the policy used to feed it.
+type promoter struct {
+	store  memory.Store
+	policy PromotionPolicy
+}
+
+// SetPromotion attaches a memory store and enables session-to-memory
+// promotion under the given policy. Pass a nil store to disable it.
+func (s *SQLiteStore) SetPromotion(store memory.Store, policy PromotionPolicy) {
+	if store == nil {
+		s.promoter = nil
+		return
+	}
+	if policy.MinImportance <= 0 {
+		policy.MinImportance = DefaultPromotionPolicy().MinImportance
+	}
+	s.promoter = &promoter{store: store, policy: policy}
+}
+
+// promote writes qualifying, not-yet-promoted entries of a session to the
+// memory store. Entries go through the store's normal dedup and conflict
+// detection. Returns the number of entries handed to the memory store.
+func (s *SQLiteStore) promote(ctx context.Context, sessionID string) (int, error) {
+	if s.promoter == nil {
+		return 0, nil
+	}
+	policy := s.promoter.policy
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, role, original_content, source, embedding, embedding_q
+		 FROM session_entries
+		 WHERE session_id = ? AND promoted = 0 AND source != ?
+		 ORDER BY seq ASC`,
+		sessionID, rollingSummarySource,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("query promotion candidates: %w", err)
+	}
+
+	sources := make(map[string]bool, len(policy.Sources))
+	for _, src := range policy.Sources {
+		sources[src] = true
+	}
+
+	var ids []string
+	var entries []memory.StoreEntry
+	for rows.Next() {
+		var id, role, content, source string
+		var embBlob, embQBlob []byte
+		if err := rows.Scan(&id, &role, &content, &source, &embBlob, &embQBlob); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+
+		importance := summarize.ScoreImportance(summarize.Turn{Role: role, Content: content})
+		if importance < policy.MinImportance && !sources[source] {
+			continue
+		}
+
+		memSource := source
+		if memSource == "" {
+			memSource = "session"
+		}
+		ids = append(ids, id)
+		entries = append(entries, memory.StoreEntry{
+			Text:      content,
+			Embedding: distillmath.StoredEmbedding(embBlob, embQBlob),
+			Source:    memSource,
+			Tags:      policy.Tags,
+			Metadata: map[string]interface{}{
+				"role":             role,
+				"session_entry_id": id,
+				"importance":       importance,
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, err
+	}
+	_ = rows.Close()
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.promoter.store.Store(ctx, memory.StoreRequest{
+		SessionID: sessionID,
+		Entries:   entries,
+	}); err != nil {
+		return 0, fmt.Errorf("store promoted entries: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := s.db.ExecContext(ctx,
+			"UPDATE session_entries SET promoted = 1 WHERE id = ?", id,
+		); err != nil {
+			return len(ids), fmt.Errorf("mark promoted: %w", err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// shouldPromoteOnPush reports whether the periodic promotion trigger fires
+// for the given push count.
+func (s *SQLiteStore) shouldPromoteOnPush(pushCount int) bool {
+	if s.promoter == nil || s.promoter.policy.EveryNPushes <= 0 {
+		return false
+	}
+	return pushCount > 0 && pushCount%s.promoter.policy.EveryNPushes == 0
+}
+
+// ExpireIdle deletes sessions that have not been updated within ttl,
+// promoting their entries first when the promotion policy asks for it.
+// Returns the number of sessions removed.
+func (s *SQLiteStore) ExpireIdle(ctx context.Context, ttl time.Duration) (int, error) {
+	if ttl <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Add(-ttl).Format(time.RFC3339Nano)
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM sessions WHERE updated_at < ?", cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("query idle sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, err
+	}
+	_ = rows.Close()
+
+	expired := 0
+	for _, id := range ids {
+		ok, err := s.expireIfIdle(ctx, id, cutoff)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expireIfIdle deletes a session if it has not been updated since cutoff,
+// re-checking under the session's lock so that a push landing after the
+// idle scan keeps the session alive. Reports whether it was deleted.
+func (s *SQLiteStore) expireIfIdle(ctx context.Context, sessionID, cutoff string) (bool, error) {
+	unlock := s.locks.lock(sessionID)
+	defer unlock()
+
+	var updatedAt string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT updated_at FROM sessions WHERE id = ?", sessionID,
+	).Scan(&updatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check idle session: %w", err)
+	}
+	if updatedAt >= cutoff {
+		return false, nil
+	}
+
+	if _, err := s.deleteSession(ctx, sessionID); err != nil {
+		if err == ErrSessionNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
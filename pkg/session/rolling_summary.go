@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
+)
+
+// rollingSummarySource marks the single "conversation so far" entry of a
+// session. The entry is stored at seq 0 so it always leads the window.
+const rollingSummarySource = "rolling_summary"
+
+// rollingSummaryHeader is the first line of the rolling summary content.
+const rollingSummaryHeader = "Conversation so far:"
+
+// RollingSummaryConfig controls the rolling summary entry. When enabled,
+// entries that are evicted or compressed to LevelKeywords are folded into a
+// system entry so the early conversation survives as a short thread.
+type RollingSummaryConfig struct {
+	// Enabled turns the rolling summary on or off. Default: false.
+	Enabled bool
+
+	// MaxTokens caps the size of the summary entry. When a fold pushes it
+	// over, the oldest lines are merged into a keyword line. The cap never
+	// exceeds half the session's budget, so the summary cannot crowd out
+	// the entries it leads. Default: 512.
+	MaxTokens int
+}
+
+// DefaultRollingSummaryConfig returns sensible defaults.
+func DefaultRollingSummaryConfig() RollingSummaryConfig {
+	return RollingSummaryConfig{
+		Enabled:   false,
+		MaxTokens: 512,
+	}
+}
+
+// foldIntoSummary appends a sentence-level summary of an entry to the
+// session's rolling summary, creating the summary entry on first use.
+// Returns the change in session tokens caused by the fold.
+func (s *SQLiteStore) foldIntoSummary(ctx context.Context, q dbtx, sessionID string, cfg *sessionConfig, role, original string) (int, error) {
+	sentence := strings.Join(strings.Fields(summarize.SentenceSummary(original)), " ")
+	if sentence == "" {
+		return 0, nil
+	}
+	line := "- " + sentence
+	if role != "" {
+		line = "- " + role + ": " + sentence
+	}
+
+	var id, content string
+	var tokens int
+	err := q.QueryRowContext(ctx,
+		"SELECT id, content, tokens FROM session_entries WHERE session_id = ? AND source = ?",
+		sessionID, rollingSummarySource,
+	).Scan(&id, &content, &tokens)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("load rolling summary: %w", err)
+	}
+	if content == "" {
+		content = rollingSummaryHeader
+	}
+
+	newContent := recompressSummary(content+"\n"+line, s.summaryMaxTokens(cfg), cfg.counter)
+	newTokens := cfg.counter.Count(newContent)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	// The summary changes on every fold, so it restarts its stability clock
+	// like a freshly inserted entry.
+	var pushCount int
+	if err := q.QueryRowContext(ctx,
+		"SELECT push_count FROM sessions WHERE id = ?", sessionID,
+	).Scan(&pushCount); err != nil {
+		return 0, fmt.Errorf("load push count: %w", err)
+	}
+
+	if id == "" {
+		_, err = q.ExecContext(ctx,
+			`INSERT INTO session_entries
+			 (id, session_id, role, content, original_content, source, importance, compression_level, tokens, seq, inserted_at_push, stable_since_turn, content_hash, created_at, folded)
+			 VALUES (?, ?, 'system', ?, ?, ?, 1.0, 0, ?, 0, ?, 0, ?, ?, 1)`,
+			generateID(), sessionID, newContent, newContent, rollingSummarySource,
+			newTokens, pushCount+1, hashContent(newContent), now,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("insert rolling summary: %w", err)
+		}
+		return newTokens, nil
+	}
+
+	_, err = q.ExecContext(ctx,
+		`UPDATE session_entries
+		 SET content = ?, original_content = ?, tokens = ?, content_hash = ?,
+		     inserted_at_push = ?, stable_since_turn = 0, compressed_at = ?
+		 WHERE id = ?`,
+		newContent, newContent, newTokens, hashContent(newContent), pushCount+1, now, id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("update rolling summary: %w", err)
+	}
+	return newTokens - tokens, nil
+}
+
+// summaryMaxTokens is the rolling summary cap for a session: the configured
+// MaxTokens, but at most half the session's budget.
+func (s *SQLiteStore) summaryMaxTokens(cfg *sessionConfig) int {
+	limit := s.cfg.RollingSummary.MaxTokens
+	if half := cfg.maxTokens / 2; half > 0 && (limit <= 0 || limit > half) {
+		limit = half
+	}
+	return limit
+}
+
+// recompressSummary shrinks a rolling summary to maxTokens by merging its
+// oldest lines (at least two, up to half) into a single keyword line,
+// repeating until it fits or only one line remains.
+func recompressSummary(content string, maxTokens int, counter tokenizer.Counter) string {
+	if maxTokens <= 0 || counter.Count(content) <= maxTokens {
+		return content
+	}
+
+	lines := strings.Split(strings.TrimPrefix(content, rollingSummaryHeader+"\n"), "\n")
+	for len(lines) > 1 && counter.Count(joinSummary(lines)) > maxTokens {
+		n := len(lines) / 2
+		if n < 2 {
+			n = 2
+		}
+		var merged []string
+		for _, l := range lines[:n] {
+			l = strings.TrimPrefix(l, "- ")
+			l = strings.TrimPrefix(l, "earlier: ")
+			merged = append(merged, l)
+		}
+		keywords := summarize.KeywordSummary(strings.Join(merged, ". "))
+		lines = append([]string{"- earlier: " + keywords}, lines[n:]...)
+	}
+	return joinSummary(lines)
+}
+
+func joinSummary(lines []string) string {
+	return rollingSummaryHeader + "\n" + strings.Join(lines, "\n")
+}
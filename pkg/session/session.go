@@ -5,8 +5,12 @@ package session
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
+
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
 )
 
 // Common errors.
@@ -14,6 +18,7 @@ var (
 	ErrSessionNotFound = errors.New("session not found")
 	ErrSessionExists   = errors.New("session already exists")
 	ErrOverBudget      = errors.New("single entry exceeds token budget")
+	ErrVersionConflict = errors.New("session version conflict")
 )
 
 // CompressionLevel indicates how compressed an entry is.
@@ -49,6 +54,8 @@ type Session struct {
 	EntryCount           int       `json:"entry_count"`
 	CacheBoundaryTokens  int       `json:"cache_boundary_tokens,omitempty"`
 	PushCount            int       `json:"push_count,omitempty"`
+	Version              int       `json:"version"`
+	Tokenizer            string    `json:"tokenizer,omitempty"`
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -59,12 +66,26 @@ type CreateRequest struct {
 	MaxTokens      int     `json:"max_tokens"`
 	DedupThreshold float64 `json:"dedup_threshold,omitempty"`
 	PreserveRecent int     `json:"preserve_recent,omitempty"` // always keep last N at full fidelity
+
+	// Tokenizer names the token counter used for this session's budget
+	// (e.g. "cl100k_base"). Empty follows the process-wide default.
+	Tokenizer string `json:"tokenizer,omitempty"`
 }
 
 // PushRequest is the input for adding entries to a session.
 type PushRequest struct {
 	SessionID string       `json:"session_id"`
 	Entries   []PushEntry  `json:"entries"`
+
+	// Format and Messages push provider-native messages ("openai" or
+	// "anthropic") instead of, or after, Entries.
+	Format   string            `json:"format,omitempty"`
+	Messages []json.RawMessage `json:"messages,omitempty"`
+
+	// ExpectedVersion makes the push conditional: it is rejected with
+	// ErrVersionConflict unless the session is still at this version.
+	// Nil pushes unconditionally.
+	ExpectedVersion *int `json:"expected_version,omitempty"`
 }
 
 // PushEntry is a single entry in a push request.
@@ -74,6 +95,11 @@ type PushEntry struct {
 	Source     string    `json:"source,omitempty"`
 	Embedding  []float32 `json:"embedding,omitempty"`
 	Importance float64   `json:"importance,omitempty"` // default 0.5
+
+	// Parts holds structured content (text, images, tool calls and results).
+	// Content defaults to the flattened parts when empty. Tool calls and
+	// their results are grouped and only ever evicted together.
+	Parts []ContentPart `json:"parts,omitempty"`
 }
 
 // PushResult is the output of a push operation.
@@ -85,6 +111,10 @@ type PushResult struct {
 	Evicted             int                  `json:"evicted"`
 	CurrentTokens       int                  `json:"current_tokens"`
 	BudgetRemaining     int                  `json:"budget_remaining"`
+	Summarized          int                  `json:"summarized,omitempty"`
+	Promoted            int                  `json:"promoted,omitempty"`
+	PromoteError        string               `json:"promote_error,omitempty"` // promotion failed; the push is stored
+	Version             int                  `json:"version"`
 	CacheBoundary       *CacheBoundaryResult `json:"cache_boundary,omitempty"`
 }
 
@@ -93,12 +123,19 @@ type ContextRequest struct {
 	SessionID string `json:"session_id"`
 	MaxTokens int    `json:"max_tokens,omitempty"` // 0 = return full window
 	Role      string `json:"role,omitempty"`       // filter by role
+	Format    string `json:"format,omitempty"`     // also emit messages: openai, anthropic
 }
 
 // ContextResult is the output of a context read.
 type ContextResult struct {
 	Entries []ContextEntry `json:"entries"`
 	Stats   ContextStats   `json:"stats"`
+
+	// Messages is a ready-to-send messages array in the requested Format.
+	// For Anthropic, cache_control markers are placed from the session's
+	// cache boundary and system entries are returned in System.
+	Messages []map[string]interface{} `json:"messages,omitempty"`
+	System   []map[string]interface{} `json:"system,omitempty"`
 }
 
 // ContextEntry is a single entry returned from a context read.
@@ -110,6 +147,8 @@ type ContextEntry struct {
 	Level     CompressionLevel `json:"level"`
 	Tokens    int              `json:"tokens"`
 	Age       string           `json:"age"`
+	Parts     []ContentPart    `json:"parts,omitempty"`
+	GroupID   string           `json:"group_id,omitempty"` // shared by a tool call and its results
 }
 
 // ContextStats contains context window metrics.
@@ -124,6 +163,8 @@ type ContextStats struct {
 type DeleteResult struct {
 	SessionID    string `json:"session_id"`
 	EntriesRemoved int  `json:"entries_removed"`
+	Promoted     int    `json:"promoted,omitempty"`
+	PromoteError string `json:"promote_error,omitempty"` // promotion failed; the session is deleted
 }
 
 // Store is the interface for session backends.
@@ -151,6 +192,24 @@ type Config struct {
 
 	// CacheBoundary configures the session-aware cache boundary manager.
 	CacheBoundary CacheBoundaryConfig
+
+	// RollingSummary configures the "conversation so far" entry that
+	// absorbs evicted and keyword-level entries.
+	RollingSummary RollingSummaryConfig
+
+	// Summarizer writes the summary- and sentence-level versions of
+	// compressed entries, e.g. a summarize.AbstractiveSummarizer. Nil uses
+	// the extractive compressor.
+	Summarizer summarize.TextSummarizer
+
+	// Quantization stores entry embeddings as int8 or binary instead of
+	// float32, and push dedup compares the quantised form. Existing
+	// float32 entries are converted when the store opens. Default: float32.
+	Quantization distillmath.Quantization
+
+	// Rescore keeps the float32 embedding next to the quantised one and
+	// confirms near-threshold duplicates at full precision.
+	Rescore bool
 }
 
 // DefaultConfig returns sensible defaults.
@@ -160,5 +219,6 @@ func DefaultConfig() Config {
 		DefaultDedupThreshold: 0.15,
 		DefaultPreserveRecent: 10,
 		CacheBoundary:         DefaultCacheBoundaryConfig(),
+		RollingSummary:        DefaultRollingSummaryConfig(),
 	}
 }
@@ -2,9 +2,18 @@ package session
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/memory"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 )
 
 func makeEmbedding(angle float64, dim int) []float32 {
@@ -312,6 +321,104 @@ func TestPushEmptyContent(t *testing.T) {
 	}
 }
 
+// stubSummarizer returns a fixed summary for each level, or an error.
+type stubSummarizer struct{ err error }
+
+func (s stubSummarizer) SummarizeText(_ context.Context, _ string, level summarize.Level) (string, error) {
+	return fmt.Sprintf("summary at level %d", level), s.err
+}
+
+func TestCompressEntry_Summarizer(t *testing.T) {
+	text := "The authentication service uses JWT tokens with RS256 signing. It validates tokens on every request."
+	s := newTestStore(t)
+	s.cfg.Summarizer = stubSummarizer{}
+	ctx := context.Background()
+
+	if got := s.compressEntry(ctx, text, LevelSummary); got != "summary at level 1" {
+		t.Errorf("summary level: got %q", got)
+	}
+	if got := s.compressEntry(ctx, text, LevelSentence); got != "summary at level 2" {
+		t.Errorf("sentence level: got %q", got)
+	}
+	if got := s.compressEntry(ctx, text, LevelKeywords); got != compressToLevel(text, LevelKeywords) {
+		t.Errorf("keywords should stay extractive, got %q", got)
+	}
+
+	// Summaries are cached, so a later failure still returns them.
+	s.cfg.Summarizer = stubSummarizer{err: errors.New("model down")}
+	if got := s.compressEntry(ctx, text, LevelSentence); got != "summary at level 2" {
+		t.Errorf("expected the cached summary, got %q", got)
+	}
+
+	s = newTestStore(t)
+	s.cfg.Summarizer = stubSummarizer{err: errors.New("model down")}
+	if got := s.compressEntry(ctx, text, LevelSentence); got != compressToLevel(text, LevelSentence) {
+		t.Errorf("expected extractive fallback, got %q", got)
+	}
+}
+
+// blockingSummarizer counts its calls and fails any made while the
+// store's connection is inside a transaction.
+type blockingSummarizer struct {
+	s     *SQLiteStore
+	calls int
+}
+
+func (b *blockingSummarizer) SummarizeText(ctx context.Context, _ string, level summarize.Level) (string, error) {
+	b.calls++
+	// With one connection, a query here blocks while a transaction is open.
+	qctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	var n int
+	if err := b.s.db.QueryRowContext(qctx, "SELECT 1").Scan(&n); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("summary at level %d", level), nil
+}
+
+func TestPushSummarizesOutsideTransaction(t *testing.T) {
+	s := newTestStore(t)
+	sum := &blockingSummarizer{s: s}
+	s.cfg.Summarizer = sum
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "abs", MaxTokens: 60, PreserveRecent: 1})
+
+	text := "The authentication service uses JWT tokens with RS256 signing and validates them on every request."
+	for i := 0; i < 3; i++ {
+		if _, err := s.Push(ctx, PushRequest{SessionID: "abs", Entries: []PushEntry{{Role: "user", Content: text + strings.Repeat(" more", i)}}}); err != nil {
+			t.Fatalf("Push %d: %v", i, err)
+		}
+	}
+	if sum.calls == 0 {
+		t.Fatal("expected the summarizer to be used")
+	}
+
+	res, err := s.Context(ctx, ContextRequest{SessionID: "abs"})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	summarized := false
+	for _, e := range res.Entries {
+		if strings.HasPrefix(e.Content, "summary at level") {
+			summarized = true
+		}
+	}
+	if !summarized {
+		t.Errorf("expected an entry compressed with the prepared summary, got %+v", res.Entries)
+	}
+
+	// A stale push fails before the model is asked again.
+	calls := sum.calls
+	stale := 0
+	_, err = s.Push(ctx, PushRequest{SessionID: "abs", ExpectedVersion: &stale, Entries: []PushEntry{{Role: "user", Content: text + " again"}}})
+	if err != ErrVersionConflict {
+		t.Fatalf("expected a version conflict, got %v", err)
+	}
+	if sum.calls != calls {
+		t.Errorf("expected no summarizer calls for a stale push, got %d", sum.calls-calls)
+	}
+}
+
 func TestCompressToLevel(t *testing.T) {
 	text := "The authentication service uses JWT tokens with RS256 signing. It validates tokens on every request. The token expiry is set to 24 hours. Refresh tokens are stored in Redis with a 7-day TTL. The service also supports OAuth2 for third-party integrations."
 
@@ -346,3 +453,390 @@ func TestCompressToLevel(t *testing.T) {
 		t.Error("keywords should differ from original text")
 	}
 }
+
+func TestRollingSummary(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultPreserveRecent = 1
+	cfg.RollingSummary.Enabled = true
+	cfg.RollingSummary.MaxTokens = 40
+	s, err := NewSQLiteStore(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "roll", MaxTokens: 50})
+
+	var summarized int
+	for _, content := range []string{
+		"The login endpoint returns 500 when the JWT secret is rotated. Users are logged out.",
+		"We traced the failure to the key cache in auth/keys.go. It never refreshes.",
+		"Decided to add a refresh interval of five minutes to the key cache.",
+		"The refresh interval fix passed all integration tests on staging.",
+		"Next step is to deploy the fix to production and monitor error rates.",
+	} {
+		r, err := s.Push(ctx, PushRequest{
+			SessionID: "roll",
+			Entries:   []PushEntry{{Role: "user", Content: content}},
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+		summarized += r.Summarized
+	}
+	if summarized == 0 {
+		t.Fatal("expected entries to be folded into the rolling summary")
+	}
+
+	result, err := s.Context(ctx, ContextRequest{SessionID: "roll"})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	first := result.Entries[0]
+	if first.Source != rollingSummarySource || first.Role != "system" {
+		t.Fatalf("expected rolling summary first, got role=%s source=%s", first.Role, first.Source)
+	}
+	if !strings.HasPrefix(first.Content, rollingSummaryHeader) {
+		t.Errorf("unexpected summary content %q", first.Content)
+	}
+	if first.Tokens > cfg.RollingSummary.MaxTokens {
+		t.Errorf("summary tokens %d exceed cap %d", first.Tokens, cfg.RollingSummary.MaxTokens)
+	}
+
+	sess, _ := s.Get(ctx, "roll")
+	if sess.CurrentTokens > 70 {
+		t.Errorf("expected tokens <= 70, got %d", sess.CurrentTokens)
+	}
+}
+
+func TestRollingSummaryStaysWithinSessionBudget(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultPreserveRecent = 1
+	cfg.RollingSummary.Enabled = true // default MaxTokens is far above the session budget
+	s, err := NewSQLiteStore(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "small", MaxTokens: 60})
+	for i := 0; i < 12; i++ {
+		_, err := s.Push(ctx, PushRequest{
+			SessionID: "small",
+			Entries: []PushEntry{{Role: "user", Content: fmt.Sprintf(
+				"Step %d of the migration moved the orders table shard %d to the new cluster.", i, i*7)}},
+		})
+		if err != nil {
+			t.Fatalf("Push %d: %v", i, err)
+		}
+	}
+
+	result, err := s.Context(ctx, ContextRequest{SessionID: "small"})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	if first := result.Entries[0]; first.Source != rollingSummarySource || first.Tokens > 30 {
+		t.Errorf("expected the rolling summary capped at half the budget, got %d tokens (source %s)", first.Tokens, first.Source)
+	}
+	if sess, _ := s.Get(ctx, "small"); sess.CurrentTokens > 90 {
+		t.Errorf("expected the session near its budget, got %d tokens", sess.CurrentTokens)
+	}
+}
+
+func TestRecompressSummary(t *testing.T) {
+	content := rollingSummaryHeader
+	for i := 0; i < 20; i++ {
+		content += "\n- user: The deployment pipeline failed on the integration stage again."
+	}
+	out := recompressSummary(content, 50, tokenizer.Default())
+	if n := tokenizer.Count(out); n > 50 {
+		t.Errorf("expected <= 50 tokens, got %d: %q", n, out)
+	}
+	if !strings.Contains(out, "- earlier: ") {
+		t.Errorf("expected merged keyword line, got %q", out)
+	}
+}
+
+func newTestMemoryStore(t *testing.T) *memory.SQLiteStore {
+	t.Helper()
+	m, err := memory.NewSQLiteStore(":memory:", memory.DefaultConfig())
+	if err != nil {
+		t.Fatalf("memory.NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+func TestPromoteOnDelete(t *testing.T) {
+	s := newTestStore(t)
+	mem := newTestMemoryStore(t)
+	ctx := context.Background()
+
+	s.SetPromotion(mem, PromotionPolicy{
+		OnDelete: true,
+		Sources:  []string{"user_preference"},
+		Tags:     []string{"promoted"},
+	})
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "p1", MaxTokens: 50000})
+	_, err := s.Push(ctx, PushRequest{
+		SessionID: "p1",
+		Entries: []PushEntry{
+			{Role: "user", Content: "ok"},
+			{Role: "user", Content: "Always use tabs.", Source: "user_preference"},
+			{Role: "tool", Content: "panic: runtime error: nil pointer dereference in handler.go line 42 while serving request"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	del, err := s.Delete(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if del.Promoted != 2 {
+		t.Errorf("expected 2 promoted, got %d", del.Promoted)
+	}
+
+	recall, err := mem.Recall(ctx, memory.RecallRequest{Query: "anything", Tags: []string{"promoted"}, RecencyWeight: 1})
+	if err != nil {
+		t.Fatalf("Recall: %v", err)
+	}
+	if len(recall.Memories) != 2 {
+		t.Fatalf("expected 2 memories, got %d", len(recall.Memories))
+	}
+	for _, m := range recall.Memories {
+		if m.Text == "ok" {
+			t.Error("low-importance entry should not be promoted")
+		}
+	}
+}
+
+func TestPromoteEveryNPushes(t *testing.T) {
+	s := newTestStore(t)
+	mem := newTestMemoryStore(t)
+	ctx := context.Background()
+
+	s.SetPromotion(mem, PromotionPolicy{EveryNPushes: 2, Sources: []string{"decision"}})
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "p2", MaxTokens: 50000})
+	push := func(content string) *PushResult {
+		r, err := s.Push(ctx, PushRequest{
+			SessionID: "p2",
+			Entries:   []PushEntry{{Role: "assistant", Content: content, Source: "decision"}},
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+		return r
+	}
+
+	if r := push("Use Postgres for the job queue."); r.Promoted != 0 {
+		t.Errorf("expected no promotion on first push, got %d", r.Promoted)
+	}
+	if r := push("Retry failed jobs three times."); r.Promoted != 2 {
+		t.Errorf("expected 2 promoted on second push, got %d", r.Promoted)
+	}
+	push("Log every retry.")
+	if r := push("Alert after the third failure."); r.Promoted != 2 {
+		t.Errorf("expected only new entries promoted, got %d", r.Promoted)
+	}
+
+	stats, _ := mem.Stats(ctx)
+	if stats.TotalMemories != 4 {
+		t.Errorf("expected 4 memories, got %d", stats.TotalMemories)
+	}
+}
+
+type memoryStore = memory.Store
+
+// failingMemory is a memory store whose writes fail.
+type failingMemory struct{ memoryStore }
+
+func (failingMemory) Store(context.Context, memory.StoreRequest) (*memory.StoreResult, error) {
+	return nil, errors.New("memory store down")
+}
+
+func TestPromoteFailureKeepsPush(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	s.SetPromotion(failingMemory{}, PromotionPolicy{EveryNPushes: 1, OnDelete: true, Sources: []string{"decision"}})
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "pf", MaxTokens: 50000})
+	expected := 0
+	r, err := s.Push(ctx, PushRequest{
+		SessionID:       "pf",
+		ExpectedVersion: &expected,
+		Entries:         []PushEntry{{Role: "assistant", Content: "Use Postgres for the job queue.", Source: "decision"}},
+	})
+	if err != nil {
+		t.Fatalf("expected the push to succeed, got %v", err)
+	}
+	if r.Version != 1 || r.Accepted != 1 || r.PromoteError == "" {
+		t.Errorf("expected a committed push reporting the promotion failure, got %+v", r)
+	}
+
+	d, err := s.Delete(ctx, "pf")
+	if err != nil {
+		t.Fatalf("expected the delete to succeed, got %v", err)
+	}
+	if d.PromoteError == "" {
+		t.Error("expected the delete to report the promotion failure")
+	}
+}
+
+func TestExpireIdle(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "old"})
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "fresh"})
+	past := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+	if _, err := s.db.ExecContext(ctx, "UPDATE sessions SET updated_at = ? WHERE id = ?", past, "old"); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	n, err := s.ExpireIdle(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("ExpireIdle: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired session, got %d", n)
+	}
+	if _, err := s.Get(ctx, "old"); err != ErrSessionNotFound {
+		t.Errorf("expected old session removed, got %v", err)
+	}
+	if _, err := s.Get(ctx, "fresh"); err != nil {
+		t.Errorf("expected fresh session kept, got %v", err)
+	}
+}
+
+func TestExpireIdleRechecksUnderLock(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "busy"})
+
+	// A cutoff from before the session's last update, as when a push lands
+	// between the idle scan and the delete.
+	cutoff := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339Nano)
+	ok, err := s.expireIfIdle(ctx, "busy", cutoff)
+	if err != nil {
+		t.Fatalf("expireIfIdle: %v", err)
+	}
+	if ok {
+		t.Error("expected a recently updated session not to expire")
+	}
+	if _, err := s.Get(ctx, "busy"); err != nil {
+		t.Errorf("expected session kept, got %v", err)
+	}
+
+	if ok, err := s.expireIfIdle(ctx, "missing", cutoff); ok || err != nil {
+		t.Errorf("expected a missing session skipped, got %v, %v", ok, err)
+	}
+}
+
+// wordCounter counts whitespace-separated words, so token counts in tests
+// are easy to predict.
+type wordCounter struct{}
+
+func (wordCounter) Count(text string) int { return len(strings.Fields(text)) }
+func (wordCounter) Name() string          { return "words" }
+
+func TestSessionTokenizer(t *testing.T) {
+	tokenizer.Register(wordCounter{})
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	sess, err := s.Create(ctx, CreateRequest{SessionID: "tok", MaxTokens: 1000, Tokenizer: "words"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if sess.Tokenizer != "words" {
+		t.Errorf("expected tokenizer words, got %q", sess.Tokenizer)
+	}
+
+	if _, err := s.Push(ctx, PushRequest{
+		SessionID: "tok",
+		Entries:   []PushEntry{{Role: "user", Content: "the quick brown fox jumps"}},
+	}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	got, err := s.Get(ctx, "tok")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.CurrentTokens != 5 || got.Tokenizer != "words" {
+		t.Errorf("expected 5 tokens counted by words, got %d by %q", got.CurrentTokens, got.Tokenizer)
+	}
+
+	// Nothing is compressed, so originals counted by the same tokenizer
+	// save nothing.
+	cr, err := s.Context(ctx, ContextRequest{SessionID: "tok"})
+	if err != nil {
+		t.Fatalf("Context: %v", err)
+	}
+	if cr.Stats.CompressionSavings != 0 {
+		t.Errorf("expected no compression savings, got %d", cr.Stats.CompressionSavings)
+	}
+
+	if _, err := s.Create(ctx, CreateRequest{SessionID: "bad", Tokenizer: "nope"}); !errors.Is(err, tokenizer.ErrUnknownTokenizer) {
+		t.Errorf("expected ErrUnknownTokenizer, got %v", err)
+	}
+}
+
+func TestPushDedup_Quantized(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "sessions.db")
+
+	cfg := DefaultConfig()
+	s, err := NewSQLiteStore(dsn, cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	_, _ = s.Create(ctx, CreateRequest{SessionID: "q", MaxTokens: 50000})
+	if _, err := s.Push(ctx, PushRequest{
+		SessionID: "q",
+		Entries:   []PushEntry{{Role: "tool", Content: "File: auth/jwt.go contents...", Embedding: makeEmbedding(0, 64)}},
+	}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	_ = s.Close()
+
+	// Reopening with int8 quantization converts the float32 entries, and
+	// dedup decisions match the float32 baseline.
+	for i, rescore := range []bool{false, true} {
+		cfg.Quantization = distillmath.QuantizeInt8
+		cfg.Rescore = rescore
+		s, err = NewSQLiteStore(dsn, cfg)
+		if err != nil {
+			t.Fatalf("NewSQLiteStore: %v", err)
+		}
+
+		var quantized int
+		if err := s.db.QueryRowContext(ctx, "SELECT COUNT(embedding_q) FROM session_entries").Scan(&quantized); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if quantized != i+1 {
+			t.Errorf("rescore=%v: expected %d quantised entries, got %d", rescore, i+1, quantized)
+		}
+
+		r, err := s.Push(ctx, PushRequest{
+			SessionID: "q",
+			Entries: []PushEntry{
+				{Role: "tool", Content: "File: auth/jwt.go (re-read)", Embedding: makeEmbedding(0.01, 64)},
+				{Role: "tool", Content: "File: billing/stripe.go", Embedding: makeEmbedding(math.Pi/2*float64(i+1), 64)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+		if r.Deduplicated != 1 || r.Accepted != 1 {
+			t.Errorf("rescore=%v: expected 1 deduplicated and 1 accepted, got %d and %d", rescore, r.Deduplicated, r.Accepted)
+		}
+		_ = s.Close()
+	}
+}
@@ -4,26 +4,42 @@ import (
 	"context"
 	"crypto/rand"
 	"database/sql"
-	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"math"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Siddhant-K-code/distill/pkg/compress"
 	distillmath "github.com/Siddhant-K-code/distill/pkg/math"
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
+	"github.com/Siddhant-K-code/distill/pkg/summarize"
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 	"github.com/Siddhant-K-code/distill/pkg/types"
 	_ "modernc.org/sqlite"
 )
 
 // SQLiteStore implements Store using SQLite.
-// Single connection (SetMaxOpenConns(1)) - SQLite handles serialization.
+// Single connection (SetMaxOpenConns(1)) - SQLite handles statement
+// serialization; multi-statement operations on one session are serialized
+// by a per-session lock.
 type SQLiteStore struct {
-	db      *sql.DB
-	cfg     Config
-	boundary *CacheBoundaryManager
+	db        *sql.DB
+	cfg       Config
+	boundary  *CacheBoundaryManager
+	promoter  *promoter
+	locks     *sessionLocks
+	summaries *summaryCache
+}
+
+// dbtx is the query surface shared by *sql.DB and *sql.Tx, letting the
+// steps of a push run inside its transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // NewSQLiteStore creates a new SQLite-backed session store.
@@ -49,9 +65,11 @@ func NewSQLiteStore(dsn string, cfg Config) (*SQLiteStore, error) {
 	}
 
 	s := &SQLiteStore{
-		db:       db,
-		cfg:      cfg,
-		boundary: newCacheBoundaryManager(db, cfg.CacheBoundary),
+		db:        db,
+		cfg:       cfg,
+		boundary:  newCacheBoundaryManager(db, cfg.CacheBoundary),
+		locks:     newSessionLocks(),
+		summaries: newSummaryCache(),
 	}
 	if err := s.migrate(); err != nil {
 		_ = db.Close()
@@ -70,6 +88,8 @@ func (s *SQLiteStore) migrate() error {
 		preserve_recent        INTEGER NOT NULL DEFAULT 10,
 		push_count             INTEGER NOT NULL DEFAULT 0,
 		cache_boundary_tokens  INTEGER NOT NULL DEFAULT 0,
+		version                INTEGER NOT NULL DEFAULT 0,
+		tokenizer              TEXT NOT NULL DEFAULT '',
 		created_at             TEXT NOT NULL,
 		updated_at             TEXT NOT NULL
 	);
@@ -90,14 +110,86 @@ func (s *SQLiteStore) migrate() error {
 		content_hash      TEXT NOT NULL DEFAULT '',
 		created_at        TEXT NOT NULL,
 		compressed_at     TEXT DEFAULT '',
+		folded            INTEGER NOT NULL DEFAULT 0,
+		promoted          INTEGER NOT NULL DEFAULT 0,
+		parts             TEXT NOT NULL DEFAULT '',
+		group_id          TEXT NOT NULL DEFAULT '',
+		tool_call_ids     TEXT NOT NULL DEFAULT '',
 		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
 	);
 	CREATE INDEX IF NOT EXISTS idx_entries_session ON session_entries(session_id);
 	CREATE INDEX IF NOT EXISTS idx_entries_seq ON session_entries(session_id, seq);
 	CREATE INDEX IF NOT EXISTS idx_entries_stable ON session_entries(session_id, stable_since_turn);
 	`
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Add columns to existing databases that lack them.
+	_, _ = s.db.Exec("ALTER TABLE sessions ADD COLUMN tokenizer TEXT NOT NULL DEFAULT ''")
+	_, _ = s.db.Exec("ALTER TABLE sessions ADD COLUMN version INTEGER NOT NULL DEFAULT 0")
+	for _, col := range []struct{ name, def string }{
+		{"folded", "INTEGER NOT NULL DEFAULT 0"},
+		{"promoted", "INTEGER NOT NULL DEFAULT 0"},
+		{"parts", "TEXT NOT NULL DEFAULT ''"},
+		{"group_id", "TEXT NOT NULL DEFAULT ''"},
+		{"tool_call_ids", "TEXT NOT NULL DEFAULT ''"},
+		{"embedding_q", "BLOB"},
+	} {
+		_, _ = s.db.Exec("ALTER TABLE session_entries ADD COLUMN " + col.name + " " + col.def)
+	}
+
+	if _, err := s.QuantizeEmbeddings(context.Background()); err != nil {
+		return fmt.Errorf("quantize embeddings: %w", err)
+	}
+	return nil
+}
+
+// QuantizeEmbeddings converts stored float32 entry embeddings to the
+// configured Quantization, dropping the float32 copy unless Rescore is set.
+// It runs when the store opens. Returns the number of entries converted.
+func (s *SQLiteStore) QuantizeEmbeddings(ctx context.Context) (int, error) {
+	quant := s.cfg.Quantization
+	if quant == distillmath.QuantizeNone {
+		return 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, embedding, embedding_q FROM session_entries WHERE embedding IS NOT NULL")
+	if err != nil {
+		return 0, err
+	}
+	type update struct {
+		id      string
+		full, q []byte
+	}
+	var updates []update
+	for rows.Next() {
+		var id string
+		var full, q []byte
+		if err := rows.Scan(&id, &full, &q); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		newFull, newQ, ok := distillmath.Requantize(full, q, quant, s.cfg.Rescore)
+		if !ok {
+			continue
+		}
+		updates = append(updates, update{id: id, full: newFull, q: newQ})
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, err
+	}
+	_ = rows.Close()
+
+	for _, u := range updates {
+		if _, err := s.db.ExecContext(ctx,
+			"UPDATE session_entries SET embedding = ?, embedding_q = ? WHERE id = ?", u.full, u.q, u.id,
+		); err != nil {
+			return 0, fmt.Errorf("update embedding: %w", err)
+		}
+	}
+	return len(updates), nil
 }
 
 // Create creates a new session.
@@ -122,13 +214,23 @@ func (s *SQLiteStore) Create(ctx context.Context, req CreateRequest) (*Session,
 		preserveRecent = s.cfg.DefaultPreserveRecent
 	}
 
+	// An empty name follows the process-wide default tokenizer.
+	tokenizerName := req.Tokenizer
+	if tokenizerName != "" {
+		counter, err := tokenizer.Get(tokenizerName)
+		if err != nil {
+			return nil, err
+		}
+		tokenizerName = counter.Name()
+	}
+
 	nowTime := time.Now().UTC()
 	now := nowTime.Format(time.RFC3339Nano)
 
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO sessions (id, max_tokens, dedup_threshold, preserve_recent, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		id, maxTokens, threshold, preserveRecent, now, now,
+		`INSERT INTO sessions (id, max_tokens, dedup_threshold, preserve_recent, tokenizer, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, maxTokens, threshold, preserveRecent, tokenizerName, now, now,
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
@@ -140,6 +242,7 @@ func (s *SQLiteStore) Create(ctx context.Context, req CreateRequest) (*Session,
 	return &Session{
 		ID:            id,
 		MaxTokens:     maxTokens,
+		Tokenizer:     tokenizerName,
 		CurrentTokens: 0,
 		EntryCount:    0,
 		CreatedAt:     nowTime,
@@ -148,23 +251,96 @@ func (s *SQLiteStore) Create(ctx context.Context, req CreateRequest) (*Session,
 }
 
 // Push adds entries to a session with dedup and budget enforcement.
+//
+// Pushes to the same session are serialized, so concurrent callers never
+// share seq numbers or interleave budget enforcement. Every push bumps the
+// session version; set ExpectedVersion to make the push conditional on it.
+// A push that fails leaves the session and its version unchanged.
 func (s *SQLiteStore) Push(ctx context.Context, req PushRequest) (*PushResult, error) {
+	entries := append([]PushEntry(nil), req.Entries...)
+	if len(req.Messages) > 0 {
+		parsed, err := ParseMessages(req.Format, req.Messages)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, parsed...)
+	}
+
+	unlock := s.locks.lock(req.SessionID)
+	defer unlock()
+
 	// Load session config
 	sess, err := s.loadSessionConfig(ctx, req.SessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	result := &PushResult{SessionID: req.SessionID}
+	// Reject single entries that exceed the entire budget before anything
+	// is written, so a rejected push leaves the session untouched.
+	for i := range entries {
+		if entries[i].Content == "" && len(entries[i].Parts) > 0 {
+			entries[i].Content = flattenParts(entries[i].Parts)
+		}
+		if sess.counter.Count(entries[i].Content) > sess.maxTokens {
+			return nil, ErrOverBudget
+		}
+	}
+
+	// A push that is already stale fails before the summarizer is asked
+	// for anything; the claim below still decides.
+	if req.ExpectedVersion != nil {
+		var current int
+		if err := s.db.QueryRowContext(ctx,
+			"SELECT version FROM sessions WHERE id = ?", req.SessionID,
+		).Scan(&current); err != nil {
+			return nil, fmt.Errorf("read version: %w", err)
+		}
+		if current != *req.ExpectedVersion {
+			return nil, ErrVersionConflict
+		}
+	}
+
+	// Model calls happen before the transaction opens: the store has one
+	// connection, so a slow summarizer would otherwise stall every session.
+	s.prepareSummaries(ctx, req.SessionID, sess, entries)
+
+	// Everything from the version claim to the push count runs in one
+	// transaction: a failed push changes nothing, and the claim's write
+	// lock keeps other processes out of the seq and push_count reads.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin push: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	version, err := s.claimVersion(ctx, tx, req.SessionID, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PushResult{SessionID: req.SessionID, Version: version}
 
 	// Get current max seq
 	var maxSeq int
-	_ = s.db.QueryRowContext(ctx,
+	if err := tx.QueryRowContext(ctx,
 		"SELECT COALESCE(MAX(seq), 0) FROM session_entries WHERE session_id = ?",
 		req.SessionID,
-	).Scan(&maxSeq)
+	).Scan(&maxSeq); err != nil {
+		return nil, fmt.Errorf("read seq: %w", err)
+	}
 
-	for _, entry := range req.Entries {
+	// inserted_at_push is set after RecordPush increments the counter,
+	// so we read the current push_count and add 1 (the value it will be
+	// after RecordPush runs at the end of this Push call).
+	var currentPushCount int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT push_count FROM sessions WHERE id = ?", req.SessionID,
+	).Scan(&currentPushCount); err != nil {
+		return nil, fmt.Errorf("read push count: %w", err)
+	}
+	insertedAtPush := currentPushCount + 1
+
+	for _, entry := range entries {
 		if entry.Content == "" {
 			continue
 		}
@@ -174,9 +350,14 @@ func (s *SQLiteStore) Push(ctx context.Context, req PushRequest) (*PushResult, e
 			importance = 0.5
 		}
 
-		// Check for duplicates
-		if len(entry.Embedding) > 0 {
-			isDup, err := s.isDuplicate(ctx, req.SessionID, entry.Embedding, sess.dedupThreshold)
+		callIDs := toolCallIDs(entry.Parts)
+		resultIDs := toolResultIDs(entry.Parts)
+		isToolEntry := len(callIDs) > 0 || len(resultIDs) > 0
+
+		// Check for duplicates. Tool calls and results are never deduped so
+		// a pair cannot lose one half.
+		if len(entry.Embedding) > 0 && !isToolEntry {
+			isDup, err := s.isDuplicate(ctx, tx, req.SessionID, entry.Embedding, sess.dedupThreshold)
 			if err != nil {
 				return nil, fmt.Errorf("dedup check: %w", err)
 			}
@@ -186,34 +367,40 @@ func (s *SQLiteStore) Push(ctx context.Context, req PushRequest) (*PushResult, e
 			}
 		}
 
-		tokens := estimateTokens(entry.Content)
-
-		// Reject single entries that exceed the entire budget
-		if tokens > sess.maxTokens {
-			return nil, ErrOverBudget
-		}
+		tokens := sess.counter.Count(entry.Content)
 
 		maxSeq++
 		id := generateID()
 		now := time.Now().UTC().Format(time.RFC3339Nano)
 		contentHash := hashContent(entry.Content)
 
-		// inserted_at_push is set after RecordPush increments the counter,
-		// so we read the current push_count and add 1 (the value it will be
-		// after RecordPush runs at the end of this Push call).
-		var currentPushCount int
-		_ = s.db.QueryRowContext(ctx,
-			"SELECT push_count FROM sessions WHERE id = ?", req.SessionID,
-		).Scan(&currentPushCount)
-		insertedAtPush := currentPushCount + 1
+		// A tool call opens a group named after its entry; results join
+		// the group of the call they answer.
+		groupID := ""
+		if len(callIDs) > 0 {
+			groupID = id
+		} else if len(resultIDs) > 0 {
+			groupID, err = s.findToolGroup(ctx, tx, req.SessionID, resultIDs[0])
+			if err != nil {
+				return nil, fmt.Errorf("find tool group: %w", err)
+			}
+		}
 
-		_, err := s.db.ExecContext(ctx,
+		var partsJSON string
+		if len(entry.Parts) > 0 {
+			b, _ := json.Marshal(entry.Parts)
+			partsJSON = string(b)
+		}
+
+		embBlob, embQBlob := distillmath.EmbeddingColumns(entry.Embedding, s.cfg.Quantization, s.cfg.Rescore)
+		_, err := tx.ExecContext(ctx,
 			`INSERT INTO session_entries
-			 (id, session_id, role, content, original_content, source, embedding, importance, compression_level, tokens, seq, inserted_at_push, stable_since_turn, content_hash, created_at)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, 0, ?, ?)`,
+			 (id, session_id, role, content, original_content, source, embedding, embedding_q, importance, compression_level, tokens, seq, inserted_at_push, stable_since_turn, content_hash, created_at, parts, group_id, tool_call_ids)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, 0, ?, ?, ?, ?, ?)`,
 			id, req.SessionID, entry.Role, entry.Content, entry.Content,
-			entry.Source, encodeEmbedding(entry.Embedding), importance,
+			entry.Source, embBlob, embQBlob, importance,
 			tokens, maxSeq, insertedAtPush, contentHash, now,
+			partsJSON, groupID, strings.Join(callIDs, ","),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("insert entry: %w", err)
@@ -224,34 +411,55 @@ func (s *SQLiteStore) Push(ctx context.Context, req PushRequest) (*PushResult, e
 
 	// Enforce token budget - loop until within budget or no progress
 	for {
-		c, e, err := s.enforceBudget(ctx, req.SessionID, sess)
+		c, e, f, err := s.enforceBudget(ctx, tx, req.SessionID, sess)
 		if err != nil {
 			return nil, fmt.Errorf("enforce budget: %w", err)
 		}
 		result.Compressed += c
 		result.Evicted += e
+		result.Summarized += f
 		if c == 0 && e == 0 {
 			break // no progress possible
 		}
 	}
 
 	// Record push and promote stable entries.
-	if err := s.boundary.RecordPush(ctx, req.SessionID); err != nil {
+	if err := s.boundary.recordPush(ctx, tx, req.SessionID); err != nil {
 		return nil, fmt.Errorf("record push: %w", err)
 	}
 
+	// Update session timestamp
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE sessions SET updated_at = ? WHERE id = ?",
+		time.Now().UTC().Format(time.RFC3339Nano), req.SessionID,
+	); err != nil {
+		return nil, fmt.Errorf("update session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit push: %w", err)
+	}
+
 	// Evaluate cache boundary.
 	boundary, err := s.boundary.Evaluate(ctx, req.SessionID)
 	if err == nil {
 		result.CacheBoundary = boundary
 	}
 
-	// Update session timestamp
-	now := time.Now().UTC().Format(time.RFC3339Nano)
-	_, _ = s.db.ExecContext(ctx,
-		"UPDATE sessions SET updated_at = ? WHERE id = ?",
-		now, req.SessionID,
-	)
+	// Periodically promote important entries to long-term memory.
+	var pushCount int
+	_ = s.db.QueryRowContext(ctx,
+		"SELECT push_count FROM sessions WHERE id = ?", req.SessionID,
+	).Scan(&pushCount)
+	// The push is committed, so a failed promotion is reported rather than
+	// returned: a caller retrying the push would only hit a conflict.
+	if s.shouldPromoteOnPush(pushCount) {
+		promoted, err := s.promote(ctx, req.SessionID)
+		if err != nil {
+			result.PromoteError = err.Error()
+		}
+		result.Promoted = promoted
+	}
 
 	// Compute current tokens
 	var currentTokens int
@@ -266,23 +474,56 @@ func (s *SQLiteStore) Push(ctx context.Context, req PushRequest) (*PushResult, e
 	return result, nil
 }
 
+// claimVersion advances the session version, first checking it against
+// expected when one is given. The check and increment are a single UPDATE
+// that takes SQLite's write lock; Push runs it first in its transaction, so
+// the push's reads and writes are safe across processes sharing the
+// database and roll back with the claim. Returns the new version.
+func (s *SQLiteStore) claimVersion(ctx context.Context, q dbtx, sessionID string, expected *int) (int, error) {
+	query := "UPDATE sessions SET version = version + 1 WHERE id = ?"
+	args := []interface{}{sessionID}
+	if expected != nil {
+		query += " AND version = ?"
+		args = append(args, *expected)
+	}
+
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("claim version: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return 0, ErrVersionConflict
+	}
+
+	var version int
+	if err := q.QueryRowContext(ctx,
+		"SELECT version FROM sessions WHERE id = ?", sessionID,
+	).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read version: %w", err)
+	}
+	return version, nil
+}
+
 // Context returns the current context window for a session.
 func (s *SQLiteStore) Context(ctx context.Context, req ContextRequest) (*ContextResult, error) {
+	unlock := s.locks.lock(req.SessionID)
+	defer unlock()
+
 	// Verify session exists
-	var exists int
-	err := s.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM sessions WHERE id = ?", req.SessionID,
-	).Scan(&exists)
-	if err != nil || exists == 0 {
-		return nil, ErrSessionNotFound
+	sess, err := s.loadSessionConfig(ctx, req.SessionID)
+	if err != nil {
+		return nil, err
 	}
 
-	query := "SELECT id, role, content, source, compression_level, tokens, created_at FROM session_entries WHERE session_id = ?"
+	query := "SELECT id, role, content, source, compression_level, tokens, created_at, parts, group_id FROM session_entries WHERE session_id = ?"
 	args := []interface{}{req.SessionID}
 
+	// A role filter keeps tool call groups whole: a group is returned
+	// when any of its entries has the role.
 	if req.Role != "" {
-		query += " AND role = ?"
-		args = append(args, req.Role)
+		query += ` AND (role = ? OR (group_id != '' AND group_id IN (
+			SELECT group_id FROM session_entries WHERE session_id = ? AND role = ? AND group_id != '')))`
+		args = append(args, req.Role, req.SessionID, req.Role)
 	}
 
 	query += " ORDER BY seq ASC"
@@ -294,12 +535,13 @@ func (s *SQLiteStore) Context(ctx context.Context, req ContextRequest) (*Context
 
 	type rawEntry struct {
 		id, role, content, source, createdAt string
+		parts, groupID                       string
 		level, tokens                        int
 	}
 	var raw []rawEntry
 	for rows.Next() {
 		var r rawEntry
-		if err := rows.Scan(&r.id, &r.role, &r.content, &r.source, &r.level, &r.tokens, &r.createdAt); err != nil {
+		if err := rows.Scan(&r.id, &r.role, &r.content, &r.source, &r.level, &r.tokens, &r.createdAt, &r.parts, &r.groupID); err != nil {
 			_ = rows.Close()
 			return nil, err
 		}
@@ -318,12 +560,26 @@ func (s *SQLiteStore) Context(ctx context.Context, req ContextRequest) (*Context
 
 	for _, r := range raw {
 		if req.MaxTokens > 0 && tokenCount+r.tokens > req.MaxTokens {
+			// Never return a tool call without its results.
+			if r.groupID != "" {
+				for len(entries) > 0 && entries[len(entries)-1].GroupID == r.groupID {
+					last := entries[len(entries)-1]
+					entries = entries[:len(entries)-1]
+					tokenCount -= last.Tokens
+					levels[int(last.Level)]--
+				}
+			}
 			break
 		}
 
 		created, _ := time.Parse(time.RFC3339Nano, r.createdAt)
 		age := formatAge(now.Sub(created))
 
+		var parts []ContentPart
+		if r.parts != "" {
+			_ = json.Unmarshal([]byte(r.parts), &parts)
+		}
+
 		entries = append(entries, ContextEntry{
 			ID:      r.id,
 			Role:    r.role,
@@ -332,19 +588,22 @@ func (s *SQLiteStore) Context(ctx context.Context, req ContextRequest) (*Context
 			Level:   CompressionLevel(r.level),
 			Tokens:  r.tokens,
 			Age:     age,
+			Parts:   parts,
+			GroupID: r.groupID,
 		})
 		tokenCount += r.tokens
 		levels[r.level]++
 	}
 
-	// Compute compression savings (original tokens - current tokens)
-	var totalOriginalTokens int
-	_ = s.db.QueryRowContext(ctx,
-		"SELECT COALESCE(SUM(LENGTH(original_content)+3)/4, 0) FROM session_entries WHERE session_id = ?",
-		req.SessionID,
-	).Scan(&totalOriginalTokens)
+	// Compute compression savings (original tokens - current tokens),
+	// counting originals with the session's tokenizer so both sides are
+	// in the same unit.
+	totalOriginalTokens, err := s.originalTokens(ctx, req.SessionID, sess.counter)
+	if err != nil {
+		return nil, fmt.Errorf("count original tokens: %w", err)
+	}
 
-	return &ContextResult{
+	result := &ContextResult{
 		Entries: entries,
 		Stats: ContextStats{
 			TotalEntries:       len(entries),
@@ -352,7 +611,43 @@ func (s *SQLiteStore) Context(ctx context.Context, req ContextRequest) (*Context
 			CompressionLevels:  levels,
 			CompressionSavings: totalOriginalTokens - tokenCount,
 		},
-	}, nil
+	}
+
+	if req.Format != "" {
+		cacheAt := make(map[string]bool)
+		if boundary, err := s.boundary.markers(ctx, req.SessionID); err == nil {
+			for _, m := range boundary.Markers {
+				cacheAt[m.EntryID] = true
+			}
+		}
+		result.Messages, result.System, err = renderMessages(req.Format, entries, cacheAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// originalTokens counts the uncompressed content of a session's entries.
+func (s *SQLiteStore) originalTokens(ctx context.Context, sessionID string, counter tokenizer.Counter) (int, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT original_content FROM session_entries WHERE session_id = ?", sessionID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	total := 0
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return 0, err
+		}
+		total += counter.Count(content)
+	}
+	return total, rows.Err()
 }
 
 // Get returns session metadata.
@@ -361,9 +656,9 @@ func (s *SQLiteStore) Get(ctx context.Context, sessionID string) (*Session, erro
 	var createdStr, updatedStr string
 
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, max_tokens, push_count, cache_boundary_tokens, created_at, updated_at FROM sessions WHERE id = ?",
+		"SELECT id, max_tokens, push_count, cache_boundary_tokens, version, tokenizer, created_at, updated_at FROM sessions WHERE id = ?",
 		sessionID,
-	).Scan(&sess.ID, &sess.MaxTokens, &sess.PushCount, &sess.CacheBoundaryTokens, &createdStr, &updatedStr)
+	).Scan(&sess.ID, &sess.MaxTokens, &sess.PushCount, &sess.CacheBoundaryTokens, &sess.Version, &sess.Tokenizer, &createdStr, &updatedStr)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrSessionNotFound
@@ -382,8 +677,29 @@ func (s *SQLiteStore) Get(ctx context.Context, sessionID string) (*Session, erro
 	return &sess, nil
 }
 
-// Delete removes a session and all its entries.
+// Delete removes a session and all its entries. When a promotion policy
+// with OnDelete is attached, qualifying entries are promoted first.
 func (s *SQLiteStore) Delete(ctx context.Context, sessionID string) (*DeleteResult, error) {
+	unlock := s.locks.lock(sessionID)
+	defer unlock()
+	return s.deleteSession(ctx, sessionID)
+}
+
+// deleteSession promotes and deletes a session. The caller holds the
+// session's lock.
+func (s *SQLiteStore) deleteSession(ctx context.Context, sessionID string) (*DeleteResult, error) {
+	// A failed promotion is reported and does not keep the session alive,
+	// so an unreachable memory store cannot stall expiry.
+	promoted := 0
+	promoteErr := ""
+	if s.promoter != nil && s.promoter.policy.OnDelete {
+		n, err := s.promote(ctx, sessionID)
+		if err != nil {
+			promoteErr = err.Error()
+		}
+		promoted = n
+	}
+
 	var count int
 	_ = s.db.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM session_entries WHERE session_id = ?",
@@ -403,6 +719,8 @@ func (s *SQLiteStore) Delete(ctx context.Context, sessionID string) (*DeleteResu
 	return &DeleteResult{
 		SessionID:      sessionID,
 		EntriesRemoved: count,
+		Promoted:       promoted,
+		PromoteError:   promoteErr,
 	}, nil
 }
 
@@ -417,45 +735,75 @@ type sessionConfig struct {
 	maxTokens      int
 	dedupThreshold float64
 	preserveRecent int
+	counter        tokenizer.Counter
 }
 
 func (s *SQLiteStore) loadSessionConfig(ctx context.Context, sessionID string) (*sessionConfig, error) {
 	var cfg sessionConfig
+	var tokenizerName string
 	err := s.db.QueryRowContext(ctx,
-		"SELECT max_tokens, dedup_threshold, preserve_recent FROM sessions WHERE id = ?",
+		"SELECT max_tokens, dedup_threshold, preserve_recent, tokenizer FROM sessions WHERE id = ?",
 		sessionID,
-	).Scan(&cfg.maxTokens, &cfg.dedupThreshold, &cfg.preserveRecent)
+	).Scan(&cfg.maxTokens, &cfg.dedupThreshold, &cfg.preserveRecent, &tokenizerName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrSessionNotFound
 		}
 		return nil, err
 	}
+	cfg.counter, err = tokenizer.Get(tokenizerName)
+	if err != nil {
+		return nil, fmt.Errorf("session tokenizer: %w", err)
+	}
 	return &cfg, nil
 }
 
 // isDuplicate checks if an embedding is within threshold of any existing entry.
+// Quantised embeddings are compared in quantised form; with Rescore,
+// near-threshold matches are confirmed at full precision.
 //
 // TODO: Full table scan (O(n) per entry). Fine for typical session sizes
 // (< 1K entries). For larger sessions, consider caching embeddings in memory.
-func (s *SQLiteStore) isDuplicate(ctx context.Context, sessionID string, embedding []float32, threshold float64) (bool, error) {
-	rows, err := s.db.QueryContext(ctx,
-		"SELECT embedding FROM session_entries WHERE session_id = ? AND embedding IS NOT NULL",
+func (s *SQLiteStore) isDuplicate(ctx context.Context, q dbtx, sessionID string, embedding []float32, threshold float64) (bool, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, embedding_q, CASE WHEN embedding_q IS NULL THEN embedding END
+		 FROM session_entries WHERE session_id = ? AND (embedding IS NOT NULL OR embedding_q IS NOT NULL)`,
 		sessionID,
 	)
 	if err != nil {
 		return false, err
 	}
 
-	// Scan all then close - single connection pattern.
-	var blobs [][]byte
+	margin := 0.0
+	if s.cfg.Rescore {
+		margin = distillmath.RescoreMargin(s.cfg.Quantization)
+	}
+
+	// Scan all then close - single connection pattern. Quantised matches
+	// within the margin are rescored once the scan is done.
+	query := distillmath.NewQuantizedQuery(embedding)
+	var rescore []string
+	approxDist := make(map[string]float64)
 	for rows.Next() {
-		var blob []byte
-		if err := rows.Scan(&blob); err != nil {
+		var id string
+		var qBlob, fullBlob []byte
+		if err := rows.Scan(&id, &qBlob, &fullBlob); err != nil {
 			_ = rows.Close()
 			return false, err
 		}
-		blobs = append(blobs, blob)
+		dist, approx, ok := query.StoredDistance(fullBlob, qBlob)
+		if !ok || dist >= threshold+margin {
+			continue
+		}
+		if approx && s.cfg.Rescore {
+			rescore = append(rescore, id)
+			approxDist[id] = dist
+			continue
+		}
+		if dist < threshold {
+			_ = rows.Close()
+			return true, nil
+		}
 	}
 	if err := rows.Err(); err != nil {
 		_ = rows.Close()
@@ -463,12 +811,15 @@ func (s *SQLiteStore) isDuplicate(ctx context.Context, sessionID string, embeddi
 	}
 	_ = rows.Close()
 
-	for _, blob := range blobs {
-		existing := decodeEmbedding(blob)
-		if len(existing) == 0 {
-			continue
+	full, err := loadFullEmbeddings(ctx, q, rescore)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range rescore {
+		dist := approxDist[id]
+		if emb, ok := full[id]; ok {
+			dist = distillmath.CosineDistance(embedding, emb)
 		}
-		dist := distillmath.CosineDistance(embedding, existing)
 		if dist < threshold {
 			return true, nil
 		}
@@ -476,30 +827,65 @@ func (s *SQLiteStore) isDuplicate(ctx context.Context, sessionID string, embeddi
 	return false, nil
 }
 
+// loadFullEmbeddings returns the float32 embeddings kept for rescoring.
+func loadFullEmbeddings(ctx context.Context, q dbtx, ids []string) (map[string][]float32, error) {
+	out := make(map[string][]float32, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := q.QueryContext(ctx,
+		"SELECT id, embedding FROM session_entries WHERE embedding IS NOT NULL AND id IN ("+strings.Join(placeholders, ",")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		if emb := distillmath.DecodeEmbedding(blob); len(emb) > 0 {
+			out[id] = emb
+		}
+	}
+	return out, rows.Err()
+}
+
 // compressor reused across calls.
 var compressor = compress.NewExtractiveCompressor()
 
 // enforceBudget compresses and evicts entries until within token budget.
-// Returns (compressed count, evicted count).
-func (s *SQLiteStore) enforceBudget(ctx context.Context, sessionID string, cfg *sessionConfig) (int, int, error) {
+// When the rolling summary is enabled, entries reaching LevelKeywords or
+// being evicted are folded into it first.
+// Returns (compressed count, evicted count, folded count).
+func (s *SQLiteStore) enforceBudget(ctx context.Context, q dbtx, sessionID string, cfg *sessionConfig) (int, int, int, error) {
 	var currentTokens int
-	_ = s.db.QueryRowContext(ctx,
+	_ = q.QueryRowContext(ctx,
 		"SELECT COALESCE(SUM(tokens), 0) FROM session_entries WHERE session_id = ?",
 		sessionID,
 	).Scan(&currentTokens)
 
 	if currentTokens <= cfg.maxTokens {
-		return 0, 0, nil
+		return 0, 0, 0, nil
 	}
 
 	compressed := 0
 	evicted := 0
+	folded := 0
 
-	// Get total entry count to determine which are "recent"
+	// Get total entry count to determine which are "recent". The rolling
+	// summary is never a compression candidate.
 	var totalEntries int
-	_ = s.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM session_entries WHERE session_id = ?",
-		sessionID,
+	_ = q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM session_entries WHERE session_id = ? AND source != ?",
+		sessionID, rollingSummarySource,
 	).Scan(&totalEntries)
 
 	// Load compressible entries (oldest first, skip the most recent N)
@@ -508,99 +894,287 @@ func (s *SQLiteStore) enforceBudget(ctx context.Context, sessionID string, cfg *
 	if limit <= 0 {
 		// All entries are "recent" - nothing to compress, but still over budget.
 		// Evict the oldest non-recent entry as a last resort.
-		return s.evictOldest(ctx, sessionID, cfg, currentTokens)
+		return s.evictOldest(ctx, q, sessionID, cfg, currentTokens)
 	}
 
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, original_content, compression_level, importance, tokens
-		 FROM session_entries WHERE session_id = ?
-		 ORDER BY seq ASC LIMIT ?`,
-		sessionID, limit,
-	)
+	candidates, err := s.loadCandidates(ctx, q, sessionID, limit)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, 0, err
 	}
-
-	var candidates []compressCandidate
-	for rows.Next() {
-		var c compressCandidate
-		if err := rows.Scan(&c.id, &c.originalContent, &c.level, &c.importance, &c.tokens); err != nil {
-			_ = rows.Close()
-			return 0, 0, err
-		}
-		candidates = append(candidates, c)
-	}
-	if err := rows.Err(); err != nil {
-		_ = rows.Close()
-		return 0, 0, err
+	eligible := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		eligible[c.id] = true
 	}
-	_ = rows.Close()
 
 	// Process candidates from oldest, lowest importance first.
 	// Sort: by importance ASC, then by position (already ordered by seq ASC).
 	sortCandidates(candidates)
 
+	var groups []string
+	seenGroup := make(map[string]bool)
 	for _, c := range candidates {
 		if currentTokens <= cfg.maxTokens {
 			break
 		}
 
+		// Tool call groups are never compressed (that would break the
+		// call/result structure); they are evicted whole further down.
+		if c.groupID != "" {
+			if !seenGroup[c.groupID] {
+				seenGroup[c.groupID] = true
+				groups = append(groups, c.groupID)
+			}
+			continue
+		}
+
 		nextLevel := c.level + 1
 
+		if nextLevel >= int(LevelKeywords) && !c.folded && s.cfg.RollingSummary.Enabled {
+			delta, err := s.foldIntoSummary(ctx, q, sessionID, cfg, c.role, c.originalContent)
+			if err != nil {
+				return compressed, evicted, folded, err
+			}
+			if _, err := q.ExecContext(ctx,
+				"UPDATE session_entries SET folded = 1 WHERE id = ?", c.id,
+			); err != nil {
+				return compressed, evicted, folded, err
+			}
+			currentTokens += delta
+			folded++
+			c.folded = true
+		}
+
 		if nextLevel > int(LevelKeywords) {
 			// Already at keywords - evict
-			_, err := s.db.ExecContext(ctx,
-				"DELETE FROM session_entries WHERE id = ?", c.id,
-			)
+			delta, _, err := s.evictEntries(ctx, q, sessionID, cfg, []compressCandidate{c})
 			if err != nil {
-				return compressed, evicted, err
+				return compressed, evicted, folded, err
 			}
-			currentTokens -= c.tokens
+			currentTokens += delta
 			evicted++
 			continue
 		}
 
 		// Compress to next level
-		newContent := compressToLevel(c.originalContent, CompressionLevel(nextLevel))
-		newTokens := estimateTokens(newContent)
+		newContent := s.compressCached(c.originalContent, CompressionLevel(nextLevel))
+		newTokens := cfg.counter.Count(newContent)
 		now := time.Now().UTC().Format(time.RFC3339Nano)
 
-		_, err := s.db.ExecContext(ctx,
+		_, err := q.ExecContext(ctx,
 			`UPDATE session_entries SET content = ?, compression_level = ?, tokens = ?, compressed_at = ? WHERE id = ?`,
 			newContent, nextLevel, newTokens, now, c.id,
 		)
 		if err != nil {
-			return compressed, evicted, err
+			return compressed, evicted, folded, err
 		}
 
 		currentTokens -= (c.tokens - newTokens)
 		compressed++
 	}
 
-	return compressed, evicted, nil
+	// Once nothing else can give way, evict whole tool groups, oldest and
+	// least important first. A group straddling the preserve_recent window
+	// is left alone.
+	if compressed == 0 && evicted == 0 {
+		for _, groupID := range groups {
+			if currentTokens <= cfg.maxTokens {
+				break
+			}
+			members, err := s.loadGroup(ctx, q, sessionID, groupID)
+			if err != nil {
+				return compressed, evicted, folded, err
+			}
+			allEligible := true
+			for _, m := range members {
+				if !eligible[m.id] {
+					allEligible = false
+					break
+				}
+			}
+			if !allEligible {
+				continue
+			}
+			delta, f, err := s.evictEntries(ctx, q, sessionID, cfg, members)
+			if err != nil {
+				return compressed, evicted, folded, err
+			}
+			currentTokens += delta
+			evicted += len(members)
+			folded += f
+		}
+	}
+
+	return compressed, evicted, folded, nil
+}
+
+// loadCandidates returns the session's oldest limit entries, excluding the
+// rolling summary, in seq order.
+func (s *SQLiteStore) loadCandidates(ctx context.Context, q dbtx, sessionID string, limit int) ([]compressCandidate, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, role, original_content, compression_level, importance, tokens, folded, group_id
+		 FROM session_entries WHERE session_id = ? AND source != ?
+		 ORDER BY seq ASC LIMIT ?`,
+		sessionID, rollingSummarySource, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []compressCandidate
+	for rows.Next() {
+		var c compressCandidate
+		if err := rows.Scan(&c.id, &c.role, &c.originalContent, &c.level, &c.importance, &c.tokens, &c.folded, &c.groupID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	_ = rows.Close()
+	return candidates, nil
+}
+
+// loadGroup returns all entries of a tool call group in seq order.
+func (s *SQLiteStore) loadGroup(ctx context.Context, q dbtx, sessionID, groupID string) ([]compressCandidate, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, role, original_content, compression_level, importance, tokens, folded, group_id
+		 FROM session_entries WHERE session_id = ? AND group_id = ?
+		 ORDER BY seq ASC`,
+		sessionID, groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var members []compressCandidate
+	for rows.Next() {
+		var c compressCandidate
+		if err := rows.Scan(&c.id, &c.role, &c.originalContent, &c.level, &c.importance, &c.tokens, &c.folded, &c.groupID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		members = append(members, c)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	_ = rows.Close()
+	return members, nil
+}
+
+// evictEntries deletes entries, folding each into the rolling summary first
+// when enabled. Returns the change in session tokens and the fold count.
+func (s *SQLiteStore) evictEntries(ctx context.Context, q dbtx, sessionID string, cfg *sessionConfig, entries []compressCandidate) (int, int, error) {
+	delta := 0
+	folded := 0
+	for _, e := range entries {
+		if s.cfg.RollingSummary.Enabled && !e.folded {
+			d, err := s.foldIntoSummary(ctx, q, sessionID, cfg, e.role, e.originalContent)
+			if err != nil {
+				return delta, folded, err
+			}
+			delta += d
+			folded++
+		}
+		if _, err := q.ExecContext(ctx,
+			"DELETE FROM session_entries WHERE id = ?", e.id,
+		); err != nil {
+			return delta, folded, err
+		}
+		delta -= e.tokens
+	}
+	return delta, folded, nil
+}
+
+// findToolGroup returns the group of the entry that made the given tool
+// call, or "" when the call is not in the session.
+func (s *SQLiteStore) findToolGroup(ctx context.Context, q dbtx, sessionID, toolCallID string) (string, error) {
+	var groupID string
+	err := q.QueryRowContext(ctx,
+		`SELECT group_id FROM session_entries
+		 WHERE session_id = ? AND instr(',' || tool_call_ids || ',', ',' || ? || ',') > 0
+		 ORDER BY seq DESC LIMIT 1`,
+		sessionID, toolCallID,
+	).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return groupID, err
 }
 
 // evictOldest is a fallback when all entries are "recent" but still over budget.
-func (s *SQLiteStore) evictOldest(ctx context.Context, sessionID string, cfg *sessionConfig, currentTokens int) (int, int, error) {
+func (s *SQLiteStore) evictOldest(ctx context.Context, q dbtx, sessionID string, cfg *sessionConfig, currentTokens int) (int, int, int, error) {
 	evicted := 0
+	folded := 0
 	for currentTokens > cfg.maxTokens {
-		var id string
-		var tokens int
-		err := s.db.QueryRowContext(ctx,
-			"SELECT id, tokens FROM session_entries WHERE session_id = ? ORDER BY seq ASC LIMIT 1",
-			sessionID,
-		).Scan(&id, &tokens)
+		var c compressCandidate
+		err := q.QueryRowContext(ctx,
+			`SELECT id, role, original_content, compression_level, importance, tokens, folded, group_id
+			 FROM session_entries
+			 WHERE session_id = ? AND source != ? ORDER BY seq ASC LIMIT 1`,
+			sessionID, rollingSummarySource,
+		).Scan(&c.id, &c.role, &c.originalContent, &c.level, &c.importance, &c.tokens, &c.folded, &c.groupID)
 		if err != nil {
 			break
 		}
-		_, _ = s.db.ExecContext(ctx, "DELETE FROM session_entries WHERE id = ?", id)
-		currentTokens -= tokens
-		evicted++
+
+		// Tool call groups go as a unit.
+		victims := []compressCandidate{c}
+		if c.groupID != "" {
+			victims, err = s.loadGroup(ctx, q, sessionID, c.groupID)
+			if err != nil {
+				return 0, evicted, folded, err
+			}
+		}
+		delta, f, err := s.evictEntries(ctx, q, sessionID, cfg, victims)
+		if err != nil {
+			return 0, evicted, folded, err
+		}
+		currentTokens += delta
+		evicted += len(victims)
+		folded += f
+	}
+	return 0, evicted, folded, nil
+}
+
+// summarizerLevels maps session compression levels to the summarize
+// levels a configured Summarizer is asked for.
+var summarizerLevels = map[CompressionLevel]summarize.Level{
+	LevelSummary:  summarize.LevelParagraph,
+	LevelSentence: summarize.LevelSentence,
+}
+
+// compressEntry compresses text to level with the configured Summarizer,
+// caching its output, and falls back to compressToLevel when it is unset
+// or fails. It may wait on the model, so it never runs inside a push's
+// transaction.
+func (s *SQLiteStore) compressEntry(ctx context.Context, text string, level CompressionLevel) string {
+	if sl, ok := summarizerLevels[level]; ok && s.cfg.Summarizer != nil {
+		if out, ok := s.summaries.get(text, level); ok {
+			return out
+		}
+		if out, err := s.cfg.Summarizer.SummarizeText(ctx, text, sl); err == nil && out != "" {
+			s.summaries.put(text, level, out)
+			return out
+		}
 	}
-	return 0, evicted, nil
+	return compressToLevel(text, level)
 }
 
-// compressToLevel applies compression for the given level.
+// compressCached compresses text to level with a summary prepared before
+// the push's transaction, falling back to compressToLevel.
+func (s *SQLiteStore) compressCached(text string, level CompressionLevel) string {
+	if s.cfg.Summarizer != nil {
+		if out, ok := s.summaries.get(text, level); ok {
+			return out
+		}
+	}
+	return compressToLevel(text, level)
+}
+
+// compressToLevel applies extractive compression for the given level.
 func compressToLevel(text string, level CompressionLevel) string {
 	switch level {
 	case LevelSummary:
@@ -614,10 +1188,8 @@ func compressToLevel(text string, level CompressionLevel) string {
 		return text
 	case LevelSentence:
 		// Keep first sentence only
-		for i, r := range text {
-			if r == '.' || r == '!' || r == '?' {
-				return text[:i+1]
-			}
+		if sentences := nlp.SplitSentences(text); len(sentences) > 1 || (len(sentences) == 1 && nlp.EndsSentence(sentences[0])) {
+			return sentences[0]
 		}
 		// No sentence boundary - truncate at word boundary near 50 chars
 		if len(text) > 50 {
@@ -626,7 +1198,11 @@ func compressToLevel(text string, level CompressionLevel) string {
 				cut--
 			}
 			if cut == 0 {
-				cut = 50 // no space found, hard cut
+				// No space found (e.g. CJK): hard cut on a rune boundary.
+				cut = 50
+				for cut > 0 && !utf8.RuneStart(text[cut]) {
+					cut--
+				}
 			}
 			return strings.TrimSpace(text[:cut]) + "..."
 		}
@@ -638,45 +1214,22 @@ func compressToLevel(text string, level CompressionLevel) string {
 	}
 }
 
-// extractKeywords produces a keyword-only representation.
+// extractKeywords produces a keyword-only representation: up to 15
+// lower-cased content words, using the stopwords of the text's language.
 func extractKeywords(text string) string {
-	words := strings.Fields(text)
-	seen := make(map[string]bool)
-	var keywords []string
-
-	for _, w := range words {
-		lower := strings.ToLower(strings.Trim(w, ".,;:!?\"'()[]{}"))
-		if lower == "" || len(lower) < 4 || stopWords[lower] || seen[lower] {
-			continue
-		}
-		seen[lower] = true
-		keywords = append(keywords, lower)
-	}
-
-	if len(keywords) > 15 {
-		keywords = keywords[:15]
-	}
-	return strings.Join(keywords, ", ")
-}
-
-var stopWords = map[string]bool{
-	"that": true, "this": true, "with": true, "from": true,
-	"have": true, "been": true, "were": true, "they": true,
-	"their": true, "which": true, "would": true, "there": true,
-	"about": true, "could": true, "other": true, "into": true,
-	"more": true, "some": true, "than": true, "them": true,
-	"very": true, "when": true, "what": true, "your": true,
-	"also": true, "each": true, "does": true, "will": true,
-	"just": true, "should": true, "because": true, "these": true,
+	return strings.ToLower(strings.Join(nlp.Keywords(text, 15), ", "))
 }
 
 // compressCandidate is an entry eligible for compression or eviction.
 type compressCandidate struct {
 	id              string
+	role            string
 	originalContent string
 	level           int
 	importance      float64
 	tokens          int
+	folded          bool
+	groupID         string
 }
 
 // sortCandidates sorts by importance ASC (least important first).
@@ -715,32 +1268,6 @@ func generateID() string {
 	return hex.EncodeToString(b)
 }
 
-func encodeEmbedding(emb []float32) []byte {
-	if len(emb) == 0 {
-		return nil
-	}
-	buf := make([]byte, len(emb)*4)
-	for i, v := range emb {
-		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
-	}
-	return buf
-}
-
-func decodeEmbedding(buf []byte) []float32 {
-	if len(buf) == 0 || len(buf)%4 != 0 {
-		return nil
-	}
-	emb := make([]float32, len(buf)/4)
-	for i := range emb {
-		emb[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
-	}
-	return emb
-}
-
-func estimateTokens(text string) int {
-	return (len(text) + 3) / 4
-}
-
 func formatAge(d time.Duration) string {
 	switch {
 	case d < time.Minute:
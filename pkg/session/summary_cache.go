@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// summaryCacheSize caps the abstractive summaries kept between pushes.
+const summaryCacheSize = 4096
+
+// summaryCache holds the configured Summarizer's output by content hash and
+// level. Push fills it before its transaction opens, so the budget pass
+// inside the transaction never waits on the model, and a push retried
+// after a version conflict reuses the summaries already written.
+type summaryCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+func newSummaryCache() *summaryCache {
+	return &summaryCache{entries: make(map[string]string)}
+}
+
+func summaryKey(text string, level CompressionLevel) string {
+	return hashContent(text) + ":" + strconv.Itoa(int(level))
+}
+
+func (c *summaryCache) get(text string, level CompressionLevel) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.entries[summaryKey(text, level)]
+	return out, ok
+}
+
+func (c *summaryCache) put(text string, level CompressionLevel, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= summaryCacheSize {
+		c.entries = make(map[string]string)
+	}
+	c.entries[summaryKey(text, level)] = summary
+}
+
+// prepareSummaries asks the Summarizer, ahead of a push's transaction, for
+// the entries the budget pass is expected to compress to a summarized
+// level once entries are added. It follows the pass's order, least
+// important first and one level per round, and stops once the projected
+// total fits the budget. Failures only leave the cache cold: the budget
+// pass then falls back to extractive compression.
+func (s *SQLiteStore) prepareSummaries(ctx context.Context, sessionID string, cfg *sessionConfig, entries []PushEntry) {
+	if s.cfg.Summarizer == nil {
+		return
+	}
+
+	incoming, added := 0, 0
+	for _, e := range entries {
+		if e.Content != "" {
+			incoming += cfg.counter.Count(e.Content)
+			added++
+		}
+	}
+
+	var currentTokens, totalEntries int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(tokens), 0) FROM session_entries WHERE session_id = ?",
+		sessionID,
+	).Scan(&currentTokens); err != nil {
+		return
+	}
+	excess := currentTokens + incoming - cfg.maxTokens
+	if excess <= 0 {
+		return
+	}
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM session_entries WHERE session_id = ? AND source != ?",
+		sessionID, rollingSummarySource,
+	).Scan(&totalEntries); err != nil {
+		return
+	}
+
+	// New entries join the preserve_recent window, pushing older ones out.
+	limit := totalEntries + added - cfg.preserveRecent
+	if limit > totalEntries {
+		limit = totalEntries
+	}
+	if limit <= 0 {
+		return
+	}
+	candidates, err := s.loadCandidates(ctx, s.db, sessionID, limit)
+	if err != nil {
+		return
+	}
+	sortCandidates(candidates)
+
+	for round := 0; round < len(summarizerLevels) && excess > 0; round++ {
+		for i := range candidates {
+			c := &candidates[i]
+			next := CompressionLevel(c.level + 1)
+			if _, ok := summarizerLevels[next]; !ok || c.groupID != "" {
+				continue
+			}
+			out := s.compressEntry(ctx, c.originalContent, next)
+			tokens := cfg.counter.Count(out)
+			excess -= c.tokens - tokens
+			c.level, c.tokens = int(next), tokens
+			if excess <= 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAbstractiveTimeout     = 30 * time.Second
+	defaultAbstractiveTemperature = 0.3
+	defaultAbstractiveCacheSize   = 1024
+
+	// deterministicSeed is sent with every request in deterministic mode.
+	// Servers that honour it (llama.cpp, Ollama, OpenAI) then return the
+	// same summary for the same input.
+	deterministicSeed = 42
+)
+
+// abstractivePrompts are the system prompts for the levels the model
+// writes. Keyword summaries stay extractive: a model adds nothing there.
+var abstractivePrompts = map[Level]string{
+	LevelParagraph: "Summarize the text in one short paragraph. Keep names, numbers, " +
+		"identifiers, file paths, and code verbatim. Write in the language of the text. " +
+		"Reply with the summary only.",
+	LevelSentence: "Summarize the text in a single sentence. Keep the most important " +
+		"names, numbers, and identifiers verbatim. Write in the language of the text. " +
+		"Reply with the sentence only.",
+}
+
+// abstractiveMaxTokens caps the completion length per level.
+var abstractiveMaxTokens = map[Level]int{
+	LevelParagraph: 256,
+	LevelSentence:  80,
+}
+
+// AbstractiveConfig configures an AbstractiveSummarizer.
+type AbstractiveConfig struct {
+	// BaseURL is the OpenAI-compatible API root, e.g.
+	// http://localhost:11434/v1 (Ollama) or http://localhost:8080/v1
+	// (llama.cpp server). Required.
+	BaseURL string
+
+	// Model is the chat model name. Servers that host a single model
+	// (llama.cpp) accept any value.
+	Model string
+
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+
+	// Timeout bounds each request. Default: 30s.
+	Timeout time.Duration
+
+	// Temperature is the sampling temperature. Default: 0.3.
+	Temperature float64
+
+	// Deterministic forces temperature 0 and a fixed seed so repeated runs
+	// produce the same summaries.
+	Deterministic bool
+
+	// CacheSize is the number of summaries cached by content hash.
+	// Default: 1024. Negative disables the cache.
+	CacheSize int
+
+	// Fallback produces summaries when the model fails or times out.
+	// Default: extractive.
+	Fallback TextSummarizer
+
+	// HTTPClient overrides the client used for requests.
+	HTTPClient *http.Client
+}
+
+// AbstractiveStats reports how an AbstractiveSummarizer's summaries were
+// produced.
+type AbstractiveStats struct {
+	Requests  int64 // calls to the model
+	CacheHits int64 // summaries served from the cache
+	Fallbacks int64 // summaries produced by Fallback after a model error
+}
+
+// AbstractiveSummarizer writes paragraph and sentence summaries with a
+// chat model served over the OpenAI chat completions API, such as a local
+// llama.cpp or Ollama server. Summaries are cached by content hash, and any
+// request that fails, times out, or returns an unusable summary falls back
+// to the extractive summary, so a model outage only degrades quality.
+//
+// It implements TextSummarizer, for memory decay and session compression,
+// and Summarizer, as a HierarchicalSummarizer using the model.
+type AbstractiveSummarizer struct {
+	cfg    AbstractiveConfig
+	client *http.Client
+	hier   *HierarchicalSummarizer
+
+	mu    sync.Mutex
+	cache map[string]string
+	order []string // cache keys, oldest first
+
+	requests  atomic.Int64
+	cacheHits atomic.Int64
+	fallbacks atomic.Int64
+}
+
+// NewAbstractiveSummarizer creates an abstractive summarizer.
+func NewAbstractiveSummarizer(cfg AbstractiveConfig) (*AbstractiveSummarizer, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultAbstractiveTimeout
+	}
+	if cfg.Temperature <= 0 {
+		cfg.Temperature = defaultAbstractiveTemperature
+	}
+	if cfg.Deterministic {
+		cfg.Temperature = 0
+	}
+	if cfg.CacheSize == 0 {
+		cfg.CacheSize = defaultAbstractiveCacheSize
+	}
+	if cfg.Fallback == nil {
+		cfg.Fallback = Extractive{}
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	a := &AbstractiveSummarizer{
+		cfg:    cfg,
+		client: client,
+		cache:  make(map[string]string),
+	}
+	a.hier = &HierarchicalSummarizer{Text: a}
+	return a, nil
+}
+
+// Summarize implements Summarizer.
+func (a *AbstractiveSummarizer) Summarize(ctx context.Context, turns []Turn, opts SummarizeOptions) ([]Turn, SummarizeStats, error) {
+	return a.hier.Summarize(ctx, turns, opts)
+}
+
+// SummarizeText implements TextSummarizer. Model errors are not returned:
+// the fallback summary is used instead and counted in Stats.
+func (a *AbstractiveSummarizer) SummarizeText(ctx context.Context, text string, level Level) (string, error) {
+	prompt, ok := abstractivePrompts[level]
+	if !ok || strings.TrimSpace(text) == "" {
+		return a.cfg.Fallback.SummarizeText(ctx, text, level)
+	}
+
+	key := a.cacheKey(text, level)
+	if summary, ok := a.cached(key); ok {
+		a.cacheHits.Add(1)
+		return summary, nil
+	}
+
+	summary, err := a.complete(ctx, prompt, text, level)
+	if err != nil {
+		a.fallbacks.Add(1)
+		return a.cfg.Fallback.SummarizeText(ctx, text, level)
+	}
+	a.store(key, summary)
+	return summary, nil
+}
+
+// Stats returns counters since the summarizer was created.
+func (a *AbstractiveSummarizer) Stats() AbstractiveStats {
+	return AbstractiveStats{
+		Requests:  a.requests.Load(),
+		CacheHits: a.cacheHits.Load(),
+		Fallbacks: a.fallbacks.Load(),
+	}
+}
+
+// chatRequest is the OpenAI chat completions request body.
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Seed        *int          `json:"seed,omitempty"`
+	Stream      bool          `json:"stream"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatResponse is the subset of the chat completions response we read.
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// complete asks the model for a summary of text.
+func (a *AbstractiveSummarizer) complete(ctx context.Context, prompt, text string, level Level) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+
+	body := chatRequest{
+		Model: a.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: text},
+		},
+		Temperature: a.cfg.Temperature,
+		MaxTokens:   abstractiveMaxTokens[level],
+	}
+	if a.cfg.Deterministic {
+		seed := deterministicSeed
+		body.Seed = &seed
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.cfg.APIKey)
+	}
+
+	a.requests.Add(1)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, truncate(string(respBody), 200))
+	}
+
+	var result chatResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("unmarshal response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned")
+	}
+	summary := strings.TrimSpace(result.Choices[0].Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	if len(summary) >= len(text) {
+		return "", fmt.Errorf("summary is not shorter than the input")
+	}
+	return summary, nil
+}
+
+// cacheKey hashes everything that determines the summary.
+func (a *AbstractiveSummarizer) cacheKey(text string, level Level) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%t\x00", a.cfg.Model, level, a.cfg.Deterministic)
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (a *AbstractiveSummarizer) cached(key string) (string, bool) {
+	if a.cfg.CacheSize < 0 {
+		return "", false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	summary, ok := a.cache[key]
+	return summary, ok
+}
+
+// store caches a summary, evicting the oldest entry when full.
+func (a *AbstractiveSummarizer) store(key, summary string) {
+	if a.cfg.CacheSize < 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.cache[key]; ok {
+		return
+	}
+	if len(a.order) >= a.cfg.CacheSize {
+		delete(a.cache, a.order[0])
+		a.order = a.order[1:]
+	}
+	a.cache[key] = summary
+	a.order = append(a.order, key)
+}
+
+// Extractive is the TextSummarizer that returns ExtractiveSummary. It never
+// fails.
+type Extractive struct{}
+
+// SummarizeText implements TextSummarizer.
+func (Extractive) SummarizeText(_ context.Context, text string, level Level) (string, error) {
+	return ExtractiveSummary(text, level), nil
+}
@@ -6,15 +6,21 @@ import (
 	"strings"
 	"time"
 	"unicode"
+
+	"github.com/Siddhant-K-code/distill/pkg/nlp"
 )
 
 // HierarchicalSummarizer implements Summarizer using rule-based compression.
-// It does not require an LLM — compression is performed locally using
-// extractive techniques (sentence selection, keyword extraction).
+// By default it does not require an LLM — compression is performed locally
+// using extractive techniques (sentence selection, keyword extraction).
 //
-// For LLM-backed summarization, wrap this with an LLMSummarizer that
-// overrides the compress method.
-type HierarchicalSummarizer struct{}
+// Set Text to produce the per-turn summaries some other way, for example
+// with an AbstractiveSummarizer.
+type HierarchicalSummarizer struct {
+	// Text summarizes a turn's original content at a level. Nil uses
+	// ExtractiveSummary; errors also fall back to it.
+	Text TextSummarizer
+}
 
 // NewHierarchicalSummarizer creates a new summarizer.
 func NewHierarchicalSummarizer() *HierarchicalSummarizer {
@@ -43,11 +49,12 @@ func (s *HierarchicalSummarizer) Summarize(
 
 	// Score importance for turns that don't have it set.
 	ScoreTurns(turns)
+	counter := turnCounter(opts.Tokenizer)
 
 	// Count input tokens.
 	inputTokens := 0
 	for i := range turns {
-		turns[i].TokenCount = estimateTokens(turns[i].Content)
+		turns[i].TokenCount = counter.Count(turns[i].Content)
 		inputTokens += turns[i].TokenCount
 	}
 
@@ -88,17 +95,17 @@ func (s *HierarchicalSummarizer) Summarize(
 		}
 
 		// Compress to target level.
-		if err := s.compressTo(t, maxLevel); err != nil {
+		if err := s.compressTo(ctx, t, maxLevel); err != nil {
 			return nil, stats, fmt.Errorf("compress turn %s: %w", t.ID, err)
 		}
-		t.TokenCount = estimateTokens(t.Content)
+		t.TokenCount = counter.Count(t.Content)
 		stats.CompressedTurns++
 	}
 
 	// If MaxTokens is set and we're still over budget, do a second pass
 	// compressing more aggressively from oldest to newest.
 	if opts.MaxTokens > 0 {
-		result = s.enforceTokenBudget(result, opts, recentCutoff)
+		result = s.enforceTokenBudget(ctx, result, opts, recentCutoff)
 	}
 
 	// Compute output stats.
@@ -120,6 +127,7 @@ func (s *HierarchicalSummarizer) Summarize(
 // It progressively compresses oldest turns through all levels, including
 // eviction (dropping turns entirely) as a last resort.
 func (s *HierarchicalSummarizer) enforceTokenBudget(
+	ctx context.Context,
 	turns []Turn,
 	opts SummarizeOptions,
 	recentCutoff int,
@@ -131,6 +139,7 @@ func (s *HierarchicalSummarizer) enforceTokenBudget(
 	if total <= opts.MaxTokens {
 		return turns
 	}
+	counter := turnCounter(opts.Tokenizer)
 
 	// Compress oldest non-recent turns progressively through all levels.
 	for level := LevelParagraph; level <= LevelEvicted && total > opts.MaxTokens; level++ {
@@ -151,8 +160,8 @@ func (s *HierarchicalSummarizer) enforceTokenBudget(
 				t.Content = ""
 				t.TokenCount = 0
 			} else {
-				_ = s.compressTo(t, level)
-				t.TokenCount = estimateTokens(t.Content)
+				_ = s.compressTo(ctx, t, level)
+				t.TokenCount = counter.Count(t.Content)
 			}
 			total -= before - t.TokenCount
 			if total <= opts.MaxTokens {
@@ -184,21 +193,60 @@ func (s *HierarchicalSummarizer) maxLevelForAge(age time.Duration, levels []AgeL
 
 // compressTo compresses a turn to the target level in-place.
 // The original content is preserved in Turn.Original on first compression.
-func (s *HierarchicalSummarizer) compressTo(t *Turn, target Level) error {
+func (s *HierarchicalSummarizer) compressTo(ctx context.Context, t *Turn, target Level) error {
 	if t.Original == "" {
 		t.Original = t.Content
 	}
 
-	switch target {
+	if target >= LevelParagraph && target <= LevelKeywords {
+		t.Content = s.summarizeText(ctx, t.Original, target)
+	}
+	t.Level = target
+	return nil
+}
+
+// summarizeText summarizes text with s.Text, falling back to the
+// extractive summary when it is unset or fails.
+func (s *HierarchicalSummarizer) summarizeText(ctx context.Context, text string, level Level) string {
+	if s.Text != nil {
+		if summary, err := s.Text.SummarizeText(ctx, text, level); err == nil && summary != "" {
+			return summary
+		}
+	}
+	return ExtractiveSummary(text, level)
+}
+
+// ExtractiveSummary returns the extractive summary of text at level:
+// ParagraphSummary, SentenceSummary, or KeywordSummary. Other levels
+// return text unchanged.
+func ExtractiveSummary(text string, level Level) string {
+	switch level {
 	case LevelParagraph:
-		t.Content = extractParagraphSummary(t.Original)
+		return extractParagraphSummary(text)
 	case LevelSentence:
-		t.Content = extractSentenceSummary(t.Original)
+		return extractSentenceSummary(text)
 	case LevelKeywords:
-		t.Content = extractKeywordSummary(t.Original)
+		return extractKeywordSummary(text)
 	}
-	t.Level = target
-	return nil
+	return text
+}
+
+// ParagraphSummary returns the LevelParagraph extractive summary of text:
+// the first paragraph plus any code blocks.
+func ParagraphSummary(text string) string {
+	return extractParagraphSummary(text)
+}
+
+// SentenceSummary returns the LevelSentence extractive summary of text:
+// its first one or two sentences with code blocks removed.
+func SentenceSummary(text string) string {
+	return extractSentenceSummary(text)
+}
+
+// KeywordSummary returns the LevelKeywords extractive summary of text:
+// up to 12 significant words, comma-separated.
+func KeywordSummary(text string) string {
+	return extractKeywordSummary(text)
 }
 
 // extractParagraphSummary keeps the first paragraph and any code blocks.
@@ -248,23 +296,7 @@ func extractSentenceSummary(text string) string {
 
 // extractKeywordSummary extracts the most significant words.
 func extractKeywordSummary(text string) string {
-	text = stripCodeBlocks(text)
-	words := strings.Fields(text)
-	var keywords []string
-	seen := map[string]bool{}
-	for _, w := range words {
-		w = strings.Trim(w, `.,;:!?"'()[]{}`)
-		lower := strings.ToLower(w)
-		if len(w) < 4 || isStopWord(lower) || seen[lower] {
-			continue
-		}
-		seen[lower] = true
-		keywords = append(keywords, w)
-		if len(keywords) >= 12 {
-			break
-		}
-	}
-	return strings.Join(keywords, ", ")
+	return strings.Join(nlp.Keywords(stripCodeBlocks(text), 12), ", ")
 }
 
 func stripCodeBlocks(text string) string {
@@ -284,22 +316,7 @@ func stripCodeBlocks(text string) string {
 }
 
 func splitSentences(text string) []string {
-	var sentences []string
-	var cur strings.Builder
-	for _, r := range text {
-		cur.WriteRune(r)
-		if r == '.' || r == '!' || r == '?' {
-			s := strings.TrimSpace(cur.String())
-			if s != "" {
-				sentences = append(sentences, s)
-			}
-			cur.Reset()
-		}
-	}
-	if s := strings.TrimSpace(cur.String()); s != "" {
-		sentences = append(sentences, s)
-	}
-	return sentences
+	return nlp.SplitSentences(text)
 }
 
 func truncate(s string, maxRunes int) string {
@@ -310,24 +327,6 @@ func truncate(s string, maxRunes int) string {
 	return string(runes[:maxRunes]) + "…"
 }
 
-func isStopWord(w string) bool {
-	return stopWords[w]
-}
-
-var stopWords = func() map[string]bool {
-	words := []string{
-		"the", "and", "for", "that", "this", "with", "from", "have",
-		"will", "been", "were", "they", "their", "there", "when",
-		"what", "which", "would", "could", "should", "about", "into",
-		"more", "also", "some", "than", "then", "just", "like",
-	}
-	m := map[string]bool{}
-	for _, w := range words {
-		m[w] = true
-	}
-	return m
-}()
-
 // DetectTurns segments a flat message list into Turn structs, assigning
 // timestamps based on index when real timestamps are unavailable.
 func DetectTurns(messages []struct {
@@ -3,6 +3,8 @@ package summarize
 import (
 	"strings"
 	"unicode"
+
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 )
 
 // ScoreImportance returns an importance score (0–1) for a turn based on
@@ -73,9 +75,28 @@ func ScoreTurns(turns []Turn) {
 	}
 }
 
-// estimateTokens approximates token count using the 4-chars-per-token heuristic.
+// estimateTokens counts tokens with the default counter, as turnCounter
+// resolves it.
 func estimateTokens(s string) int {
-	// Count printable runes only.
+	return turnCounter(nil).Count(s)
+}
+
+// turnCounter resolves the counter for turn token counts: c, else the
+// default. Summaries have always been budgeted on printable characters
+// only, so the heuristic default keeps doing that here.
+func turnCounter(c tokenizer.Counter) tokenizer.Counter {
+	c = tokenizer.OrDefault(c)
+	if _, ok := c.(tokenizer.Heuristic); ok {
+		return printableHeuristic{}
+	}
+	return c
+}
+
+// printableHeuristic is the heuristic counter over non-whitespace
+// characters.
+type printableHeuristic struct{}
+
+func (printableHeuristic) Count(s string) int {
 	n := 0
 	for _, r := range s {
 		if !unicode.IsSpace(r) {
@@ -85,6 +106,8 @@ func estimateTokens(s string) int {
 	return (n + 3) / 4
 }
 
+func (printableHeuristic) Name() string { return tokenizer.NameHeuristic }
+
 var errorKeywords = []string{
 	"error", "exception", "panic", "fatal", "failed", "failure",
 	"crash", "bug", "traceback", "stack trace", "nil pointer",
@@ -10,6 +10,8 @@ package summarize
 import (
 	"context"
 	"time"
+
+	"github.com/Siddhant-K-code/distill/pkg/tokenizer"
 )
 
 // Level represents a compression level for a conversation turn.
@@ -51,6 +53,10 @@ type SummarizeOptions struct {
 	// AgeLevels maps turn age to the maximum compression level allowed.
 	// Turns older than AgeLevels[i].After are compressed to AgeLevels[i].MaxLevel.
 	AgeLevels []AgeLevel
+
+	// Tokenizer counts turn tokens. Nil uses the package-wide default from
+	// pkg/tokenizer.
+	Tokenizer tokenizer.Counter
 }
 
 // AgeLevel maps a minimum age to a maximum compression level.
@@ -89,3 +95,11 @@ type SummarizeStats struct {
 type Summarizer interface {
 	Summarize(ctx context.Context, turns []Turn, opts SummarizeOptions) ([]Turn, SummarizeStats, error)
 }
+
+// TextSummarizer summarizes a single text at a compression level. It is
+// the extension point for LLM-backed summaries: HierarchicalSummarizer,
+// memory decay, and session compression use one when configured and fall
+// back to ExtractiveSummary on error.
+type TextSummarizer interface {
+	SummarizeText(ctx context.Context, text string, level Level) (string, error)
+}
@@ -2,6 +2,9 @@ package summarize
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -215,3 +218,109 @@ func TestSummarizeStats_ReductionPct(t *testing.T) {
 		t.Errorf("expected positive reduction, got %.1f%%", stats.ReductionPct)
 	}
 }
+
+// chatServer serves OpenAI-style chat completions with a fixed reply and
+// records the requests it receives.
+func chatServer(t *testing.T, reply string, status int) (*httptest.Server, *[]chatRequest) {
+	t.Helper()
+	var reqs []chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+		if status != http.StatusOK {
+			http.Error(w, "model unavailable", status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": reply}},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+const longText = "The deploy failed because the migration locked the users table for twelve minutes. " +
+	"Requests timed out and the load balancer marked every pod unhealthy. " +
+	"We rolled back, split the migration into batches, and redeployed at 14:05."
+
+func TestAbstractiveSummarizer_CachesByContent(t *testing.T) {
+	srv, reqs := chatServer(t, "A locking migration broke the deploy; batching it fixed it.", http.StatusOK)
+	s, err := NewAbstractiveSummarizer(AbstractiveConfig{BaseURL: srv.URL, Model: "test", Deterministic: true})
+	if err != nil {
+		t.Fatalf("NewAbstractiveSummarizer: %v", err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		got, err := s.SummarizeText(ctx, longText, LevelSentence)
+		if err != nil || !strings.Contains(got, "batching") {
+			t.Fatalf("SummarizeText = %q, %v", got, err)
+		}
+	}
+	if len(*reqs) != 1 {
+		t.Fatalf("expected one model call, got %d", len(*reqs))
+	}
+	req := (*reqs)[0]
+	if req.Temperature != 0 || req.Seed == nil || req.Model != "test" {
+		t.Errorf("deterministic request should use temperature 0 and a seed, got %+v", req)
+	}
+	if st := s.Stats(); st.Requests != 1 || st.CacheHits != 1 || st.Fallbacks != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// Keyword summaries stay extractive.
+	if got, _ := s.SummarizeText(ctx, longText, LevelKeywords); got != KeywordSummary(longText) || len(*reqs) != 1 {
+		t.Errorf("keywords should not call the model, got %q", got)
+	}
+}
+
+func TestAbstractiveSummarizer_FallsBack(t *testing.T) {
+	srv, _ := chatServer(t, "", http.StatusInternalServerError)
+	s, _ := NewAbstractiveSummarizer(AbstractiveConfig{BaseURL: srv.URL})
+	got, err := s.SummarizeText(context.Background(), longText, LevelSentence)
+	if err != nil || got != SentenceSummary(longText) {
+		t.Errorf("expected extractive fallback, got %q, %v", got, err)
+	}
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	s, _ = NewAbstractiveSummarizer(AbstractiveConfig{BaseURL: slow.URL, Timeout: 50 * time.Millisecond})
+	if got, _ := s.SummarizeText(context.Background(), longText, LevelParagraph); got != ParagraphSummary(longText) {
+		t.Errorf("expected fallback on timeout, got %q", got)
+	}
+	if s.Stats().Fallbacks != 1 {
+		t.Errorf("expected one fallback, got %+v", s.Stats())
+	}
+
+	if _, err := NewAbstractiveSummarizer(AbstractiveConfig{}); err == nil {
+		t.Error("expected error without a base URL")
+	}
+}
+
+func TestAbstractiveSummarizer_Summarize(t *testing.T) {
+	srv, _ := chatServer(t, "Migration lock broke the deploy.", http.StatusOK)
+	s, _ := NewAbstractiveSummarizer(AbstractiveConfig{BaseURL: srv.URL})
+	turns := []Turn{makeTurn("1", "assistant", longText, 3*time.Hour, 0.3)}
+	opts := DefaultOptions()
+	opts.PreserveRecent = 0
+
+	result, _, err := s.Summarize(context.Background(), turns, opts)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if result[0].Level != LevelSentence || result[0].Content != "Migration lock broke the deploy." {
+		t.Errorf("expected model sentence summary, got level %d %q", result[0].Level, result[0].Content)
+	}
+	if result[0].Original != longText {
+		t.Error("original content should be preserved")
+	}
+}
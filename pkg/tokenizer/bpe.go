@@ -0,0 +1,146 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SplitFunc pre-tokenizes text into the pieces BPE merges are applied to.
+type SplitFunc func(text string) []string
+
+// maxCachedPieces bounds the per-counter piece cache. Pieces are short and
+// repeat heavily in natural language and code, so a small cache removes most
+// of the merge work.
+const maxCachedPieces = 1 << 16
+
+// BPE counts tokens with byte-level byte-pair encoding over a tiktoken-style
+// rank table. It only counts; it never materialises token IDs.
+type BPE struct {
+	name  string
+	ranks map[string]int
+	split SplitFunc
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// NewBPE creates a BPE counter from a rank table mapping token bytes to merge
+// rank. A nil split uses SplitCL100K.
+func NewBPE(name string, ranks map[string]int, split SplitFunc) *BPE {
+	if split == nil {
+		split = SplitCL100K
+	}
+	return &BPE{
+		name:  name,
+		ranks: ranks,
+		split: split,
+		cache: make(map[string]int),
+	}
+}
+
+// LoadBPE reads a tiktoken vocab file ("<base64 token> <rank>" per line) and
+// returns a BPE counter for it.
+func LoadBPE(name, path string, split SplitFunc) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open vocab %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("vocab %s line %d: expected \"<token> <rank>\"", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("vocab %s line %d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("vocab %s line %d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read vocab %s: %w", path, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("vocab %s is empty", path)
+	}
+	return NewBPE(name, ranks, split), nil
+}
+
+// Name implements Counter.
+func (b *BPE) Name() string { return b.name }
+
+// Count implements Counter.
+func (b *BPE) Count(text string) int {
+	total := 0
+	for _, piece := range b.split(text) {
+		total += b.pieceTokens(piece)
+	}
+	return total
+}
+
+func (b *BPE) pieceTokens(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+
+	b.mu.Lock()
+	n, ok := b.cache[piece]
+	b.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = b.merge(piece)
+
+	b.mu.Lock()
+	if len(b.cache) >= maxCachedPieces {
+		b.cache = make(map[string]int)
+	}
+	b.cache[piece] = n
+	b.mu.Unlock()
+	return n
+}
+
+// merge applies BPE merges to piece, always merging the adjacent pair with
+// the lowest rank, and returns the resulting number of tokens. Bytes missing
+// from the vocab count as one token each.
+func (b *BPE) merge(piece string) int {
+	// bounds[i] is the byte offset where the i-th current part starts.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := b.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
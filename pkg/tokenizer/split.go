@@ -0,0 +1,173 @@
+package tokenizer
+
+import "unicode"
+
+// splitters maps built-in encoding names to their pre-tokenizers.
+var splitters = map[string]SplitFunc{
+	NameCL100K: SplitCL100K,
+	NameO200K:  SplitO200K,
+}
+
+// SplitCL100K pre-tokenizes text the way the cl100k_base regex does:
+// contractions, letter runs with one optional leading symbol or space,
+// digit groups of up to three, punctuation runs and whitespace. The regex
+// uses a negative lookahead Go's regexp lacks, so it is hand-coded.
+func SplitCL100K(text string) []string {
+	return splitRules{}.split(text)
+}
+
+// SplitO200K pre-tokenizes text in the style of o200k_base: words are split
+// at lower-to-upper case changes, contractions stay attached to their word
+// and punctuation runs absorb trailing newlines and slashes.
+func SplitO200K(text string) []string {
+	return splitRules{caseAware: true}.split(text)
+}
+
+type splitRules struct {
+	// caseAware selects the o200k word rule.
+	caseAware bool
+}
+
+func (r splitRules) split(text string) []string {
+	rs := []rune(text)
+	var pieces []string
+	for i := 0; i < len(rs); {
+		j := r.next(rs, i)
+		pieces = append(pieces, string(rs[i:j]))
+		i = j
+	}
+	return pieces
+}
+
+// next returns the end of the piece starting at i.
+func (r splitRules) next(rs []rune, i int) int {
+	c := rs[i]
+
+	if !r.caseAware && c == '\'' {
+		if n := contraction(rs, i); n > 0 {
+			return i + n
+		}
+	}
+
+	// Word, optionally preceded by one non-letter, non-digit, non-newline.
+	start := i
+	if !r.wordRune(c) && !unicode.IsNumber(c) && c != '\r' && c != '\n' &&
+		i+1 < len(rs) && r.wordRune(rs[i+1]) {
+		start = i + 1
+	}
+	if r.wordRune(rs[start]) {
+		if end := r.word(rs, start); end > start {
+			if r.caseAware {
+				end += contraction(rs, end)
+			}
+			return end
+		}
+	}
+
+	if unicode.IsNumber(c) {
+		j := i
+		for j < len(rs) && j-i < 3 && unicode.IsNumber(rs[j]) {
+			j++
+		}
+		return j
+	}
+
+	// Punctuation run, optionally preceded by a single space.
+	start = -1
+	if c == ' ' && i+1 < len(rs) && isSymbol(rs[i+1]) {
+		start = i + 1
+	} else if isSymbol(c) {
+		start = i
+	}
+	if start >= 0 {
+		j := start
+		for j < len(rs) && isSymbol(rs[j]) {
+			j++
+		}
+		for j < len(rs) && (rs[j] == '\r' || rs[j] == '\n' || (r.caseAware && rs[j] == '/')) {
+			j++
+		}
+		return j
+	}
+
+	// Whitespace run.
+	end := i
+	lastNewline := -1
+	for end < len(rs) && unicode.IsSpace(rs[end]) {
+		if rs[end] == '\r' || rs[end] == '\n' {
+			lastNewline = end
+		}
+		end++
+	}
+	switch {
+	case lastNewline >= 0:
+		return lastNewline + 1
+	case end == len(rs):
+		return end
+	case end-i > 1:
+		// Leave the last space to prefix the following word.
+		return end - 1
+	default:
+		return i + 1
+	}
+}
+
+func (r splitRules) wordRune(c rune) bool {
+	if r.caseAware {
+		return isUpperish(c) || isLowerish(c)
+	}
+	return unicode.IsLetter(c)
+}
+
+// word returns the end of the word starting at i.
+func (r splitRules) word(rs []rune, i int) int {
+	j := i
+	if !r.caseAware {
+		for j < len(rs) && unicode.IsLetter(rs[j]) {
+			j++
+		}
+		return j
+	}
+	// Upper-case prefix followed by lower-case tail, so "HelloWorld" splits
+	// into "Hello" and "World" while "HTTPServer" stays one piece.
+	for j < len(rs) && isUpperish(rs[j]) {
+		j++
+	}
+	k := j
+	for k < len(rs) && isLowerish(rs[k]) {
+		k++
+	}
+	return k
+}
+
+func isUpperish(c rune) bool {
+	return unicode.In(c, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isLowerish(c rune) bool {
+	return unicode.In(c, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isSymbol(c rune) bool {
+	return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// contraction returns the length of an English contraction ('s, 't, 're,
+// 've, 'm, 'll, 'd) starting at i, or 0.
+func contraction(rs []rune, i int) int {
+	if i+1 >= len(rs) || rs[i] != '\'' {
+		return 0
+	}
+	a := unicode.ToLower(rs[i+1])
+	switch a {
+	case 's', 't', 'm', 'd':
+		return 2
+	}
+	if i+2 < len(rs) {
+		b := unicode.ToLower(rs[i+2])
+		if (a == 'r' && b == 'e') || (a == 'v' && b == 'e') || (a == 'l' && b == 'l') {
+			return 3
+		}
+	}
+	return 0
+}
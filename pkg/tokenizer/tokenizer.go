@@ -0,0 +1,167 @@
+// Package tokenizer provides the token counters shared by every package that
+// budgets text: sessions, memory, compression, summarization and the
+// pipeline. The default counter is the historical 4-chars-per-token
+// heuristic; offline BPE counters (cl100k/o200k-style) are loaded from
+// tiktoken-format vocab files on disk.
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Counter counts tokens in text.
+type Counter interface {
+	// Count returns the number of tokens text encodes to.
+	Count(text string) int
+
+	// Name identifies the encoding (e.g. "heuristic", "cl100k_base").
+	Name() string
+}
+
+// Built-in encoding names.
+const (
+	NameHeuristic = "heuristic"
+	NameCL100K    = "cl100k_base"
+	NameO200K     = "o200k_base"
+)
+
+// VocabDirEnv is the environment variable consulted for the vocab
+// directory when none has been set with SetVocabDir.
+const VocabDirEnv = "DISTILL_TOKENIZER_DIR"
+
+// ErrUnknownTokenizer is returned by Get for names that are neither built in
+// nor registered.
+var ErrUnknownTokenizer = errors.New("unknown tokenizer")
+
+// Heuristic approximates token counts as one token per 4 bytes of text,
+// whitespace included: the (len+3)/4 estimate sessions, memory,
+// compression and the cache have always budgeted with. It needs no vocab
+// and is the default when nothing else is configured.
+type Heuristic struct{}
+
+// Count implements Counter.
+func (Heuristic) Count(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Name implements Counter.
+func (Heuristic) Name() string { return NameHeuristic }
+
+var (
+	mu       sync.RWMutex
+	current  Counter = Heuristic{}
+	vocabDir string
+	counters = map[string]Counter{}
+)
+
+// Default returns the process-wide default counter.
+func Default() Counter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the process-wide default counter. A nil counter
+// restores the heuristic.
+func SetDefault(c Counter) {
+	if c == nil {
+		c = Heuristic{}
+	}
+	mu.Lock()
+	current = c
+	mu.Unlock()
+}
+
+// Count counts tokens in text with the default counter.
+func Count(text string) int {
+	return Default().Count(text)
+}
+
+// OrDefault returns c, or the default counter when c is nil. Packages use it
+// to resolve an optional per-request counter.
+func OrDefault(c Counter) Counter {
+	if c == nil {
+		return Default()
+	}
+	return c
+}
+
+// SetVocabDir sets the directory BPE vocab files are loaded from. Files are
+// named <encoding>.tiktoken.
+func SetVocabDir(dir string) {
+	mu.Lock()
+	vocabDir = dir
+	mu.Unlock()
+}
+
+// Register makes a counter available to Get under its Name. Registering a
+// name again replaces the previous counter.
+func Register(c Counter) {
+	mu.Lock()
+	counters[c.Name()] = c
+	mu.Unlock()
+}
+
+// Get returns the counter for name. An empty name returns the default
+// counter. BPE encodings are loaded from the vocab directory on first use
+// and cached.
+func Get(name string) (Counter, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Default(), nil
+	}
+	if name == NameHeuristic {
+		return Heuristic{}, nil
+	}
+
+	mu.RLock()
+	c, ok := counters[name]
+	dir := vocabDir
+	mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q; supported: %s", ErrUnknownTokenizer, name, strings.Join(Supported(), ", "))
+	}
+	if dir == "" {
+		dir = os.Getenv(VocabDirEnv)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("tokenizer %q needs a vocab file; set tokenizer.vocab_dir or %s", name, VocabDirEnv)
+	}
+
+	bpe, err := LoadBPE(name, filepath.Join(dir, name+".tiktoken"), split)
+	if err != nil {
+		return nil, err
+	}
+	Register(bpe)
+	return bpe, nil
+}
+
+// Supported returns the names Get accepts.
+func Supported() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	seen := map[string]bool{NameHeuristic: true}
+	for name := range splitters {
+		seen[name] = true
+	}
+	for name := range counters {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,147 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeuristic(t *testing.T) {
+	h := Heuristic{}
+	if h.Count("") != 0 {
+		t.Error("empty text should be 0 tokens")
+	}
+	// Whitespace counts, as in the historical (len+3)/4 estimate.
+	if got := h.Count("abcd efgh"); got != 3 {
+		t.Errorf("expected 3, got %d", got)
+	}
+}
+
+func TestSplitCL100K(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm here", []string{"I", "'m", " here"}},
+		{"x = 12345;", []string{"x", " =", " ", "123", "45", ";"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"end\n\n  next", []string{"end", "\n\n", " ", " next"}},
+		{"trailing  ", []string{"trailing", "  "}},
+	}
+	for _, tt := range tests {
+		if got := SplitCL100K(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCL100K(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitO200K(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"HelloWorld", []string{"Hello", "World"}},
+		{"HTTPServer", []string{"HTTPServer"}},
+		{"I'm", []string{"I'm"}},
+		{"a//\nb", []string{"a", "//\n", "b"}},
+	}
+	for _, tt := range tests {
+		if got := SplitO200K(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitO200K(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// testRanks builds a tiny vocab: all single bytes plus a few merges.
+func testRanks() map[string]int {
+	ranks := make(map[string]int)
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	for i, tok := range []string{"he", "ll", "hell", "hello", " w", "or", " wor", " world"} {
+		ranks[tok] = 256 + i
+	}
+	return ranks
+}
+
+func TestBPECount(t *testing.T) {
+	b := NewBPE("test", testRanks(), nil)
+	if got := b.Count("hello world"); got != 2 {
+		t.Errorf("expected 2 tokens, got %d", got)
+	}
+	// "help" only merges "he": "he" + "l" + "p".
+	if got := b.Count("help"); got != 3 {
+		t.Errorf("expected 3 tokens, got %d", got)
+	}
+	// Cached result must match.
+	if got := b.Count("help"); got != 3 {
+		t.Errorf("expected cached 3 tokens, got %d", got)
+	}
+}
+
+func writeVocab(t *testing.T, dir, name string, ranks map[string]int) {
+	t.Helper()
+	var sb strings.Builder
+	for tok, rank := range ranks {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".tiktoken"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetLoadsVocabFromDir(t *testing.T) {
+	dir := t.TempDir()
+	writeVocab(t, dir, NameO200K, testRanks())
+	SetVocabDir(dir)
+	t.Cleanup(func() {
+		SetVocabDir("")
+		mu.Lock()
+		delete(counters, NameO200K)
+		mu.Unlock()
+	})
+
+	c, err := Get("O200K_BASE")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if c.Name() != NameO200K {
+		t.Errorf("unexpected name %q", c.Name())
+	}
+	if got := c.Count("hello world"); got != 2 {
+		t.Errorf("expected 2 tokens, got %d", got)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	if _, err := Get("nope"); !errors.Is(err, ErrUnknownTokenizer) {
+		t.Errorf("expected ErrUnknownTokenizer, got %v", err)
+	}
+	SetVocabDir(t.TempDir())
+	t.Cleanup(func() { SetVocabDir("") })
+	if _, err := Get(NameCL100K); err == nil {
+		t.Error("expected error for missing vocab file")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	b := NewBPE("test", testRanks(), nil)
+	SetDefault(b)
+	t.Cleanup(func() { SetDefault(nil) })
+	if Count("hello world") != 2 {
+		t.Error("package Count should use the default counter")
+	}
+	if OrDefault(nil) != Counter(b) {
+		t.Error("OrDefault(nil) should return the default counter")
+	}
+	c, _ := Get("")
+	if c != Counter(b) {
+		t.Error("Get(\"\") should return the default counter")
+	}
+}
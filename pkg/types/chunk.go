@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 // Chunk represents a retrieved document chunk with its embedding and relevance score.
 type Chunk struct {
@@ -60,6 +63,26 @@ func (c *Chunk) Clone() *Chunk {
 	}
 }
 
+// MetadataTime parses a timestamp stored in chunk metadata: an RFC 3339
+// string, a time.Time, or Unix seconds.
+func MetadataTime(v interface{}) (time.Time, bool) {
+	switch ts := v.(type) {
+	case time.Time:
+		return ts, !ts.IsZero()
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		return t, err == nil
+	case float64:
+		sec, frac := math.Modf(ts)
+		return time.Unix(int64(sec), int64(frac*1e9)), ts > 0
+	case int64:
+		return time.Unix(ts, 0), ts > 0
+	case int:
+		return time.Unix(int64(ts), 0), ts > 0
+	}
+	return time.Time{}, false
+}
+
 // RetrievalRequest represents a query to the vector database.
 type RetrievalRequest struct {
 	// Query is the text query (will be embedded if EmbeddingProvider is set)
@@ -68,6 +91,10 @@ type RetrievalRequest struct {
 	// QueryEmbedding is the pre-computed query vector (optional if Query is set)
 	QueryEmbedding []float32
 
+	// Queries are extra variants of Query (rephrasings or sub-questions).
+	// Each is retrieved separately and the results are fused.
+	Queries []string
+
 	// TopK is the number of results to retrieve
 	TopK int
 
@@ -133,6 +160,11 @@ type ClusterResult struct {
 	// ClusterCount is the number of clusters formed
 	ClusterCount int
 
+	// Threshold is the distance threshold clustering used, and
+	// ThresholdAuto reports whether it was picked from the data.
+	Threshold     float64
+	ThresholdAuto bool
+
 	// Latency is the clustering execution time
 	Latency time.Duration
 }
@@ -162,6 +194,11 @@ type BrokerStats struct {
 	// Clustered is the number of clusters formed
 	Clustered int
 
+	// Threshold is the clustering distance threshold used, and
+	// ThresholdAuto reports whether it was picked from the data
+	Threshold     float64
+	ThresholdAuto bool
+
 	// Returned is the number of chunks in final output
 	Returned int
 
@@ -171,6 +208,25 @@ type BrokerStats struct {
 	// ClusteringLatency is time spent clustering
 	ClusteringLatency time.Duration
 
+	// Reranked is the number of chunks rescored by the reranker
+	Reranked int
+
+	// RerankLatency is time spent reranking
+	RerankLatency time.Duration
+
+	// RerankFallback is the error that made the reranker score with its
+	// fallback (e.g. BM25 after a failed API call); empty otherwise
+	RerankFallback string
+
+	// Expanded is the number of surrounding chunks added by expansion
+	Expanded int
+
+	// ExpandLatency is time spent fetching and merging surrounding chunks
+	ExpandLatency time.Duration
+
+	// Queries is the number of query variants retrieved (1 for a single query)
+	Queries int
+
 	// TotalLatency is end-to-end processing time
 	TotalLatency time.Duration
 }